@@ -3,20 +3,50 @@
 Analyze and compare GC benchmark results
 """
 
+import argparse
+import csv
+import hashlib
+import math
+import operator
 import re
 import sys
 from pathlib import Path
 
+EXPECTATION_OPS = {
+    '>=': operator.ge,
+    '>': operator.gt,
+    '<=': operator.le,
+    '<': operator.lt,
+}
+
+DURATION_UNITS = [('ns', 1e-6), ('µs', 1e-3), ('us', 1e-3), ('ms', 1.0), ('s', 1000.0)]
+SIZE_UNITS = [('GB', 1024 ** 3), ('MB', 1024 ** 2), ('KB', 1024), ('B', 1)]
+
 def parse_duration(duration_str):
     """Convert duration string to milliseconds"""
-    if 's' in duration_str:
-        return float(duration_str.replace('s', '')) * 1000
-    elif 'ms' in duration_str:
-        return float(duration_str.replace('ms', ''))
-    elif 'µs' in duration_str or 'us' in duration_str:
-        return float(duration_str.replace('µs', '').replace('us', '')) / 1000
+    duration_str = duration_str.strip()
+    for suffix, scale in DURATION_UNITS:
+        if duration_str.endswith(suffix):
+            return float(duration_str[:-len(suffix)]) * scale
     return 0
 
+def parse_size(size_str):
+    """Convert size string (e.g. "12.5 MB") to bytes"""
+    size_str = size_str.strip()
+    for suffix, scale in SIZE_UNITS:
+        if size_str.endswith(suffix):
+            return float(size_str[:-len(suffix)]) * scale
+    return 0
+
+def to_number(value):
+    """Convert a metric value with optional unit to a plain number"""
+    value = value.strip().rstrip('%')
+    if value.endswith('B'):
+        return parse_size(value)
+    if value[-1:].isalpha():
+        return parse_duration(value)
+    return float(value)
+
 def extract_metrics(filename):
     """Extract key metrics from benchmark output"""
     try:
@@ -30,17 +60,25 @@ def extract_metrics(filename):
     
     # Extract metrics using regex
     patterns = {
-        'duration': r'Total Duration:\s*([\d.]+[µms]+)',
+        'duration': r'Total Duration:\s*([\d.]+(?:ns|µs|us|ms|s))',
         'ops_per_sec': r'Operations/sec:\s*([\d.]+)',
-        'total_alloc': r'Total Allocated:\s*([\d.]+)\s*MB',
-        'heap_alloc': r'Heap Allocated:\s*([\d.]+)\s*MB',
+        'total_alloc': r'Total Allocated:\s*([\d.]+\s*[KMG]?B)',
+        'heap_alloc': r'Heap Allocated:\s*([\d.]+\s*[KMG]?B)',
         'heap_objects': r'Heap Objects:\s*(\d+)',
         'num_gc': r'Number of GCs:\s*(\d+)',
-        'total_pause': r'Total GC Pause:\s*([\d.]+[µms]+)',
-        'avg_pause': r'Average GC Pause:\s*([\d.]+[µms]+)',
+        'total_pause': r'Total GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'avg_pause': r'Average GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'p50_pause': r'p50 GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'p90_pause': r'p90 GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'p99_pause': r'p99 GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'p999_pause': r'p99\.9 GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'max_pause': r'Max GC Pause:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'tail_excess_gc': r'Tail Excess from GC:\s*([\d.]+)%',
         'gc_pause_overhead': r'GC Pause Overhead:\s*([\d.]+)%',
         'gc_cpu_fraction': r'GC CPU Fraction:\s*([\d.]+)%',
-        'time_per_iter': r'Time per iteration:\s*([\d.]+[µms]+)',
+        'time_per_iter': r'Time per iteration:\s*([\d.]+(?:ns|µs|us|ms|s))',
+        'recommended_gogc': r'Recommended GOGC:\s*(\d+)',
+        'sustainable_rps': r'Sustainable Requests/sec:\s*([\d.]+)',
     }
     
     for key, pattern in patterns.items():
@@ -50,17 +88,298 @@ def extract_metrics(filename):
     
     return metrics
 
+PROVENANCE_COMPONENTS = ['Config', 'Build', 'Environment']
+
+def provenance_hash(text):
+    """Truncated SHA-256, matching provenanceHash in provenance.go"""
+    return hashlib.sha256(text.encode()).hexdigest()[:16]
+
+def extract_provenance(filename):
+    """Extract the Provenance section of a result file, or None if absent"""
+    try:
+        with open(filename, 'r') as f:
+            lines = f.read().splitlines()
+    except FileNotFoundError:
+        return None
+
+    try:
+        start = lines.index('=== Provenance ===')
+    except ValueError:
+        return None
+
+    provenance = {}
+    for line in lines[start + 1:]:
+        if ': ' not in line:
+            break
+        name, value = line.split(': ', 1)
+        provenance[name] = value
+    return provenance
+
+def check_provenance(name, provenance):
+    """Recompute a result file's provenance hashes, returning a list of problems"""
+    problems = []
+    for component in PROVENANCE_COMPONENTS:
+        if component not in provenance:
+            problems.append(f"{name}: {component} missing from provenance")
+            continue
+        if provenance_hash(provenance[component]) != provenance.get(f"{component} Hash"):
+            problems.append(f"{name}: {component} does not match its hash")
+    if not problems:
+        combined = '\n'.join(provenance[c] for c in PROVENANCE_COMPONENTS)
+        if provenance_hash(combined) != provenance.get('Provenance Hash'):
+            problems.append(f"{name}: Provenance Hash does not match")
+    return problems
+
+def without_goexperiment(build):
+    """Drop the settings expected to differ between collectors: GOEXPERIMENT
+    and the hashes of workload plugins, which are built once per collector"""
+    return ' '.join(part for part in build.split(' ')
+                    if not part.startswith(('GOEXPERIMENT=', 'plugin.')))
+
+def build_differences(standard, greentea):
+    """The build settings that differ between the runs, as name=value pairs"""
+    std_parts, gt_parts = set(standard.split(' ')), set(greentea.split(' '))
+    return sorted(std_parts - gt_parts), sorted(gt_parts - std_parts)
+
+def compare_provenance(standard, greentea, any_build=False):
+    """Verify both result files' provenance and that they are comparable.
+    With any_build, the builds may differ in anything; the differences are
+    listed rather than failed.
+
+    Returns the number of problems found.
+    """
+    if standard is None or greentea is None:
+        print("⚠️  Provenance not recorded in both result files; the comparison cannot be audited")
+        return 0
+
+    problems = check_provenance('standard', standard) + check_provenance('greentea', greentea)
+    if not problems:
+        if standard['Config'] != greentea['Config']:
+            problems.append("configurations differ: "
+                            f"standard [{standard['Config']}] vs greentea [{greentea['Config']}]")
+        if standard['Environment'] != greentea['Environment']:
+            problems.append("environments differ: "
+                            f"standard [{standard['Environment']}] vs greentea [{greentea['Environment']}]")
+        if any_build:
+            only_std, only_gt = build_differences(standard['Build'], greentea['Build'])
+            if only_std or only_gt:
+                print(f"[INFO] builds differ: standard [{' '.join(only_std)}] vs greentea [{' '.join(only_gt)}]")
+        elif without_goexperiment(standard['Build']) != without_goexperiment(greentea['Build']):
+            problems.append("builds differ by more than GOEXPERIMENT")
+
+    for problem in problems:
+        print(f"[FAIL] {problem}")
+    if not problems:
+        print(f"[PASS] Provenance verified (config {standard['Config Hash']}, "
+              f"environment {standard['Environment Hash']})")
+    return len(problems)
+
 def calculate_improvement(standard, greentea):
     """Calculate percentage improvement"""
     try:
-        std = float(standard.replace('%', '').replace('ms', '').replace('s', '').replace('MB', '').replace('µs', ''))
-        gt = float(greentea.replace('%', '').replace('ms', '').replace('s', '').replace('MB', '').replace('µs', ''))
+        std = to_number(standard)
+        gt = to_number(greentea)
         improvement = ((std - gt) / std) * 100
         return improvement
     except:
         return None
 
+def load_expectations(filename):
+    """Parse an expectations file.
+
+    Each non-comment line has the form:
+
+        <metric> <op> <percent>%  [description]
+
+    where <metric> is one of the keys extracted by extract_metrics, <op> is
+    one of >=, >, <=, < and <percent> is the change of Green Tea relative to
+    the standard GC, signed so that positive means "better" (lower for
+    durations, memory and GC metrics, higher for Operations/sec).
+    """
+    expectations = []
+    try:
+        with open(filename, 'r') as f:
+            lines = f.readlines()
+    except FileNotFoundError:
+        print(f"Error: {filename} not found")
+        return None
+
+    for lineno, line in enumerate(lines, 1):
+        line = line.split('#', 1)[0].strip()
+        if not line:
+            continue
+        match = re.match(r'(\w+)\s*(>=|<=|>|<)\s*([+-]?[\d.]+)%?\s*(.*)$', line)
+        if not match:
+            print(f"Error: {filename}:{lineno}: cannot parse expectation: {line}")
+            return None
+        metric, op, threshold, description = match.groups()
+        expectations.append({
+            'metric': metric,
+            'op': op,
+            'threshold': float(threshold),
+            'description': description or f"{metric} {op} {threshold}%",
+        })
+
+    return expectations
+
+def check_expectations(expectations, changes):
+    """Evaluate expectations against per-metric changes, returning the number of failures"""
+    failures = 0
+
+    for exp in expectations:
+        actual = changes.get(exp['metric'])
+        if actual is None:
+            status = "FAIL"
+            detail = "metric not available"
+        elif EXPECTATION_OPS[exp['op']](actual, exp['threshold']):
+            status = "PASS"
+            detail = f"actual {actual:+.2f}%"
+        else:
+            status = "FAIL"
+            detail = f"actual {actual:+.2f}%"
+
+        if status == "FAIL":
+            failures += 1
+
+        print(f"[{status}] {exp['description']:<60} "
+              f"({exp['metric']} {exp['op']} {exp['threshold']:g}%, {detail})")
+
+    return failures
+
+def load_pauses(filename):
+    """Load a -pauses-out histogram as (lower_ns, upper_ns, count) buckets"""
+    try:
+        with open(filename, newline='') as f:
+            rows = list(csv.DictReader(f))
+    except FileNotFoundError:
+        return None
+    buckets = []
+    for row in rows:
+        lower = float(row['lower_ns'])
+        # The last bucket is unbounded; plot it at its lower bound
+        upper = float(row['upper_ns']) if row['upper_ns'] else lower
+        buckets.append((lower, upper, int(row['count'])))
+    return buckets
+
+def pause_cdf(buckets):
+    """Cumulative fraction of pauses at each bucket's upper bound"""
+    total = sum(count for _, _, count in buckets)
+    points = []
+    seen = 0
+    for _, upper, count in buckets:
+        seen += count
+        points.append((upper, seen / total))
+    return points
+
+def cdf_at(points, x):
+    """Evaluate a step CDF at x"""
+    value = 0.0
+    for bound, fraction in points:
+        if bound > x:
+            break
+        value = fraction
+    return value
+
+def max_cdf_distance(a, b):
+    """Maximum vertical distance between two CDFs (the Kolmogorov-Smirnov
+    statistic, to the histograms' bucket precision) and where it occurs"""
+    distance, at = 0.0, 0.0
+    for x in sorted({bound for bound, _ in a} | {bound for bound, _ in b}):
+        d = abs(cdf_at(a, x) - cdf_at(b, x))
+        if d > distance:
+            distance, at = d, x
+    return distance, at
+
+def format_ns(ns):
+    """Format nanoseconds like the benchmark does"""
+    for suffix, scale in (('s', 1e9), ('ms', 1e6), ('µs', 1e3)):
+        if ns >= scale:
+            return f"{ns / scale:.2f}{suffix}"
+    return f"{ns:.0f}ns"
+
+CDF_SERIES = [('Standard GC', '#c0392b'), ('Green Tea GC', '#1f4e99')]
+
+def write_cdf_chart(filename, cdfs, distance, at):
+    """Write both pause CDFs on one log-scale SVG chart in an HTML page"""
+    width, height, left, top, plot_w, plot_h = 720, 420, 60, 20, 620, 340
+    bounds = [bound for points in cdfs for bound, _ in points if bound > 0]
+    lo = 10 ** math.floor(math.log10(min(bounds)))
+    hi = 10 ** math.ceil(math.log10(max(bounds)))
+    if hi <= lo:
+        hi = lo * 10
+
+    def x(ns):
+        return left + plot_w * (math.log10(max(ns, lo)) - math.log10(lo)) / (math.log10(hi) - math.log10(lo))
+
+    def y(fraction):
+        return top + plot_h * (1 - fraction)
+
+    parts = [f'<rect x="{left}" y="{top}" width="{plot_w}" height="{plot_h}" fill="none" stroke="#999"/>']
+    decade = lo
+    while decade <= hi:
+        parts.append(f'<line x1="{x(decade):.1f}" y1="{top}" x2="{x(decade):.1f}" y2="{top + plot_h}" stroke="#eee"/>')
+        parts.append(f'<text x="{x(decade):.1f}" y="{top + plot_h + 16}" text-anchor="middle">{format_ns(decade)}</text>')
+        decade *= 10
+    for fraction in (0, 0.25, 0.5, 0.75, 1):
+        parts.append(f'<text x="{left - 6}" y="{y(fraction) + 4:.1f}" text-anchor="end">{fraction:g}</text>')
+    for (label, color), points in zip(CDF_SERIES, cdfs):
+        path = [f"M{x(lo):.1f},{y(0):.1f}"]
+        for bound, fraction in points:
+            path.append(f"H{x(bound):.1f}V{y(fraction):.1f}")
+        path.append(f"H{x(hi):.1f}")
+        parts.append(f'<path d="{"".join(path)}" fill="none" stroke="{color}" stroke-width="2"><title>{label}</title></path>')
+    a_frac, b_frac = cdf_at(cdfs[0], at), cdf_at(cdfs[1], at)
+    parts.append(f'<line x1="{x(at):.1f}" y1="{y(a_frac):.1f}" x2="{x(at):.1f}" y2="{y(b_frac):.1f}" '
+                 f'stroke="#222" stroke-dasharray="4 3"/>')
+    for i, (label, color) in enumerate(CDF_SERIES):
+        parts.append(f'<text x="{left + 10}" y="{top + 18 + 16 * i}" fill="{color}">{label}</text>')
+
+    with open(filename, 'w') as f:
+        f.write(f"""<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>GC Pause CDF</title>
+<style>body {{ font-family: sans-serif; margin: 2em; }} svg text {{ font-size: 11px; }}</style></head>
+<body>
+<h1>GC Pause Duration CDF</h1>
+<p>Maximum vertical distance: {distance:.3f} at {format_ns(at)} (dashed line).</p>
+<svg width="{width}" height="{height}" xmlns="http://www.w3.org/2000/svg">
+{chr(10).join(parts)}
+</svg>
+</body>
+</html>
+""")
+
+def compare_pauses(standard_file, greentea_file, chart_file):
+    """Compare the runs' pause distributions, plotting both CDFs"""
+    standard = load_pauses(standard_file)
+    greentea = load_pauses(greentea_file)
+    if not standard or not greentea:
+        print("Pause distributions not available (run with -pauses-out, as run_benchmark.sh does)")
+        return
+    cdfs = [pause_cdf(standard), pause_cdf(greentea)]
+    distance, at = max_cdf_distance(*cdfs)
+    print(f"Pauses: {sum(c for _, _, c in standard)} standard, {sum(c for _, _, c in greentea)} Green Tea")
+    print(f"Maximum CDF distance: {distance:.3f} at {format_ns(at)} "
+          f"({cdf_at(cdfs[0], at):.1%} vs {cdf_at(cdfs[1], at):.1%} of pauses at or below)")
+    write_cdf_chart(chart_file, cdfs, distance, at)
+    print(f"CDF chart written to {chart_file}")
+
 def main():
+    parser = argparse.ArgumentParser(description=__doc__.strip())
+    parser.add_argument('--expect', metavar='FILE',
+                        help='check results against an expectations file')
+    parser.add_argument('--any-build', action='store_true',
+                        help='allow the builds to differ in more than GOEXPERIMENT, e.g. Go version')
+    parser.add_argument('--cdf-chart', metavar='FILE', default='benchmark_results/pause_cdf.html',
+                        help='where to write the pause CDF comparison chart (default: %(default)s)')
+    args = parser.parse_args()
+
+    expectations = None
+    if args.expect:
+        expectations = load_expectations(args.expect)
+        if expectations is None:
+            sys.exit(1)
+
     results_dir = Path("benchmark_results")
     
     if not results_dir.exists():
@@ -96,12 +415,23 @@ def main():
         ('Number of GCs', 'num_gc', 'lower'),
         ('Total GC Pause', 'total_pause', 'lower'),
         ('Average GC Pause', 'avg_pause', 'lower'),
+        ('p50 GC Pause', 'p50_pause', 'lower'),
+        ('p90 GC Pause', 'p90_pause', 'lower'),
+        ('p99 GC Pause', 'p99_pause', 'lower'),
+        ('p99.9 GC Pause', 'p999_pause', 'lower'),
+        ('Max GC Pause', 'max_pause', 'lower'),
+        ('Tail Excess from GC', 'tail_excess_gc', 'lower'),
         ('GC Pause Overhead', 'gc_pause_overhead', 'lower'),
         ('GC CPU Fraction', 'gc_cpu_fraction', 'lower'),
         ('Time per Iteration', 'time_per_iter', 'lower'),
+        # Autotune mode: a lower GOGC meeting the same target uses less memory
+        ('Recommended GOGC', 'recommended_gogc', 'lower'),
+        # Capacity mode: the throughput a service can run at within its SLO
+        ('Sustainable Requests/sec', 'sustainable_rps', 'higher'),
     ]
     
     improvements = []
+    changes = {}
     
     for display_name, key, direction in metrics_to_compare:
         if key in standard_metrics and key in greentea_metrics:
@@ -115,12 +445,15 @@ def main():
                     change_str = f"{improvement:+.2f}%"
                     if improvement > 0:
                         change_str += " ✓"
+                    changes[key] = improvement
                 elif direction == 'higher':
                     change_str = f"{-improvement:+.2f}%"
                     if improvement < 0:
                         change_str += " ✓"
+                    changes[key] = -improvement
                 else:
                     change_str = f"{improvement:+.2f}%"
+                    changes[key] = improvement
                 
                 improvements.append((display_name, improvement, direction))
             else:
@@ -135,6 +468,7 @@ def main():
     print()
     
     # Calculate overall GC improvement
+    gc_improvement = None
     if 'gc_cpu_fraction' in standard_metrics and 'gc_cpu_fraction' in greentea_metrics:
         gc_improvement = calculate_improvement(
             standard_metrics['gc_cpu_fraction'],
@@ -194,5 +528,42 @@ def main():
     
     print()
 
+    print("=" * 80)
+    print("PAUSE DISTRIBUTION")
+    print("=" * 80)
+    print()
+
+    compare_pauses(results_dir / "standard_pauses.csv", results_dir / "greentea_pauses.csv",
+                   args.cdf_chart)
+    print()
+
+    print("=" * 80)
+    print("PROVENANCE")
+    print("=" * 80)
+    print()
+
+    provenance_problems = compare_provenance(extract_provenance(standard_file),
+                                             extract_provenance(greentea_file),
+                                             args.any_build)
+    print()
+
+    if expectations is not None:
+        print("=" * 80)
+        print("EXPECTATIONS")
+        print("=" * 80)
+        print()
+
+        failures = check_expectations(expectations, changes)
+
+        print()
+        print(f"{len(expectations) - failures}/{len(expectations)} expectations met")
+        print()
+
+        if failures:
+            sys.exit(1)
+
+    if provenance_problems:
+        sys.exit(1)
+
 if __name__ == "__main__":
     main()
\ No newline at end of file
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	assertP50Pause = flag.Duration("assert-p50-pause", 0,
+		"fail the run (exit status 1) if the median GC pause exceeds this (0 disables)")
+	assertP99Pause = flag.Duration("assert-p99-pause", 0,
+		"fail the run (exit status 1) if the 99th percentile GC pause exceeds this (0 disables)")
+	assertMaxPause = flag.Duration("assert-max-pause", 0,
+		"fail the run (exit status 1) if the longest GC pause exceeds this (0 disables)")
+	assertWindowPause = flag.Duration("assert-window-pause", 0,
+		"fail the run (exit status 1) if the GC pause in any -pause-window window exceeds this (0 disables)")
+
+	assertMaxRSS  byteSize
+	assertMaxHeap byteSize
+)
+
+func init() {
+	flag.Var(&assertMaxRSS, "assert-max-rss",
+		"fail the run (exit status 1) if peak RSS exceeds this size, e.g. 4GB (Linux only; 0 disables)")
+	flag.Var(&assertMaxHeap, "assert-max-heap",
+		"fail the run (exit status 1) if the peak heap goal exceeds this size, e.g. 512MB (0 disables)")
+}
+
+// byteSize is a flag holding a size such as "512MB" or "4 GB"; a bare number
+// is in bytes
+type byteSize uint64
+
+func (b *byteSize) String() string {
+	if *b == 0 {
+		return "0"
+	}
+	return formatBytes(uint64(*b))
+}
+
+func (b *byteSize) Set(s string) error {
+	v, kind, ok := parseMetricValue(s)
+	if !ok || v < 0 || (kind != kindBytes && kind != kindNumber) {
+		return fmt.Errorf("invalid size %q (want e.g. 512MB or 4GB)", s)
+	}
+	*b = byteSize(v)
+	return nil
+}
+
+// validateAssertions checks that the assertions requested can be evaluated
+// on this platform
+func validateAssertions() error {
+	if *assertWindowPause > 0 && *pauseWindow <= 0 {
+		return fmt.Errorf("-assert-window-pause needs -pause-window")
+	}
+	if _, ok := peakRSS(); assertMaxRSS > 0 && !ok {
+		return fmt.Errorf("-assert-max-rss needs peak RSS, which is only available on Linux and WebAssembly")
+	}
+	return nil
+}
+
+// assertionResult is the outcome of one SLO assertion
+type assertionResult struct {
+	name   string
+	actual string
+	limit  string
+	passed bool
+}
+
+// assertionResults collects assertion outcomes for printAssertions
+var assertionResults []assertionResult
+
+// checkPauseAssertions evaluates the pause SLO flags against the pause
+// percentiles of the measured phase
+func checkPauseAssertions(pauses pausePercentiles) {
+	for _, a := range []struct {
+		name   string
+		limit  time.Duration
+		actual time.Duration
+	}{
+		{"p50 GC pause", *assertP50Pause, pauses.p50},
+		{"p99 GC pause", *assertP99Pause, pauses.p99},
+		{"max GC pause", *assertMaxPause, pauses.max},
+	} {
+		if a.limit <= 0 {
+			continue
+		}
+		assertionResults = append(assertionResults, assertionResult{
+			name:   a.name,
+			actual: formatDuration(a.actual),
+			limit:  formatDuration(a.limit),
+			passed: a.actual <= a.limit,
+		})
+	}
+}
+
+// checkPauseBudgetAssertion evaluates -assert-window-pause against the
+// worst window of the measured phase
+func checkPauseBudgetAssertion(b *pauseBudget) {
+	if *assertWindowPause <= 0 || b == nil {
+		return
+	}
+	worst := b.Max()
+	assertionResults = append(assertionResults, assertionResult{
+		name:   "GC pause per " + formatDuration(b.window),
+		actual: formatDuration(worst),
+		limit:  formatDuration(*assertWindowPause),
+		passed: worst <= *assertWindowPause,
+	})
+}
+
+// checkMemoryAssertions evaluates the peak memory SLO flags against the
+// whole run
+func checkMemoryAssertions(peakHeap uint64) {
+	if assertMaxRSS > 0 {
+		rss, _ := peakRSS()
+		name := "peak RSS"
+		if onWasm() {
+			name = "peak linear memory"
+		}
+		assertionResults = append(assertionResults, assertionResult{
+			name:   name,
+			actual: formatBytes(rss),
+			limit:  formatBytes(uint64(assertMaxRSS)),
+			passed: rss <= uint64(assertMaxRSS),
+		})
+	}
+	if assertMaxHeap > 0 {
+		assertionResults = append(assertionResults, assertionResult{
+			name:   "peak heap goal",
+			actual: formatBytes(peakHeap),
+			limit:  formatBytes(uint64(assertMaxHeap)),
+			passed: peakHeap <= uint64(assertMaxHeap),
+		})
+	}
+}
+
+// printAssertions prints every assertion's outcome and reports whether any
+// failed
+func printAssertions() (failed bool) {
+	if len(assertionResults) == 0 {
+		return false
+	}
+
+	fmt.Println()
+	printSection("Assertions")
+	for _, a := range assertionResults {
+		status := "PASS"
+		if !a.passed {
+			status = "FAIL"
+			failed = true
+		}
+		fmt.Printf("[%s] %s: %s (limit %s)\n", status, a.name, a.actual, a.limit)
+	}
+	return failed
+}
+
+// exitAssertionsFailed runs cleanup, which os.Exit would otherwise skip, and
+// exits with status 1
+func exitAssertionsFailed(cleanup func()) {
+	cleanup()
+	fmt.Fprintln(os.Stderr, "SLO assertions failed")
+	os.Exit(1)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+var (
+	tuneGCCPU = flag.Float64("tune-gc-cpu", 0,
+		"autotune mode: target share of CPU time spent in GC, in percent")
+	tuneP99Pause = flag.Duration("tune-p99-pause", 0,
+		"autotune mode: target 99th percentile GC pause")
+	tuneEpoch = flag.Int("tune-epoch", 200,
+		"autotune mode: iterations measured between GOGC adjustments")
+	tuneMaxEpochs = flag.Int("tune-max-epochs", 40,
+		"autotune mode: epochs to run before giving up on convergence")
+)
+
+// Autotune controller bounds and gains
+const (
+	tuneMinGOGC = 10
+	tuneMaxGOGC = 10000
+	// tuneRaise and tuneLower scale GOGC when the metric is over target or
+	// comfortably under it; lowering is gentler so the search settles
+	// rather than oscillating
+	tuneRaise = 1.5
+	tuneLower = 0.8
+	// tuneSlack is how far under target the metric must be before GOGC is
+	// lowered to give memory back
+	tuneSlack = 0.7
+	// tuneStableEpochs is how many epochs GOGC must hold for the run to
+	// count as converged
+	tuneStableEpochs = 3
+)
+
+// validateAutotune checks that exactly one autotune target is set
+func validateAutotune() error {
+	if (*tuneGCCPU > 0) == (*tuneP99Pause > 0) {
+		return fmt.Errorf("autotune mode needs exactly one of -tune-gc-cpu or -tune-p99-pause")
+	}
+	if *tuneEpoch < 1 || *tuneMaxEpochs < 1 {
+		return fmt.Errorf("-tune-epoch and -tune-max-epochs must be positive")
+	}
+	if err := requireFeature("autotune mode", "/cpu/classes/gc/total:cpu-seconds", "/cpu/classes/total:cpu-seconds"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// tuneEpochResult is what one epoch measured at one GOGC setting
+type tuneEpochResult struct {
+	gogc     int
+	gcCPU    float64 // Percent
+	p99Pause time.Duration
+	numGC    uint64
+	heapGoal uint64
+}
+
+// runAutotune adjusts GOGC between epochs of the workload until the target
+// GC CPU share or p99 pause is met with as little memory as possible, then
+// reports the GOGC it settled on. GOGC is raised while the metric is over
+// target and lowered while it is comfortably under.
+func runAutotune(ws []Workload) {
+	samples := []metrics.Sample{
+		{Name: "/cpu/classes/gc/total:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/gc/heap/goal:bytes"},
+	}
+
+	gogc := debug.SetGCPercent(-1)
+	if gogc < 0 {
+		gogc = 100
+	}
+	debug.SetGCPercent(gogc)
+
+	target := fmt.Sprintf("GC CPU <= %.2f%%", *tuneGCCPU)
+	if *tuneP99Pause > 0 {
+		target = fmt.Sprintf("p99 GC pause <= %s", formatDuration(*tuneP99Pause))
+	}
+	fmt.Printf("Tuning GOGC for %s, %d iterations per epoch...\n", target, *tuneEpoch)
+	fmt.Println()
+	fmt.Printf("%5s | %6s | %8s | %10s | %4s | %s\n", "Epoch", "GOGC", "GC CPU", "p99 Pause", "GCs", "Heap Goal")
+
+	var history []tuneEpochResult
+	stable := 0
+	for epoch := 1; epoch <= *tuneMaxEpochs; epoch++ {
+		metrics.Read(samples)
+		gcCPUBefore, cpuBefore := samples[0].Value.Float64(), samples[1].Value.Float64()
+		cyclesBefore := samples[2].Value.Uint64()
+		pausesBefore := readPauses()
+
+		runIterations(ws, *tuneEpoch)
+
+		metrics.Read(samples)
+		r := tuneEpochResult{
+			gogc:     gogc,
+			p99Pause: readPauses().Since(pausesBefore).Percentile(99),
+			numGC:    samples[2].Value.Uint64() - cyclesBefore,
+			heapGoal: samples[3].Value.Uint64(),
+		}
+		if cpu := samples[1].Value.Float64() - cpuBefore; cpu > 0 {
+			r.gcCPU = (samples[0].Value.Float64() - gcCPUBefore) / cpu * 100
+		}
+		history = append(history, r)
+		fmt.Printf("%5d | %6d | %7.2f%% | %10s | %4d | %s\n",
+			epoch, r.gogc, r.gcCPU, formatDuration(r.p99Pause), r.numGC, formatBytes(r.heapGoal))
+
+		next := nextGOGC(gogc, r)
+		if next == gogc {
+			stable++
+			if stable >= tuneStableEpochs {
+				break
+			}
+			continue
+		}
+		stable = 0
+		gogc = next
+		debug.SetGCPercent(gogc)
+	}
+
+	last := history[len(history)-1]
+	fmt.Println()
+	printSection("Autotune Results")
+	printMetric("Target", "%s", target)
+	printMetric("Converged", "%t", stable >= tuneStableEpochs)
+	printMetric("Recommended GOGC", "%d", last.gogc)
+	printMetric("GC CPU at Recommended GOGC", "%.2f%%", last.gcCPU)
+	printMetric("p99 GC Pause at Recommended GOGC", "%s", formatDuration(last.p99Pause))
+	printMetric("Heap Goal at Recommended GOGC", "%s", formatBytes(last.heapGoal))
+	if stable < tuneStableEpochs {
+		fmt.Printf("GOGC did not settle within %d epochs; raise -tune-max-epochs or -tune-epoch\n", *tuneMaxEpochs)
+	}
+}
+
+// nextGOGC applies one controller step to the epoch's result
+func nextGOGC(gogc int, r tuneEpochResult) int {
+	ratio := r.gcCPU / *tuneGCCPU
+	if *tuneP99Pause > 0 {
+		ratio = float64(r.p99Pause) / float64(*tuneP99Pause)
+	}
+	next := gogc
+	switch {
+	case ratio > 1:
+		next = int(float64(gogc) * tuneRaise)
+	case ratio < tuneSlack:
+		next = int(float64(gogc) * tuneLower)
+	}
+	return min(max(next, tuneMinGOGC), tuneMaxGOGC)
+}
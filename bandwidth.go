@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	bandwidthHogs = flag.Int("bandwidth-hogs", 0,
+		"background goroutines streaming through large buffers during the measured phase to contend for memory bandwidth (0 disables)")
+	bandwidthDuty = flag.Int("bandwidth-duty", 100,
+		"percent of each 10ms period every -bandwidth-hogs goroutine spends streaming; it sleeps for the rest. "+
+			"0 allocates the buffers without streaming, a baseline with the same heap")
+	bandwidthBuffer = byteSize(64 << 20)
+)
+
+func init() {
+	flag.Var(&bandwidthBuffer, "bandwidth-buffer",
+		"size of the buffer each -bandwidth-hogs goroutine streams through, e.g. 64MB; well beyond the last-level cache")
+}
+
+// bandwidthPeriod is the period the hogs' duty cycle repeats over
+const bandwidthPeriod = 10 * time.Millisecond
+
+// bandwidthChunk is how much a hog copies between checks of its duty cycle
+const bandwidthChunk = 1 << 20
+
+// bandwidthHog streams memory in the background so the collector has to
+// mark and sweep with memory bandwidth contended, as on a machine shared
+// with other memory-bound work. Each goroutine copies one half of its
+// buffer over the other and back, which misses every cache level once the
+// buffer is well beyond the last-level cache. The buffers are pointer-free,
+// so the collector never scans them, but they count toward the live heap
+// and so raise the heap goal; a duty cycle of 0 keeps the buffers without
+// streaming, for a baseline with the same heap. The goroutines also take
+// CPU time, so use fewer of them than spare cores to contend for bandwidth
+// alone.
+type bandwidthHog struct {
+	buffers  [][]byte
+	duty     time.Duration // Streaming time per period
+	streamed atomic.Uint64 // Bytes copied
+	stop     chan struct{}
+	done     sync.WaitGroup
+	started  time.Time
+	elapsed  time.Duration
+}
+
+// newBandwidthHog allocates and touches the hogs' buffers, returning nil
+// when disabled. Doing this before warmup keeps the heap growth and page
+// faults out of the measured phase.
+func newBandwidthHog() (*bandwidthHog, error) {
+	if *bandwidthHogs <= 0 {
+		return nil, nil
+	}
+	if *bandwidthDuty < 0 || *bandwidthDuty > 100 {
+		return nil, fmt.Errorf("-bandwidth-duty must be between 0 and 100, got %d", *bandwidthDuty)
+	}
+	if bandwidthBuffer < 2*bandwidthChunk {
+		return nil, fmt.Errorf("-bandwidth-buffer must be at least %s", formatBytes(2*bandwidthChunk))
+	}
+	h := &bandwidthHog{
+		buffers: make([][]byte, *bandwidthHogs),
+		duty:    bandwidthPeriod * time.Duration(*bandwidthDuty) / 100,
+		stop:    make(chan struct{}),
+	}
+	for n := range h.buffers {
+		h.buffers[n] = make([]byte, bandwidthBuffer)
+		for i := range h.buffers[n] {
+			h.buffers[n][i] = byte(i)
+		}
+	}
+	return h, nil
+}
+
+// Start starts the streaming goroutines, if they have any duty
+func (h *bandwidthHog) Start() {
+	h.started = time.Now()
+	if h.duty == 0 {
+		return
+	}
+	for _, buf := range h.buffers {
+		h.done.Add(1)
+		go h.stream(buf)
+	}
+}
+
+// stream copies between the halves of buf a chunk at a time, for the duty
+// share of every period, until stopped
+func (h *bandwidthHog) stream(buf []byte) {
+	defer h.done.Done()
+	half := len(buf) / 2 / bandwidthChunk * bandwidthChunk
+	src, dst := buf[:half], buf[half:2*half]
+	offset := 0
+	for {
+		periodStart := time.Now()
+		for time.Since(periodStart) < h.duty {
+			copy(dst[offset:offset+bandwidthChunk], src[offset:offset+bandwidthChunk])
+			h.streamed.Add(bandwidthChunk)
+			if offset += bandwidthChunk; offset == half {
+				offset = 0
+				src, dst = dst, src
+			}
+		}
+		select {
+		case <-h.stop:
+			return
+		default:
+		}
+		if rest := bandwidthPeriod - time.Since(periodStart); rest > 0 {
+			time.Sleep(rest)
+		}
+	}
+}
+
+// Stop stops the streaming goroutines
+func (h *bandwidthHog) Stop() {
+	close(h.stop)
+	h.done.Wait()
+	h.elapsed = time.Since(h.started)
+}
+
+// Report prints the contention the hogs generated. The copy rate counts
+// each byte copied once, though it is both read and written.
+func (h *bandwidthHog) Report() {
+	printSection("Bandwidth Hog")
+	printMetric("Hog Goroutines", "%d", len(h.buffers))
+	printMetric("Hog Buffer", "%s", formatBytes(uint64(len(h.buffers[0]))))
+	printMetric("Hog Duty Cycle", "%d%%", *bandwidthDuty)
+	printMetric("Hog Bytes Copied", "%s", formatBytes(h.streamed.Load()))
+	if h.elapsed > 0 {
+		printMetric("Hog Copy Rate", "%s/s", formatBytes(uint64(float64(h.streamed.Load())/h.elapsed.Seconds())))
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
+)
+
+var bundleDir = flag.String("out", "",
+	"benchmark mode: write a bundle of the run to this directory: JSON results, CSV timelines, the HTML report, the "+
+		"heap profile, a CPU profile and execution trace of a separate run of the measured phase and a manifest")
+
+// bundleFile is a file a bundle can hold and the flag that writes it, if
+// the bundle fills one in
+type bundleFile struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	flag        *string
+}
+
+// bundleFiles are the files of a bundle, in manifest order. Flags already
+// set on the command line keep their paths, and their files stay out of
+// the bundle.
+var bundleFiles = []bundleFile{
+	{"results.json", "the -format=json document", nil},
+	{"report.html", "the -html report", htmlReport},
+	{"iterations.csv", "every measured iteration's latency and GC phase (-iterations-out)", iterationsOut},
+	{"pauses.csv", "the measured phase's GC pause distribution (-pauses-out)", pausesOut},
+	{"mutator_utilization.csv", "the mutator utilization timeline (-mu-out)", muOut},
+	{"gc_timeline.csv", "the per-cycle GC timeline from gctrace (-gc-timeline)", gcTimeline},
+	{"slow_stacks.txt", "goroutine dumps of slow iterations (-slow-stacks-out)", slowStacksOut},
+	{"cpu.pprof", "CPU profile of a separate run of the measured phase", nil},
+	{"trace.out", "execution trace of a separate run of the measured phase", nil},
+	{"heap.pprof", "heap profile at the end of the run", nil},
+	{"manifest.json", "this manifest", nil},
+}
+
+// bundleManifest describes a bundle: the run it came from and its files
+type bundleManifest struct {
+	SchemaVersion int              `json:"schema_version"`
+	Created       time.Time        `json:"created"`
+	Args          []string         `json:"args"`
+	Mode          string           `json:"mode"`
+	Workload      string           `json:"workload"`
+	GoVersion     string           `json:"go_version"`
+	Files         []bundleFile     `json:"files"`
+	Provenance    provenanceRecord `json:"provenance"`
+}
+
+// configureBundle creates the -out directory and points the output flags
+// that aren't set at files in it
+func configureBundle(mode string) error {
+	if *bundleDir == "" {
+		return nil
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-out is only supported in benchmark mode")
+	}
+	if err := os.MkdirAll(*bundleDir, 0o755); err != nil {
+		return err
+	}
+	for _, f := range bundleFiles {
+		if f.flag == nil || *f.flag != "" {
+			continue
+		}
+		// The timeline re-executes the benchmark, which WebAssembly can't
+		if f.flag == gcTimeline && runtime.GOOS == "js" {
+			continue
+		}
+		// Snapshots are only taken with a threshold
+		if f.flag == slowStacksOut && *slowThreshold == 0 {
+			continue
+		}
+		// The timeline is only sampled with a window
+		if f.flag == muOut && *muWindow <= 0 {
+			continue
+		}
+		*f.flag = bundlePath(f.Name)
+	}
+	return nil
+}
+
+// bundlePath returns the path of a file in the bundle
+func bundlePath(name string) string {
+	return filepath.Join(*bundleDir, name)
+}
+
+// bundleProfiles are the CPU profile and execution trace a bundle records
+// over a run of the measured phase
+type bundleProfiles struct {
+	cpu   *os.File
+	trace *os.File
+}
+
+// profileBundle runs the measured phase once more on ws, with phases if
+// set, under the CPU profiler and execution tracer, if a bundle is being
+// written. Both slow the program down, the trace most, so they get a run
+// of their own after the measured one rather than skewing its numbers.
+func profileBundle(ws []Workload, phases []phase) error {
+	if *bundleDir == "" {
+		return nil
+	}
+	fmt.Println("Profiling another run for the bundle...")
+	defer detachRecorders()()
+	var measured *phaseRun
+	if phases != nil {
+		measured = newPhaseRun(ws, phases)
+	}
+	runtime.GC()
+	p, err := startBundleProfiles()
+	if err != nil {
+		return err
+	}
+	markPhase("profile", true)
+	if measured != nil {
+		measured.run()
+	} else {
+		runIterations(ws, *iterations)
+	}
+	markPhase("profile", false)
+	return p.Stop()
+}
+
+// startBundleProfiles starts profiling and tracing
+func startBundleProfiles() (*bundleProfiles, error) {
+	p := &bundleProfiles{}
+	var err error
+	if p.cpu, err = os.Create(bundlePath("cpu.pprof")); err != nil {
+		return nil, err
+	}
+	if err := pprof.StartCPUProfile(p.cpu); err != nil {
+		p.cpu.Close()
+		return nil, err
+	}
+	if p.trace, err = os.Create(bundlePath("trace.out")); err != nil {
+		pprof.StopCPUProfile()
+		p.cpu.Close()
+		return nil, err
+	}
+	if err := trace.Start(p.trace); err != nil {
+		pprof.StopCPUProfile()
+		p.cpu.Close()
+		p.trace.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Stop stops profiling and tracing and closes their files
+func (p *bundleProfiles) Stop() error {
+	trace.Stop()
+	pprof.StopCPUProfile()
+	err := p.trace.Close()
+	if cerr := p.cpu.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeBundle writes the bundle's JSON results, heap profile and manifest,
+// if a bundle was requested. The other files are written by their flags.
+func writeBundle(mode, workload string, results *runResults) error {
+	if *bundleDir == "" {
+		return nil
+	}
+	if err := writeJSONFile(bundlePath("results.json"), newJSONReport(mode, workload, results)); err != nil {
+		return err
+	}
+
+	f, err := os.Create(bundlePath("heap.pprof"))
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup("heap").WriteTo(f, 0); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	manifest := bundleManifest{
+		SchemaVersion: reportSchemaVersion,
+		Created:       time.Now(),
+		Args:          os.Args[1:],
+		Mode:          mode,
+		Workload:      workload,
+		GoVersion:     runtime.Version(),
+		Provenance:    currentProvenance(),
+	}
+	for _, f := range bundleFiles {
+		// Only files that made it into the bundle
+		if f.flag != nil && *f.flag != bundlePath(f.Name) {
+			continue
+		}
+		if _, err := os.Stat(bundlePath(f.Name)); err == nil || f.Name == "manifest.json" {
+			manifest.Files = append(manifest.Files, f)
+		}
+	}
+	return writeJSONFile(bundlePath("manifest.json"), manifest)
+}
+
+// writeJSONFile writes v to path as indented JSON
+func writeJSONFile(path string, v any) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// godebugMetricPrefix starts the name of the metric counting non-default
+// behavior of each GODEBUG setting the runtime knows
+const godebugMetricPrefix = "/godebug/non-default-behavior/"
+
+// runtimeCapabilities is what the running toolchain offers the benchmark:
+// the runtime metrics it provides, the GODEBUG settings it knows and the
+// experiments the binary was built with, and the features the benchmark
+// turned off for lack of them. Runtime metrics come and go between Go
+// releases, so features that need one check for it instead of reading a
+// metric the runtime doesn't have.
+type runtimeCapabilities struct {
+	GoVersion   string   `json:"go_version"`
+	Experiments []string `json:"experiments"`
+	Metrics     []string `json:"metrics"`
+	GODEBUG     []string `json:"godebug"`
+	Unavailable []string `json:"unavailable,omitempty"`
+}
+
+var (
+	capabilitiesMu sync.Mutex
+	capabilities   = probeCapabilities()
+	// availableMetrics indexes capabilities.Metrics
+	availableMetrics = map[string]bool{}
+)
+
+func init() {
+	for _, name := range capabilities.Metrics {
+		availableMetrics[name] = true
+	}
+}
+
+// probeCapabilities lists what the running toolchain provides
+func probeCapabilities() runtimeCapabilities {
+	c := runtimeCapabilities{GoVersion: runtime.Version(), Experiments: []string{}, GODEBUG: []string{}}
+	for _, d := range metrics.All() {
+		c.Metrics = append(c.Metrics, d.Name)
+		if setting, ok := strings.CutPrefix(d.Name, godebugMetricPrefix); ok {
+			c.GODEBUG = append(c.GODEBUG, strings.TrimSuffix(setting, ":events"))
+		}
+	}
+	if exp := goExperiment(); exp != "" {
+		c.Experiments = strings.Split(exp, ",")
+	}
+	sort.Strings(c.GODEBUG)
+	return c
+}
+
+// featureSupported reports whether the runtime provides every metric a
+// feature needs. The first time a feature is found unsupported, it is noted
+// on stderr and in the capabilities, and the feature is expected to turn
+// itself off.
+func featureSupported(feature string, names ...string) bool {
+	var missing []string
+	for _, name := range names {
+		if !availableMetrics[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return true
+	}
+	note := fmt.Sprintf("%s (needs %s)", feature, strings.Join(missing, ", "))
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	for _, u := range capabilities.Unavailable {
+		if u == note {
+			return false
+		}
+	}
+	capabilities.Unavailable = append(capabilities.Unavailable, note)
+	fmt.Fprintf(os.Stderr, "%s lacks a runtime metric; %s is unavailable\n", runtime.Version(), note)
+	return false
+}
+
+// requireFeature returns an error if the runtime lacks a metric a feature
+// the user asked for needs
+func requireFeature(feature string, names ...string) error {
+	if featureSupported(feature, names...) {
+		return nil
+	}
+	return fmt.Errorf("%s is not supported by %s", feature, runtime.Version())
+}
+
+// currentCapabilities returns the capabilities with the features found
+// unavailable so far
+func currentCapabilities() runtimeCapabilities {
+	capabilitiesMu.Lock()
+	defer capabilitiesMu.Unlock()
+	c := capabilities
+	c.Unavailable = append([]string(nil), c.Unavailable...)
+	return c
+}
+
+// printCapabilities prints a summary of the capabilities
+func printCapabilities() {
+	c := currentCapabilities()
+	printSection("Runtime Capabilities")
+	experiments := "none"
+	if len(c.Experiments) > 0 {
+		experiments = strings.Join(c.Experiments, ",")
+	}
+	fmt.Printf("Go Version: %s\n", c.GoVersion)
+	fmt.Printf("Experiments: %s\n", experiments)
+	fmt.Printf("Runtime Metrics: %d\n", len(c.Metrics))
+	fmt.Printf("GODEBUG Settings: %d\n", len(c.GODEBUG))
+	if len(c.Unavailable) > 0 {
+		fmt.Printf("Unavailable: %s\n", strings.Join(c.Unavailable, "; "))
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"time"
+)
+
+var (
+	capacitySLO = flag.Duration("capacity-slo", 0,
+		"capacity mode: p99 request latency the sustainable throughput must stay within")
+	capacityEpoch = flag.Int("capacity-epoch", 10,
+		"capacity mode: iterations measured at each probed arrival rate")
+	capacitySteps = flag.Int("capacity-steps", 8,
+		"capacity mode: probes of the binary search, each halving the interval the sustainable rate lies in")
+)
+
+// workloadArrivalRate is implemented by open-loop workloads whose request
+// arrival rate capacity mode can set, and whose request latency it reads
+type workloadArrivalRate interface {
+	// SetArrivalRate sets the arrival rate in requests/sec per worker, 0
+	// for unlimited
+	SetArrivalRate(rate float64)
+	// RequestLatency returns the latency of the requests served since the
+	// last ResetStats
+	RequestLatency() *latencyHistogram
+}
+
+// validateCapacity checks the capacity mode flags, and that the workload
+// has an arrival rate to search
+func validateCapacity(w Workload) error {
+	if *capacitySLO <= 0 {
+		return fmt.Errorf("capacity mode needs a -capacity-slo p99 latency")
+	}
+	if *capacityEpoch < 1 || *capacitySteps < 1 {
+		return fmt.Errorf("-capacity-epoch and -capacity-steps must be positive")
+	}
+	if _, ok := w.(workloadArrivalRate); !ok {
+		return fmt.Errorf("the %s workload has no arrival rate for capacity mode (try -workload=service)", w.Name())
+	}
+	return nil
+}
+
+// capacityProbe is what one epoch measured at one arrival rate
+type capacityProbe struct {
+	rate      float64 // Requests/sec over all workers, 0 for unlimited
+	served    uint64
+	elapsed   time.Duration
+	p99       time.Duration
+	numGC     uint32
+	withinSLO bool
+}
+
+// throughput returns the requests served per second
+func (p capacityProbe) throughput() float64 {
+	return float64(p.served) / p.elapsed.Seconds()
+}
+
+// runCapacity searches for the highest open-loop arrival rate at which the
+// p99 request latency stays within -capacity-slo. It first runs an epoch
+// with requests sent as fast as they are served, whose throughput bounds
+// the search: no arrival rate above it can be sustained, as the queue would
+// grow without end. It then probes that rate and binary-searches below it,
+// each epoch starting from an empty queue. A pause that delays requests
+// builds a backlog behind it, so the collector decides how close to
+// saturation a service can run, not only how fast it runs.
+func runCapacity(ws []Workload) {
+	fmt.Printf("Searching for the throughput with p99 request latency <= %s, %d iterations per probe...\n",
+		formatDuration(*capacitySLO), *capacityEpoch)
+	fmt.Println()
+	fmt.Printf("%5s | %14s | %14s | %10s | %4s | %s\n", "Probe", "Arrival Rate", "Throughput", "p99", "GCs", "Within SLO")
+
+	saturated := probeCapacity(ws, 0)
+	printCapacityProbe("max", saturated)
+	hi := saturated.throughput()
+
+	var best *capacityProbe
+	lo := 0.0
+	for step := 1; step <= *capacitySteps; step++ {
+		// The first probe tries the saturation throughput itself
+		rate := hi
+		if step > 1 {
+			rate = (lo + hi) / 2
+		}
+		p := probeCapacity(ws, rate)
+		printCapacityProbe(fmt.Sprint(step), p)
+		if p.withinSLO {
+			best, lo = &p, rate
+			if step == 1 {
+				break
+			}
+		} else {
+			hi = rate
+		}
+	}
+
+	fmt.Println()
+	printSection("Sustainable Throughput")
+	printMetric("Latency SLO", "p99 <= %s", formatDuration(*capacitySLO))
+	printMetric("Saturation Requests/sec", "%.2f", saturated.throughput())
+	if best == nil {
+		printMetric("Sustainable Requests/sec", "%.2f", 0.0)
+		fmt.Printf("No probed rate met the SLO; the lowest probed was %.2f req/s, raise -capacity-steps or -capacity-slo\n", hi)
+		return
+	}
+	printMetric("Sustainable Requests/sec", "%.2f", best.rate)
+	printMetric("p99 at Sustainable Rate", "%s", formatDuration(best.p99))
+	printMetric("Share of Saturation", "%.2f%%", best.rate/saturated.throughput()*100)
+	printMetric("Search Resolution", "%.2f req/s", hi-lo)
+}
+
+// probeCapacity runs an epoch at an arrival rate over all workers, 0 for
+// unlimited, and measures the request latency
+func probeCapacity(ws []Workload, rate float64) capacityProbe {
+	for _, w := range ws {
+		w.(workloadArrivalRate).SetArrivalRate(rate / float64(len(ws)))
+		if r, ok := w.(workloadStatsResetter); ok {
+			r.ResetStats()
+		}
+	}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	runIterations(ws, *capacityEpoch)
+	p := capacityProbe{rate: rate, elapsed: time.Since(start)}
+	runtime.ReadMemStats(&after)
+	p.numGC = after.NumGC - before.NumGC
+
+	merged := new(latencyHistogram)
+	for _, w := range ws {
+		merged.Merge(w.(workloadArrivalRate).RequestLatency())
+	}
+	p.served = merged.Count()
+	p.p99 = merged.Percentile(99)
+	p.withinSLO = p.p99 <= *capacitySLO
+	return p
+}
+
+// printCapacityProbe prints a row of the search table
+func printCapacityProbe(name string, p capacityProbe) {
+	rate := "unlimited"
+	if p.rate > 0 {
+		rate = fmt.Sprintf("%.2f/s", p.rate)
+	}
+	fmt.Printf("%5s | %14s | %14s | %10s | %4d | %t\n", name, rate,
+		fmt.Sprintf("%.2f/s", p.throughput()), formatDuration(p.p99), p.numGC, p.withinSLO)
+}
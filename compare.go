@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// compareCommand is the subcommand comparing two -format=json documents
+const compareCommand = "compare"
+
+// isCompareCommand reports whether the benchmark was run as
+// "compare a.json b.json"
+func isCompareCommand() bool {
+	return len(os.Args) > 1 && os.Args[1] == compareCommand
+}
+
+// higherIsBetter lists substrings of the names of metrics other than
+// percentages that improve as they grow. Other durations and sizes, and
+// counts of GCs, allocations and misses, improve as they shrink; any other
+// metric, such as an iteration count, is compared without a verdict.
+var higherIsBetter = []string{"/sec", "Throughput"}
+
+// percentDirection gives the direction of each percentage metric, 1 if it
+// improves as it grows and -1 if it improves as it shrinks. Percentages
+// can go either way, so any other is compared without a verdict.
+var percentDirection = map[string]int{
+	"Deadline Misses":                   -1,
+	"GC CPU Fraction":                   -1,
+	"GC CPU at Recommended GOGC":        -1,
+	"GC Pause Overhead":                 -1,
+	"GC Share of CPU":                   -1,
+	"Hit Ratio":                         1,
+	"Mean Mutator Utilization":          1,
+	"Minimum Mutator Utilization":       1,
+	"OOM Risk":                          -1,
+	"Quota Used":                        -1,
+	"Share of Ceiling":                  1,
+	"Share of Saturation":               1,
+	"Tail Excess from GC":               -1,
+	"Tail Overlapping GC":               -1,
+	"Tail Overlapping Mark Termination": -1,
+	"Tail Overlapping Marking":          -1,
+	"Throttled Share":                   -1,
+}
+
+// lowerPercents lists substrings of the names of percentage metrics
+// reported per source, such as memory pressure stalls, that improve as they
+// shrink
+var lowerPercents = []string{"Memory Stall"}
+
+// inputSettings names the metrics that report a setting of the run, or a
+// value the harness steers toward one, rather than a result; they are
+// compared without a verdict
+var inputSettings = map[string]bool{
+	"Achieved Density":        true,
+	"CPU Quota":               true,
+	"Deadline":                true,
+	"GOMEMLIMIT at End":       true,
+	"GOMEMLIMIT at Start":     true,
+	"Heap Room at End":        true,
+	"Heap Room at Start":      true,
+	"Hog Buffer":              true,
+	"Hog Duty Cycle":          true,
+	"Live Set Target":         true,
+	"MU Window":               true,
+	"Mean Achieved Occupancy": true,
+	"Pause Window":            true,
+	"Peak Achieved Occupancy": true,
+	"Quota Period":            true,
+	"Region Size":             true,
+	"Target":                  true,
+	"Target Occupancy":        true,
+	"Threshold":               true,
+}
+
+// lowerIsBetter lists substrings of the names of count metrics that
+// improve as they shrink
+var lowerIsBetter = []string{"GC", "Alloc", "Misses", "Over Budget", "Outlier", "Breach"}
+
+// metricDirection returns 1 if a metric improves as it grows, -1 if it
+// improves as it shrinks and 0 if neither
+func metricDirection(name string, kind metricKind) int {
+	// Names ending in " At" are points in time, such as when the minimum
+	// utilization occurred
+	if inputSettings[name] || strings.HasSuffix(name, " At") {
+		return 0
+	}
+	if kind == kindPercent {
+		for _, s := range lowerPercents {
+			if strings.Contains(name, s) {
+				return -1
+			}
+		}
+		return percentDirection[name]
+	}
+	for _, s := range higherIsBetter {
+		if strings.Contains(name, s) {
+			return 1
+		}
+	}
+	if kind != kindNumber {
+		return -1
+	}
+	for _, s := range lowerIsBetter {
+		if strings.Contains(name, s) {
+			return -1
+		}
+	}
+	return 0
+}
+
+// runCompare is the compare subcommand: it prints each metric the two
+// documents share whose value is a single number, duration, size or
+// percentage, in the first one's order, with the change from the
+// first to the second, absolute and in percent, and flags changes beyond
+// -noise in the worse direction as regressions. It returns the exit status.
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet(compareCommand, flag.ContinueOnError)
+	noise := fs.Float64("noise", 2, "changes smaller than this percentage are neither regressions nor improvements")
+	fs.StringVar(colorMode, "color", "auto", "colorize regressions: auto (when stdout is a terminal), always or never")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] <a.json> <b.json>\n", os.Args[0], compareCommand)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	var docs [2]jsonReport
+	for n, path := range fs.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if err := json.Unmarshal(data, &docs[n]); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v (want a -format=json document)\n", path, err)
+			return 2
+		}
+	}
+	a, b := docs[0], docs[1]
+
+	fmt.Printf("A: %s (%s, %s workload, %s)\n", fs.Arg(0), a.Mode, a.Workload, describeBuild(a))
+	fmt.Printf("B: %s (%s, %s workload, %s)\n", fs.Arg(1), b.Mode, b.Workload, describeBuild(b))
+	// As analyze_results.py does, the runs must verify and share their
+	// configuration and environment, and builds may differ only in
+	// GOEXPERIMENT; anything else is compared with a warning
+	problems := provenanceMismatches("A", a.Provenance, "B", b.Provenance)
+	if len(problems) == 0 {
+		onlyA, onlyB := settingDifferences(a.Provenance.Build, b.Provenance.Build)
+		if onlyA, onlyB = withoutExperiments(onlyA), withoutExperiments(onlyB); len(onlyA)+len(onlyB) > 0 {
+			problems = append(problems, fmt.Sprintf("builds differ by more than GOEXPERIMENT: A [%s] vs B [%s]",
+				strings.Join(onlyA, " "), strings.Join(onlyB, " ")))
+		}
+	}
+	for _, p := range problems {
+		fmt.Printf("Warning: %s\n", p)
+	}
+	if len(problems) == 0 {
+		fmt.Printf("Provenance verified (config %s, environment %s)\n", a.Provenance.ConfigHash, a.Provenance.EnvironmentHash)
+	}
+
+	bValues := map[[2]string]string{}
+	for _, m := range b.Metrics {
+		key := [2]string{m.Section, m.Name}
+		if _, ok := bValues[key]; !ok {
+			bValues[key] = m.Value
+		}
+	}
+	section := ""
+	regressions, improvements, compared := 0, 0, 0
+	for _, m := range a.Metrics {
+		bValue, ok := bValues[[2]string{m.Section, m.Name}]
+		if !ok {
+			continue
+		}
+		// Values with a description after them, such as the worst
+		// pauses, are for reading rather than comparing
+		if len(strings.Fields(m.Value)) > 2 || len(strings.Fields(bValue)) > 2 {
+			continue
+		}
+		va, kind, okA := parseMetricValue(m.Value)
+		vb, kindB, okB := parseMetricValue(bValue)
+		if !okA || !okB || kind != kindB {
+			continue
+		}
+		if m.Section != section {
+			section = m.Section
+			fmt.Println()
+			printSection(section)
+			fmt.Printf("%-32s | %-14s | %-14s | %-14s | %-9s | %s\n", "Metric", "A", "B", "Delta", "Change", "Verdict")
+		}
+		compared++
+
+		change, percent := "n/a", math.NaN()
+		if va != 0 {
+			percent = (vb - va) / math.Abs(va) * 100
+			change = fmt.Sprintf("%+.2f%%", percent)
+		}
+		verdict := ""
+		if dir := metricDirection(m.Name, kind); dir != 0 && vb != va {
+			// A change from zero is beyond any noise
+			if math.IsNaN(percent) || math.Abs(percent) >= *noise {
+				if (vb > va) == (dir > 0) {
+					verdict = "improved"
+					improvements++
+				} else {
+					verdict = "REGRESSION"
+					regressions++
+				}
+			}
+		}
+		line := fmt.Sprintf("%-32s | %-14s | %-14s | %-14s | %-9s | %s",
+			m.Name, m.Value, bValue, formatMetricDelta(vb-va, kind), change, verdict)
+		if verdict == "REGRESSION" && colorEnabled() {
+			line = ansiBold + ansiRed + line + ansiReset
+		}
+		fmt.Println(line)
+	}
+
+	fmt.Println()
+	fmt.Printf("%d metrics compared: %d regressions, %d improvements beyond %g%% noise\n",
+		compared, regressions, improvements, *noise)
+	return 0
+}
+
+// describeBuild summarizes the toolchain a document's run was built with
+func describeBuild(doc jsonReport) string {
+	experiments := "no experiments"
+	if len(doc.Capabilities.Experiments) > 0 {
+		experiments = "GOEXPERIMENT=" + strings.Join(doc.Capabilities.Experiments, ",")
+	}
+	return doc.GoVersion + " " + experiments
+}
+
+// formatMetricDelta formats the difference between two values of a kind
+// in the kind's unit
+func formatMetricDelta(d float64, kind metricKind) string {
+	sign := "+"
+	if d < 0 {
+		sign = "-"
+	}
+	switch kind {
+	case kindDuration:
+		return sign + formatDuration(time.Duration(math.Abs(d)))
+	case kindBytes:
+		return sign + formatBytes(uint64(math.Abs(d)))
+	case kindPercent:
+		return fmt.Sprintf("%+.2f pp", d)
+	}
+	if d == math.Round(d) {
+		return fmt.Sprintf("%+.0f", d)
+	}
+	return fmt.Sprintf("%+.2f", d)
+}
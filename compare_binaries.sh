@@ -0,0 +1,67 @@
+#!/bin/bash
+
+# Runs two pre-built benchmark binaries with identical flags and compares
+# their results, for hosts where the benchmark can't be rebuilt. Binary A
+# takes the place of the standard GC build and binary B of the Green Tea
+# build in benchmark_results/, so analyze_results.py and its provenance
+# checks apply. Extra arguments are passed to both binaries.
+#
+# Usage: ./compare_binaries.sh --a <binary> --b <binary> [benchmark flags...]
+#
+# Example:
+#   ./compare_binaries.sh --a ./bench-std --b ./bench-greentea -workload=bintree
+
+usage() {
+    echo "Usage: $0 --a <binary> --b <binary> [benchmark flags...]"
+    exit 1
+}
+
+BIN_A=""
+BIN_B=""
+while [ $# -gt 0 ]; do
+    case "$1" in
+        --a) BIN_A=$2; shift 2 ;;
+        --a=*) BIN_A=${1#--a=}; shift ;;
+        --b) BIN_B=$2; shift 2 ;;
+        --b=*) BIN_B=${1#--b=}; shift ;;
+        --) shift; break ;;
+        *) break ;;
+    esac
+done
+
+if [ -z "$BIN_A" ] || [ -z "$BIN_B" ]; then
+    usage
+fi
+for bin in "$BIN_A" "$BIN_B"; do
+    if [ ! -x "$bin" ]; then
+        echo "Not an executable: $bin"
+        exit 1
+    fi
+done
+
+echo "======================================"
+echo "Binary Comparison"
+echo "A: $BIN_A"
+echo "B: $BIN_B"
+echo "======================================"
+echo ""
+
+mkdir -p benchmark_results
+
+echo "Running A..."
+"$BIN_A" -pauses-out benchmark_results/standard_pauses.csv "$@" > benchmark_results/standard_gc.txt || exit 1
+echo "Running B..."
+"$BIN_B" -pauses-out benchmark_results/greentea_pauses.csv "$@" > benchmark_results/greentea_gc.txt || exit 1
+echo ""
+
+# In the comparison, "Standard GC" is A and "Green Tea GC" is B. The builds
+# may differ in anything, such as the Go version; configurations and
+# environments must still match.
+python3 "$(dirname "$0")/analyze_results.py" --any-build
+STATUS=$?
+
+echo ""
+echo "Full results saved to:"
+echo "  - benchmark_results/standard_gc.txt (A)"
+echo "  - benchmark_results/greentea_gc.txt (B)"
+exit $STATUS
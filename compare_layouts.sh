@@ -0,0 +1,82 @@
+#!/bin/bash
+
+# Runs the matrix workload in every element layout under both collectors
+# and prints a layout comparison table: how much of the GC cost each step
+# from pointer-per-element storage to one flat slice removes. Extra
+# arguments are passed to both benchmark binaries.
+#
+# Usage: ./compare_layouts.sh [benchmark flags...]
+#
+# Examples:
+#   ./compare_layouts.sh
+#   ./compare_layouts.sh -size 100 -element struct
+
+LAYOUTS="pointers rowbatch values flat"
+OUT_DIR=benchmark_results/layouts
+
+echo "======================================"
+echo "Layout comparison: $LAYOUTS"
+echo "Standard GC vs Green Tea GC"
+echo "======================================"
+echo ""
+
+mkdir -p "$OUT_DIR"
+
+go build -o matrix_benchmark_standard *.go
+if [ $? -ne 0 ]; then
+    echo "Build failed for standard GC"
+    exit 1
+fi
+
+GOEXPERIMENT=greenteagc go build -o matrix_benchmark_greentea *.go
+if [ $? -ne 0 ]; then
+    echo "Build failed for Green Tea GC"
+    echo "Note: Green Tea GC is only available in Go 1.25+"
+    exit 1
+fi
+
+# Prints a metric from the result message a run sent over the coordinator
+# protocol
+extract_metric() {
+    python3 - "$1" "$2" <<'PY'
+import json, sys
+for line in open(sys.argv[1]):
+    msg = json.loads(line)
+    if msg.get("v") != 1:
+        sys.exit("unsupported protocol version %s" % msg.get("v"))
+    if msg["type"] != "result":
+        continue
+    for m in msg.get("metrics", []):
+        if m["name"] == sys.argv[2]:
+            print(m["value"])
+            sys.exit()
+PY
+}
+
+for layout in $LAYOUTS; do
+    echo "Running -layout=$layout..."
+    GREEN_TEA_BENCHMARK_IPC=fd:3 ./matrix_benchmark_standard -workload=matrix -layout="$layout" "$@" \
+        > "$OUT_DIR/standard_${layout}.txt" 3> "$OUT_DIR/standard_${layout}.jsonl" || exit 1
+    GREEN_TEA_BENCHMARK_IPC=fd:3 ./matrix_benchmark_greentea -workload=matrix -layout="$layout" "$@" \
+        > "$OUT_DIR/greentea_${layout}.txt" 3> "$OUT_DIR/greentea_${layout}.jsonl" || exit 1
+done
+
+echo ""
+printf "%-9s | %-9s | %-12s | %-12s | %-12s | %-6s | %-8s | %-12s\n" \
+    "Layout" "Collector" "Duration" "Allocated" "Heap Objects" "GCs" "GC CPU" "GC Pause"
+echo "----------|-----------|--------------|--------------|--------------|--------|----------|-------------"
+for layout in $LAYOUTS; do
+    for collector in standard greentea; do
+        RESULT="$OUT_DIR/${collector}_${layout}.jsonl"
+        printf "%-9s | %-9s | %-12s | %-12s | %-12s | %-6s | %-8s | %-12s\n" "$layout" "$collector" \
+            "$(extract_metric "$RESULT" "Total Duration")" "$(extract_metric "$RESULT" "Total Allocated")" \
+            "$(extract_metric "$RESULT" "Heap Objects")" "$(extract_metric "$RESULT" "Number of GCs")" \
+            "$(extract_metric "$RESULT" "GC CPU Fraction")" "$(extract_metric "$RESULT" "Total GC Pause")"
+    done
+done
+
+echo ""
+echo "Full results saved to $OUT_DIR/"
+
+# Cleanup
+rm -f matrix_benchmark_standard matrix_benchmark_greentea
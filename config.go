@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var configFile = flag.String("config", "",
+	"read flag settings from this file, one \"flag = value\" per line; flags given on the command line take precedence")
+
+// loadConfig applies the settings in -config to every flag not set on the
+// command line
+func loadConfig() error {
+	if *configFile == "" {
+		return nil
+	}
+	f, err := os.Open(*configFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	explicit := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	sc := bufio.NewScanner(f)
+	for lineno := 1; sc.Scan(); lineno++ {
+		line := strings.TrimSpace(strings.SplitN(sc.Text(), "#", 2)[0])
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: want \"flag = value\", got %q", *configFile, lineno, line)
+		}
+		name = strings.TrimLeft(strings.TrimSpace(name), "-")
+		value = strings.TrimSpace(value)
+		if name == "config" || name == "watch" {
+			return fmt.Errorf("%s:%d: -%s cannot be set from a config file", *configFile, lineno, name)
+		}
+		if explicit[name] {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			return fmt.Errorf("%s:%d: %v", *configFile, lineno, err)
+		}
+	}
+	return sc.Err()
+}
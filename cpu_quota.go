@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	cpuQuota = flag.Float64("cpu-quota", 0,
+		"Linux only: emulate a CPU limit of this many CPUs during the measured phase, as a Kubernetes limit such as 500m (0.5) does, "+
+			"by stopping the process for the rest of each period once it has used its share (0 disables)")
+	cpuQuotaPeriod = flag.Duration("cpu-quota-period", 100*time.Millisecond,
+		"period the -cpu-quota is enforced over; the CFS default is 100ms")
+)
+
+// throttlerEnv marks the re-executed process that throttles the benchmark,
+// with the value "pid:quota:period"
+const throttlerEnv = "GREEN_TEA_BENCHMARK_THROTTLER"
+
+// Job-control signals, by number since package syscall only names them on
+// Unix; these are their Linux numbers
+const (
+	sigStop = syscall.Signal(19)
+	sigCont = syscall.Signal(18)
+)
+
+// configureCPUQuota checks -cpu-quota can be emulated here and, unless
+// GOMAXPROCS is set explicitly, sizes GOMAXPROCS to the quota the way the
+// runtime does for a cgroup CPU limit: the quota rounded up, at least 2 and
+// at most the number of CPUs
+func configureCPUQuota() error {
+	if *cpuQuota == 0 {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("-cpu-quota is only supported on Linux")
+	}
+	if *cpuQuota < 0 || *cpuQuotaPeriod <= 0 {
+		return fmt.Errorf("-cpu-quota and -cpu-quota-period must be positive")
+	}
+	if err := requireFeature("-cpu-quota", "/cpu/classes/gc/total:cpu-seconds", "/cpu/classes/user:cpu-seconds"); err != nil {
+		return err
+	}
+	if os.Getenv("GOMAXPROCS") == "" {
+		runtime.GOMAXPROCS(min(max(2, int(math.Ceil(*cpuQuota))), runtime.NumCPU()))
+	}
+	return nil
+}
+
+// cpuQuotaEmulation throttles the benchmark from a helper process, like the
+// kernel's CFS bandwidth control throttles a cgroup: the helper polls the
+// benchmark's CPU time and, once the benchmark has used quota × period of
+// it in the current period, stops it with SIGSTOP until the period ends.
+// Every thread stops, the GC's included, so GC work spends the quota just as
+// it does in a container. The runtime's CPU accounting is based on wall
+// time, so time spent stopped lands in whichever class was running, as
+// under a real limit.
+type cpuQuotaEmulation struct {
+	quota  float64
+	period time.Duration
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+
+	cpu       []metrics.Sample // GC and mutator CPU seconds
+	cpuBefore [2]float64
+	usedStart time.Duration // Process CPU time
+	used      time.Duration
+	started   time.Time
+	elapsed   time.Duration
+	periods   int64
+	throttled int64
+	stopped   time.Duration
+}
+
+// newCPUQuotaEmulation starts the throttling helper, which waits to be told
+// to start, returning nil when -cpu-quota is disabled
+func newCPUQuotaEmulation() (*cpuQuotaEmulation, error) {
+	if *cpuQuota == 0 {
+		return nil, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	q := &cpuQuotaEmulation{
+		quota:  *cpuQuota,
+		period: *cpuQuotaPeriod,
+		cmd:    exec.Command(exe),
+		cpu: []metrics.Sample{
+			{Name: "/cpu/classes/gc/total:cpu-seconds"},
+			{Name: "/cpu/classes/user:cpu-seconds"},
+		},
+	}
+	q.cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d:%g:%d", throttlerEnv, os.Getpid(), q.quota, q.period))
+	q.cmd.Stderr = os.Stderr
+	if q.stdin, err = q.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := q.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	q.stdout = bufio.NewReader(stdout)
+	if err := q.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting CPU quota throttler: %v", err)
+	}
+	return q, nil
+}
+
+// Start starts throttling
+func (q *cpuQuotaEmulation) Start() {
+	metrics.Read(q.cpu)
+	q.cpuBefore = [2]float64{q.cpu[0].Value.Float64(), q.cpu[1].Value.Float64()}
+	q.usedStart, _ = processCPUTime(os.Getpid())
+	q.started = time.Now()
+	io.WriteString(q.stdin, "start\n")
+}
+
+// Stop stops throttling and collects the helper's counts of periods and
+// throttling
+func (q *cpuQuotaEmulation) Stop() error {
+	q.elapsed = time.Since(q.started)
+	if used, err := processCPUTime(os.Getpid()); err == nil {
+		q.used = used - q.usedStart
+	}
+	q.stdin.Close()
+	line, readErr := q.stdout.ReadString('\n')
+	if err := q.cmd.Wait(); err != nil {
+		return fmt.Errorf("CPU quota throttler: %v", err)
+	}
+	if readErr != nil {
+		return fmt.Errorf("CPU quota throttler: %v", readErr)
+	}
+	var stoppedNs int64
+	if _, err := fmt.Sscan(line, &q.periods, &q.throttled, &stoppedNs); err != nil {
+		return fmt.Errorf("CPU quota throttler: unexpected report %q", line)
+	}
+	q.stopped = time.Duration(stoppedNs)
+	return nil
+}
+
+// Report prints how often the quota throttled the process and how much of
+// the quota GC work took from the mutator. The runtime's CPU classes count
+// wall time, stopped time included, so the GC's share comes from them and
+// the CPU it took is that share of the CPU the process actually used. The
+// runtime updates the classes at the end of each GC cycle, so this is best
+// called after one.
+func (q *cpuQuotaEmulation) Report() {
+	metrics.Read(q.cpu)
+	gcCPU := q.cpu[0].Value.Float64() - q.cpuBefore[0]
+	mutatorCPU := q.cpu[1].Value.Float64() - q.cpuBefore[1]
+	available := time.Duration(q.quota * float64(q.elapsed))
+
+	printSection("CPU Quota")
+	printMetric("CPU Quota", "%g CPUs", q.quota)
+	printMetric("Quota Period", "%s", formatDuration(q.period))
+	printMetric("GOMAXPROCS", "%d", runtime.GOMAXPROCS(0))
+	printMetric("Periods", "%d", q.periods)
+	printMetric("Throttled Periods", "%d", q.throttled)
+	if q.periods > 0 {
+		printMetric("Throttled Share", "%.2f%%", float64(q.throttled)/float64(q.periods)*100)
+	}
+	printMetric("Throttled Time", "%s", formatDuration(q.stopped))
+	printMetric("Process CPU Used", "%s", formatDuration(q.used))
+	if available > 0 {
+		printMetric("Quota Used", "%.2f%%", float64(q.used)/float64(available)*100)
+	}
+	if total := gcCPU + mutatorCPU; total > 0 {
+		share := gcCPU / total
+		printMetric("GC Share of CPU", "%.2f%%", share*100)
+		printMetric("GC CPU Taken from Quota", "%s", formatDuration(time.Duration(share*float64(q.used))))
+	}
+}
+
+// isThrottler reports whether this process is a -cpu-quota helper
+func isThrottler() bool {
+	return os.Getenv(throttlerEnv) != ""
+}
+
+// runThrottler is the helper process of -cpu-quota. It waits for a line on
+// stdin, throttles the target until stdin is closed, making sure the target
+// is left running, and then prints "periods throttled stopped_ns". It
+// returns the exit status.
+func runThrottler() int {
+	var pid int
+	var quota float64
+	var period time.Duration
+	if _, err := fmt.Sscanf(strings.ReplaceAll(os.Getenv(throttlerEnv), ":", " "), "%d %g %d", &pid, &quota, &period); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", throttlerEnv, err)
+		return 2
+	}
+	target, err := os.FindProcess(pid)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	if _, err := in.ReadString('\n'); err != nil {
+		fmt.Println("0 0 0")
+		return 0
+	}
+	done := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, in)
+		close(done)
+	}()
+
+	budget := time.Duration(quota * float64(period))
+	poll := max(period/50, time.Millisecond)
+	var periods, throttled int64
+	var stopped time.Duration
+	for {
+		periods++
+		periodStart := time.Now()
+		base, err := processCPUTime(pid)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		for time.Since(periodStart) < period {
+			select {
+			case <-done:
+				fmt.Println(periods, throttled, int64(stopped))
+				return 0
+			case <-time.After(poll):
+			}
+			used, err := processCPUTime(pid)
+			if err != nil {
+				// The target has exited
+				return 0
+			}
+			if used-base < budget {
+				continue
+			}
+			if rest := period - time.Since(periodStart); rest > 0 {
+				target.Signal(sigStop)
+				time.Sleep(rest)
+				target.Signal(sigCont)
+				throttled++
+				stopped += rest
+			}
+			break
+		}
+	}
+}
+
+// processCPUTime returns the CPU time used so far by every thread of a
+// process, from the scheduler statistics of its tasks, which are kept in
+// nanoseconds rather than the clock ticks of /proc/PID/stat
+func processCPUTime(pid int) (time.Duration, error) {
+	tasks, err := filepath.Glob(fmt.Sprintf("/proc/%d/task/*/schedstat", pid))
+	if err != nil || len(tasks) == 0 {
+		return 0, fmt.Errorf("process %d: no scheduler statistics", pid)
+	}
+	var total time.Duration
+	for _, path := range tasks {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue // The thread exited
+		}
+		field, _, _ := strings.Cut(string(data), " ")
+		ns, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		total += time.Duration(ns)
+	}
+	return total, nil
+}
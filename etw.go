@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+var etwMarkers = flag.Bool("etw", false,
+	"Windows only: write ETW marker events for benchmark phases and GC cycles from the green-tea-benchmark EventSource (record it with etw_markers.wprp)")
+
+// etwMarkerScript runs in a PowerShell process for the whole run, writing
+// each line of its input as an event of the green-tea-benchmark
+// EventSource. It prints ready once the provider is registered.
+const etwMarkerScript = `$es = [System.Diagnostics.Tracing.EventSource]::new('green-tea-benchmark')
+[Console]::Out.WriteLine('ready')
+while ($null -ne ($line = [Console]::In.ReadLine())) { $es.Write($line) }
+$es.Dispose()`
+
+// validateETW checks that -etw can be honored on this platform and starts
+// the PowerShell process that writes the markers. Calling ETW in-process
+// would need a Windows-only file, which the go build *.go builds of the
+// scripts can't select.
+func validateETW() error {
+	if !*etwMarkers {
+		return nil
+	}
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("-etw is only supported on Windows, not %s", runtime.GOOS)
+	}
+	if _, err := exec.LookPath("powershell"); err != nil {
+		return fmt.Errorf("-etw needs powershell.exe on PATH: %v", err)
+	}
+	p, err := startPipeMarkers(exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", etwMarkerScript), "ready")
+	if err != nil {
+		return fmt.Errorf("-etw: %v", err)
+	}
+	markers = p
+	return nil
+}
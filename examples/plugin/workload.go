@@ -0,0 +1,63 @@
+// Command plugin is an example workload plugin. Build it with the same Go
+// toolchain and GOEXPERIMENT as the benchmark binary that will load it:
+//
+//	go build -buildmode=plugin -o linkedlist.so examples/plugin/workload.go
+//	./matrix_benchmark_standard -plugin linkedlist.so -workload linkedlist
+//
+// Plugins cannot see the benchmark's flags, so this one reads its tuning
+// from the environment.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// RegisterWorkloads is looked up by the benchmark when the plugin loads
+func RegisterWorkloads(register func(name string, newWorkload func() (any, error))) {
+	register("linkedlist", newLinkedListWorkload)
+}
+
+// node is one element of the list
+type node struct {
+	next  *node
+	value [4]uint64
+}
+
+// linkedListWorkload rebuilds a linked list every iteration, keeping the
+// most recent list alive
+type linkedListWorkload struct {
+	length int
+	head   *node
+}
+
+func newLinkedListWorkload() (any, error) {
+	length := 10000
+	if s := os.Getenv("LINKEDLIST_LENGTH"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("LINKEDLIST_LENGTH must be a positive integer, got %q", s)
+		}
+		length = n
+	}
+	return &linkedListWorkload{length: length}, nil
+}
+
+func (w *linkedListWorkload) Name() string { return "linkedlist" }
+
+func (w *linkedListWorkload) Iterate(i int) {
+	var head *node
+	for n := 0; n < w.length; n++ {
+		head = &node{next: head, value: [4]uint64{uint64(i), uint64(n)}}
+	}
+	w.head = head
+}
+
+// Report prints the length of the retained list, showing that optional
+// workload interfaces work across the plugin boundary
+func (w *linkedListWorkload) Report() {
+	fmt.Printf("List Length: %d\n", w.length)
+}
+
+func main() {}
@@ -0,0 +1,371 @@
+#!/usr/bin/env python3
+"""
+Plan and run large benchmark experiments from an explicit run manifest
+"""
+
+import argparse
+import csv
+import itertools
+import json
+import math
+import os
+import subprocess
+import sys
+from datetime import datetime, timezone
+from pathlib import Path
+
+MANIFEST_VERSION = 1
+
+# How each collector is built
+COLLECTORS = {
+    'standard': {},
+    'greentea': {'GOEXPERIMENT': 'greenteagc'},
+}
+
+def split_values(text):
+    """Split a comma-separated axis into its values"""
+    return [v.strip() for v in text.split(',') if v.strip()]
+
+def plan(args):
+    """Expand the sweep axes into a manifest listing every run"""
+    for c in args.collector:
+        if c not in COLLECTORS:
+            sys.exit(f"unknown collector {c!r} (want {' or '.join(COLLECTORS)})")
+
+    if args.repeat < 1:
+        sys.exit("--repeat must be positive")
+
+    runs = []
+    for collector, gogc, size, workers, rep in itertools.product(
+            args.collector, args.gogc, args.size, args.workers, range(1, args.repeat + 1)):
+        cell = f"gogc{gogc}_size{size}_workers{workers}"
+        runs.append({
+            'id': f"{collector}_{cell}_r{rep}",
+            'cell': cell,
+            'collector': collector,
+            'env': {'GOGC': gogc},
+            'args': [f"-workload={args.workload}", f"-size={size}", f"-workers={workers}"] + args.flags,
+        })
+
+    manifest = {
+        'version': MANIFEST_VERSION,
+        'created': datetime.now(timezone.utc).isoformat(timespec='seconds'),
+        'axes': {
+            'collector': args.collector,
+            'gogc': args.gogc,
+            'size': args.size,
+            'workers': args.workers,
+        },
+        'repeat': args.repeat,
+        'out_dir': args.out_dir or str(Path('benchmark_results') / Path(args.output).stem),
+        'runs': runs,
+    }
+    Path(args.output).parent.mkdir(parents=True, exist_ok=True)
+    with open(args.output, 'w') as f:
+        json.dump(manifest, f, indent=2)
+        f.write('\n')
+    print(f"Planned {len(runs)} runs in {args.output}")
+    return 0
+
+def load_manifest(path):
+    """Read and check a manifest written by plan"""
+    with open(path) as f:
+        manifest = json.load(f)
+    if manifest.get('version') != MANIFEST_VERSION:
+        sys.exit(f"{path}: unsupported manifest version {manifest.get('version')}")
+    ids = [r['id'] for r in manifest['runs']]
+    if len(set(ids)) != len(ids):
+        sys.exit(f"{path}: run ids are not unique")
+    return manifest
+
+def read_checkpoint(path):
+    """Return the ids of the runs a checkpoint file records as complete"""
+    if not path.exists():
+        return set()
+    with open(path) as f:
+        return {line.strip() for line in f if line.strip()}
+
+def build(collector, out_dir):
+    """Build the benchmark for a collector, returning the binary's path"""
+    binary = out_dir / f"matrix_benchmark_{collector}"
+    env = dict(os.environ, **COLLECTORS[collector])
+    sources = sorted(str(p) for p in Path('.').glob('*.go'))
+    print(f"Building {collector} binary...")
+    if subprocess.run(['go', 'build', '-o', str(binary)] + sources, env=env).returncode != 0:
+        sys.exit(f"Build failed for {collector}")
+    return binary
+
+def completed(messages):
+    """Report whether a run's coordinator messages include its result"""
+    if not messages.exists():
+        return False
+    with open(messages) as f:
+        for line in f:
+            msg = json.loads(line)
+            if msg.get('v') != 1:
+                return False
+            if msg['type'] == 'result':
+                return True
+    return False
+
+def run(args):
+    """Execute every run of a manifest not yet checkpointed as complete"""
+    manifest = load_manifest(args.manifest)
+    out_dir = Path(manifest['out_dir'])
+    out_dir.mkdir(parents=True, exist_ok=True)
+    checkpoint = Path(args.manifest).with_suffix('.done')
+    done = read_checkpoint(checkpoint)
+
+    pending = [r for r in manifest['runs'] if r['id'] not in done]
+    print(f"{len(manifest['runs'])} runs in {args.manifest}, {len(done)} already complete, {len(pending)} to run")
+
+    binaries = {}
+    failed = []
+    for n, r in enumerate(pending, 1):
+        if r['collector'] not in binaries:
+            binaries[r['collector']] = build(r['collector'], out_dir)
+        report = out_dir / f"{r['id']}.txt"
+        messages = out_dir / f"{r['id']}.jsonl"
+        pauses = out_dir / f"{r['id']}_pauses.csv"
+        messages.unlink(missing_ok=True)
+        env = dict(os.environ, **r['env'])
+        env['GREEN_TEA_BENCHMARK_IPC'] = f"file:{messages}"
+
+        print(f"[{n}/{len(pending)}] {r['id']}")
+        cmd = [str(binaries[r['collector']]), f"-pauses-out={pauses}"] + r['args']
+        with open(report, 'w') as out:
+            status = subprocess.run(cmd, env=env, stdout=out).returncode
+        # A run whose assertions fail still completed; only a missing result
+        # leaves it to be retried
+        if not completed(messages):
+            print(f"  failed (exit status {status}), see {report}")
+            failed.append(r['id'])
+            continue
+        with open(checkpoint, 'a') as f:
+            f.write(r['id'] + '\n')
+
+    print(f"\n{len(manifest['runs']) - len(failed)} of {len(manifest['runs'])} runs complete; results in {out_dir}/")
+    if failed:
+        print(f"{len(failed)} failed and will be retried by the next run: {', '.join(failed)}")
+        return 1
+    return 0
+
+# Metrics the verdict ranks configurations by: display name, how to read
+# the metric from a run and whether higher is better
+VERDICT_METRICS = [
+    ('Throughput', 'ops_per_sec', True),
+    ('p99 GC Pause', 'p99_pause_ns', False),
+    ('Peak RSS', 'peak_rss_bytes', False),
+    ('GC CPU', 'gc_cpu_fraction', False),
+]
+
+# Significance level a difference must reach to declare a winner
+VERDICT_ALPHA = 0.05
+
+SIZE_UNITS = [('GB', 1024 ** 3), ('MB', 1024 ** 2), ('KB', 1024), ('B', 1)]
+
+def parse_size(text):
+    """Convert a size such as "12.50 MB" to bytes"""
+    text = text.strip()
+    for suffix, scale in SIZE_UNITS:
+        if text.endswith(suffix):
+            return float(text[:-len(suffix)]) * scale
+    return None
+
+def pause_percentile(path, p):
+    """The upper bound of the bucket holding the pth percentile pause of a
+    -pauses-out histogram, in nanoseconds"""
+    if not path.exists():
+        return None
+    with open(path, newline='') as f:
+        buckets = [(row['lower_ns'], row['upper_ns'], int(row['count'])) for row in csv.DictReader(f)]
+    total = sum(count for _, _, count in buckets)
+    if total == 0:
+        return None
+    seen = 0
+    for lower, upper, count in buckets:
+        seen += count
+        if seen >= total * p / 100:
+            return float(upper or lower)
+    return None
+
+def run_metrics(out_dir, run_id):
+    """The verdict metrics of a completed run"""
+    metrics = {}
+    with open(out_dir / f"{run_id}.jsonl") as f:
+        for line in f:
+            msg = json.loads(line)
+            if msg['type'] != 'result':
+                continue
+            metrics['ops_per_sec'] = msg['results']['ops_per_sec']
+            metrics['gc_cpu_fraction'] = msg['results']['gc_cpu_fraction']
+            for m in msg.get('metrics', []):
+                if m['name'] == 'Peak RSS':
+                    metrics['peak_rss_bytes'] = parse_size(m['value'])
+    metrics['p99_pause_ns'] = pause_percentile(out_dir / f"{run_id}_pauses.csv", 99)
+    return {k: v for k, v in metrics.items() if v is not None}
+
+def betacf(a, b, x):
+    """Continued fraction for the regularized incomplete beta function"""
+    qab, qap, qam = a + b, a + 1, a - 1
+    c, d = 1.0, 1 - qab * x / qap
+    d = 1 / (d if abs(d) > 1e-300 else 1e-300)
+    h = d
+    for m in range(1, 200):
+        m2 = 2 * m
+        for aa in (m * (b - m) * x / ((qam + m2) * (a + m2)),
+                   -(a + m) * (qab + m) * x / ((a + m2) * (qap + m2))):
+            d = 1 + aa * d
+            d = 1 / (d if abs(d) > 1e-300 else 1e-300)
+            c = 1 + aa / c
+            c = c if abs(c) > 1e-300 else 1e-300
+            h *= d * c
+        if abs(d * c - 1) < 1e-12:
+            break
+    return h
+
+def incomplete_beta(a, b, x):
+    """The regularized incomplete beta function I_x(a, b)"""
+    if x <= 0 or x >= 1:
+        return max(0.0, min(1.0, x))
+    front = math.exp(math.lgamma(a + b) - math.lgamma(a) - math.lgamma(b)
+                     + a * math.log(x) + b * math.log(1 - x))
+    if x < (a + 1) / (a + b + 2):
+        return front * betacf(a, b, x) / a
+    return 1 - front * betacf(b, a, 1 - x) / b
+
+def welch_p(xs, ys):
+    """Two-sided p-value of Welch's t-test that xs and ys have equal means,
+    or None with fewer than two samples of either"""
+    if len(xs) < 2 or len(ys) < 2:
+        return None
+    mx, my = sum(xs) / len(xs), sum(ys) / len(ys)
+    vx = sum((x - mx) ** 2 for x in xs) / (len(xs) - 1) / len(xs)
+    vy = sum((y - my) ** 2 for y in ys) / (len(ys) - 1) / len(ys)
+    if vx + vy == 0:
+        return 1.0 if mx == my else 0.0
+    t = (mx - my) / math.sqrt(vx + vy)
+    df = (vx + vy) ** 2 / (vx ** 2 / (len(xs) - 1) + vy ** 2 / (len(ys) - 1))
+    return incomplete_beta(df / 2, 0.5, df / (df + t * t))
+
+def format_metric(key, value):
+    """Format a verdict metric's value for the tables"""
+    if key == 'p99_pause_ns':
+        for suffix, scale in (('s', 1e9), ('ms', 1e6), ('µs', 1e3)):
+            if value >= scale:
+                return f"{value / scale:.2f}{suffix}"
+        return f"{value:.0f}ns"
+    if key == 'peak_rss_bytes':
+        return f"{value / 1024 ** 2:.1f} MB"
+    if key == 'gc_cpu_fraction':
+        return f"{value * 100:.2f}%"
+    return f"{value:.2f}"
+
+def judge(samples, higher_better):
+    """Pick the winning configuration from each configuration's samples of
+    a metric: the one with the best mean, provided the difference from every
+    other is significant. Returns (winner or None, reason)."""
+    means = {c: sum(v) / len(v) for c, v in samples.items() if v}
+    if len(means) < 2:
+        return None, "not measured"
+    best = (max if higher_better else min)(means, key=means.get)
+    worst_p = 0.0
+    for c in means:
+        if c == best:
+            continue
+        p = welch_p(samples[best], samples[c])
+        if p is None:
+            return None, "needs 2+ runs each"
+        worst_p = max(worst_p, p)
+    if worst_p >= VERDICT_ALPHA:
+        return None, f"no significant difference (p={worst_p:.3f})"
+    return best, f"p={worst_p:.3f}"
+
+def verdict(args):
+    """Declare, per cell of a manifest and metric, which collector won"""
+    manifest = load_manifest(args.manifest)
+    out_dir = Path(manifest['out_dir'])
+    done = read_checkpoint(Path(args.manifest).with_suffix('.done'))
+
+    # cell -> collector -> metric -> samples
+    cells = {}
+    for r in manifest['runs']:
+        if r['id'] not in done:
+            continue
+        per_collector = cells.setdefault(r.get('cell', r['id']), {})
+        samples = per_collector.setdefault(r['collector'], {})
+        for key, value in run_metrics(out_dir, r['id']).items():
+            samples.setdefault(key, []).append(value)
+    if not cells:
+        sys.exit(f"{args.manifest}: no completed runs")
+
+    wins = {name: {} for name, _, _ in VERDICT_METRICS}
+    undecided = {name: 0 for name, _, _ in VERDICT_METRICS}
+    for cell, per_collector in cells.items():
+        collectors = sorted(per_collector)
+        counts = ', '.join(f"{c} x{len(per_collector[c].get('ops_per_sec', []))}" for c in collectors)
+        print(f"=== {cell} ({counts}) ===")
+        print(f"{'Metric':<14} | " + ' | '.join(f"{c:<12}" for c in collectors) + " | Verdict")
+        for name, key, higher_better in VERDICT_METRICS:
+            samples = {c: per_collector[c].get(key, []) for c in collectors}
+            means = [format_metric(key, sum(v) / len(v)) if v else 'n/a' for v in samples.values()]
+            winner, reason = judge(samples, higher_better)
+            if winner is None:
+                undecided[name] += 1
+                outcome = reason
+            else:
+                wins[name][winner] = wins[name].get(winner, 0) + 1
+                outcome = f"{winner} wins ({reason})"
+            print(f"{name:<14} | " + ' | '.join(f"{m:<12}" for m in means) + f" | {outcome}")
+        print()
+
+    print("=== Verdict ===")
+    for name, _, _ in VERDICT_METRICS:
+        tally = ', '.join(f"{c} {n}" for c, n in sorted(wins[name].items(), key=lambda kv: -kv[1]))
+        print(f"{name:<14}: {tally or 'no wins'}; undecided in {undecided[name]} of {len(cells)} cells")
+    print(f"\nWinners are significant at the {VERDICT_ALPHA:g} level by Welch's t-test over each cell's repeated runs.")
+    return 0
+
+def main():
+    parser = argparse.ArgumentParser(description=__doc__.strip())
+    sub = parser.add_subparsers(dest='command', required=True)
+
+    p = sub.add_parser('plan', help='expand a sweep into a run manifest',
+                       description='Expand the cartesian product of the axes into a manifest of runs. '
+                                   'Arguments after -- are passed to every run.')
+    p.add_argument('--gogc', type=split_values, default=['100'], metavar='LIST',
+                   help='comma-separated GOGC values (default 100)')
+    p.add_argument('--size', type=split_values, default=['50'], metavar='LIST',
+                   help='comma-separated -size values (default 50)')
+    p.add_argument('--workers', type=split_values, default=['1'], metavar='LIST',
+                   help='comma-separated -workers values (default 1)')
+    p.add_argument('--collector', type=split_values, default=list(COLLECTORS), metavar='LIST',
+                   help='comma-separated collectors: standard, greentea (default both)')
+    p.add_argument('--workload', default='matrix', help='workload to run (default matrix)')
+    p.add_argument('--repeat', type=int, default=1,
+                   help='runs of every configuration, for the verdict\'s significance tests (default 1)')
+    p.add_argument('-o', '--output', default='benchmark_results/manifest.json',
+                   help='manifest file to write (default benchmark_results/manifest.json)')
+    p.add_argument('--out-dir', help='directory the runs write their results to '
+                                     '(default benchmark_results/<manifest name>)')
+    p.add_argument('flags', nargs='*', help='benchmark flags passed to every run')
+    p.set_defaults(func=plan)
+
+    r = sub.add_parser('run', help='execute a run manifest, resuming where it left off',
+                       description='Run every run of the manifest not yet recorded as complete in its '
+                                   'checkpoint file (the manifest path with a .done suffix).')
+    r.add_argument('--manifest', required=True, help='manifest written by plan')
+    r.set_defaults(func=run)
+
+    v = sub.add_parser('verdict', help='declare the winning collector per metric',
+                       description='Compare the collectors in every cell of a manifest\'s completed runs '
+                                   'on throughput, p99 GC pause, peak RSS and GC CPU, and declare a winner '
+                                   'where the difference is statistically significant.')
+    v.add_argument('--manifest', required=True, help='manifest written by plan')
+    v.set_defaults(func=verdict)
+
+    args = parser.parse_args()
+    return args.func(args)
+
+if __name__ == '__main__':
+    sys.exit(main())
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+)
+
+var unitsMode = flag.String("units", "human",
+	"how sizes and durations are printed: human (auto-scaled units) or machine (fixed MB/ms units and precision)")
+
+// Byte size units, in binary multiples as reported by the runtime
+const (
+	KB = 1024
+	MB = 1024 * KB
+	GB = 1024 * MB
+)
+
+// validateUnits checks the -units flag
+func validateUnits() error {
+	if *unitsMode != "human" && *unitsMode != "machine" {
+		return fmt.Errorf("unknown -units %q (want human or machine)", *unitsMode)
+	}
+	return nil
+}
+
+// formatBytes renders a byte count. Human mode picks the largest unit that
+// keeps the value at or above one; machine mode always uses MB with three
+// decimals so output can be parsed and compared without unit handling.
+func formatBytes(b uint64) string {
+	if *unitsMode == "machine" {
+		return fmt.Sprintf("%.3f MB", float64(b)/MB)
+	}
+	switch {
+	case b >= GB:
+		return fmt.Sprintf("%.2f GB", float64(b)/GB)
+	case b >= MB:
+		return fmt.Sprintf("%.2f MB", float64(b)/MB)
+	case b >= KB:
+		return fmt.Sprintf("%.2f KB", float64(b)/KB)
+	default:
+		return fmt.Sprintf("%d B", b)
+	}
+}
+
+// formatDuration renders a duration. Human mode picks the largest unit that
+// keeps the value at or above one, with two decimals; machine mode always
+// uses milliseconds with six decimals (nanosecond resolution).
+func formatDuration(d time.Duration) string {
+	if *unitsMode == "machine" {
+		return fmt.Sprintf("%.6fms", float64(d)/float64(time.Millisecond))
+	}
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs >= time.Second:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case abs >= time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	case abs >= time.Microsecond:
+		return fmt.Sprintf("%.2fµs", float64(d)/float64(time.Microsecond))
+	default:
+		return fmt.Sprintf("%dns", int64(d))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// tailFraction is the share of the slowest iterations attribution treats
+// as the latency tail
+const tailFraction = 0.01
+
+// ReportGCAttribution attributes the latency tail, the slowest 1% of the
+// iterations in the ring, to GC or to other noise. An iteration is
+// attributed to GC if it overlapped marking, where it may have been drafted
+// into assists or stopped by the sweep termination pause, or mark
+// termination. Iterations overlap GC at some base rate by chance; the
+// enrichment is how much more often the tail does, so a value near 1 says
+// the tail isn't GC's doing even if much of it overlapped GC. Each tail
+// iteration's excess over the median is its share of the tail latency.
+// Only the tail's overlap beyond the base rate is GC's doing, so the part
+// of the tail GC accounts for is the excess of the GC-attributed iterations
+// discounted by the share the base rate explains: all of it if no other
+// iteration overlapped GC, none if the tail overlapped no more often than
+// the rest.
+func (r *latencyRing) ReportGCAttribution() {
+	n := r.kept()
+	printSection("GC Latency Attribution")
+	if n == 0 {
+		fmt.Println("No iterations")
+		return
+	}
+	order := make([]int, n)
+	overlapping := 0
+	for i := range order {
+		order[i] = i
+		if r.phases[i] != gcPhaseOff {
+			overlapping++
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return r.latencies[order[a]] > r.latencies[order[b]] })
+	median := r.latencies[order[n/2]]
+	tail := order[:int(math.Ceil(float64(n)*tailFraction))]
+
+	var marking, markTermination int
+	var excess, gcExcess time.Duration
+	for _, i := range tail {
+		over := max(r.latencies[i]-median, 0)
+		excess += over
+		switch r.phases[i] {
+		case gcPhaseMark:
+			marking++
+			gcExcess += over
+		case gcPhaseMarkTermination:
+			markTermination++
+			gcExcess += over
+		}
+	}
+	percent := func(part, whole int) float64 { return float64(part) / float64(whole) * 100 }
+	tailGC := percent(marking+markTermination, len(tail))
+	baseGC := percent(overlapping, n)
+
+	printMetric("Tail Iterations", "%d (at least %s)", len(tail), formatDuration(r.latencies[tail[len(tail)-1]]))
+	printMetric("Tail Overlapping Marking", "%.2f%%", percent(marking, len(tail)))
+	printMetric("Tail Overlapping Mark Termination", "%.2f%%", percent(markTermination, len(tail)))
+	printMetric("Tail Overlapping GC", "%.2f%%", tailGC)
+	printMetric("All Overlapping GC", "%.2f%%", baseGC)
+	if baseGC > 0 {
+		printMetric("GC Enrichment in Tail", "%.2fx", tailGC/baseGC)
+	}
+	if excess > 0 {
+		// The attributable fraction: of the GC-overlapping tail
+		// iterations, the share beyond what the base rate predicts
+		attributable := 0.0
+		if tailGC > baseGC {
+			attributable = (tailGC - baseGC) / tailGC
+		}
+		fromGC := float64(gcExcess) * attributable / float64(excess) * 100
+		printMetric("Tail Excess from GC", "%.2f%%", fromGC)
+		printMetric("Tail Excess from Other", "%.2f%%", 100-fromGC)
+	}
+}
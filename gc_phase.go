@@ -0,0 +1,86 @@
+package main
+
+import "runtime/metrics"
+
+// gcPhase is the GC phase an iteration ran in
+type gcPhase uint8
+
+const (
+	// gcPhaseOff means no GC cycle was in progress
+	gcPhaseOff gcPhase = iota
+	// gcPhaseMark means the iteration overlapped concurrent marking
+	gcPhaseMark
+	// gcPhaseMarkTermination means a cycle finished marking during the
+	// iteration, which therefore absorbed the mark termination pause
+	gcPhaseMarkTermination
+)
+
+// gcPhases lists the phases in order, for reporting
+var gcPhases = []gcPhase{gcPhaseOff, gcPhaseMark, gcPhaseMarkTermination}
+
+func (p gcPhase) String() string {
+	switch p {
+	case gcPhaseMark:
+		return "mark"
+	case gcPhaseMarkTermination:
+		return "marktermination"
+	}
+	return "off"
+}
+
+// gcPhaseProbe reads enough runtime state to tell which GC phase is
+// active. The runtime doesn't expose the phase, but every cycle records two
+// stop-the-world pauses, sweep termination as marking starts and mark
+// termination as it ends, and completes a cycle at the second. So with c
+// completed cycles, 2c+1 pauses means marking is in progress, and 2c-1
+// means mark termination is. Each goroutine needs its own probe.
+type gcPhaseProbe struct {
+	samples []metrics.Sample
+}
+
+func newGCPhaseProbe() *gcPhaseProbe {
+	return &gcPhaseProbe{samples: []metrics.Sample{
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: pauseMetric},
+	}}
+}
+
+// gcState is a reading of a gcPhaseProbe
+type gcState struct {
+	cycles uint64
+	pauses uint64
+}
+
+// Read returns the current GC state. It doesn't allocate once the pause
+// histogram has been read.
+func (p *gcPhaseProbe) Read() gcState {
+	metrics.Read(p.samples)
+	var pauses uint64
+	for _, c := range p.samples[1].Value.Float64Histogram().Counts {
+		pauses += c
+	}
+	return gcState{cycles: p.samples[0].Value.Uint64(), pauses: pauses}
+}
+
+// phase returns the phase active at the reading
+func (s gcState) phase() gcPhase {
+	switch {
+	case s.pauses < 2*s.cycles:
+		return gcPhaseMarkTermination
+	case s.pauses > 2*s.cycles:
+		return gcPhaseMark
+	}
+	return gcPhaseOff
+}
+
+// gcPhaseBetween returns the most disruptive phase active at any point
+// between two readings
+func gcPhaseBetween(start, end gcState) gcPhase {
+	if end.cycles > start.cycles {
+		return gcPhaseMarkTermination
+	}
+	if end.pauses > start.pauses {
+		return max(gcPhaseMark, start.phase(), end.phase())
+	}
+	return max(start.phase(), end.phase())
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var gcTimeline = flag.String("gc-timeline", "",
+	"write the per-cycle GC timeline (start, end, pause, heap before/after, goal) to this CSV file")
+
+// gcTimelineChildEnv marks the re-executed benchmark process whose gctrace
+// output the parent turns into the timeline
+const gcTimelineChildEnv = "GREEN_TEA_BENCHMARK_GC_TIMELINE_CHILD"
+
+// gctraceLine matches the fields of a GODEBUG=gctrace=1 line used in the
+// timeline: cycle, start offset, the three wall-clock phases (sweep
+// termination, concurrent mark, mark termination), heap at start, end and
+// live, and the heap goal
+var gctraceLine = regexp.MustCompile(
+	`^gc (\d+) @([\d.]+)s \d+%: ([\d.]+)\+([\d.]+)\+([\d.]+) ms clock, .*?(\d+)->(\d+)->(\d+) MB, (\d+) MB goal`)
+
+// gcTimelineHeader names the timeline CSV columns. Times are milliseconds
+// since the benchmark process started, which is its run clock to within
+// gctrace's millisecond precision, and the start as an absolute time; heap
+// sizes are whole megabytes, the granularity gctrace reports.
+var gcTimelineHeader = []string{
+	"cycle", "start_ms", "end_ms", "pause_ms", "mark_ms",
+	"heap_before_mb", "heap_after_mb", "heap_live_mb", "goal_mb", "forced", "start_unix_ns",
+}
+
+// wantGCTimeline reports whether this process should hand the run to a
+// traced child and write the timeline
+func wantGCTimeline() bool {
+	return *gcTimeline != "" && os.Getenv(gcTimelineChildEnv) == ""
+}
+
+// runGCTimeline re-executes the benchmark with the same arguments and
+// GODEBUG=gctrace=1, which the runtime only honors at startup. The child's
+// gctrace lines are written to the timeline CSV; everything else passes
+// through. It returns the child's exit status.
+func runGCTimeline() int {
+	f, err := os.Create(*gcTimeline)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer f.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	godebug := "gctrace=1"
+	if prev := os.Getenv("GODEBUG"); prev != "" {
+		godebug = prev + "," + godebug
+	}
+	cmd.Env = append(os.Environ(), "GODEBUG="+godebug, gcTimelineChildEnv+"=1")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer stderr.Close()
+	cmd.Stderr = stderrW
+
+	// The child's hello gives its run clock origin, which anchors the rows
+	// in absolute time; rows wait for it, or for the child to exit without
+	// sending one
+	originReady := make(chan struct{})
+	var origin *time.Time
+	var once sync.Once
+	setOrigin := func(t *time.Time) {
+		once.Do(func() {
+			origin = t
+			close(originReady)
+		})
+	}
+
+	w := csv.NewWriter(f)
+	w.Write(gcTimelineHeader)
+	var cycles int
+	var parseErr error
+	parsed := make(chan struct{})
+	go func() {
+		defer close(parsed)
+		cycles, parseErr = writeGCTimeline(w, stderr, func() *time.Time {
+			<-originReady
+			return origin
+		})
+		w.Flush()
+	}()
+
+	// Messages from the child are passed on, so a coordinator of this
+	// process gets its results
+	_, err = runChild(cmd, func(msg ipcMessage) {
+		if msg.Type == ipcHello {
+			setOrigin(msg.ClockOrigin)
+		}
+		sendIPC(msg)
+	})
+	setOrigin(nil)
+	stderrW.Close()
+	<-parsed
+
+	if parseErr == nil {
+		parseErr = w.Error()
+	}
+	if parseErr != nil {
+		fmt.Fprintf(os.Stderr, "writing GC timeline: %v\n", parseErr)
+		return 2
+	}
+	// On stderr, so it never mixes into a structured report on stdout
+	fmt.Fprintf(os.Stderr, "GC timeline: %d cycles written to %s\n", cycles, *gcTimeline)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	return 0
+}
+
+// writeGCTimeline converts gctrace lines from r into timeline rows, copying
+// any other lines to stderr, and returns the number of cycles written. origin
+// returns the wall clock time the offsets count from, or nil if unknown.
+func writeGCTimeline(w *csv.Writer, r io.Reader, origin func() *time.Time) (int, error) {
+	cycles := 0
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		m := gctraceLine.FindStringSubmatch(line)
+		if m == nil {
+			fmt.Fprintln(os.Stderr, line)
+			continue
+		}
+
+		startS, _ := strconv.ParseFloat(m[2], 64)
+		sweepTerm, _ := strconv.ParseFloat(m[3], 64)
+		mark, _ := strconv.ParseFloat(m[4], 64)
+		markTerm, _ := strconv.ParseFloat(m[5], 64)
+		start := startS * 1000
+		end := start + sweepTerm + mark + markTerm
+		startUnix := ""
+		if o := origin(); o != nil {
+			startUnix = strconv.FormatInt(o.Add(time.Duration(startS*float64(time.Second))).UnixNano(), 10)
+		}
+
+		w.Write([]string{
+			m[1],
+			strconv.FormatFloat(start, 'f', 3, 64),
+			strconv.FormatFloat(end, 'f', 3, 64),
+			strconv.FormatFloat(sweepTerm+markTerm, 'f', 3, 64),
+			strconv.FormatFloat(mark, 'f', 3, 64),
+			m[6], m[7], m[8], m[9],
+			strconv.FormatBool(strings.HasSuffix(line, "(forced)")),
+			startUnix,
+		})
+		cycles++
+	}
+	if err := sc.Err(); err != nil {
+		// Keep draining so the child never blocks writing to stderr
+		io.Copy(os.Stderr, r)
+		return cycles, err
+	}
+	return cycles, nil
+}
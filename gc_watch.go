@@ -0,0 +1,32 @@
+package main
+
+import (
+	"runtime"
+	"runtime/metrics"
+	"sync/atomic"
+)
+
+// gcSentinel is an unreachable object whose finalizer runs once per GC cycle
+type gcSentinel struct{ _ [16]byte }
+
+// watchGCCycles calls fn with the cycle count after each GC cycle completes,
+// until stop is called. fn runs on the finalizer goroutine, so it must not
+// block for long. Unlike ReadMemStats, the cycle count is read without
+// stopping the world. The finalizer re-arms the sentinel it was called
+// with, which the finalizer resurrected, so watching allocates nothing per
+// cycle.
+func watchGCCycles(fn func(numGC uint32)) (stop func()) {
+	var stopped atomic.Bool
+	sample := []metrics.Sample{{Name: "/gc/cycles/total:gc-cycles"}}
+	var rearm func(*gcSentinel)
+	rearm = func(s *gcSentinel) {
+		if stopped.Load() {
+			return
+		}
+		metrics.Read(sample)
+		fn(uint32(sample[0].Value.Uint64()))
+		runtime.SetFinalizer(s, rearm)
+	}
+	runtime.SetFinalizer(&gcSentinel{}, rearm)
+	return func() { stopped.Store(true) }
+}
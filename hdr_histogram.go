@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// The V2 encoding cookies of HdrHistogram's serialized form, with the word
+// size bits marking ZigZag LEB128 counts
+const (
+	hdrEncodingCookie           = 0x1c849303 | 0x10
+	hdrCompressedEncodingCookie = 0x1c849304 | 0x10
+)
+
+// hdrHistogram is an HdrHistogram of integer values with a lowest
+// discernible value of 1, laid out as the reference implementation lays out
+// its counts so it serializes to the form HdrHistogram tools read. Values
+// are kept to hdrSignificantDigits decimal digits of precision.
+type hdrHistogram struct {
+	highest int64 // Highest trackable value; larger values are recorded as it
+
+	subBucketHalfCountMagnitude int
+	subBucketHalfCount          int
+	subBucketMask               int64
+	leadingZeroCountBase        int
+
+	counts []int64
+	total  int64
+	max    int64
+}
+
+// hdrSignificantDigits is the precision of hdrHistogram values, 0.1%
+const hdrSignificantDigits = 3
+
+func newHDRHistogram(highest int64) *hdrHistogram {
+	largestSingleUnit := 2 * int64(math.Pow10(hdrSignificantDigits))
+	subBucketCountMagnitude := bits.Len64(uint64(largestSingleUnit - 1))
+	h := &hdrHistogram{
+		highest:                     highest,
+		subBucketHalfCountMagnitude: subBucketCountMagnitude - 1,
+		subBucketHalfCount:          1 << (subBucketCountMagnitude - 1),
+		subBucketMask:               1<<subBucketCountMagnitude - 1,
+		leadingZeroCountBase:        64 - subBucketCountMagnitude,
+	}
+	buckets := 1
+	for smallestUntrackable := int64(1) << subBucketCountMagnitude; smallestUntrackable <= highest; buckets++ {
+		if smallestUntrackable > math.MaxInt64/2 {
+			buckets++
+			break
+		}
+		smallestUntrackable <<= 1
+	}
+	h.counts = make([]int64, (buckets+1)*h.subBucketHalfCount)
+	return h
+}
+
+// countsIndex returns the index of the count of v
+func (h *hdrHistogram) countsIndex(v int64) int {
+	bucket := h.leadingZeroCountBase - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+	subBucket := int(v >> bucket)
+	return (bucket+1)<<h.subBucketHalfCountMagnitude + subBucket - h.subBucketHalfCount
+}
+
+// Record adds v, clamped to the trackable range
+func (h *hdrHistogram) Record(v int64) {
+	v = min(max(v, 0), h.highest)
+	h.counts[h.countsIndex(v)]++
+	h.total++
+	h.max = max(h.max, v)
+}
+
+// Count returns the number of values recorded
+func (h *hdrHistogram) Count() int64 { return h.total }
+
+// Max returns the largest value recorded
+func (h *hdrHistogram) Max() int64 { return h.max }
+
+// Reset empties the histogram for reuse
+func (h *hdrHistogram) Reset() {
+	clear(h.counts)
+	h.total, h.max = 0, 0
+}
+
+// Encode returns the histogram in HdrHistogram's compressed V2 form: a
+// header and the counts as ZigZag LEB128 words, with runs of zero counts
+// as one negative word, deflated behind a cookie and length
+func (h *hdrHistogram) Encode() ([]byte, error) {
+	var counts []byte
+	limit := 0
+	if h.total > 0 {
+		limit = h.countsIndex(h.max) + 1
+	}
+	for i := 0; i < limit; {
+		count := h.counts[i]
+		i++
+		if count == 0 {
+			zeros := int64(1)
+			for ; i < limit && h.counts[i] == 0; i++ {
+				zeros++
+			}
+			if zeros > 1 {
+				count = -zeros
+			}
+		}
+		counts = appendZigZag(counts, count)
+	}
+
+	var payload []byte
+	payload = binary.BigEndian.AppendUint32(payload, hdrEncodingCookie)
+	payload = binary.BigEndian.AppendUint32(payload, uint32(len(counts)))
+	payload = binary.BigEndian.AppendUint32(payload, 0) // Normalizing index offset
+	payload = binary.BigEndian.AppendUint32(payload, hdrSignificantDigits)
+	payload = binary.BigEndian.AppendUint64(payload, 1) // Lowest discernible value
+	payload = binary.BigEndian.AppendUint64(payload, uint64(h.highest))
+	payload = binary.BigEndian.AppendUint64(payload, math.Float64bits(1)) // Integer to double conversion ratio
+	payload = append(payload, counts...)
+
+	var deflated bytes.Buffer
+	zw := zlib.NewWriter(&deflated)
+	if _, err := zw.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	var out []byte
+	out = binary.BigEndian.AppendUint32(out, hdrCompressedEncodingCookie)
+	out = binary.BigEndian.AppendUint32(out, uint32(deflated.Len()))
+	return append(out, deflated.Bytes()...), nil
+}
+
+// appendZigZag appends v ZigZag-encoded as HdrHistogram's LEB128 variant,
+// whose ninth byte, if reached, holds the top 8 bits whole
+func appendZigZag(b []byte, v int64) []byte {
+	u := uint64(v<<1) ^ uint64(v>>63)
+	for n := 0; n < 8; n++ {
+		if u < 0x80 {
+			return append(b, byte(u))
+		}
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
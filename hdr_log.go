@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+var (
+	hdrOut = flag.String("hdr-out", "",
+		"write the GC pauses and iteration latencies of the measured phase to this file in HdrHistogram log format, "+
+			"a histogram of each per -hdr-interval, for HdrHistogram tools to merge and plot")
+	hdrInterval = flag.Duration("hdr-interval", time.Second,
+		"with -hdr-out, the length of the intervals the histograms cover")
+)
+
+// hdrHighest is the highest value the exported histograms track, an hour
+// in nanoseconds
+const hdrHighest = int64(time.Hour)
+
+// hdrMaxValueRatio converts the nanosecond values to the milliseconds of
+// the log's Interval_Max column, as HistogramLogWriter does by default
+const hdrMaxValueRatio = 1e6
+
+// hdrValue is a value to export with the time it ended on the run clock
+type hdrValue struct {
+	end   time.Duration
+	value time.Duration
+}
+
+// validateHDRLog checks the -hdr-out flags
+func validateHDRLog(mode string) error {
+	if *hdrOut == "" {
+		return nil
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-hdr-out is only supported in benchmark mode")
+	}
+	if *hdrInterval <= 0 {
+		return fmt.Errorf("-hdr-interval must be positive")
+	}
+	return nil
+}
+
+// writeHDRLog writes the iteration latencies of log and the GC pauses of
+// pauses to -hdr-out as an HdrHistogram interval log, tagged "iteration"
+// and "gc-pause". Each value goes in the interval it ended in; intervals
+// are aligned on the run clock, which the log's base time is the origin
+// of, and those without values of a tag are left out for it. Iteration
+// latencies need the iteration log, so -hdr-out turns it on.
+func writeHDRLog(log *iterationLog, pauses *pauseSet) error {
+	if *hdrOut == "" {
+		return nil
+	}
+	var iterations []hdrValue
+	if log != nil {
+		for i, d := range log.Latencies() {
+			iterations = append(iterations, hdrValue{log.ends[i], d})
+		}
+	}
+	var gcPauses []hdrValue
+	durations, ends := pauses.Pauses()
+	for i, d := range durations {
+		gcPauses = append(gcPauses, hdrValue{ends[i], d})
+	}
+
+	f, err := os.Create(*hdrOut)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	origin := runClockOrigin()
+	start := float64(origin.UnixNano()) / 1e9
+	fmt.Fprintf(w, "#[Histogram log format version 1.3]\n")
+	fmt.Fprintf(w, "#[StartTime: %.3f (seconds since epoch), %s]\n", start, origin.Format("Mon Jan 02 15:04:05 MST 2006"))
+	fmt.Fprintf(w, "#[BaseTime: %.3f (seconds since epoch)]\n", start)
+	fmt.Fprintf(w, "#[Values in nanoseconds: iteration latencies tagged iteration, GC cycle pauses tagged gc-pause]\n")
+	fmt.Fprintf(w, "\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n")
+	// Lines are ordered by interval, as log readers expect
+	type line struct {
+		interval time.Duration
+		text     string
+	}
+	var lines []line
+	h := newHDRHistogram(hdrHighest)
+	for _, tagged := range []struct {
+		tag    string
+		values []hdrValue
+	}{
+		{"iteration", iterations},
+		{"gc-pause", gcPauses},
+	} {
+		values := tagged.values
+		sort.Slice(values, func(i, j int) bool { return values[i].end < values[j].end })
+		for len(values) > 0 {
+			interval := values[0].end / *hdrInterval
+			h.Reset()
+			for len(values) > 0 && values[0].end / *hdrInterval == interval {
+				h.Record(int64(values[0].value))
+				values = values[1:]
+			}
+			encoded, err := h.Encode()
+			if err != nil {
+				f.Close()
+				return err
+			}
+			lines = append(lines, line{interval, fmt.Sprintf("Tag=%s,%.3f,%.3f,%.3f,%s\n", tagged.tag,
+				(interval * *hdrInterval).Seconds(), hdrInterval.Seconds(), float64(h.Max())/hdrMaxValueRatio,
+				base64.StdEncoding.EncodeToString(encoded))})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].interval < lines[j].interval })
+	for _, l := range lines {
+		w.WriteString(l.text)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// cgroupUnlimited is the threshold above which a cgroup v1 limit means
+// "no limit" (the kernel reports a page-rounded MaxInt64)
+const cgroupUnlimited = 1 << 62
+
+// memoryLimit is a ceiling the process's memory can run into
+type memoryLimit struct {
+	label string
+	bytes uint64
+	hard  bool // exceeding it gets the process killed rather than collected harder
+}
+
+// memoryLimits returns the limits in effect: GOMEMLIMIT, the cgroup memory
+// limit and physical memory, or the wasm32 address space on WebAssembly
+func memoryLimits() []memoryLimit {
+	var limits []memoryLimit
+	if l := debug.SetMemoryLimit(-1); l != math.MaxInt64 {
+		limits = append(limits, memoryLimit{"GOMEMLIMIT", uint64(l), false})
+	}
+	if onWasm() {
+		return append(limits, memoryLimit{"Wasm", wasmMemoryLimit, true})
+	}
+	if l, ok := cgroupMemoryLimit(); ok {
+		limits = append(limits, memoryLimit{"Cgroup", l, true})
+	}
+	if l, ok := readProcKB("/proc/meminfo", "MemTotal:"); ok {
+		limits = append(limits, memoryLimit{"System", l, true})
+	}
+	return limits
+}
+
+// cgroupMemoryLimit returns this process's cgroup memory limit, trying the
+// cgroup v2 memory.max and then the v1 memory.limit_in_bytes, each first
+// under the process's own cgroup path and then at the mount root (which is
+// where a container sees its own cgroup)
+func cgroupMemoryLimit() (uint64, bool) {
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return 0, false
+	}
+	var candidates []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		switch {
+		case parts[0] == "0" && parts[1] == "":
+			candidates = append(candidates,
+				filepath.Join("/sys/fs/cgroup", parts[2], "memory.max"),
+				"/sys/fs/cgroup/memory.max")
+		case strings.Contains(","+parts[1]+",", ",memory,"):
+			candidates = append(candidates,
+				filepath.Join("/sys/fs/cgroup/memory", parts[2], "memory.limit_in_bytes"),
+				"/sys/fs/cgroup/memory/memory.limit_in_bytes")
+		}
+	}
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		v := strings.TrimSpace(string(data))
+		if v == "max" {
+			return 0, false
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || n >= cgroupUnlimited {
+			return 0, false
+		}
+		return n, true
+	}
+	return 0, false
+}
+
+// peakRSS returns the process's peak resident set size, from the VmHWM
+// high-water mark on Linux. On WebAssembly it is the size of the module's
+// linear memory, which is what the host has to provide.
+func peakRSS() (uint64, bool) {
+	if onWasm() {
+		return wasmLinearMemory(), true
+	}
+	return readProcKB("/proc/self/status", "VmHWM:")
+}
+
+// peakRSSName names what peakRSS measures on this platform
+func peakRSSName() string {
+	if onWasm() {
+		return "Peak Linear Memory"
+	}
+	return "Peak RSS"
+}
+
+// readProcKB reads a "<key> <n> kB" line from a /proc file as bytes
+func readProcKB(path, key string) (uint64, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) >= 2 && fields[0] == key {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			return kb * KB, err == nil
+		}
+	}
+	return 0, false
+}
+
+// peakHeapTracker records the largest heap goal over the GC cycles it
+// watches. The heap grows up to the goal before each cycle, so the largest
+// goal is a close bound on the peak heap.
+type peakHeapTracker struct {
+	peak atomic.Uint64
+	stop func()
+}
+
+func startPeakHeapTracker() *peakHeapTracker {
+	t := &peakHeapTracker{}
+	sample := []metrics.Sample{{Name: "/gc/heap/goal:bytes"}}
+	metrics.Read(sample)
+	t.peak.Store(sample[0].Value.Uint64())
+	t.stop = watchGCCycles(func(uint32) {
+		metrics.Read(sample)
+		t.peak.Store(max(t.peak.Load(), sample[0].Value.Uint64()))
+	})
+	return t
+}
+
+// Stop ends tracking and returns the peak heap goal seen
+func (t *peakHeapTracker) Stop() uint64 {
+	t.stop()
+	return t.peak.Load()
+}
+
+// printHeadroom reports peak memory against every limit in effect and
+// estimates OOM risk from the tightest hard limit
+func printHeadroom(peakHeap uint64) {
+	rss, haveRSS := peakRSS()
+	limits := memoryLimits()
+	if !haveRSS && len(limits) == 0 {
+		return
+	}
+
+	printSection("Memory Headroom")
+	if haveRSS {
+		printMetric(peakRSSName(), "%s", formatBytes(rss))
+	}
+	printMetric("Peak Heap Goal", "%s", formatBytes(peakHeap))
+	if !haveRSS {
+		return
+	}
+
+	tightest := 0.0
+	for _, l := range limits {
+		used := float64(rss) / float64(l.bytes) * 100
+		headroom := "none"
+		if rss < l.bytes {
+			headroom = formatBytes(l.bytes - rss)
+		}
+		printMetric(l.label+" Limit", "%s", formatBytes(l.bytes))
+		printMetric(l.label+" Headroom", "%s (%.1f%% used)", headroom, used)
+		if l.hard {
+			tightest = max(tightest, used)
+		}
+	}
+
+	risk := "low"
+	switch {
+	case tightest >= 90:
+		risk = "high"
+	case tightest >= 70:
+		risk = "moderate"
+	}
+	printMetric("OOM Risk", "%s (%.1f%% of the tightest hard limit)", risk, tightest)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var colorMode = flag.String("color", "auto",
+	"colorize report lines that breach a -threshold: auto (when stdout is a terminal), always or never")
+
+// thresholds holds the -threshold flags
+var thresholds thresholdList
+
+func init() {
+	flag.Var(&thresholds, "threshold",
+		`highlight a metric when it crosses a limit, e.g. "Average GC Pause>1ms" or "GC CPU Fraction>10%" (repeatable)`)
+}
+
+// ANSI escape sequences used for highlighting
+const (
+	ansiRed   = "\x1b[31m"
+	ansiBold  = "\x1b[1m"
+	ansiReset = "\x1b[0m"
+)
+
+// metricKind is the unit family of a parsed metric value; values are only
+// compared with limits of the same kind
+type metricKind int
+
+const (
+	kindNumber metricKind = iota
+	kindDuration
+	kindBytes
+	kindPercent
+)
+
+// threshold is a limit on one named report metric
+type threshold struct {
+	metric string
+	above  bool // Breached when the value is above (rather than below) the limit
+	limit  float64
+	kind   metricKind
+	text   string
+}
+
+// thresholdList implements flag.Value for repeated -threshold flags
+type thresholdList []threshold
+
+func (l *thresholdList) String() string {
+	parts := make([]string, len(*l))
+	for i, t := range *l {
+		parts[i] = t.text
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (l *thresholdList) Set(s string) error {
+	i := strings.IndexAny(s, "<>")
+	if i <= 0 {
+		return fmt.Errorf("want <metric>><limit> or <metric><<limit>, got %q", s)
+	}
+	limit, kind, ok := parseMetricValue(s[i+1:])
+	if !ok {
+		return fmt.Errorf("invalid limit %q", s[i+1:])
+	}
+	*l = append(*l, threshold{
+		metric: strings.TrimSpace(s[:i]),
+		above:  s[i] == '>',
+		limit:  limit,
+		kind:   kind,
+		text:   s,
+	})
+	return nil
+}
+
+// byteUnits maps size unit suffixes to their multiples, longest first
+var byteUnits = []struct {
+	suffix string
+	scale  float64
+}{{"GB", GB}, {"MB", MB}, {"KB", KB}, {"B", 1}}
+
+// parseMetricValue parses the leading value of a formatted report value:
+// a duration ("1.5ms"), a size ("12.00 MB" or "12MB"), a percentage
+// ("4.2%") or a plain number. Durations are returned in nanoseconds and
+// sizes in bytes.
+func parseMetricValue(s string) (float64, metricKind, bool) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, kindNumber, false
+	}
+	v := fields[0]
+	if len(fields) > 1 {
+		for _, u := range byteUnits {
+			if fields[1] == u.suffix {
+				v += u.suffix
+			}
+		}
+	}
+
+	if strings.HasSuffix(v, "%") {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		return n, kindPercent, err == nil
+	}
+	for _, u := range byteUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(v, u.suffix), 64)
+			return n * u.scale, kindBytes, err == nil
+		}
+	}
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		return n, kindNumber, true
+	}
+	if d, err := time.ParseDuration(v); err == nil {
+		return float64(d), kindDuration, true
+	}
+	return 0, kindNumber, false
+}
+
+// colorEnabled reports whether highlighted lines should be colorized
+func colorEnabled() bool {
+	switch *colorMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// breaches records every threshold breached by a printed metric, for the
+// summary at the end of the report
+var breaches []string
+
+// printMetric prints a "name: value" report line. If the value breaches a
+// -threshold set for the metric, the line is highlighted and the breach is
+// remembered for printBreaches. Every metric is also recorded for structured
+// output.
+func printMetric(name, format string, args ...any) {
+	value := fmt.Sprintf(format, args...)
+	line := name + ": " + value
+	recordMetric(name, value)
+
+	breached := false
+	if v, kind, ok := parseMetricValue(value); ok {
+		for _, t := range thresholds {
+			if !strings.EqualFold(t.metric, name) || t.kind != kind {
+				continue
+			}
+			if (t.above && v > t.limit) || (!t.above && v < t.limit) {
+				breached = true
+				breaches = append(breaches, fmt.Sprintf("%s (limit %s)", line, t.text))
+			}
+		}
+	}
+
+	if breached && colorEnabled() {
+		line = ansiBold + ansiRed + line + ansiReset
+	}
+	fmt.Println(line)
+}
+
+// printBreaches summarizes threshold breaches, so they stand out even when
+// the output isn't colorized
+func printBreaches() {
+	if len(thresholds) == 0 {
+		return
+	}
+	fmt.Println()
+	printSection("Thresholds")
+	if len(breaches) == 0 {
+		fmt.Printf("All %d thresholds met\n", len(thresholds))
+		return
+	}
+	for _, b := range breaches {
+		printMetric("BREACHED", "%s", b)
+	}
+}
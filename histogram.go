@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// histSubBits sets the histogram precision: each power-of-two range of
+// nanoseconds is split into 2^histSubBits linear sub-buckets, bounding the
+// relative error of reported values to about 3%.
+const (
+	histSubBits    = 5
+	histSubBuckets = 1 << histSubBits
+	histBuckets    = (64 - histSubBits) * histSubBuckets
+)
+
+// latencyHistogram is a log-linear histogram of durations. Recording is a
+// few arithmetic operations and never allocates, so it is cheap enough to
+// record every iteration. It is not safe for concurrent use.
+type latencyHistogram struct {
+	counts [histBuckets]uint64
+	count  uint64
+	sum    time.Duration
+	min    time.Duration
+	max    time.Duration
+}
+
+// histBucket returns the bucket index holding v nanoseconds
+func histBucket(v uint64) int {
+	if v < histSubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - 1 - histSubBits
+	return (shift+1)*histSubBuckets + int(v>>shift) - histSubBuckets
+}
+
+// histBucketUpper returns the largest value, in nanoseconds, held by bucket idx
+func histBucketUpper(idx int) uint64 {
+	if idx < histSubBuckets {
+		return uint64(idx)
+	}
+	shift := idx/histSubBuckets - 1
+	mant := uint64(idx%histSubBuckets + histSubBuckets)
+	return (mant+1)<<shift - 1
+}
+
+// Record adds d to the histogram
+func (h *latencyHistogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.counts[histBucket(uint64(d))]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds all values recorded in other to h
+func (h *latencyHistogram) Merge(other *latencyHistogram) {
+	if other.count == 0 {
+		return
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of recorded values
+func (h *latencyHistogram) Count() uint64 { return h.count }
+
+// Min returns the smallest recorded value
+func (h *latencyHistogram) Min() time.Duration { return h.min }
+
+// Max returns the largest recorded value
+func (h *latencyHistogram) Max() time.Duration { return h.max }
+
+// Mean returns the arithmetic mean of the recorded values
+func (h *latencyHistogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns an upper bound for the p-th percentile (0-100) of the
+// recorded values, accurate to the histogram's bucket precision
+func (h *latencyHistogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen >= rank {
+			v := time.Duration(histBucketUpper(i))
+			if v > h.max {
+				v = h.max
+			}
+			return v
+		}
+	}
+	return h.max
+}
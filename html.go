@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"runtime"
+	"time"
+)
+
+var htmlReport = flag.String("html", "",
+	"write an HTML report with the results and, in benchmark mode, an iteration latency heatmap to this file")
+
+// Heatmap geometry: at most heatmapColumns columns of iterations, each
+// heatmapCellWidth pixels wide, by heatmapRows latency buckets
+const (
+	heatmapColumns    = 200
+	heatmapRows       = 32
+	heatmapCellWidth  = 4
+	heatmapCellHeight = 8
+	heatmapMargin     = 70
+)
+
+// heatCell is one non-empty cell of the heatmap, positioned in pixels
+type heatCell struct {
+	X, Y, W, H int
+	Opacity    float64
+	Title      string
+}
+
+// heatLabel is an axis label, positioned in pixels
+type heatLabel struct {
+	X, Y int
+	Text string
+}
+
+// heatmap is iteration index (x) against latency bucket (y), each cell
+// shaded by how many iterations of its column fell in its bucket. GC work
+// shows up as bands and stripes that percentiles flatten out.
+type heatmap struct {
+	Width, Height  int
+	PlotX, PlotY   int
+	PlotW, PlotH   int
+	Iterations     int
+	ItersPerColumn int
+	Cells          []heatCell
+	XLabels        []heatLabel
+	YLabels        []heatLabel
+	MinLat, MaxLat string
+	MaxCount       int
+}
+
+// buildHeatmap bins latencies in completion order into columns and
+// logarithmically spaced latency rows between the fastest and slowest
+// iteration, or returns nil if there is nothing to show
+func buildHeatmap(latencies []time.Duration) *heatmap {
+	if len(latencies) == 0 {
+		return nil
+	}
+	lo, hi := latencies[0], latencies[0]
+	for _, d := range latencies {
+		lo, hi = min(lo, d), max(hi, d)
+	}
+	lo = max(lo, 1)
+	hi = max(hi, lo+1)
+
+	perColumn := (len(latencies) + heatmapColumns - 1) / heatmapColumns
+	columns := (len(latencies) + perColumn - 1) / perColumn
+	ratio := math.Log(float64(hi) / float64(lo))
+	row := func(d time.Duration) int {
+		r := int(math.Log(float64(max(d, lo))/float64(lo)) / ratio * heatmapRows)
+		return min(r, heatmapRows-1)
+	}
+	bound := func(r int) time.Duration {
+		return time.Duration(float64(lo) * math.Exp(ratio*float64(r)/heatmapRows))
+	}
+
+	counts := make([][heatmapRows]int, columns)
+	maxCount := 0
+	for i, d := range latencies {
+		c := &counts[i/perColumn][row(d)]
+		*c++
+		maxCount = max(maxCount, *c)
+	}
+
+	h := &heatmap{
+		PlotX:          heatmapMargin,
+		PlotY:          10,
+		PlotW:          columns * heatmapCellWidth,
+		PlotH:          heatmapRows * heatmapCellHeight,
+		Iterations:     len(latencies),
+		ItersPerColumn: perColumn,
+		MinLat:         formatDuration(lo),
+		MaxLat:         formatDuration(hi),
+		MaxCount:       maxCount,
+	}
+	h.Width = h.PlotX + h.PlotW + 20
+	h.Height = h.PlotY + h.PlotH + 40
+
+	for col := range counts {
+		for r, n := range counts[col] {
+			if n == 0 {
+				continue
+			}
+			first := col * perColumn
+			last := min(first+perColumn, len(latencies)) - 1
+			h.Cells = append(h.Cells, heatCell{
+				X:       h.PlotX + col*heatmapCellWidth,
+				Y:       h.PlotY + (heatmapRows-1-r)*heatmapCellHeight,
+				W:       heatmapCellWidth,
+				H:       heatmapCellHeight,
+				Opacity: 0.15 + 0.85*float64(n)/float64(maxCount),
+				Title: fmt.Sprintf("iterations %d-%d: %d between %s and %s",
+					first, last, n, formatDuration(bound(r)), formatDuration(bound(r+1))),
+			})
+		}
+	}
+	for r := 0; r <= heatmapRows; r += heatmapRows / 4 {
+		h.YLabels = append(h.YLabels, heatLabel{
+			X:    h.PlotX - 4,
+			Y:    h.PlotY + (heatmapRows-r)*heatmapCellHeight + 4,
+			Text: formatDuration(bound(r)),
+		})
+	}
+	for col := 0; col <= columns; col += max(columns/5, 1) {
+		h.XLabels = append(h.XLabels, heatLabel{
+			X:    h.PlotX + col*heatmapCellWidth,
+			Y:    h.PlotY + h.PlotH + 16,
+			Text: fmt.Sprint(col * perColumn),
+		})
+	}
+	return h
+}
+
+// htmlSection is a report section with its metrics
+type htmlSection struct {
+	Title   string
+	Metrics []reportMetric
+}
+
+// htmlDocument is the data of the HTML report template
+type htmlDocument struct {
+	GoVersion  string
+	Platform   string
+	Mode       string
+	Workload   string
+	Heatmap    *heatmap
+	Sections   []htmlSection
+	Assertions []jsonAssertion
+	Provenance provenanceRecord
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>GC Benchmark: {{.Workload}} ({{.GoVersion}})</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+td, th { padding: 2px 12px; text-align: left; border-bottom: 1px solid #ddd; }
+.fail { color: #c0392b; font-weight: bold; }
+svg text { font-size: 11px; }
+</style>
+</head>
+<body>
+<h1>GC Benchmark: {{.Workload}}</h1>
+<p>{{.GoVersion}} on {{.Platform}}, {{.Mode}} mode. Provenance <code>{{.Provenance.Hash}}</code>.</p>
+{{with .Heatmap}}
+<h2>Iteration Latency Heatmap</h2>
+<p>{{.Iterations}} measured iterations in completion order, {{.ItersPerColumn}} per column, against latency from {{.MinLat}} to {{.MaxLat}} on a log scale. Darker cells hold more iterations (at most {{.MaxCount}}); horizontal bands and vertical stripes away from the main band are usually GC assists and pauses.</p>
+<svg width="{{.Width}}" height="{{.Height}}" xmlns="http://www.w3.org/2000/svg">
+<rect x="{{.PlotX}}" y="{{.PlotY}}" width="{{.PlotW}}" height="{{.PlotH}}" fill="#f7f7f7" stroke="#999"/>
+{{range .Cells}}<rect x="{{.X}}" y="{{.Y}}" width="{{.W}}" height="{{.H}}" fill="#1f4e99" fill-opacity="{{printf "%.2f" .Opacity}}"><title>{{.Title}}</title></rect>
+{{end}}{{range .YLabels}}<text x="{{.X}}" y="{{.Y}}" text-anchor="end">{{.Text}}</text>
+{{end}}{{range .XLabels}}<text x="{{.X}}" y="{{.Y}}" text-anchor="middle">{{.Text}}</text>
+{{end}}<text x="{{.PlotX}}" y="{{.Height}}" dy="-4">iteration</text>
+</svg>
+{{end}}
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+<table>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+{{with .Assertions}}
+<h2>Assertions</h2>
+<table>
+<tr><th>Assertion</th><th>Actual</th><th>Limit</th><th>Result</th></tr>
+{{range .}}<tr><td>{{.Name}}</td><td>{{.Actual}}</td><td>{{.Limit}}</td>{{if .Passed}}<td>pass</td>{{else}}<td class="fail">FAIL</td>{{end}}</tr>
+{{end}}</table>
+{{end}}
+<h2>Provenance</h2>
+<table>
+<tr><td>Configuration</td><td><code>{{.Provenance.Config}}</code></td></tr>
+<tr><td>Build</td><td><code>{{.Provenance.Build}}</code></td></tr>
+<tr><td>Environment</td><td><code>{{.Provenance.Environment}}</code></td></tr>
+</table>
+</body>
+</html>
+`))
+
+// writeHTMLReport writes the HTML report, if one was requested
+func writeHTMLReport(mode, workload string) error {
+	if *htmlReport == "" {
+		return nil
+	}
+
+	doc := htmlDocument{
+		GoVersion:  runtime.Version(),
+		Platform:   runtime.GOOS + "/" + runtime.GOARCH,
+		Mode:       mode,
+		Workload:   workload,
+		Provenance: currentProvenance(),
+	}
+	if measuredIterations != nil {
+		doc.Heatmap = buildHeatmap(measuredIterations.Latencies())
+	}
+	for _, m := range reportMetrics {
+		if n := len(doc.Sections); n == 0 || doc.Sections[n-1].Title != m.Section {
+			doc.Sections = append(doc.Sections, htmlSection{Title: m.Section})
+		}
+		s := &doc.Sections[len(doc.Sections)-1]
+		s.Metrics = append(s.Metrics, m)
+	}
+	for _, a := range assertionResults {
+		doc.Assertions = append(doc.Assertions, jsonAssertion{a.name, a.actual, a.limit, a.passed})
+	}
+
+	f, err := os.Create(*htmlReport)
+	if err != nil {
+		return err
+	}
+	if err := htmlTemplate.Execute(f, doc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ipcEnv tells a child benchmark process where to send protocol messages to
+// its coordinator: "fd:N" for an inherited file descriptor, normally a pipe,
+// or "file:PATH" where a descriptor can't be inherited
+const ipcEnv = "GREEN_TEA_BENCHMARK_IPC"
+
+// ipcVersion is the version of the coordinator protocol, carried by every
+// message. Message types and fields are only ever added within a version;
+// it changes when a field's meaning does.
+const ipcVersion = 1
+
+// Message types of the coordinator protocol
+const (
+	// ipcHello is the first message, identifying the child
+	ipcHello = "hello"
+	// ipcProgress marks the beginning or end of a phase of the run
+	ipcProgress = "progress"
+	// ipcResult carries the run's results once it completes
+	ipcResult = "result"
+)
+
+// ipcMessage is one message of the coordinator protocol, sent as a line of
+// JSON. Which fields are set depends on the type.
+type ipcMessage struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+
+	// hello
+	PID         int        `json:"pid,omitempty"`
+	GoVersion   string     `json:"go_version,omitempty"`
+	Mode        string     `json:"mode,omitempty"`
+	Workload    string     `json:"workload,omitempty"`
+	ClockOrigin *time.Time `json:"clock_origin,omitempty"` // Wall clock time of the run clock's origin
+
+	// progress
+	Phase   string `json:"phase,omitempty"`
+	Begin   bool   `json:"begin,omitempty"`
+	ClockNs int64  `json:"clock_ns,omitempty"` // When, on the run clock
+
+	// result
+	Results    *runResults       `json:"results,omitempty"`
+	Metrics    []reportMetric    `json:"metrics,omitempty"`
+	Assertions []jsonAssertion   `json:"assertions,omitempty"`
+	Passed     bool              `json:"passed,omitempty"`
+	Provenance *provenanceRecord `json:"provenance,omitempty"`
+}
+
+var (
+	// ipcMu guards ipcOut; messages can be sent from several goroutines
+	ipcMu sync.Mutex
+	// ipcOut is the channel to the coordinator, or nil when there is none
+	ipcOut *os.File
+)
+
+// openIPC opens the channel to the coordinator if this process has one
+func openIPC() error {
+	where := os.Getenv(ipcEnv)
+	if where == "" {
+		return nil
+	}
+	kind, arg, _ := strings.Cut(where, ":")
+	switch kind {
+	case "fd":
+		fd, err := strconv.Atoi(arg)
+		if err != nil || fd < 3 {
+			return fmt.Errorf("%s: invalid file descriptor %q", ipcEnv, arg)
+		}
+		ipcOut = os.NewFile(uintptr(fd), "ipc")
+	case "file":
+		f, err := os.OpenFile(arg, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return fmt.Errorf("%s: %v", ipcEnv, err)
+		}
+		ipcOut = f
+	default:
+		return fmt.Errorf("%s: want fd:N or file:PATH, got %q", ipcEnv, where)
+	}
+	return nil
+}
+
+// sendIPC sends a message to the coordinator, if there is one. Each message
+// is written whole, so none is lost when the process exits right after.
+func sendIPC(msg ipcMessage) {
+	ipcMu.Lock()
+	defer ipcMu.Unlock()
+	if ipcOut == nil {
+		return
+	}
+	msg.Version = ipcVersion
+	line, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "encoding %s message: %v\n", msg.Type, err)
+		return
+	}
+	if _, err := ipcOut.Write(append(line, '\n')); err != nil {
+		// The coordinator went away; stop talking to it
+		fmt.Fprintf(os.Stderr, "sending %s message: %v\n", msg.Type, err)
+		ipcOut = nil
+	}
+}
+
+// ipcProgressLine is the buffer progress messages are encoded into, reused
+// so marking a phase inside the measured window allocates nothing. ipcMu
+// guards it.
+var ipcProgressLine = make([]byte, 0, 256)
+
+// sendIPCProgress sends a progress message for the beginning or end of a
+// phase. It encodes the message by hand, as json.Marshal would, since
+// json.Marshal allocates.
+func sendIPCProgress(phase string, begin bool, clock time.Duration) {
+	ipcMu.Lock()
+	defer ipcMu.Unlock()
+	if ipcOut == nil {
+		return
+	}
+	b := append(ipcProgressLine[:0], `{"v":`...)
+	b = strconv.AppendInt(b, ipcVersion, 10)
+	b = append(b, `,"type":"`+ipcProgress+`"`...)
+	if phase != "" {
+		b = append(b, `,"phase":`...)
+		b = appendJSONString(b, phase)
+	}
+	if begin {
+		b = append(b, `,"begin":true`...)
+	}
+	if clock != 0 {
+		b = append(b, `,"clock_ns":`...)
+		b = strconv.AppendInt(b, int64(clock), 10)
+	}
+	b = append(b, "}\n"...)
+	ipcProgressLine = b
+	if _, err := ipcOut.Write(b); err != nil {
+		fmt.Fprintf(os.Stderr, "sending %s message: %v\n", ipcProgress, err)
+		ipcOut = nil
+	}
+}
+
+// appendJSONString appends s to b as a JSON string, escaping quotes,
+// backslashes and control characters
+func appendJSONString(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			b = append(b, '\\', c)
+		case c < 0x20:
+			b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			b = append(b, c)
+		}
+	}
+	return append(b, '"')
+}
+
+// sendIPCHello identifies this process to the coordinator
+func sendIPCHello(mode, workload string) {
+	origin := runClockOrigin()
+	sendIPC(ipcMessage{
+		Type:        ipcHello,
+		PID:         os.Getpid(),
+		GoVersion:   runtime.Version(),
+		Mode:        mode,
+		Workload:    workload,
+		ClockOrigin: &origin,
+	})
+}
+
+// sendIPCResult sends the results of a completed run to the coordinator.
+// results is nil outside benchmark mode.
+func sendIPCResult(results *runResults, passed bool) {
+	if ipcOut == nil {
+		return
+	}
+	msg := ipcMessage{Type: ipcResult, Results: results, Metrics: reportMetrics, Passed: passed}
+	for _, a := range assertionResults {
+		msg.Assertions = append(msg.Assertions, jsonAssertion{a.name, a.actual, a.limit, a.passed})
+	}
+	p := currentProvenance()
+	msg.Provenance = &p
+	sendIPC(msg)
+}
+
+// readIPC decodes protocol messages from r, calling handle for each, until
+// r is exhausted. Messages of another protocol version are an error.
+func readIPC(r io.Reader, handle func(ipcMessage)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64<<20) // Result messages carry the whole report
+	for sc.Scan() {
+		var msg ipcMessage
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			return fmt.Errorf("decoding child message: %v", err)
+		}
+		if msg.Version != ipcVersion {
+			return fmt.Errorf("child speaks protocol version %d, want %d", msg.Version, ipcVersion)
+		}
+		handle(msg)
+	}
+	return sc.Err()
+}
+
+// runChild runs cmd, a benchmark child process, with a protocol channel to
+// it, calling onMessage (if not nil) for each message as it arrives. It
+// returns the child's result message, or nil if the child didn't complete a
+// run, together with the error from running it. The channel is a pipe
+// where the platform can pass one to a child, and a temporary file read
+// after the child exits otherwise.
+func runChild(cmd *exec.Cmd, onMessage func(ipcMessage)) (*ipcMessage, error) {
+	var result *ipcMessage
+	handle := func(msg ipcMessage) {
+		if msg.Type == ipcResult {
+			result = &msg
+		}
+		if onMessage != nil {
+			onMessage(msg)
+		}
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// The child gets its own channel, never this process's
+	env := cmd.Env[:0:0]
+	for _, kv := range cmd.Env {
+		if !strings.HasPrefix(kv, ipcEnv+"=") {
+			env = append(env, kv)
+		}
+	}
+	cmd.Env = env
+
+	if runtime.GOOS == "windows" {
+		f, err := os.CreateTemp("", "green-tea-benchmark-ipc-*.jsonl")
+		if err != nil {
+			return nil, err
+		}
+		f.Close()
+		defer os.Remove(f.Name())
+		cmd.Env = append(cmd.Env, ipcEnv+"=file:"+f.Name())
+		runErr := cmd.Run()
+		f, err = os.Open(f.Name())
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if err := readIPC(f, handle); err != nil {
+			return nil, err
+		}
+		return result, runErr
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	cmd.ExtraFiles = append(cmd.ExtraFiles, w)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=fd:%d", ipcEnv, 2+len(cmd.ExtraFiles)))
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	// Only the child holds the write end now, so reading ends when it exits
+	w.Close()
+	readErr := readIPC(r, handle)
+	if readErr != nil {
+		// Keep the child from blocking on a full pipe
+		io.Copy(io.Discard, r)
+	}
+	runErr := cmd.Wait()
+	if readErr != nil {
+		return nil, readErr
+	}
+	return result, runErr
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+var iterationsOut = flag.String("iterations-out", "",
+	"write every measured iteration's latency and GC phase (off, mark or marktermination) to this file as CSV")
+
+// iterationLog records the latency of every measured iteration, when it
+// ended on the run clock and the GC phase it ran in, in completion order,
+// for views of latency over time such as the HTML report's heatmap.
+// Recording is safe for concurrent use and doesn't allocate; iterations
+// beyond its capacity are dropped.
+type iterationLog struct {
+	latencies []time.Duration
+	ends      []time.Duration
+	phases    []gcPhase // nil unless the log's GC phases are wanted
+	next      atomic.Int64
+}
+
+// measuredIterations is the log of the measured phase, or nil if nothing
+// needs per-iteration latencies
+var measuredIterations *iterationLog
+
+// wantIterationLog reports whether any output needs per-iteration records
+func wantIterationLog() bool {
+	return *htmlReport != "" || *iterationsOut != "" || *hdrOut != ""
+}
+
+// wantIterationPhases reports whether any output needs the GC phase of
+// every iteration. Only -iterations-out writes them; the HTML report and
+// the HDR log need the latencies alone.
+func wantIterationPhases() bool {
+	return *iterationsOut != ""
+}
+
+// newIterationLog returns a log of capacity iterations, which records
+// their GC phases if phased is set
+func newIterationLog(capacity int, phased bool) *iterationLog {
+	l := &iterationLog{
+		latencies: make([]time.Duration, capacity),
+		ends:      make([]time.Duration, capacity),
+	}
+	if phased {
+		l.phases = make([]gcPhase, capacity)
+	}
+	return l
+}
+
+// wantPhases reports whether the log's iterations need their GC phase,
+// which takes reading the runtime's metrics around each of them
+func (l *iterationLog) wantPhases() bool {
+	return l != nil && l.phases != nil
+}
+
+// Record logs the start, latency and GC phase of the next iteration to
+// complete; phase is ignored unless the log records phases
+func (l *iterationLog) Record(start time.Time, d time.Duration, phase gcPhase) {
+	if i := l.next.Add(1) - 1; i < int64(len(l.latencies)) {
+		l.latencies[i] = d
+		l.ends[i] = runClock(start) + d
+		if l.phases != nil {
+			l.phases[i] = phase
+		}
+	}
+}
+
+// Len returns the number of iterations recorded
+func (l *iterationLog) Len() int {
+	return int(min(l.next.Load(), int64(len(l.latencies))))
+}
+
+// Latencies returns the recorded latencies in completion order
+func (l *iterationLog) Latencies() []time.Duration {
+	return l.latencies[:l.Len()]
+}
+
+// Phases returns the recorded GC phases in completion order, or nil if
+// the log doesn't record phases
+func (l *iterationLog) Phases() []gcPhase {
+	if l.phases == nil {
+		return nil
+	}
+	return l.phases[:l.Len()]
+}
+
+// Report prints the iteration latency distribution in each GC phase and how
+// much slower iterations are while the collector marks. The log must
+// record phases.
+func (l *iterationLog) Report() {
+	hists := map[gcPhase]*latencyHistogram{}
+	for _, p := range gcPhases {
+		hists[p] = new(latencyHistogram)
+	}
+	phases := l.Phases()
+	for i, d := range l.Latencies() {
+		hists[phases[i]].Record(d)
+	}
+
+	printSection("Iteration Latency by GC Phase")
+	for _, p := range gcPhases {
+		h := hists[p]
+		printMetric(fmt.Sprintf("GC %s Iterations", p), "%d", h.Count())
+		if h.Count() > 0 {
+			printMetric(fmt.Sprintf("GC %s p50", p), "%s", formatDuration(h.Percentile(50)))
+			printMetric(fmt.Sprintf("GC %s p99", p), "%s", formatDuration(h.Percentile(99)))
+		}
+	}
+	off, mark := hists[gcPhaseOff], hists[gcPhaseMark]
+	if off.Count() > 0 && mark.Count() > 0 && off.Percentile(50) > 0 {
+		printMetric("Mark Slowdown (p50)", "%.2fx", float64(mark.Percentile(50))/float64(off.Percentile(50)))
+	}
+}
+
+// WriteFile writes the records to -iterations-out as CSV, with each
+// iteration's end on the run clock and as an absolute time
+func (l *iterationLog) WriteFile() error {
+	if *iterationsOut == "" {
+		return nil
+	}
+	f, err := os.Create(*iterationsOut)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"iteration", "latency_ns", "gc_phase", "end_ms", "end_unix_ns"})
+	phases := l.Phases()
+	origin := runClockOrigin()
+	for i, d := range l.Latencies() {
+		w.Write([]string{
+			strconv.Itoa(i), strconv.FormatInt(int64(d), 10), phases[i].String(),
+			runClockMillis(l.ends[i]), strconv.FormatInt(origin.Add(l.ends[i]).UnixNano(), 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
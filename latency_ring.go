@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	latencyRingSize = flag.Int("latency-ring", 0,
+		"benchmark mode: keep the wall time of the most recent this many measured iterations in a preallocated ring "+
+			"and report their latency percentiles (0 disables)")
+	slowestIterations = flag.Int("slowest", 0,
+		"with -latency-ring, list the N slowest iterations in the ring with when they ended and the GC phase they overlapped "+
+			"and attribute the latency tail to GC; each iteration then reads the GC state before and after it (0 disables)")
+)
+
+// latencyRing keeps the latencies of the most recent iterations, however
+// many workers run them, with when each ended on the run clock and, with
+// -slowest, the GC phase it overlapped. Recording is safe for concurrent
+// use and doesn't allocate; once the ring is full, each iteration
+// overwrites the oldest.
+type latencyRing struct {
+	latencies []time.Duration
+	ends      []time.Duration
+	phases    []gcPhase
+	next      atomic.Int64
+}
+
+// iterationLatencies is the ring of the measured phase, or nil if
+// -latency-ring is 0
+var iterationLatencies *latencyRing
+
+// newLatencyRing returns a ring of size latencies, or nil if size isn't
+// positive
+func newLatencyRing(size int) *latencyRing {
+	if size <= 0 {
+		return nil
+	}
+	return &latencyRing{
+		latencies: make([]time.Duration, size),
+		ends:      make([]time.Duration, size),
+		phases:    make([]gcPhase, size),
+	}
+}
+
+// wantPhases reports whether the ring's iterations need their GC phase,
+// which takes reading the runtime's metrics around each of them
+func (r *latencyRing) wantPhases() bool {
+	return r != nil && *slowestIterations > 0
+}
+
+// Record adds an iteration that started at start, took d and overlapped
+// phase
+func (r *latencyRing) Record(start time.Time, d time.Duration, phase gcPhase) {
+	i := (r.next.Add(1) - 1) % int64(len(r.latencies))
+	r.latencies[i] = d
+	r.ends[i] = runClock(start) + d
+	r.phases[i] = phase
+}
+
+// kept returns the number of iterations the ring holds
+func (r *latencyRing) kept() int {
+	return int(min(r.next.Load(), int64(len(r.latencies))))
+}
+
+// Report prints the latency percentiles of the iterations in the ring. An
+// iteration that absorbs a GC assist or a pause runs long, so the tail
+// shows the hiccups the mutator saw, which GC pause totals can't.
+func (r *latencyRing) Report() {
+	recorded := r.next.Load()
+	kept := append([]time.Duration(nil), r.latencies[:r.kept()]...)
+	sortDurations(kept)
+
+	printSection("Iteration Latency")
+	if int64(len(kept)) < recorded {
+		printMetric("Iterations", "%d (the last %d kept)", recorded, len(kept))
+	} else {
+		printMetric("Iterations", "%d", recorded)
+	}
+	if len(kept) == 0 {
+		return
+	}
+	p50 := durationPercentile(kept, 50)
+	printMetric("Mean", "%s", formatDuration(meanDuration(kept)))
+	printMetric("p50", "%s", formatDuration(p50))
+	printMetric("p90", "%s", formatDuration(durationPercentile(kept, 90)))
+	printMetric("p99", "%s", formatDuration(durationPercentile(kept, 99)))
+	printMetric("p99.9", "%s", formatDuration(durationPercentile(kept, 99.9)))
+	printMetric("Max", "%s", formatDuration(kept[len(kept)-1]))
+	if p50 > 0 {
+		printMetric("Max / p50", "%.2fx", float64(kept[len(kept)-1])/float64(p50))
+	}
+}
+
+// ReportSlowest lists the -slowest slowest iterations in the ring, slowest
+// first, with when each ended on the run clock and the GC phase it
+// overlapped, so latency spikes can be matched with collector activity:
+// an iteration that overlapped marking may have been drafted into
+// assists, and one that overlapped mark termination absorbed its pause.
+func (r *latencyRing) ReportSlowest() {
+	if *slowestIterations <= 0 {
+		return
+	}
+	order := make([]int, r.kept())
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return r.latencies[order[a]] > r.latencies[order[b]] })
+	order = order[:min(len(order), *slowestIterations)]
+
+	printSection("Slowest Iterations")
+	if len(order) == 0 {
+		fmt.Println("No iterations")
+		return
+	}
+	overlapped := 0
+	for n, i := range order {
+		gc := "no GC"
+		if r.phases[i] != gcPhaseOff {
+			gc = "GC " + r.phases[i].String()
+			overlapped++
+		}
+		printMetric(fmt.Sprintf("Slowest %d", n+1), "%s at %s (%s)",
+			formatDuration(r.latencies[i]), formatDuration(r.ends[i]), gc)
+	}
+	printMetric("Slowest Overlapping GC", "%d of %d", overlapped, len(order))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+var (
+	liveSetSize     byteSize
+	liveSetWorkload = flag.String("live-set-workload", "",
+		"workload whose allocations make up the -live-set (default: the benchmarked workload)")
+)
+
+func init() {
+	flag.Var(&liveSetSize, "live-set",
+		"before warmup, retain objects allocated by -live-set-workload until the live heap has grown by this size, e.g. 256MB (0 disables)")
+}
+
+// liveSetMaxStalls is how many batches in a row may fail to grow the live
+// heap by a thousandth of the target before the builder gives up on the
+// workload
+const liveSetMaxStalls = 3
+
+// liveSet is a pre-existing live heap for the benchmark to run on top of,
+// made only of objects from one workload's allocation sites
+type liveSet struct {
+	workload   string
+	target     uint64
+	built      uint64
+	iterations int
+	retained   []any
+	instances  []Workload
+	elapsed    time.Duration
+}
+
+// readLiveHeap collects garbage and returns the live heap in bytes
+func readLiveHeap() uint64 {
+	runtime.GC()
+	sample := []metrics.Sample{{Name: "/gc/heap/live:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// buildLiveSet runs iterations of fresh instances of the named workload,
+// retaining every result passed to their Sink and the instances themselves
+// (with whatever state they hold), until the live heap has grown by target.
+// It runs in batches sized from the growth seen so far, measuring the live
+// heap after a GC at the end of each.
+func buildLiveSet(name string, target uint64) (*liveSet, error) {
+	ls := &liveSet{workload: name, target: target}
+	start := time.Now()
+	baseline := readLiveHeap()
+
+	batch, stalls := 1, 0
+	for ls.built < target {
+		ws, err := newWorkloads(name, 1)
+		if err != nil {
+			return nil, err
+		}
+		w := ws[0]
+		ls.retained = append(ls.retained, w)
+		ls.instances = append(ls.instances, w)
+		sink, _ := w.(sinkOwner)
+		for i := 0; i < batch; i++ {
+			w.Iterate(i)
+			if sink != nil {
+				ls.retained = append(ls.retained, sink.Last())
+			}
+		}
+		ls.iterations += batch
+
+		before := ls.built
+		if live := readLiveHeap(); live > baseline {
+			ls.built = live - baseline
+		}
+		// At least a byte of progress, so small targets stall too and
+		// perIteration below is never zero
+		if ls.built-min(before, ls.built) < max(target/1000, 1) {
+			if stalls++; stalls == liveSetMaxStalls {
+				return nil, fmt.Errorf("-live-set: workload %s retained too little after %d iterations (%s); it can't build this live set",
+					name, ls.iterations, formatBytes(ls.built))
+			}
+			batch *= 2
+			continue
+		}
+		stalls = 0
+		perIteration := float64(ls.built) / float64(ls.iterations)
+		batch = max(1, min(int(float64(target-min(ls.built, target))/perIteration)+1, 4*batch))
+	}
+
+	ls.elapsed = time.Since(start)
+	return ls, nil
+}
+
+// Report prints what the live set is made of
+func (ls *liveSet) Report() {
+	printSection("Live Set")
+	printMetric("Live Set Workload", "%s", ls.workload)
+	printMetric("Live Set Target", "%s", formatBytes(ls.target))
+	printMetric("Live Set Built", "%s", formatBytes(ls.built))
+	printMetric("Live Set Iterations", "%d", ls.iterations)
+	printMetric("Live Set Build Time", "%s", formatDuration(ls.elapsed))
+}
+
+// Teardown tears down the instances that built the live set
+func (ls *liveSet) Teardown() {
+	teardownWorkloads(ls.instances)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"time"
+)
+
+var markCycles = flag.Int("mark-cycles", 100, "markcost mode: number of forced GC cycles to measure")
+
+// markCPUMetrics are the runtime/metrics CPU classes spent marking
+var markCPUMetrics = []string{
+	"/cpu/classes/gc/mark/assist:cpu-seconds",
+	"/cpu/classes/gc/mark/dedicated:cpu-seconds",
+	"/cpu/classes/gc/mark/idle:cpu-seconds",
+}
+
+// readMarkCPU returns the cumulative CPU time spent in GC mark work
+func readMarkCPU(samples []metrics.Sample) time.Duration {
+	metrics.Read(samples)
+	var total float64
+	for _, s := range samples {
+		if s.Value.Kind() == metrics.KindFloat64 {
+			total += s.Value.Float64()
+		}
+	}
+	return time.Duration(total * float64(time.Second))
+}
+
+// runMarkCost repeatedly forces GC cycles over the workload's live heap
+// without running any iterations in between, so every cycle marks the same
+// unchanging set of objects. It reports the distribution of per-cycle wall
+// time and mark CPU time, a clean measure of scanning speed.
+func runMarkCost(ws []Workload, cycles int) {
+	samples := make([]metrics.Sample, len(markCPUMetrics))
+	for i, name := range markCPUMetrics {
+		samples[i].Name = name
+	}
+
+	// Settle the heap so the first measured cycle doesn't also free garbage
+	runtime.GC()
+	runtime.GC()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+
+	fmt.Printf("Measuring %d forced GC cycles...\n", cycles)
+	wall := make([]time.Duration, cycles)
+	markCPU := make([]time.Duration, cycles)
+	for c := 0; c < cycles; c++ {
+		cpuBefore := readMarkCPU(samples)
+		start := time.Now()
+		runtime.GC()
+		wall[c] = time.Since(start)
+		markCPU[c] = readMarkCPU(samples) - cpuBefore
+	}
+
+	runtime.KeepAlive(ws)
+
+	fmt.Println()
+	printSection("Mark Cost")
+	printMetric("Cycles", "%d", cycles)
+	printMetric("Live Heap", "%s", formatBytes(memStats.HeapAlloc))
+	printMetric("Live Objects", "%d", memStats.HeapObjects)
+	fmt.Println()
+
+	printDistribution := func(name string, d []time.Duration) {
+		fmt.Printf("%s:\n", name)
+		fmt.Printf("  Mean: %s\n", formatDuration(meanDuration(d)))
+		sortDurations(d)
+		fmt.Printf("  Min: %s\n", formatDuration(d[0]))
+		fmt.Printf("  p50: %s\n", formatDuration(durationPercentile(d, 50)))
+		fmt.Printf("  p90: %s\n", formatDuration(durationPercentile(d, 90)))
+		fmt.Printf("  p99: %s\n", formatDuration(durationPercentile(d, 99)))
+		fmt.Printf("  Max: %s\n", formatDuration(d[len(d)-1]))
+	}
+	printDistribution("GC Cycle Wall Time", wall)
+	printDistribution("Mark CPU Time", markCPU)
+
+	if memStats.HeapAlloc > 0 {
+		fmt.Println()
+		fmt.Printf("Mark CPU per MB: %s\n",
+			formatDuration(time.Duration(float64(meanDuration(markCPU))/(float64(memStats.HeapAlloc)/MB))))
+	}
+}
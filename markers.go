@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var markIterations = flag.Int("mark-iterations", 0,
+	"with -etw or -oslog, also mark the start and end of every Nth iteration (0 disables)")
+
+// markerBackend records named markers in an external tracing tool. It is
+// opened once, when its flag is validated, and stays open for the run.
+type markerBackend interface {
+	// Mark records one marker
+	Mark(name string) error
+	// Close releases the backend after the last marker
+	Close() error
+}
+
+// markers is the enabled marker backend, or nil
+var markers markerBackend
+
+// pipeMarkers is a marker backend writing each marker as a line to the
+// standard input of one tracing process that runs as long as the benchmark,
+// so markers cost a pipe write rather than a process start
+type pipeMarkers struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// startPipeMarkers starts cmd with the marker pipe as its standard input.
+// If ready isn't empty, it waits for cmd to print it as its first line, so
+// that no marker is written before cmd can record it.
+func startPipeMarkers(cmd *exec.Cmd, ready string) (*pipeMarkers, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	var stdout io.Reader
+	if ready != "" {
+		if stdout, err = cmd.StdoutPipe(); err != nil {
+			return nil, err
+		}
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	p := &pipeMarkers{cmd: cmd, stdin: stdin}
+	if ready != "" {
+		line, err := bufio.NewReader(stdout).ReadString('\n')
+		if err == nil && strings.TrimSpace(line) != ready {
+			err = fmt.Errorf("unexpected output %q", line)
+		}
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("%s did not start: %v", cmd.Path, err)
+		}
+	}
+	return p, nil
+}
+
+func (p *pipeMarkers) Mark(name string) error {
+	_, err := io.WriteString(p.stdin, name+"\n")
+	return err
+}
+
+// Close ends the process's input and waits for it to record what is left
+func (p *pipeMarkers) Close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// markerQueue feeds markers to a single goroutine writing them to markers,
+// so GC cycle markers never block the finalizer goroutine. markerMu guards
+// sends against the queue being closed by stop.
+var (
+	markerMu    sync.Mutex
+	markerQueue chan string
+)
+
+// startMarkers starts the marker goroutine and emits a marker for every GC
+// cycle until the returned stop function is called. It does nothing unless a
+// marker backend is enabled.
+func startMarkers() (stop func()) {
+	if markers == nil {
+		return func() {}
+	}
+
+	queue := make(chan string, 256)
+	markerQueue = queue
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for name := range queue {
+			if err := markers.Mark(name); err != nil {
+				fmt.Fprintf(os.Stderr, "marker %q: %v\n", name, err)
+			}
+		}
+	}()
+
+	stopGC := watchGCCycles(func(numGC uint32) {
+		// Drop GC markers rather than stall the finalizer goroutine when
+		// cycles outpace the marker backend
+		emitMarker(fmt.Sprintf("gc %d", numGC), false)
+	})
+
+	return func() {
+		stopGC()
+		markerMu.Lock()
+		close(markerQueue)
+		markerQueue = nil
+		markerMu.Unlock()
+		<-done
+		if err := markers.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "closing markers: %v\n", err)
+		}
+	}
+}
+
+// emitMarker queues a marker, waiting for queue space if wait is set and
+// dropping the marker otherwise
+func emitMarker(name string, wait bool) {
+	markerMu.Lock()
+	defer markerMu.Unlock()
+	if markerQueue == nil {
+		return
+	}
+	name = "green-tea-benchmark: " + name
+	if wait {
+		markerQueue <- name
+		return
+	}
+	select {
+	case markerQueue <- name:
+	default:
+	}
+}
+
+// activePhases are the phases that have begun and not yet ended, innermost
+// last, guarded by phaseMu
+var (
+	phaseMu      sync.Mutex
+	activePhases = make([]string, 0, 4)
+)
+
+// currentPhase returns the innermost active phase, or "" outside any phase
+func currentPhase() string {
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+	if len(activePhases) == 0 {
+		return ""
+	}
+	return activePhases[len(activePhases)-1]
+}
+
+// markPhase emits a marker for the beginning or end of a benchmark phase
+// and reports it to the coordinating process, if any. Phases begin and end
+// at the edges of measured windows, so without a marker backend it
+// allocates nothing.
+func markPhase(phase string, begin bool) {
+	phaseMu.Lock()
+	if begin {
+		activePhases = append(activePhases, phase)
+	} else if n := len(activePhases); n > 0 && activePhases[n-1] == phase {
+		activePhases = activePhases[:n-1]
+	}
+	phaseMu.Unlock()
+
+	if markers != nil {
+		edge := "end"
+		if begin {
+			edge = "begin"
+		}
+		emitMarker(phase+" "+edge, true)
+	}
+	sendIPCProgress(phase, begin, runClock(time.Now()))
+}
+
+// markIteration emits a marker for the beginning or end of iteration i if
+// -mark-iterations selects it
+func markIteration(i int, begin bool) {
+	if *markIterations <= 0 || i%*markIterations != 0 {
+		return
+	}
+	edge := "end"
+	if begin {
+		edge = "begin"
+	}
+	emitMarker(fmt.Sprintf("iteration %d %s", i, edge), false)
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// matrixAllocStrategies are the -alloc strategies, the default first
+var matrixAllocStrategies = []string{"heap", "pool", "arena", "chunked"}
+
+var matrixAlloc = flag.String("alloc", "heap",
+	"matrix workload: allocation strategy: heap (allocate every matrix), pool (recycle matrices and their rows through sync.Pool) "+
+		"arena (carve each iteration's matrices from an arena freed once they are all released) "+
+		"or chunked (allocate matrices from the heap and carve only their element data from pointer-free chunks, which are Go heap too, not off-heap memory); "+
+		"in benchmark mode, strategies other than heap are compared with heap after the measured phase")
+
+// matrixAllocator allocates the matrices of one matrix workload instance
+// by an -alloc strategy other than heap, and takes them back when they are
+// released. Matrices derived from one by the matrix operations come from
+// its allocator too. An instance runs on one goroutine at a time, so the
+// allocator needs no locking.
+//
+// With pool, released matrices go to a sync.Pool, and a new matrix of the
+// same shape takes one, rows, elements and all, and refills its values. The
+// pool drops what it holds over GC cycles, so recycling spares the
+// collector most allocations but not all.
+//
+// With arena, each iteration's matrices are carved from an arena of its
+// own, and the arena is freed as a whole once every matrix in it has been
+// released, to be reused by a later iteration. A retained result keeps its
+// arena, and everything else in it, live.
+//
+// With chunked, only the matrices' element data is carved from arenas, in
+// pointer-free chunks, and the matrices and their rows are allocated from
+// the heap as with heap. The elements stay out of the allocation stream
+// and are never scanned, so what the collector is left with is the cost of
+// the data structures themselves. The chunks are ordinary Go heap, not
+// off-heap memory: they count toward the live heap and the heap goal.
+type matrixAllocator[T matrixElement[T]] struct {
+	strategy string
+	pool     sync.Pool
+	arena    *matrixArena[T]   // The current iteration's arena
+	free     []*matrixArena[T] // Arenas whose matrices have all been released
+}
+
+// newMatrixAllocator returns the allocator for an -alloc strategy, nil for
+// heap
+func newMatrixAllocator[T matrixElement[T]](strategy string) *matrixAllocator[T] {
+	if strategy == "heap" {
+		return nil
+	}
+	return &matrixAllocator[T]{strategy: strategy}
+}
+
+// beginIteration starts an iteration. With arena and chunked, the
+// iteration's matrices go to a free arena, or a new one if there is none.
+func (a *matrixAllocator[T]) beginIteration() {
+	if a == nil || a.strategy == "pool" {
+		return
+	}
+	// An arena whose matrices have all been released is rewound and reused
+	if a.arena != nil && a.arena.live == 0 {
+		a.arena.reset()
+		return
+	}
+	if n := len(a.free); n > 0 {
+		a.arena = a.free[n-1]
+		a.free = a.free[:n-1]
+		a.arena.reset()
+		return
+	}
+	a.arena = &matrixArena[T]{dataOnly: a.strategy == "chunked"}
+}
+
+// newMatrix returns a rows×cols matrix of elements drawn from rng,
+// NewMatrix's if a is nil
+func (a *matrixAllocator[T]) newMatrix(rng *rand.Rand, rows, cols int) *Matrix[T] {
+	if a == nil {
+		return NewMatrix[T](rng, rows, cols)
+	}
+	var m *Matrix[T]
+	switch a.strategy {
+	case "pool":
+		if p, ok := a.pool.Get().(*Matrix[T]); ok && p.rows == rows && p.cols == cols {
+			var zero T
+			for i := 0; i < rows; i++ {
+				for j := 0; j < cols; j++ {
+					*p.ref(i, j) = zero.FromFloat(matrixValue(rng))
+				}
+			}
+			return p
+		}
+		m = NewMatrix[T](rng, rows, cols)
+	case "arena", "chunked":
+		m = newMatrixIn(a.arena, rng, rows, cols)
+		a.arena.live++
+	}
+	m.alloc = a
+	return m
+}
+
+// release takes back m, which must not be used afterwards
+func (a *matrixAllocator[T]) release(m *Matrix[T]) {
+	switch a.strategy {
+	case "pool":
+		a.pool.Put(m)
+	case "arena", "chunked":
+		if m.arena.live--; m.arena.live == 0 && m.arena != a.arena {
+			a.free = append(a.free, m.arena)
+		}
+	}
+}
+
+// release returns m to the allocator it came from, if any. m must not be
+// used afterwards.
+func (m *Matrix[T]) release() {
+	if m != nil && m.alloc != nil {
+		m.alloc.release(m)
+	}
+}
+
+// releaseMatrices releases every matrix of ms
+func releaseMatrices[T matrixElement[T]](ms []*Matrix[T]) {
+	for _, m := range ms {
+		m.release()
+	}
+}
+
+// matrixArena holds matrices' storage: the Matrix values, their rows and
+// their elements, each carved from chunks of its type. Freeing an arena
+// keeps its chunks for reuse and doesn't clear them; everything carved
+// from them is overwritten before it is read. Its methods allocate from the
+// heap when the arena is nil, and all but those for elements do when it
+// holds only element data.
+type matrixArena[T matrixElement[T]] struct {
+	matrices  bump[Matrix[T]]
+	elems     bump[T]
+	ptrs      bump[*T]
+	ptrRows   bump[[]*T]
+	valueRows bump[[]T]
+	mixedRows bump[mixedRow[T]]
+	live      int  // Matrices allocated and not yet released
+	dataOnly  bool // Only elements are carved from the arena, for chunked
+}
+
+// reset frees everything carved from the arena
+func (a *matrixArena[T]) reset() {
+	a.matrices.reset()
+	a.elems.reset()
+	a.ptrs.reset()
+	a.ptrRows.reset()
+	a.valueRows.reset()
+	a.mixedRows.reset()
+}
+
+func (a *matrixArena[T]) newMatrix() *Matrix[T] {
+	if a == nil || a.dataOnly {
+		return new(Matrix[T])
+	}
+	return &a.matrices.alloc(1)[0]
+}
+
+func (a *matrixArena[T]) newElem() *T {
+	if a == nil {
+		return new(T)
+	}
+	return &a.elems.alloc(1)[0]
+}
+
+func (a *matrixArena[T]) makeElems(n int) []T {
+	if a == nil {
+		return make([]T, n)
+	}
+	return a.elems.alloc(n)
+}
+
+func (a *matrixArena[T]) makePtrs(n int) []*T {
+	if a == nil || a.dataOnly {
+		return make([]*T, n)
+	}
+	return a.ptrs.alloc(n)
+}
+
+func (a *matrixArena[T]) makePtrRows(n int) [][]*T {
+	if a == nil || a.dataOnly {
+		return make([][]*T, n)
+	}
+	return a.ptrRows.alloc(n)
+}
+
+func (a *matrixArena[T]) makeValueRows(n int) [][]T {
+	if a == nil || a.dataOnly {
+		return make([][]T, n)
+	}
+	return a.valueRows.alloc(n)
+}
+
+func (a *matrixArena[T]) makeMixedRows(n int) []mixedRow[T] {
+	if a == nil || a.dataOnly {
+		return make([]mixedRow[T], n)
+	}
+	return a.mixedRows.alloc(n)
+}
+
+// bump hands out slices of E from chunks by bumping an offset, in the
+// manner of the regions workload's region. A chunk too small for a request
+// is skipped; a new chunk is at least twice the size of the last, so an
+// arena reaches the size of an iteration in a few chunks and then stops
+// allocating.
+type bump[E any] struct {
+	chunks [][]E
+	chunk  int // Chunk being allocated from
+	used   int // Elements handed out of it
+}
+
+// alloc returns n elements, not zeroed
+func (b *bump[E]) alloc(n int) []E {
+	for b.chunk < len(b.chunks) && b.used+n > len(b.chunks[b.chunk]) {
+		b.chunk, b.used = b.chunk+1, 0
+	}
+	if b.chunk == len(b.chunks) {
+		size := max(n, 16)
+		if len(b.chunks) > 0 {
+			size = max(n, 2*len(b.chunks[len(b.chunks)-1]))
+		}
+		b.chunks = append(b.chunks, make([]E, size))
+	}
+	s := b.chunks[b.chunk][b.used : b.used+n : b.used+n]
+	b.used += n
+	return s
+}
+
+// reset frees every element handed out
+func (b *bump[E]) reset() {
+	b.chunk, b.used = 0, 0
+}
+
+// AllocStrategy returns the workload's -alloc strategy
+func (w *matrixWorkload[T]) AllocStrategy() string { return w.alloc }
+
+// WithAlloc returns a fresh instance of the workload with an allocation
+// strategy
+func (w *matrixWorkload[T]) WithAlloc(strategy string) Workload {
+	d := newMatrixWorkload[T]().(*matrixWorkload[T])
+	d.alloc, d.allocator = strategy, newMatrixAllocator[T](strategy)
+	return d
+}
+
+// allocRun is what a run of the workload under one allocation strategy
+// cost the heap and the collector
+type allocRun struct {
+	strategy   string
+	layout     string
+	duration   time.Duration
+	allocs     uint64
+	totalAlloc uint64
+	numGC      uint32
+	pause      time.Duration
+}
+
+// measureAlloc runs warmup and then iterations of fresh instances of the
+// workload with an allocation strategy, one per worker, so strategies are
+// compared on the same work. As in the measured phase, each instance runs
+// on a goroutine of its own, claiming iterations from a shared counter;
+// collections before and after bracket the run as they do the measured
+// phase.
+func measureAlloc(ws []Workload, strategy string, warmup, iterations int) allocRun {
+	instances := make([]Workload, len(ws))
+	for n := range instances {
+		instances[n] = ws[n].(workloadAllocStrategy).WithAlloc(strategy)
+	}
+	run := func(iterations int) {
+		var next atomic.Int64
+		var wg sync.WaitGroup
+		for _, w := range instances {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := int(next.Add(1) - 1); i < iterations; i = int(next.Add(1) - 1) {
+					w.Iterate(i)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	run(warmup)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	run(iterations)
+	r := allocRun{strategy: strategy, layout: layout, duration: time.Since(start)}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	r.allocs = after.Mallocs - before.Mallocs
+	r.totalAlloc = after.TotalAlloc - before.TotalAlloc
+	r.numGC = after.NumGC - before.NumGC
+	r.pause = time.Duration(after.PauseTotalNs - before.PauseTotalNs)
+	runtime.KeepAlive(instances)
+	return r
+}
+
+// printAllocComparison prints the measured phase's allocation strategy
+// next to the default one, and the change from the default
+func printAllocComparison(measured, heap allocRun) {
+	printSection("Allocation Strategy")
+	printMetric("Strategy", "%s (compared with %s)", measured.strategy, heap.strategy)
+	printMetric("Allocations", "%d vs %d (%s)", measured.allocs, heap.allocs,
+		formatChange(float64(measured.allocs), float64(heap.allocs)))
+	printMetric("GC Count", "%d vs %d (%s)", measured.numGC, heap.numGC,
+		formatChange(float64(measured.numGC), float64(heap.numGC)))
+	printMetric("GC Pause", "%s vs %s (%s)", formatDuration(measured.pause), formatDuration(heap.pause),
+		formatChange(float64(measured.pause), float64(heap.pause)))
+	printMetric("Duration", "%s vs %s (%s)", formatDuration(measured.duration), formatDuration(heap.duration),
+		formatChange(float64(measured.duration), float64(heap.duration)))
+}
+
+// formatChange formats the relative change from base to v
+func formatChange(v, base float64) string {
+	if base == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.2f%%", (v-base)/base*100)
+}
+
+// validateAllocStrategies checks that the workload has allocation
+// strategies for the strategies mode to compare
+func validateAllocStrategies(w Workload) error {
+	if _, ok := w.(workloadAllocStrategy); !ok {
+		return fmt.Errorf("the %s workload has no allocation strategies to compare (try -workload=matrix)", w.Name())
+	}
+	return nil
+}
+
+// runAllocStrategies runs the workload with each allocation strategy in
+// turn, on fresh instances, then with the default strategy in the flat
+// layout, the allocation-free layout alternative to the strategies, and
+// prints them side by side. Each run has the same warmup, iterations and
+// workers as a benchmark run, and collections before and after, so the
+// runs don't charge each other's garbage to one another.
+func runAllocStrategies(ws []Workload) {
+	type strategy struct{ alloc, layout string }
+	var strategies []strategy
+	for _, alloc := range matrixAllocStrategies {
+		strategies = append(strategies, strategy{alloc, layout})
+	}
+	if layout != LayoutFlat {
+		strategies = append(strategies, strategy{"heap", LayoutFlat})
+	}
+
+	fmt.Printf("Running %d iterations with each of %d allocation strategies...\n", *iterations, len(strategies))
+	defer func(saved string) { layout = saved }(layout)
+	runs := make([]allocRun, len(strategies))
+	for n, s := range strategies {
+		fmt.Printf("  -alloc=%s -layout=%s\n", s.alloc, s.layout)
+		layout = s.layout
+		runs[n] = measureAlloc(ws, s.alloc, *warmupIters, *iterations)
+	}
+
+	fmt.Println()
+	printSection("Allocation Strategies")
+	fmt.Printf("%-8s | %-8s | %-12s | %-12s | %-12s | %-12s | %-6s | %-12s | %s\n",
+		"Strategy", "Layout", "Duration", "Ops/sec", "Allocations", "Allocated", "GCs", "GC Pause", "Duration vs heap")
+	for _, r := range runs {
+		fmt.Printf("%-8s | %-8s | %-12s | %-12.2f | %-12d | %-12s | %-6d | %-12s | %s\n",
+			r.strategy, r.layout, formatDuration(r.duration), float64(*iterations)/r.duration.Seconds(),
+			r.allocs, formatBytes(r.totalAlloc), r.numGC, formatDuration(r.pause),
+			formatChange(float64(r.duration), float64(runs[0].duration)))
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var runBaseline = flag.Bool("baseline", false,
+	"benchmark mode: after the measured phase, run the same iterations through the workload's zero-allocation baseline kernel "+
+		"and report it as the performance ceiling")
+
+// matrixBaseline is the matrix workload's baseline kernel: the same chain
+// of operations on matrices of the same size, computed on float64 values in
+// flat buffers allocated once, so an iteration allocates nothing and the
+// collector never runs. Its time is the ceiling the workload could reach
+// if memory management cost nothing; the gap to it is the price of
+// allocating every matrix, its pointers and its GC.
+type matrixBaseline struct {
+	size  int
+	ops   int
+	chain [][]float64
+	rng   *rand.Rand
+}
+
+func newMatrixBaseline(size, ops int) *matrixBaseline {
+	b := &matrixBaseline{size: size, ops: ops, chain: make([][]float64, ops+2), rng: rand.New(rand.NewSource(*dataSeed))}
+	for i := range b.chain {
+		b.chain[i] = make([]float64, size*size)
+	}
+	return b
+}
+
+func (b *matrixBaseline) Name() string { return "matrix baseline (float64, flat, no allocation)" }
+
+func (b *matrixBaseline) Iterate(int) {
+	n := b.size
+	for _, m := range b.chain[:2] {
+		for k := range m {
+			m[k] = matrixValue(b.rng)
+		}
+	}
+	for op := 0; op < b.ops; op++ {
+		step := matrixChain[op%len(matrixChain)]
+		out := b.chain[op+2]
+		x, y := b.chain[op+2-step.x], b.chain[op+2-max(step.y, 1)]
+		switch step.op {
+		case opMultiply:
+			for i := 0; i < n; i++ {
+				row := x[i*n : (i+1)*n]
+				for j := 0; j < n; j++ {
+					var sum float64
+					for k, v := range row {
+						sum += v * y[k*n+j]
+					}
+					out[i*n+j] = sum
+				}
+			}
+		case opAdd:
+			for k := range out {
+				out[k] = x[k] + y[k]
+			}
+		case opTranspose:
+			for i := 0; i < n; i++ {
+				for j := 0; j < n; j++ {
+					out[j*n+i] = x[i*n+j]
+				}
+			}
+		case opScale:
+			for k := range out {
+				out[k] = x[k] * 2.5
+			}
+		}
+	}
+}
+
+// Baseline returns the matrix workload's baseline kernel
+func (w *matrixWorkload[T]) Baseline() Workload {
+	return newMatrixBaseline(w.size, w.ops)
+}
+
+// baselineResult is the timing of a baseline run
+type baselineResult struct {
+	name       string
+	iterations int
+	duration   time.Duration
+	allocs     uint64
+}
+
+// measureBaseline runs iterations of the baseline kernel after a
+// collection, so the kernel starts from a quiet heap. Like the measured
+// phase, each of workers goroutines runs a kernel of its own, claiming
+// iterations from a shared counter.
+func measureBaseline(ws []Workload, workers, iterations int) baselineResult {
+	kernels := make([]Workload, workers)
+	for n := range kernels {
+		kernels[n] = ws[n].(workloadBaseline).Baseline()
+	}
+	runtime.GC()
+	allocs := []metrics.Sample{{Name: "/gc/heap/allocs:objects"}}
+	metrics.Read(allocs)
+	before := allocs[0].Value.Uint64()
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for _, kernel := range kernels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < iterations; i = int(next.Add(1) - 1) {
+				kernel.Iterate(i)
+			}
+		}()
+	}
+	wg.Wait()
+	r := baselineResult{name: kernels[0].Name(), iterations: iterations, duration: time.Since(start)}
+	metrics.Read(allocs)
+	// Starting the goroutines allocates a few objects each; the kernels none
+	r.allocs = allocs[0].Value.Uint64() - before
+	return r
+}
+
+// printBaseline prints the baseline alongside the measured phase's
+// duration: the ceiling, and how far below it the workload ran
+func printBaseline(r baselineResult, measured time.Duration) {
+	printSection("Baseline")
+	printMetric("Baseline Kernel", "%s", r.name)
+	printMetric("Baseline Duration", "%s", formatDuration(r.duration))
+	printMetric("Baseline Operations/sec", "%.2f", float64(r.iterations)/r.duration.Seconds())
+	printMetric("Baseline Allocations", "%d", r.allocs)
+	printMetric("Workload vs Baseline", "%.2fx", float64(measured)/float64(r.duration))
+	printMetric("Share of Ceiling", "%.2f%%", float64(r.duration)/float64(measured)*100)
+}
+
+// validateBaseline checks that -baseline can be honored for the workload
+func validateBaseline(mode string, w Workload) error {
+	if !*runBaseline {
+		return nil
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-baseline is only supported in benchmark mode")
+	}
+	if _, ok := w.(workloadBaseline); !ok {
+		return fmt.Errorf("the %s workload has no baseline kernel for -baseline", w.Name())
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import "math/rand"
+
+// Filling new matrices and the element-wise matrix operations take a fast
+// path for float64, the default element type. Methods called through a type parameter go through
+// the instantiation's dictionary and aren't inlined, so the generic code
+// pays a call for every element it fills, adds or scales; these kernels
+// do the conversions and arithmetic inline. They cover every layout but mixed, which stays
+// on the generic path. Multiplication, fused or not, already hands each
+// element type a whole dot product, so it needs none.
+
+// asFloat64 returns m as a float64 matrix, or nil if its elements are of
+// another type or it is in the mixed layout
+func asFloat64[T matrixElement[T]](m *Matrix[T]) *Matrix[elemFloat64] {
+	f, _ := any(m).(*Matrix[elemFloat64])
+	if f != nil && f.mixed != nil {
+		return nil
+	}
+	return f
+}
+
+// fillFloat64 sets elems to values drawn from rng
+func fillFloat64(elems []elemFloat64, rng *rand.Rand) {
+	for k := range elems {
+		elems[k] = elemFloat64(matrixValue(rng))
+	}
+}
+
+// fillFloat64Ptrs sets the elements ptrs point to to values drawn from rng
+func fillFloat64Ptrs(ptrs []*elemFloat64, rng *rand.Rand) {
+	for _, p := range ptrs {
+		*p = elemFloat64(matrixValue(rng))
+	}
+}
+
+// addFloat64 stores m+other in result
+func addFloat64(m, other, result *Matrix[elemFloat64]) {
+	switch {
+	case m.flat != nil:
+		for k, v := range m.flat {
+			result.flat[k] = v + other.flat[k]
+		}
+	case m.values != nil:
+		for i, row := range m.values {
+			b, out := other.values[i], result.values[i]
+			for j, v := range row {
+				out[j] = v + b[j]
+			}
+		}
+	default:
+		for i, row := range m.data {
+			b, out := other.data[i], result.data[i]
+			for j, v := range row {
+				*out[j] = *v + *b[j]
+			}
+		}
+	}
+}
+
+// scaleFloat64 stores scalar·m in result
+func scaleFloat64(m, result *Matrix[elemFloat64], scalar float64) {
+	s := elemFloat64(scalar)
+	switch {
+	case m.flat != nil:
+		for k, v := range m.flat {
+			result.flat[k] = v * s
+		}
+	case m.values != nil:
+		for i, row := range m.values {
+			out := result.values[i]
+			for j, v := range row {
+				out[j] = v * s
+			}
+		}
+	default:
+		for i, row := range m.data {
+			out := result.data[i]
+			for j, v := range row {
+				*out[j] = *v * s
+			}
+		}
+	}
+}
+
+// scaledSumFloat64 stores scalar·(m+other) in result
+func scaledSumFloat64(m, other, result *Matrix[elemFloat64], scalar float64) {
+	s := elemFloat64(scalar)
+	switch {
+	case m.flat != nil:
+		for k, v := range m.flat {
+			result.flat[k] = (v + other.flat[k]) * s
+		}
+	case m.values != nil:
+		for i, row := range m.values {
+			b, out := other.values[i], result.values[i]
+			for j, v := range row {
+				out[j] = (v + b[j]) * s
+			}
+		}
+	default:
+		for i, row := range m.data {
+			b, out := other.data[i], result.data[i]
+			for j, v := range row {
+				*out[j] = (*v + *b[j]) * s
+			}
+		}
+	}
+}
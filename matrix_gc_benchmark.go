@@ -1,102 +1,19 @@
 package main
 
 import (
+	"flag"
 	"fmt"
-	"math/rand"
+	"os"
 	"runtime"
 	"runtime/debug"
 	"time"
 )
 
-// Matrix represents a 2D matrix with heap-allocated rows
-type Matrix struct {
-	rows int
-	cols int
-	data [][]*float64 // Slice of slices of pointers - creates lots of heap objects
-}
-
-// NewMatrix creates a new matrix with the given dimensions
-func NewMatrix(rows, cols int) *Matrix {
-	m := &Matrix{
-		rows: rows,
-		cols: cols,
-		data: make([][]*float64, rows),
-	}
-	
-	for i := 0; i < rows; i++ {
-		m.data[i] = make([]*float64, cols)
-		for j := 0; j < cols; j++ {
-			val := rand.Float64()
-			m.data[i][j] = &val // Each element is a pointer to heap-allocated float64
-		}
-	}
-	
-	return m
-}
-
-// Multiply performs matrix multiplication
-func (m *Matrix) Multiply(other *Matrix) *Matrix {
-	if m.cols != other.rows {
-		panic("incompatible dimensions for multiplication")
-	}
-	
-	result := NewMatrix(m.rows, other.cols)
-	
-	for i := 0; i < m.rows; i++ {
-		for j := 0; j < other.cols; j++ {
-			sum := 0.0
-			for k := 0; k < m.cols; k++ {
-				sum += *m.data[i][k] * *other.data[k][j]
-			}
-			*result.data[i][j] = sum
-		}
-	}
-	
-	return result
-}
-
-// Add performs matrix addition
-func (m *Matrix) Add(other *Matrix) *Matrix {
-	if m.rows != other.rows || m.cols != other.cols {
-		panic("incompatible dimensions for addition")
-	}
-	
-	result := NewMatrix(m.rows, m.cols)
-	
-	for i := 0; i < m.rows; i++ {
-		for j := 0; j < m.cols; j++ {
-			*result.data[i][j] = *m.data[i][j] + *other.data[i][j]
-		}
-	}
-	
-	return result
-}
-
-// Transpose creates a transposed version of the matrix
-func (m *Matrix) Transpose() *Matrix {
-	result := NewMatrix(m.cols, m.rows)
-	
-	for i := 0; i < m.rows; i++ {
-		for j := 0; j < m.cols; j++ {
-			*result.data[j][i] = *m.data[i][j]
-		}
-	}
-	
-	return result
-}
-
-// ScalarMultiply multiplies each element by a scalar
-func (m *Matrix) ScalarMultiply(scalar float64) *Matrix {
-	result := NewMatrix(m.rows, m.cols)
-	
-	for i := 0; i < m.rows; i++ {
-		for j := 0; j < m.cols; j++ {
-			*result.data[i][j] = *m.data[i][j] * scalar
-		}
-	}
-	
-	return result
-}
+// Benchmark configuration
+var (
+	iterations  = flag.Int("iters", 1000, "number of measured iterations")
+	warmupIters = flag.Int("warmup", 100, "number of warmup iterations run before measuring")
+)
 
 // GCStats holds garbage collection statistics
 type GCStats struct {
@@ -122,6 +39,200 @@ func getGCStats() GCStats {
 }
 
 func main() {
+	workloadName := flag.String("workload", "matrix", "workload to run (see -help for workload flags)")
+	workers := flag.Int("workers", 1,
+		"number of worker goroutines, each running its own instance of the workload")
+	mode := flag.String("mode", "benchmark",
+		"benchmark: time iterations of the workload; markcost: repeatedly force GC over the workload's live heap; "+
+			"repl: adjust knobs and run measurement bursts interactively; "+
+			"autotune: adjust GOGC during the run to meet a -tune-gc-cpu or -tune-p99-pause target; "+
+			"capacity: search for the highest arrival rate whose p99 request latency meets -capacity-slo; "+
+			"strategies: run the workload with each -alloc strategy and the flat layout in turn and compare them; "+
+			"selftest: verify the harness makes no heap allocations inside the measured window")
+	flag.StringVar(&layout, "layout", LayoutPointers,
+		"element allocation layout: pointers (one allocation per element), rowbatch (one allocation per row), "+
+			"values (one allocation per row, no element pointers), flat (one slice, no element pointers) "+
+			"or mixed (-element-pointers percent of elements behind pointers)")
+	if isThrottler() {
+		os.Exit(runThrottler())
+	}
+	if isCompareCommand() {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+	flag.Parse()
+
+	if err := loadConfig(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := openIPC(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateFormat(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateWasm(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := loadPlugins(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateWatch(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if wantWatch() {
+		runWatch()
+		return
+	}
+
+	if layout != LayoutPointers && layout != LayoutRowBatch && layout != LayoutValues && layout != LayoutFlat &&
+		layout != LayoutMixed {
+		fmt.Fprintf(os.Stderr, "unknown layout %q (want %s, %s, %s, %s or %s)\n",
+			layout, LayoutPointers, LayoutRowBatch, LayoutValues, LayoutFlat, LayoutMixed)
+		os.Exit(2)
+	}
+
+	if err := validateUnits(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateETW(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateOSLog(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateAssertions(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := configureCPUQuota(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *colorMode != "auto" && *colorMode != "always" && *colorMode != "never" {
+		fmt.Fprintf(os.Stderr, "unknown -color %q (want auto, always or never)\n", *colorMode)
+		os.Exit(2)
+	}
+	if *mode != "benchmark" && *mode != "markcost" && *mode != "repl" && *mode != "autotune" && *mode != "capacity" &&
+		*mode != "strategies" && *mode != "selftest" {
+		fmt.Fprintf(os.Stderr, "unknown mode %q (want benchmark, markcost, repl, autotune, capacity, strategies or selftest)\n", *mode)
+		os.Exit(2)
+	}
+	if *mode == "repl" && *outputFormat != "text" {
+		fmt.Fprintln(os.Stderr, "repl mode only supports -format=text")
+		os.Exit(2)
+	}
+	if *mode == "autotune" {
+		if err := validateAutotune(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if err := validateOccupancy(*mode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateSlowIterations(*mode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := configureBundle(*mode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *mode == "markcost" && *markCycles < 1 {
+		fmt.Fprintf(os.Stderr, "-mark-cycles must be positive, got %d\n", *markCycles)
+		os.Exit(2)
+	}
+	if *mode == "markcost" {
+		if err := requireFeature("markcost mode", markCPUMetrics...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if *workers < 1 {
+		fmt.Fprintf(os.Stderr, "-workers must be positive, got %d\n", *workers)
+		os.Exit(2)
+	}
+	if *iterations < 1 || *warmupIters < 0 {
+		fmt.Fprintf(os.Stderr, "-iters must be positive and -warmup non-negative\n")
+		os.Exit(2)
+	}
+
+	phases, err := parsePhases(*phaseSpec, *workers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "-phases: %v\n", err)
+		os.Exit(2)
+	}
+	if phases != nil && *mode != "benchmark" {
+		fmt.Fprintf(os.Stderr, "-phases is only supported in benchmark mode\n")
+		os.Exit(2)
+	}
+	if phases != nil {
+		*iterations = phaseIterations(phases)
+	}
+
+	if *mode == "selftest" {
+		os.Exit(runSelfTest(*workers))
+	}
+
+	if wantGCTimeline() {
+		os.Exit(runGCTimeline())
+	}
+	sendIPCHello(*mode, *workloadName)
+
+	if err := beginReport(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ws, err := newWorkloads(*workloadName, phaseWorkers(phases, *workers))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateBaseline(*mode, ws[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := loadRegressionGate(*mode, ws[0].Name()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateRepetitions(*mode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateHDRLog(*mode); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := validateMUTimeline(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *mode == "capacity" {
+		if err := validateCapacity(ws[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *mode == "strategies" {
+		if err := validateAllocStrategies(ws[0]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
 	fmt.Println("=== Matrix GC Benchmark ===")
 	fmt.Println("Comparing GC performance with heavy heap allocation")
 	fmt.Println()
@@ -132,24 +243,133 @@ func main() {
 	fmt.Printf("NumCPU: %d\n", runtime.NumCPU())
 	fmt.Println()
 	
-	// Configuration
-	const (
-		matrixSize  = 50   // Size of matrices
-		iterations  = 1000 // Number of iterations
-		warmupIters = 100  // Warmup iterations
-	)
-	
 	fmt.Printf("Configuration:\n")
-	fmt.Printf("  Matrix Size: %dx%d\n", matrixSize, matrixSize)
-	fmt.Printf("  Iterations: %d (+ %d warmup)\n", iterations, warmupIters)
+	fmt.Printf("  Mode: %s\n", *mode)
+	fmt.Printf("  Workload: %s\n", ws[0].Name())
+	fmt.Printf("  Workers: %d\n", *workers)
+	fmt.Printf("  Matrix Size: %dx%d\n", *matrixSize, *matrixSize)
+	fmt.Printf("  Iterations: %d (+ %d warmup)\n", *iterations, *warmupIters)
+	if layout == LayoutMixed {
+		fmt.Printf("  Layout: %s (%d%% of elements behind pointers)\n", layout, *elementPointers)
+	} else {
+		fmt.Printf("  Layout: %s\n", layout)
+	}
+	fmt.Printf("  Element: %s\n", *elementType)
+	if *multiplyTile > 0 {
+		fmt.Printf("  Multiply Tile: %d\n", *multiplyTile)
+	}
+	for _, p := range phases {
+		fmt.Printf("  Phase %s: %d iterations, %d workers\n", p.name, p.iters, p.workers)
+	}
 	fmt.Println()
 	
+	var live *liveSet
+	if liveSetSize > 0 {
+		name := *liveSetWorkload
+		if name == "" {
+			name = *workloadName
+		}
+		fmt.Println("Building live set...")
+		if live, err = buildLiveSet(name, uint64(liveSetSize)); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		defer live.Teardown()
+		live.Report()
+		fmt.Println()
+	}
+	// The live set stays reachable for the whole run
+	defer runtime.KeepAlive(live)
+
+	if *mode == "repl" {
+		runREPL(os.Stdin, os.Stdout, *workloadName, ws)
+		return
+	}
+	defer teardownWorkloads(ws)
+
+	stopMarkers := startMarkers()
+	defer stopMarkers()
+
+	peakHeap := startPeakHeapTracker()
+
+	hog, err := newBandwidthHog()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	
 	// Warmup phase
 	fmt.Println("Running warmup...")
-	for i := 0; i < warmupIters; i++ {
-		m1 := NewMatrix(matrixSize, matrixSize)
-		m2 := NewMatrix(matrixSize, matrixSize)
-		_ = m1.Multiply(m2)
+	warmupStats, err := warmUp(ws, *workers, *warmupIters)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	for _, w := range ws {
+		if r, ok := w.(workloadStatsResetter); ok {
+			r.ResetStats()
+		}
+	}
+
+	if *mode == "autotune" {
+		markPhase("autotune", true)
+		runAutotune(ws)
+		markPhase("autotune", false)
+		finishRun(*mode, ws[0].Name(), nil, stopMarkers)
+		return
+	}
+
+	if *mode == "capacity" {
+		markPhase("capacity", true)
+		runCapacity(ws[:*workers])
+		markPhase("capacity", false)
+		finishRun(*mode, ws[0].Name(), nil, stopMarkers)
+		return
+	}
+
+	if *mode == "strategies" {
+		markPhase("strategies", true)
+		runAllocStrategies(ws[:*workers])
+		markPhase("strategies", false)
+		finishRun(*mode, ws[0].Name(), nil, stopMarkers)
+		return
+	}
+
+	if *mode == "markcost" {
+		markPhase("markcost", true)
+		psi := startPSIMonitor()
+		pausesBefore := readPauses()
+		runMarkCost(ws, *markCycles)
+		pauses := readPauses().Since(pausesBefore)
+		checkPauseAssertions(pauses.Percentiles())
+		if err := writePauseFile(pauses); err != nil {
+			fmt.Fprintf(os.Stderr, "writing pauses: %v\n", err)
+			stopMarkers()
+			os.Exit(2)
+		}
+		markPhase("markcost", false)
+		if psi != nil {
+			psi.Stop()
+			fmt.Println()
+			psi.Report()
+		}
+		fmt.Println()
+		peak := peakHeap.Stop()
+		printHeadroom(peak)
+		checkMemoryAssertions(peak)
+		finishRun(*mode, ws[0].Name(), nil, stopMarkers)
+		return
+	}
+
+	occupancy, err := setOccupancy(ws)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if occupancy != nil {
+		fmt.Printf("Set GOMEMLIMIT to %s for %.1f%% occupancy of a %s live heap (%s outside the heap)\n",
+			formatBytes(uint64(occupancy.startLimit)), occupancy.target*100, formatBytes(occupancy.live),
+			formatBytes(uint64(occupancy.startLimit)-occupancy.startRoom))
 	}
 	
 	// Force GC before benchmark
@@ -161,75 +381,347 @@ func main() {
 	runtime.ReadMemStats(&memStatsBefore)
 	gcStatsBefore := getGCStats()
 	
+	// Everything the measured window uses is set up before it opens, so the
+	// harness doesn't allocate inside it (see -mode=selftest)
+	if wantIterationLog() {
+		measuredIterations = newIterationLog(*iterations, wantIterationPhases())
+	}
+	iterationLatencies = newLatencyRing(*latencyRingSize)
+	slowIterations = newSlowIterationWatch(len(ws))
+	var measured *phaseRun
+	if phases != nil {
+		measured = newPhaseRun(ws, phases)
+	} else {
+		iterationRunnerFor(ws)
+	}
+	pausesBefore := readPauses()
+	reps := newRepetitions()
+	quota, err := newCPUQuotaEmulation()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		stopMarkers()
+		os.Exit(2)
+	}
+
 	fmt.Println("Starting benchmark...")
+	psi := startPSIMonitor()
+	mu := startMUTimeline(*muWindow)
+	var harvest pauseHarvest
+	outliers := startPauseOutliers(&harvest)
+	pauseSet := startPauseSet(&harvest)
+	budget := startPauseBudget(&harvest)
+	harvest.Start()
+	if hog != nil {
+		hog.Start()
+	}
+	if quota != nil {
+		quota.Start()
+	}
+	if occupancy != nil {
+		occupancy.Start()
+	}
+	if slowIterations != nil {
+		slowIterations.Start()
+	}
 	startTime := time.Now()
 	
 	// Main benchmark loop
-	var results []*Matrix
-	for i := 0; i < iterations; i++ {
-		// Create matrices
-		m1 := NewMatrix(matrixSize, matrixSize)
-		m2 := NewMatrix(matrixSize, matrixSize)
-		
-		// Perform operations (creates many intermediate objects)
-		m3 := m1.Multiply(m2)
-		m4 := m1.Add(m2)
-		m5 := m3.Transpose()
-		m6 := m4.ScalarMultiply(2.5)
-		m7 := m5.Add(m6)
-		
-		// Keep some results to prevent optimization away
-		if i%100 == 0 {
-			results = append(results, m7)
+	markPhase("measure", true)
+	var latencies []*latencyHistogram
+	var stats []phaseStats
+	if measured != nil {
+		stats, latencies = measured.run()
+	} else {
+		latencies = runIterations(ws, *iterations)
+	}
+	if psi != nil {
+		psi.Stop()
+	}
+	if mu != nil {
+		mu.Stop()
+	}
+	harvest.Stop()
+	if outliers != nil {
+		outliers.Stop()
+	}
+	if budget != nil {
+		budget.Stop()
+	}
+	if quota != nil {
+		if err := quota.Stop(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			stopMarkers()
+			os.Exit(2)
 		}
 	}
+	if hog != nil {
+		hog.Stop()
+	}
+	if occupancy != nil {
+		occupancy.Stop()
+	}
+	if slowIterations != nil {
+		slowIterations.Stop()
+	}
+	markPhase("measure", false)
 	
 	duration := time.Since(startTime)
+	pauses := readPauses().Since(pausesBefore)
+	pausePct := pauseSet.Percentiles()
+	checkPauseAssertions(pausePct)
+	checkPauseBudgetAssertion(budget)
+	if err := writePauseFile(pauses); err != nil {
+		fmt.Fprintf(os.Stderr, "writing pauses: %v\n", err)
+		stopMarkers()
+		os.Exit(2)
+	}
 	
 	// Capture final GC stats
 	runtime.GC() // Force final GC to get accurate stats
 	var memStatsAfter runtime.MemStats
 	runtime.ReadMemStats(&memStatsAfter)
 	gcStatsAfter := getGCStats()
+	reps.run(ws, phases)
+	if err := profileBundle(ws, phases); err != nil {
+		fmt.Fprintf(os.Stderr, "writing bundle profiles: %v\n", err)
+		stopMarkers()
+		os.Exit(2)
+	}
 	
 	// Calculate differences
 	numGCs := gcStatsAfter.NumGC - gcStatsBefore.NumGC
+	var baseline baselineResult
+	if *runBaseline {
+		fmt.Println("Running baseline...")
+		baseline = measureBaseline(ws, *workers, *iterations)
+	}
 	totalPause := gcStatsAfter.PauseTotal - gcStatsBefore.PauseTotal
 	totalAlloc := memStatsAfter.TotalAlloc - memStatsBefore.TotalAlloc
+	var allocStrategy, defaultAlloc allocRun
+	if s, ok := ws[0].(workloadAllocStrategy); ok && *mode == "benchmark" && s.AllocStrategy() != "heap" {
+		fmt.Println("Running default allocation strategy...")
+		allocStrategy = allocRun{
+			strategy:   s.AllocStrategy(),
+			layout:     layout,
+			duration:   duration,
+			allocs:     memStatsAfter.Mallocs - memStatsBefore.Mallocs,
+			totalAlloc: memStatsAfter.TotalAlloc - memStatsBefore.TotalAlloc,
+			numGC:      numGCs,
+			pause:      totalPause,
+		}
+		defaultAlloc = measureAlloc(ws[:*workers], "heap", *warmupIters, *iterations)
+	}
 	
 	// Print results
 	fmt.Println()
-	fmt.Println("=== Results ===")
-	fmt.Printf("Total Duration: %v\n", duration)
-	fmt.Printf("Operations/sec: %.2f\n", float64(iterations)/duration.Seconds())
+	printSection("Results")
+	printMetric("Total Duration", "%s", formatDuration(duration))
+	printMetric("Operations/sec", "%.2f", float64(*iterations)/duration.Seconds())
 	fmt.Println()
 	
-	fmt.Println("=== Memory Statistics ===")
-	fmt.Printf("Total Allocated: %.2f MB\n", float64(totalAlloc)/(1024*1024))
-	fmt.Printf("Heap Allocated: %.2f MB\n", float64(memStatsAfter.HeapAlloc)/(1024*1024))
-	fmt.Printf("Heap Objects: %d\n", memStatsAfter.HeapObjects)
+	printSection("Memory Statistics")
+	printMetric("Total Allocated", "%s", formatBytes(totalAlloc))
+	printMetric("Heap Allocated", "%s", formatBytes(memStatsAfter.HeapAlloc))
+	printMetric("Heap Objects", "%d", memStatsAfter.HeapObjects)
 	fmt.Println()
 	
-	fmt.Println("=== Garbage Collection Statistics ===")
-	fmt.Printf("Number of GCs: %d\n", numGCs)
-	fmt.Printf("Total GC Pause: %v\n", totalPause)
+	printSection("Garbage Collection Statistics")
+	printMetric("Number of GCs", "%d", numGCs)
+	printMetric("Total GC Pause", "%s", formatDuration(totalPause))
 	if numGCs > 0 {
 		avgPause := totalPause / time.Duration(numGCs)
-		fmt.Printf("Average GC Pause: %v\n", avgPause)
-		fmt.Printf("GC Pause Overhead: %.2f%%\n", 
+		printMetric("Average GC Pause", "%s", formatDuration(avgPause))
+		printMetric("GC Pause Overhead", "%.2f%%",
 			(float64(totalPause)/float64(duration))*100)
 	}
-	fmt.Printf("Last GC Pause: %v\n", gcStatsAfter.LastPause)
+	printMetric("Last GC Pause", "%s", formatDuration(gcStatsAfter.LastPause))
+	if pausePct.max > 0 {
+		printMetric("p50 GC Pause", "%s", formatDuration(pausePct.p50))
+		printMetric("p90 GC Pause", "%s", formatDuration(pausePct.p90))
+		printMetric("p99 GC Pause", "%s", formatDuration(pausePct.p99))
+		printMetric("p99.9 GC Pause", "%s", formatDuration(pausePct.p999))
+		printMetric("Max GC Pause", "%s", formatDuration(pausePct.max))
+	}
 	fmt.Println()
 	
-	fmt.Println("=== Performance Metrics ===")
+	if outliers != nil {
+		outliers.Report()
+		fmt.Println()
+	}
+
+	if budget != nil {
+		budget.Report()
+		fmt.Println()
+	}
+
+	printSection("Performance Metrics")
 	gcCPUFraction := memStatsAfter.GCCPUFraction
-	fmt.Printf("GC CPU Fraction: %.2f%%\n", gcCPUFraction*100)
-	fmt.Printf("Time per iteration: %v\n", duration/iterations)
+	printMetric("GC CPU Fraction", "%.2f%%", gcCPUFraction*100)
+	printMetric("Time per iteration", "%s", formatDuration(duration/time.Duration(*iterations)))
 	
-	// Keep results alive
-	_ = results
+	if reps != nil {
+		fmt.Println()
+		reps.Report()
+	}
+
+	if *runBaseline {
+		fmt.Println()
+		printBaseline(baseline, duration)
+	}
+
+	if defaultAlloc.strategy != "" {
+		fmt.Println()
+		printAllocComparison(allocStrategy, defaultAlloc)
+	}
+
+	if iterationLatencies != nil {
+		fmt.Println()
+		iterationLatencies.Report()
+		if *slowestIterations > 0 {
+			fmt.Println()
+			iterationLatencies.ReportSlowest()
+			fmt.Println()
+			iterationLatencies.ReportGCAttribution()
+		}
+	}
+
+	if latencies != nil {
+		fmt.Println()
+		printWorkerLatency(latencies)
+	}
+
+	if stats != nil {
+		if *warmupIters > 0 {
+			stats = append(warmupStats, stats...)
+		}
+		printPhaseStats(stats)
+	}
+
+	if measuredIterations.wantPhases() {
+		fmt.Println()
+		measuredIterations.Report()
+	}
+	if measuredIterations != nil {
+		if err := measuredIterations.WriteFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing iterations: %v\n", err)
+			stopMarkers()
+			os.Exit(2)
+		}
+	}
+	if err := writeHDRLog(measuredIterations, pauseSet); err != nil {
+		fmt.Fprintf(os.Stderr, "writing HDR histogram log: %v\n", err)
+		stopMarkers()
+		os.Exit(2)
+	}
+
+	if mu != nil {
+		fmt.Println()
+		mu.Report()
+		if err := mu.WriteFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing mutator utilization: %v\n", err)
+			stopMarkers()
+			os.Exit(2)
+		}
+	}
+
+	if psi != nil {
+		fmt.Println()
+		psi.Report()
+	}
+
+	if hog != nil {
+		fmt.Println()
+		hog.Report()
+	}
+
+	if quota != nil {
+		fmt.Println()
+		quota.Report()
+	}
+
+	if occupancy != nil {
+		fmt.Println()
+		occupancy.Report()
+	}
+
+	if slowIterations != nil {
+		fmt.Println()
+		slowIterations.Report()
+		if err := slowIterations.WriteFile(); err != nil {
+			fmt.Fprintf(os.Stderr, "writing slow iteration stacks: %v\n", err)
+			stopMarkers()
+			os.Exit(2)
+		}
+	}
+
+	fmt.Println()
+	peak := peakHeap.Stop()
+	printHeadroom(peak)
+	checkMemoryAssertions(peak)
+
+	reportWorkloads(ws)
+
+	// Keep the workload's retained objects alive until the end
+	runtime.KeepAlive(ws)
+
+	results := &runResults{
+		Iterations:      *iterations,
+		DurationNs:      int64(duration),
+		OpsPerSec:       float64(*iterations) / duration.Seconds(),
+		TotalAllocBytes: totalAlloc,
+		TotalAllocs:     memStatsAfter.Mallocs - memStatsBefore.Mallocs,
+		HeapAllocBytes:  memStatsAfter.HeapAlloc,
+		HeapObjects:     memStatsAfter.HeapObjects,
+		NumGC:           numGCs,
+		TotalPauseNs:    int64(totalPause),
+		LastPauseNs:     int64(gcStatsAfter.LastPause),
+		P50PauseNs:      int64(pausePct.p50),
+		P90PauseNs:      int64(pausePct.p90),
+		P99PauseNs:      int64(pausePct.p99),
+		P999PauseNs:     int64(pausePct.p999),
+		MaxPauseNs:      int64(pausePct.max),
+		GCCPUFraction:   gcCPUFraction,
+	}
+	if numGCs > 0 {
+		results.AvgPauseNs = int64(totalPause / time.Duration(numGCs))
+	}
+	checkRegressionGate(results)
+	finishRun(*mode, ws[0].Name(), results, stopMarkers)
+}
+
+// finishRun prints the closing sections every measuring mode shares, writes
+// the structured report if one was requested and exits with status 1 if an
+// assertion failed. results is nil outside benchmark mode.
+func finishRun(mode, workload string, results *runResults, cleanup func()) {
+	printBreaches()
+	failed := printAssertions()
+
+	fmt.Println()
+	printCapabilities()
+
+	fmt.Println()
+	printProvenance()
+
+	if err := writeReport(mode, workload, results); err != nil {
+		fmt.Fprintf(os.Stderr, "writing %s report: %v\n", *outputFormat, err)
+		cleanup()
+		os.Exit(2)
+	}
+	if err := writeHTMLReport(mode, workload); err != nil {
+		fmt.Fprintf(os.Stderr, "writing HTML report: %v\n", err)
+		cleanup()
+		os.Exit(2)
+	}
+	if err := writeBundle(mode, workload, results); err != nil {
+		fmt.Fprintf(os.Stderr, "writing bundle: %v\n", err)
+		cleanup()
+		os.Exit(2)
+	}
+	sendIPCResult(results, !failed)
+	if failed {
+		exitAssertionsFailed(cleanup)
+	}
 	
 	fmt.Println()
 	fmt.Println("Benchmark complete!")
-}
\ No newline at end of file
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	muWindow = flag.Duration("mu-window", 0,
+		"sample the mutator utilization timeline over the measured phase in windows of this length, e.g. 10ms (0 disables)")
+	muOut = flag.String("mu-out", "",
+		"write the mutator utilization timeline to this file as CSV")
+)
+
+// muMetrics are the CPU classes GC work is read from. Idle-priority mark
+// work only uses CPU the mutator left idle, so it isn't counted against
+// utilization.
+var muMetrics = []string{
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/gc/mark/idle:cpu-seconds",
+}
+
+// muSample is one window of the timeline
+type muSample struct {
+	start   time.Duration // On the run clock
+	length  time.Duration // Actual length; the sampler can be delayed
+	marking bool          // Whether a GC cycle was marking in the window
+	gcCPU   float64       // GC CPU seconds attributed to the window
+}
+
+// muTimeline samples the share of CPU left to the mutator in fixed windows.
+// The runtime only adds a cycle's GC CPU time to its CPU classes when the
+// cycle ends, so the timeline notes which windows each cycle was marking in
+// (see gcPhaseProbe) and spreads the cycle's GC CPU evenly over them once it
+// is known. Utilization dips are therefore located to the window, and their
+// depth is the cycle's average.
+type muTimeline struct {
+	window  time.Duration
+	procs   int
+	samples []muSample
+
+	probe  *gcPhaseProbe
+	cpu    []metrics.Sample
+	ticker *time.Ticker
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// muPreallocated is how much of the timeline has its samples allocated up
+// front. Longer runs grow the buffer while sampling, which allocates.
+const muPreallocated = time.Minute
+
+// validateMUTimeline checks -mu-out has a timeline to write
+func validateMUTimeline() error {
+	if *muOut != "" && *muWindow <= 0 {
+		return fmt.Errorf("-mu-out needs -mu-window")
+	}
+	return nil
+}
+
+// startMUTimeline starts sampling in windows of window, returning nil when
+// it isn't positive
+func startMUTimeline(window time.Duration) *muTimeline {
+	if window <= 0 || !featureSupported("mutator utilization timeline", muMetrics...) {
+		return nil
+	}
+	t := &muTimeline{
+		window:  window,
+		procs:   runtime.GOMAXPROCS(0),
+		samples: make([]muSample, 0, min(muPreallocated/window, 1<<20)+1),
+		probe:   newGCPhaseProbe(),
+		cpu:     make([]metrics.Sample, len(muMetrics)),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for i, name := range muMetrics {
+		t.cpu[i].Name = name
+	}
+	t.ticker = time.NewTicker(t.window)
+	go t.sample(time.Now(), t.probe.Read(), t.readGCCPU())
+	return t
+}
+
+// readGCCPU returns the GC CPU seconds counted against the mutator so far
+func (t *muTimeline) readGCCPU() float64 {
+	metrics.Read(t.cpu)
+	return t.cpu[0].Value.Float64() - t.cpu[1].Value.Float64()
+}
+
+func (t *muTimeline) sample(began time.Time, prevState gcState, prevCPU float64) {
+	defer close(t.done)
+	prevTime := began
+	pending := 0 // First window of the cycle whose GC CPU isn't known yet
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-t.ticker.C:
+			state, gcCPU := t.probe.Read(), t.readGCCPU()
+			i := len(t.samples)
+			t.samples = append(t.samples, muSample{
+				start:   runClock(prevTime),
+				length:  now.Sub(prevTime),
+				marking: gcPhaseBetween(prevState, state) != gcPhaseOff,
+			})
+			if delta := gcCPU - prevCPU; delta > 0 {
+				t.attribute(pending, delta)
+				pending = i + 1
+			}
+			prevTime, prevState, prevCPU = now, state, gcCPU
+		}
+	}
+}
+
+// attribute spreads GC CPU seconds evenly over the marking windows from
+// window first on, or gives them to the latest window if none was marking
+func (t *muTimeline) attribute(first int, gcCPU float64) {
+	marking := 0
+	for _, s := range t.samples[first:] {
+		if s.marking {
+			marking++
+		}
+	}
+	if marking == 0 {
+		t.samples[len(t.samples)-1].gcCPU += gcCPU
+		return
+	}
+	for w := first; w < len(t.samples); w++ {
+		if t.samples[w].marking {
+			t.samples[w].gcCPU += gcCPU / float64(marking)
+		}
+	}
+}
+
+// Stop ends sampling
+func (t *muTimeline) Stop() {
+	close(t.stop)
+	<-t.done
+	t.ticker.Stop()
+}
+
+// utilization returns the mutator's share of CPU in window i
+func (t *muTimeline) utilization(i int) float64 {
+	capacity := t.samples[i].length.Seconds() * float64(t.procs)
+	return max(0, 1-t.samples[i].gcCPU/capacity)
+}
+
+// Report summarizes the timeline: the time-weighted mean, the minimum (the
+// minimum mutator utilization at this window size) and where it occurred
+func (t *muTimeline) Report() {
+	printSection("Mutator Utilization")
+	printMetric("MU Window", "%s", formatDuration(t.window))
+	printMetric("MU Windows", "%d", len(t.samples))
+	if len(t.samples) == 0 {
+		return
+	}
+	var weighted, total float64
+	worst, below := 0, 0
+	for i, s := range t.samples {
+		u := t.utilization(i)
+		weighted += u * s.length.Seconds()
+		total += s.length.Seconds()
+		if u < t.utilization(worst) {
+			worst = i
+		}
+		if u < 0.5 {
+			below++
+		}
+	}
+	printMetric("Mean Mutator Utilization", "%.2f%%", weighted/total*100)
+	printMetric("Minimum Mutator Utilization", "%.2f%%", t.utilization(worst)*100)
+	printMetric("Minimum At", "%s", formatDuration(t.samples[worst].start))
+	printMetric("Windows Below 50%", "%d", below)
+}
+
+// WriteFile writes the timeline to -mu-out as CSV, with each window's start
+// on the run clock and as an absolute time
+func (t *muTimeline) WriteFile() error {
+	if *muOut == "" {
+		return nil
+	}
+	f, err := os.Create(*muOut)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"start_ms", "length_ms", "mutator_utilization", "marking", "start_unix_ns"})
+	origin := runClockOrigin()
+	for i, s := range t.samples {
+		w.Write([]string{
+			runClockMillis(s.start),
+			runClockMillis(s.length),
+			strconv.FormatFloat(t.utilization(i), 'f', 4, 64),
+			strconv.FormatBool(s.marking),
+			strconv.FormatInt(origin.Add(s.start).UnixNano(), 10),
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync/atomic"
+)
+
+var targetOccupancy = flag.Float64("occupancy", 0,
+	"benchmark mode: after warmup, set GOMEMLIMIT so the live heap is this fraction of the memory it leaves the heap, "+
+		"between 0 and 1, resizing it every GC cycle, "+
+		"and turn off GOGC unless it is set in the environment (0 disables)")
+
+// validateOccupancy checks -occupancy is a usable fraction and doesn't
+// conflict with settings it would override
+func validateOccupancy(mode string) error {
+	if *targetOccupancy == 0 {
+		return nil
+	}
+	if *targetOccupancy < 0 || *targetOccupancy >= 1 {
+		return fmt.Errorf("-occupancy must be between 0 and 1, got %g", *targetOccupancy)
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-occupancy is only supported in benchmark mode")
+	}
+	if os.Getenv("GOMEMLIMIT") != "" {
+		return fmt.Errorf("-occupancy sets the memory limit itself; unset GOMEMLIMIT")
+	}
+	return requireFeature("-occupancy", "/gc/heap/live:bytes", "/memory/classes/total:bytes",
+		"/memory/classes/heap/objects:bytes", "/memory/classes/heap/unused:bytes",
+		"/memory/classes/heap/free:bytes", "/memory/classes/heap/released:bytes")
+}
+
+// occupancyControl holds the heap occupancy, the live heap as a fraction of
+// the memory available to the heap, at a target: GC papers sweep occupancy
+// rather than GOGC, since it sets how much room the collector has
+// regardless of the live heap's size. It measures the live heap once the
+// workload has warmed up and gives the heap live / target of room. The
+// memory limit covers all of the runtime's memory, so GOMEMLIMIT is set to
+// that room plus the runtime's memory outside the heap. Unless GOGC was set
+// explicitly, it turns GOGC off so the limit alone paces the collector, the
+// way a fixed-size heap does. The live heap drifts during the run, so the
+// limit is sized again from the live heap at the end of every GC cycle,
+// and the occupancy each cycle actually achieved against the room it had is
+// recorded.
+type occupancyControl struct {
+	target     float64
+	live       uint64 // Live heap the first limit was sized from
+	peak       uint64 // Heap in use at the peak of an iteration on every instance
+	gcOff      bool
+	sample     []metrics.Sample
+	room       atomic.Uint64 // Memory the current limit leaves the heap
+	nonHeap    atomic.Uint64 // Runtime memory outside the heap when the limit was sized
+	limit      atomic.Int64
+	startRoom  uint64
+	startLimit int64
+	cycles     atomic.Uint64
+	sum        atomic.Uint64 // Sum of achieved occupancies, in millionths
+	highest    atomic.Uint64 // Peak achieved occupancy, in millionths
+	stopped    func()
+}
+
+// newOccupancySample returns the metrics the limit is sized from: the live
+// heap, then the runtime's total memory and the heap's classes of it
+func newOccupancySample() []metrics.Sample {
+	return []metrics.Sample{
+		{Name: "/gc/heap/live:bytes"},
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/objects:bytes"},
+		{Name: "/memory/classes/heap/unused:bytes"},
+		{Name: "/memory/classes/heap/free:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+}
+
+// setOccupancy sets the memory limit for -occupancy from the current live
+// heap, returning nil when -occupancy is disabled. The heap must hold the
+// live heap plus what an iteration allocates before a collection can free
+// any of it, so it runs one iteration on each of ws with the collector off
+// to measure that peak, and refuses a target whose room is below it: the
+// limit couldn't be met, and the collector would run back to back.
+func setOccupancy(ws []Workload) (*occupancyControl, error) {
+	if *targetOccupancy == 0 {
+		return nil, nil
+	}
+	o := &occupancyControl{
+		target: *targetOccupancy,
+		sample: newOccupancySample(),
+	}
+
+	runtime.GC()
+	metrics.Read(o.sample)
+	inUse := o.sample[2].Value.Uint64()
+	percent := debug.SetGCPercent(-1)
+	for _, w := range ws {
+		w.Iterate(*warmupIters)
+	}
+	metrics.Read(o.sample)
+	o.peak = o.sample[2].Value.Uint64() - inUse
+	debug.SetGCPercent(percent)
+
+	runtime.GC()
+	metrics.Read(o.sample)
+	o.live = o.sample[0].Value.Uint64()
+	o.peak += o.live
+	if room := uint64(float64(o.live) / o.target); room < o.peak {
+		return nil, fmt.Errorf("-occupancy %g leaves the heap %s, less than the %s it holds at the peak of an iteration "+
+			"on a %s live heap; the highest occupancy that can be met is %.2f",
+			o.target, formatBytes(room), formatBytes(o.peak), formatBytes(o.live), float64(o.live)/float64(o.peak))
+	}
+	o.size(o.sample)
+	o.startRoom, o.startLimit = o.room.Load(), o.limit.Load()
+	if os.Getenv("GOGC") == "" {
+		debug.SetGCPercent(-1)
+		o.gcOff = true
+	}
+	return o, nil
+}
+
+// size sets the memory limit from the live heap and memory classes read
+// into sample
+func (o *occupancyControl) size(sample []metrics.Sample) {
+	room := uint64(float64(sample[0].Value.Uint64()) / o.target)
+	heap := uint64(0)
+	for _, class := range sample[2:] {
+		heap += class.Value.Uint64()
+	}
+	nonHeap := sample[1].Value.Uint64() - heap
+	o.room.Store(room)
+	o.nonHeap.Store(nonHeap)
+	o.limit.Store(int64(room + nonHeap))
+	debug.SetMemoryLimit(int64(room + nonHeap))
+}
+
+// Start starts recording the occupancy of each GC cycle
+func (o *occupancyControl) Start() {
+	o.stopped = watchGCCycles(func(uint32) { o.record(o.sample) })
+}
+
+// record adds the occupancy of the latest GC cycle against the room it had,
+// reading the live heap and memory classes into sample, then sizes the
+// limit again for the next
+func (o *occupancyControl) record(sample []metrics.Sample) {
+	metrics.Read(sample)
+	achieved := uint64(float64(sample[0].Value.Uint64()) / float64(o.room.Load()) * 1e6)
+	o.cycles.Add(1)
+	o.sum.Add(achieved)
+	if achieved > o.highest.Load() {
+		o.highest.Store(achieved)
+	}
+	o.size(sample)
+}
+
+// Stop stops recording. The finalizer that notices cycles can lag behind
+// them, so if it noticed none, the latest cycle's live heap is recorded.
+func (o *occupancyControl) Stop() {
+	o.stopped()
+	if o.cycles.Load() == 0 {
+		// A sample of its own, in case the finalizer is still running
+		o.record(newOccupancySample())
+	}
+}
+
+// Report prints the limits that were set and the occupancy achieved
+func (o *occupancyControl) Report() {
+	printSection("Heap Occupancy")
+	printMetric("Target Occupancy", "%.1f%%", o.target*100)
+	printMetric("Live Heap at Start", "%s", formatBytes(o.live))
+	printMetric("Peak Heap per Iteration", "%s", formatBytes(o.peak))
+	printMetric("Heap Room at Start", "%s", formatBytes(o.startRoom))
+	printMetric("GOMEMLIMIT at Start", "%s", formatBytes(uint64(o.startLimit)))
+	printMetric("Heap Room at End", "%s", formatBytes(o.room.Load()))
+	printMetric("Non-Heap Runtime Memory at End", "%s", formatBytes(o.nonHeap.Load()))
+	printMetric("GOMEMLIMIT at End", "%s", formatBytes(uint64(o.limit.Load())))
+	if o.gcOff {
+		printMetric("GOGC", "off")
+	} else {
+		printMetric("GOGC", "%s", os.Getenv("GOGC"))
+	}
+	cycles := o.cycles.Load()
+	printMetric("Cycles Observed", "%d", cycles)
+	if cycles == 0 {
+		return
+	}
+	printMetric("Mean Achieved Occupancy", "%.1f%%", float64(o.sum.Load())/float64(cycles)/1e4)
+	printMetric("Peak Achieved Occupancy", "%.1f%%", float64(o.highest.Load())/1e4)
+}
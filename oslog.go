@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+var osLogMarkers = flag.Bool("oslog", false,
+	"macOS only: log benchmark phase and GC cycle markers to the unified log for the Instruments os_log track")
+
+// validateOSLog checks that -oslog can be honored on this platform and
+// starts the logger process that writes the markers, logging each line of
+// its input as a message. They are plain os_log messages, not os_signpost
+// intervals, which are only reachable through cgo.
+func validateOSLog() error {
+	if !*osLogMarkers {
+		return nil
+	}
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("-oslog is only supported on macOS, not %s", runtime.GOOS)
+	}
+	if *etwMarkers {
+		return fmt.Errorf("-oslog and -etw are mutually exclusive")
+	}
+	p, err := startPipeMarkers(exec.Command("logger", "-t", "green-tea-benchmark"), "")
+	if err != nil {
+		return fmt.Errorf("-oslog: %v", err)
+	}
+	markers = p
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	pauseWindow = flag.Duration("pause-window", 0,
+		"account GC pause time in windows of this length over the measured phase, e.g. 1s, and list the worst (0 disables)")
+	pauseWorstWindows = flag.Int("pause-worst-windows", 5,
+		"with -pause-window, the number of worst windows to list")
+)
+
+// pauseBudgetPreallocated is how much of the measured phase has its windows
+// allocated up front. Longer runs grow the buffer while harvesting, which
+// allocates.
+const pauseBudgetPreallocated = 10 * time.Minute
+
+// pauseWindowTotal is the GC pause accounted to one window
+type pauseWindowTotal struct {
+	pause  time.Duration
+	cycles int
+}
+
+// pauseBudget totals GC pause time per fixed window of the measured phase,
+// the form pause SLOs usually take ("at most 10ms of pause per second"),
+// which neither the total pause nor the pause distribution shows: many
+// short pauses close together can break a budget no single pause comes near.
+// It takes cycles' pauses from the shared harvest. A cycle's pause, the sum
+// of its stop-the-world pauses, is accounted to the window its last pause
+// ended in.
+type pauseBudget struct {
+	mu      sync.Mutex
+	window  time.Duration
+	started time.Time
+	ended   time.Time
+	windows []pauseWindowTotal
+}
+
+// startPauseBudget starts accounting, taking pauses from harvest, and
+// returns nil when disabled
+func startPauseBudget(harvest *pauseHarvest) *pauseBudget {
+	if *pauseWindow <= 0 {
+		return nil
+	}
+	b := &pauseBudget{
+		window:  *pauseWindow,
+		windows: make([]pauseWindowTotal, 0, min(pauseBudgetPreallocated / *pauseWindow, 1<<20)+1),
+		started: time.Now(),
+	}
+	harvest.Subscribe(b.take)
+	return b
+}
+
+// take accounts a harvested cycle's pause to its window
+func (b *pauseBudget) take(_ uint32, pause time.Duration, end time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if end.Before(b.started) {
+		return
+	}
+	w := int(end.Sub(b.started) / b.window)
+	for len(b.windows) <= w {
+		b.windows = append(b.windows, pauseWindowTotal{})
+	}
+	b.windows[w].pause += pause
+	b.windows[w].cycles++
+}
+
+// Stop ends accounting, adding the windows up to now that had none. The
+// harvest must be stopped first, so the pauses of the remaining cycles are
+// in.
+func (b *pauseBudget) Stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.ended = time.Now()
+	for len(b.windows) <= int(b.ended.Sub(b.started)/b.window) {
+		b.windows = append(b.windows, pauseWindowTotal{})
+	}
+}
+
+// Max returns the most pause accounted to any window
+func (b *pauseBudget) Max() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.maxPause()
+}
+
+// maxPause returns the most pause accounted to any window. b.mu must be
+// held.
+func (b *pauseBudget) maxPause() time.Duration {
+	var worst time.Duration
+	for _, w := range b.windows {
+		worst = max(worst, w.pause)
+	}
+	return worst
+}
+
+// Report prints the mean and worst pause per window, the windows over the
+// -assert-window-pause budget if one is set, and the worst windows, worst
+// first. The last window is usually cut short by the end of the phase.
+func (b *pauseBudget) Report() {
+	printSection("Pause Budget")
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	printMetric("Pause Window", "%s", formatDuration(b.window))
+	printMetric("Pause Windows", "%d", len(b.windows))
+	var total time.Duration
+	over := 0
+	for _, w := range b.windows {
+		total += w.pause
+		if *assertWindowPause > 0 && w.pause > *assertWindowPause {
+			over++
+		}
+	}
+	// Scaled to a full window, so runs of different lengths compare
+	printMetric("Mean Pause per Window", "%s",
+		formatDuration(time.Duration(float64(total)*float64(b.window)/float64(b.ended.Sub(b.started)))))
+	printMetric("Max Pause per Window", "%s", formatDuration(b.maxPause()))
+	if *assertWindowPause > 0 {
+		printMetric("Windows Over Budget", "%d", over)
+	}
+
+	order := make([]int, len(b.windows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return b.windows[order[i]].pause > b.windows[order[j]].pause })
+	for n, i := range order[:min(len(order), *pauseWorstWindows)] {
+		w := b.windows[i]
+		if w.pause == 0 {
+			break
+		}
+		start := runClock(b.started) + time.Duration(i)*b.window
+		printMetric(fmt.Sprintf("Worst Window %d", n+1), "%s in %d cycles from %s (%.2f%% of the window)",
+			formatDuration(w.pause), w.cycles, formatDuration(start), float64(w.pause)/float64(b.window)*100)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// pauseRingSize is the number of recent cycles whose pauses MemStats keeps
+const pauseRingSize = len(runtime.MemStats{}.PauseNs)
+
+// pauseHarvestEvery is how many cycles the harvest lets pass before reading
+// pauses out of MemStats, leaving slack before the ring wraps
+const pauseHarvestEvery = pauseRingSize * 3 / 4
+
+// pauseHarvest reads the pause of every GC cycle out of MemStats for each
+// instrument that needs them. The runtime only keeps the durations of the
+// last pauseRingSize cycles, so the harvest reads them every
+// pauseHarvestEvery cycles and once more when it stops. Reading MemStats
+// stops the world briefly, outside any GC pause, so the instruments share
+// one harvest rather than each stopping the world for its own, and none is
+// taken when no instrument needs the pauses.
+type pauseHarvest struct {
+	mu        sync.Mutex
+	harvested uint32 // Cycles up to this one have been handed out
+	memStats  runtime.MemStats
+	consumers []func(cycle uint32, pause time.Duration, end time.Time)
+	stop      func()
+}
+
+// Subscribe has fn called with the pause of every cycle the harvest reads,
+// oldest first, and when its last pause ended. It must be called before
+// Start; fn runs on the finalizer goroutine, so it must not block for long.
+func (h *pauseHarvest) Subscribe(fn func(cycle uint32, pause time.Duration, end time.Time)) {
+	h.consumers = append(h.consumers, fn)
+}
+
+// Start starts harvesting the cycles that end from now on, if anything
+// subscribed
+func (h *pauseHarvest) Start() {
+	if len(h.consumers) == 0 {
+		return
+	}
+	runtime.ReadMemStats(&h.memStats)
+	h.harvested = h.memStats.NumGC
+	h.stop = watchGCCycles(func(numGC uint32) {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if numGC-h.harvested >= uint32(pauseHarvestEvery) {
+			h.harvest()
+		}
+	})
+}
+
+// harvest hands out the pauses of the cycles since the last harvest. h.mu
+// must be held.
+func (h *pauseHarvest) harvest() {
+	h.harvested = readCyclePauses(&h.memStats, h.harvested, func(cycle uint32, pause time.Duration, end time.Time) {
+		for _, fn := range h.consumers {
+			fn(cycle, pause, end)
+		}
+	})
+}
+
+// Stop ends harvesting and hands out the pauses of the remaining cycles
+func (h *pauseHarvest) Stop() {
+	if h.stop == nil {
+		return
+	}
+	h.stop()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.harvest()
+}
+
+// readCyclePauses reads MemStats into ms and calls fn with the total pause
+// and the end of the last pause of every cycle after the given one still in
+// the ring, oldest first. It returns the latest cycle.
+func readCyclePauses(ms *runtime.MemStats, after uint32, fn func(cycle uint32, pause time.Duration, end time.Time)) uint32 {
+	runtime.ReadMemStats(ms)
+	latest := ms.NumGC
+	// Cycles that have left the ring are lost; harvesting often enough
+	// keeps this from happening unless the finalizer goroutine stalls
+	first := max(after+1, latest-min(latest, uint32(pauseRingSize)-1))
+	for cycle := first; cycle <= latest; cycle++ {
+		slot := (cycle - 1) % uint32(pauseRingSize)
+		fn(cycle, time.Duration(ms.PauseNs[slot]), time.Unix(0, int64(ms.PauseEnd[slot])))
+	}
+	return latest
+}
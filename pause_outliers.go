@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+var pauseOutliers = flag.Int("pause-outliers", 0,
+	"list the N longest GC pauses of the measured phase with the heap, phase and activity around each (0 disables)")
+
+// pauseContextMetrics describe the state of the program when a cycle ends
+var pauseContextMetrics = []string{
+	"/gc/heap/live:bytes",
+	"/gc/heap/goal:bytes",
+	"/gc/heap/allocs:bytes",
+	"/sched/goroutines:goroutines",
+}
+
+// pauseContext is the state of the program noted as a GC cycle ended
+type pauseContext struct {
+	cycle      uint32
+	phase      string
+	heapLive   uint64
+	heapGoal   uint64
+	goroutines uint64
+	allocRate  float64 // Bytes per second allocated since the previous noted cycle
+}
+
+// pauseOutlier is one of the longest pauses, with its cycle's context if
+// the cycle was noted
+type pauseOutlier struct {
+	cycle   uint32
+	pause   time.Duration
+	end     time.Time
+	noted   bool
+	context pauseContext
+}
+
+// pauseOutlierWatch keeps the longest GC pauses since it started. The
+// runtime only keeps the durations of the last pauseRingSize cycles, in
+// MemStats, so the watch notes each cycle's context as it ends and keeps
+// the longest of the pauses the shared harvest reads. Everything is
+// allocated up front, so watching allocates nothing per cycle.
+type pauseOutlierWatch struct {
+	mu        sync.Mutex
+	started   time.Time
+	contexts  [pauseRingSize]pauseContext
+	worst     []pauseOutlier // Longest first
+	sample    []metrics.Sample
+	noting    bool // Whether the runtime has the context metrics
+	prevAlloc uint64
+	prevAt    time.Duration
+	stop      func()
+}
+
+// startPauseOutliers starts watching, taking pauses from harvest, and
+// returns nil when disabled
+func startPauseOutliers(harvest *pauseHarvest) *pauseOutlierWatch {
+	if *pauseOutliers <= 0 {
+		return nil
+	}
+	p := &pauseOutlierWatch{
+		worst:  make([]pauseOutlier, 0, *pauseOutliers),
+		sample: make([]metrics.Sample, len(pauseContextMetrics)),
+		noting: featureSupported("pause outlier context", pauseContextMetrics...),
+	}
+	for i, name := range pauseContextMetrics {
+		p.sample[i].Name = name
+	}
+	metrics.Read(p.sample)
+	p.started = time.Now()
+	if p.noting {
+		p.prevAlloc = p.sample[2].Value.Uint64()
+	}
+	harvest.Subscribe(p.take)
+	p.stop = watchGCCycles(p.noteCycle)
+	return p
+}
+
+// noteCycle records the context of the cycle that just ended, if the
+// runtime provides it. It runs on the finalizer goroutine.
+func (p *pauseOutlierWatch) noteCycle(numGC uint32) {
+	if !p.noting {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	metrics.Read(p.sample)
+	at := time.Since(p.started)
+	alloc := p.sample[2].Value.Uint64()
+	c := &p.contexts[numGC%uint32(pauseRingSize)]
+	*c = pauseContext{
+		cycle:      numGC,
+		phase:      currentPhase(),
+		heapLive:   p.sample[0].Value.Uint64(),
+		heapGoal:   p.sample[1].Value.Uint64(),
+		goroutines: p.sample[3].Value.Uint64(),
+	}
+	if elapsed := at - p.prevAt; elapsed > 0 {
+		c.allocRate = float64(alloc-p.prevAlloc) / elapsed.Seconds()
+	}
+	p.prevAlloc, p.prevAt = alloc, at
+}
+
+// take keeps a harvested cycle's pause if it is among the longest, with
+// the cycle's context if it was noted
+func (p *pauseOutlierWatch) take(cycle uint32, pause time.Duration, end time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	o := pauseOutlier{cycle: cycle, pause: pause, end: end}
+	if c := &p.contexts[cycle%uint32(pauseRingSize)]; c.cycle == cycle {
+		o.noted, o.context = true, *c
+	}
+	p.consider(o)
+}
+
+// consider keeps o if it is among the longest pauses so far. p.mu must be
+// held.
+func (p *pauseOutlierWatch) consider(o pauseOutlier) {
+	n := len(p.worst)
+	if n == cap(p.worst) {
+		if o.pause <= p.worst[n-1].pause {
+			return
+		}
+		n--
+	}
+	p.worst = p.worst[:n+1]
+	i := n
+	for ; i > 0 && p.worst[i-1].pause < o.pause; i-- {
+		p.worst[i] = p.worst[i-1]
+	}
+	p.worst[i] = o
+}
+
+// Stop ends noting cycles' context; the harvest is stopped separately
+func (p *pauseOutlierWatch) Stop() {
+	p.stop()
+}
+
+// Report lists the longest pauses, longest first, with what was going on
+// when each cycle ended. A cycle's pause is the sum of its stop-the-world
+// pauses; the time is when the last of them ended, on the run clock. Cycles
+// are numbered from the start of the program, as in gctrace and the GC
+// timeline.
+func (p *pauseOutlierWatch) Report() {
+	printSection("Pause Outliers")
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.worst) == 0 {
+		fmt.Println("No GC cycles")
+		return
+	}
+	for n, o := range p.worst {
+		desc := fmt.Sprintf("%s in cycle %d at %s", formatDuration(o.pause), o.cycle,
+			formatDuration(runClock(o.end)))
+		if o.noted {
+			c := o.context
+			phase := c.phase
+			if phase == "" {
+				phase = "none"
+			}
+			desc += fmt.Sprintf(" (phase %s, live heap %s, goal %s, %d goroutines, allocating %s/s)",
+				phase, formatBytes(c.heapLive), formatBytes(c.heapGoal), c.goroutines,
+				formatBytes(uint64(c.allocRate)))
+		} else {
+			desc += " (context not captured)"
+		}
+		printMetric(fmt.Sprintf("Outlier %d", n+1), "%s", desc)
+	}
+}
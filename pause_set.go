@@ -0,0 +1,70 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// pauseSetCapacity is the number of cycles the pause set has room for up
+// front; beyond it, the set grows as a slice does
+const pauseSetCapacity = 4096
+
+// pauseSet collects the exact pause of every GC cycle since it started, the
+// sum of the cycle's stop-the-world pauses, with when it ended, so the
+// report's pause percentiles and the HdrHistogram log are measured rather
+// than read off the runtime histogram's buckets, and count cycles as the
+// total and average pause do. It takes the pauses from the shared harvest.
+type pauseSet struct {
+	mu     sync.Mutex
+	pauses []time.Duration
+	ends   []time.Duration // When each pause ended, on the run clock
+}
+
+// startPauseSet starts collecting pauses from harvest
+func startPauseSet(harvest *pauseHarvest) *pauseSet {
+	s := &pauseSet{
+		pauses: make([]time.Duration, 0, pauseSetCapacity),
+		ends:   make([]time.Duration, 0, pauseSetCapacity),
+	}
+	harvest.Subscribe(s.take)
+	return s
+}
+
+// take adds a harvested cycle's pause
+func (s *pauseSet) take(_ uint32, pause time.Duration, end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pauses = append(s.pauses, pause)
+	s.ends = append(s.ends, runClock(end))
+}
+
+// Pauses returns the pauses collected, oldest first, and when each ended on
+// the run clock. The harvest must be stopped first, so the pauses of the
+// last cycles are in.
+func (s *pauseSet) Pauses() (pauses, ends []time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pauses, s.ends
+}
+
+// pausePercentiles are the pause percentiles the report prints and the
+// pause assertions judge
+type pausePercentiles struct {
+	p50, p90, p99, p999, max time.Duration
+}
+
+// Percentiles returns the percentiles of the pauses collected by nearest
+// rank, all zero if no cycle ran. The harvest must be stopped first.
+func (s *pauseSet) Percentiles() pausePercentiles {
+	s.mu.Lock()
+	sorted := append([]time.Duration(nil), s.pauses...)
+	s.mu.Unlock()
+	sortDurations(sorted)
+	return pausePercentiles{
+		p50:  durationPercentile(sorted, 50),
+		p90:  durationPercentile(sorted, 90),
+		p99:  durationPercentile(sorted, 99),
+		p999: durationPercentile(sorted, 99.9),
+		max:  durationPercentile(sorted, 100),
+	}
+}
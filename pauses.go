@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime/metrics"
+	"time"
+)
+
+var pausesOut = flag.String("pauses-out", "",
+	"write the GC pause distribution of the measured phase to this file as CSV, for analyze_results.py's pause CDF comparison")
+
+// pauseMetric is the runtime's histogram of GC stop-the-world pauses. Each
+// GC cycle contributes two pauses, sweep termination and mark termination.
+const pauseMetric = "/sched/pauses/total/gc:seconds"
+
+// pauseDistribution is the distribution of GC pauses over a phase of the run
+type pauseDistribution struct {
+	counts  []uint64
+	buckets []float64 // Bucket boundaries in seconds, len(counts)+1 of them
+}
+
+// readPauses returns the pause distribution since the program started
+func readPauses() pauseDistribution {
+	if !featureSupported("pause distribution", pauseMetric) {
+		return pauseDistribution{}
+	}
+	sample := []metrics.Sample{{Name: pauseMetric}}
+	metrics.Read(sample)
+	h := sample[0].Value.Float64Histogram()
+	return pauseDistribution{
+		counts:  append([]uint64(nil), h.Counts...),
+		buckets: h.Buckets,
+	}
+}
+
+// Since returns the pauses that happened after earlier was read
+func (d pauseDistribution) Since(earlier pauseDistribution) pauseDistribution {
+	delta := pauseDistribution{counts: append([]uint64(nil), d.counts...), buckets: d.buckets}
+	delta.Sub(earlier)
+	return delta
+}
+
+// Sub removes the pauses of earlier, which must have been read before d,
+// from d in place
+func (d *pauseDistribution) Sub(earlier pauseDistribution) {
+	for i := range d.counts {
+		if i < len(earlier.counts) {
+			d.counts[i] -= earlier.counts[i]
+		}
+	}
+}
+
+// pauseReader reads the pause distribution into buffers it keeps, so reads
+// after the first don't allocate
+type pauseReader struct {
+	sample    []metrics.Sample
+	supported bool
+}
+
+func newPauseReader() *pauseReader {
+	r := &pauseReader{
+		sample:    []metrics.Sample{{Name: pauseMetric}},
+		supported: featureSupported("pause distribution", pauseMetric),
+	}
+	metrics.Read(r.sample)
+	return r
+}
+
+// ReadInto stores the pause distribution since the program started in d,
+// reusing d's counts
+func (r *pauseReader) ReadInto(d *pauseDistribution) {
+	if !r.supported {
+		return
+	}
+	metrics.Read(r.sample)
+	h := r.sample[0].Value.Float64Histogram()
+	d.counts = append(d.counts[:0], h.Counts...)
+	d.buckets = h.Buckets
+}
+
+// Count returns the number of pauses
+func (d pauseDistribution) Count() uint64 {
+	var n uint64
+	for _, c := range d.counts {
+		n += c
+	}
+	return n
+}
+
+// Percentile returns the pause at the pth percentile by nearest rank. The
+// runtime only records which bucket a pause fell in, so this is the upper
+// bound of that bucket.
+func (d pauseDistribution) Percentile(p float64) time.Duration {
+	total := d.Count()
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range d.counts {
+		seen += c
+		if seen >= rank {
+			return d.bucketBound(i)
+		}
+	}
+	return d.Max()
+}
+
+// Max returns the upper bound of the highest non-empty bucket
+func (d pauseDistribution) Max() time.Duration {
+	for i := len(d.counts) - 1; i >= 0; i-- {
+		if d.counts[i] > 0 {
+			return d.bucketBound(i)
+		}
+	}
+	return 0
+}
+
+// Percentiles returns the distribution's percentiles for the pause
+// assertions of runs without a pause set. Unlike the pause set's, they are
+// per stop-the-world pause and bucket bounds.
+func (d pauseDistribution) Percentiles() pausePercentiles {
+	return pausePercentiles{
+		p50:  d.Percentile(50),
+		p90:  d.Percentile(90),
+		p99:  d.Percentile(99),
+		p999: d.Percentile(99.9),
+		max:  d.Max(),
+	}
+}
+
+// bucketBound returns the upper bound of bucket i, or its lower bound for
+// the final bucket, which is unbounded
+func (d pauseDistribution) bucketBound(i int) time.Duration {
+	hi := d.buckets[i+1]
+	if math.IsInf(hi, 1) {
+		hi = d.buckets[i]
+	}
+	return time.Duration(hi * float64(time.Second))
+}
+
+// writePauseFile writes the distribution to -pauses-out as CSV rows of
+// lower_ns,upper_ns,count for the non-empty buckets, for comparing
+// distributions across runs; the final bucket's upper bound is empty
+func writePauseFile(d pauseDistribution) error {
+	if *pausesOut == "" {
+		return nil
+	}
+	f, err := os.Create(*pausesOut)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"lower_ns", "upper_ns", "count"})
+	for i, c := range d.counts {
+		if c == 0 {
+			continue
+		}
+		upper := ""
+		if !math.IsInf(d.buckets[i+1], 1) {
+			upper = fmt.Sprint(int64(d.buckets[i+1] * 1e9))
+		}
+		w.Write([]string{fmt.Sprint(int64(max(d.buckets[i], 0) * 1e9)), upper, fmt.Sprint(c)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
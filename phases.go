@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var phaseSpec = flag.String("phases", "",
+	`measured phases as comma-separated name=iterations[@workers], e.g. "ramp=200@1,steady=1000@4,spike=200@8"; `+
+		`replaces -iters, and each phase is reported separately`)
+
+// phase is one segment of a scenario, such as a ramp, steady state or spike
+type phase struct {
+	name    string
+	iters   int
+	workers int
+}
+
+// parsePhases parses -phases; phases without @workers use defaultWorkers
+func parsePhases(spec string, defaultWorkers int) ([]phase, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var phases []phase
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		name, rest, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid phase %q (want name=iterations[@workers])", part)
+		}
+		if seen[name] || name == "warmup" {
+			return nil, fmt.Errorf("phase name %q is reserved or repeated", name)
+		}
+		seen[name] = true
+
+		p := phase{name: name, workers: defaultWorkers}
+		iters, workers, hasWorkers := strings.Cut(rest, "@")
+		n, err := strconv.Atoi(iters)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("phase %s: iterations must be a positive integer, got %q", name, iters)
+		}
+		p.iters = n
+		if hasWorkers {
+			w, err := strconv.Atoi(workers)
+			if err != nil || w < 1 {
+				return nil, fmt.Errorf("phase %s: workers must be a positive integer, got %q", name, workers)
+			}
+			p.workers = w
+		}
+		phases = append(phases, p)
+	}
+	return phases, nil
+}
+
+// phaseMetrics are the runtime/metrics read at phase boundaries
+var phaseMetrics = []string{
+	"/gc/cycles/total:gc-cycles",
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+	"/gc/heap/allocs:bytes",
+	"/gc/heap/goal:bytes",
+}
+
+// phaseStats are the metrics of one phase, measured on its own
+type phaseStats struct {
+	phase      phase
+	duration   time.Duration
+	numGC      uint64
+	pauses     pauseDistribution
+	gcCPU      float64 // Percent of CPU time spent in GC
+	allocBytes uint64
+	heapGoal   uint64            // At the end of the phase
+	latency    *latencyHistogram // Merged over workers; nil for a single worker
+}
+
+// phaseRecorder measures the phase between start and stop. Its buffers are
+// allocated up front, so measuring a phase doesn't allocate.
+type phaseRecorder struct {
+	samples []metrics.Sample
+	before  []metrics.Value
+	reader  *pauseReader
+	pauses  pauseDistribution
+	began   time.Time
+}
+
+func newPhaseRecorder() *phaseRecorder {
+	r := &phaseRecorder{
+		samples: make([]metrics.Sample, len(phaseMetrics)),
+		before:  make([]metrics.Value, len(phaseMetrics)),
+		reader:  newPauseReader(),
+	}
+	for i, name := range phaseMetrics {
+		r.samples[i].Name = name
+	}
+	r.reader.ReadInto(&r.pauses)
+	return r
+}
+
+func (r *phaseRecorder) start() {
+	metrics.Read(r.samples)
+	for i, s := range r.samples {
+		r.before[i] = s.Value
+	}
+	r.reader.ReadInto(&r.pauses)
+	r.began = time.Now()
+}
+
+// stop fills in st with the phase's metrics, merging the workers' latency
+// histograms into st.latency
+func (r *phaseRecorder) stop(st *phaseStats, latencies []*latencyHistogram) {
+	st.duration = time.Since(r.began)
+	metrics.Read(r.samples)
+	st.numGC = r.samples[0].Value.Uint64() - r.before[0].Uint64()
+	r.reader.ReadInto(&st.pauses)
+	st.pauses.Sub(r.pauses)
+	st.allocBytes = r.samples[3].Value.Uint64() - r.before[3].Uint64()
+	st.heapGoal = r.samples[4].Value.Uint64()
+	if cpu := r.samples[2].Value.Float64() - r.before[2].Float64(); cpu > 0 {
+		st.gcCPU = (r.samples[1].Value.Float64() - r.before[1].Float64()) / cpu * 100
+	}
+	if st.latency != nil {
+		*st.latency = latencyHistogram{}
+		for _, h := range latencies {
+			st.latency.Merge(h)
+		}
+	}
+}
+
+// phaseRun runs a sequence of phases. Everything measuring them needs is
+// allocated by newPhaseRun, before the measured window opens.
+type phaseRun struct {
+	ws       []Workload
+	recorder *phaseRecorder
+	stats    []phaseStats
+	merged   []*latencyHistogram // Per instance over all phases; nil if no phase runs several workers
+}
+
+// newPhaseRun prepares to run the phases on ws, which must hold enough
+// instances for every phase
+func newPhaseRun(ws []Workload, phases []phase) *phaseRun {
+	r := &phaseRun{ws: ws, recorder: newPhaseRecorder(), stats: make([]phaseStats, len(phases))}
+	for n, p := range phases {
+		st := &r.stats[n]
+		st.phase = p
+		r.recorder.reader.ReadInto(&st.pauses)
+		if p.workers > 1 {
+			st.latency = new(latencyHistogram)
+			if r.merged == nil {
+				r.merged = make([]*latencyHistogram, len(ws))
+				for n := range r.merged {
+					r.merged[n] = new(latencyHistogram)
+				}
+			}
+		}
+	}
+	iterationRunnerFor(ws)
+	return r
+}
+
+// run runs the phases in order and returns their stats together with each
+// instance's latency histogram merged over all phases, or nil if no phase
+// ran more than one worker
+func (r *phaseRun) run() ([]phaseStats, []*latencyHistogram) {
+	for n := range r.stats {
+		st := &r.stats[n]
+		r.recorder.start()
+		markPhase(st.phase.name, true)
+		latencies := runIterations(r.ws[:st.phase.workers], st.phase.iters)
+		markPhase(st.phase.name, false)
+		r.recorder.stop(st, latencies)
+		for n, h := range latencies {
+			r.merged[n].Merge(h)
+		}
+	}
+	return r.stats, r.merged
+}
+
+// warmUp runs the warmup on the first workers instances of ws, then warms up
+// the instances only later phases run one by one, each with an initial
+// instance's share of the warmup, and verifies that the instances did real
+// work. It returns the stats of the shared warmup.
+func warmUp(ws []Workload, workers, iters int) ([]phaseStats, error) {
+	allocs := []metrics.Sample{{Name: "/gc/heap/allocs:objects"}}
+	metrics.Read(allocs)
+	allocsBefore := allocs[0].Value.Uint64()
+
+	// The initial instances claim warmup iterations from a shared counter,
+	// so some of them may not get any; count what each one ran
+	ran := make([]uint64, len(ws))
+	stats, latencies := newPhaseRun(ws[:workers], []phase{{name: "warmup", iters: iters, workers: workers}}).run()
+	if latencies != nil {
+		for n, h := range latencies {
+			ran[n] = h.Count()
+		}
+	} else {
+		ran[0] = uint64(iters)
+	}
+	if iters > 0 {
+		extra := phase{name: "warmup", iters: max(iters/workers, 1), workers: 1}
+		for n := workers; n < len(ws); n++ {
+			newPhaseRun(ws[n:n+1], []phase{extra}).run()
+			ran[n] = uint64(extra.iters)
+		}
+	}
+
+	metrics.Read(allocs)
+	// With no warmup there is nothing to verify yet
+	if iters == 0 {
+		return stats, nil
+	}
+	return stats, verifyWorkloads(ws, ran, allocs[0].Value.Uint64()-allocsBefore)
+}
+
+// printPhaseStats prints a section per phase, so transient behavior in one
+// phase isn't averaged away by the others
+func printPhaseStats(stats []phaseStats) {
+	for _, st := range stats {
+		fmt.Println()
+		printSection("Phase: " + st.phase.name)
+		printMetric("Iterations", "%d", st.phase.iters)
+		printMetric("Workers", "%d", st.phase.workers)
+		printMetric("Duration", "%s", formatDuration(st.duration))
+		printMetric("Operations/sec", "%.2f", float64(st.phase.iters)/st.duration.Seconds())
+		printMetric("Total Allocated", "%s", formatBytes(st.allocBytes))
+		printMetric("Number of GCs", "%d", st.numGC)
+		printMetric("p50 GC Pause", "%s", formatDuration(st.pauses.Percentile(50)))
+		printMetric("p99 GC Pause", "%s", formatDuration(st.pauses.Percentile(99)))
+		printMetric("Max GC Pause", "%s", formatDuration(st.pauses.Max()))
+		printMetric("GC CPU Fraction", "%.2f%%", st.gcCPU)
+		printMetric("Heap Goal", "%s", formatBytes(st.heapGoal))
+		if st.latency != nil {
+			printMetric("Iteration p50", "%s", formatDuration(st.latency.Percentile(50)))
+			printMetric("Iteration p99", "%s", formatDuration(st.latency.Percentile(99)))
+		}
+	}
+}
+
+// phaseWorkers returns how many workload instances the phases need
+func phaseWorkers(phases []phase, workers int) int {
+	for _, p := range phases {
+		workers = max(workers, p.workers)
+	}
+	return workers
+}
+
+// phaseIterations returns the total measured iterations of the phases
+func phaseIterations(phases []phase) int {
+	total := 0
+	for _, p := range phases {
+		total += p.iters
+	}
+	return total
+}
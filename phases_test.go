@@ -0,0 +1,39 @@
+package main
+
+import "testing"
+
+// keepingWorkload allocates a result every iteration and keeps it
+type keepingWorkload struct{ Sink }
+
+func (w *keepingWorkload) Name() string  { return "keeping" }
+func (w *keepingWorkload) Iterate(i int) { w.Keep(make([]byte, 64+i)) }
+
+// discardingWorkload allocates a result every iteration but never keeps it
+type discardingWorkload struct {
+	Sink
+	last []byte
+}
+
+func (w *discardingWorkload) Name() string  { return "discarding" }
+func (w *discardingWorkload) Iterate(i int) { w.last = make([]byte, 64+i) }
+
+// TestWarmUpMoreWorkersThanIterations checks that instances the shared
+// warmup counter left without an iteration don't fail verification
+func TestWarmUpMoreWorkersThanIterations(t *testing.T) {
+	ws := make([]Workload, 5) // The fifth runs only in later phases
+	for n := range ws {
+		ws[n] = new(keepingWorkload)
+	}
+	if _, err := warmUp(ws, 4, 2); err != nil {
+		t.Fatalf("-workers 4 -warmup 2: %v", err)
+	}
+}
+
+// TestWarmUpUnfedSink checks that an instance that ran warmup iterations
+// without keeping a result still fails verification
+func TestWarmUpUnfedSink(t *testing.T) {
+	ws := []Workload{new(discardingWorkload), new(discardingWorkload)}
+	if _, err := warmUp(ws, 2, 10); err == nil {
+		t.Fatal("warmup of a workload that keeps nothing passed verification")
+	}
+}
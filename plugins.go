@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"plugin"
+	"strings"
+)
+
+// pluginList is a repeatable flag of plugin paths
+type pluginList []string
+
+func (p *pluginList) String() string { return strings.Join(*p, ",") }
+
+func (p *pluginList) Set(s string) error {
+	*p = append(*p, s)
+	return nil
+}
+
+var workloadPlugins pluginList
+
+func init() {
+	flag.Var(&workloadPlugins, "plugin",
+		"load additional workloads from a Go plugin (.so) built with -buildmode=plugin (repeatable)")
+}
+
+// pluginRegisterSymbol is the function every workload plugin exports. It is
+// called with a register function taking a workload name and constructor:
+//
+//	func RegisterWorkloads(register func(name string, newWorkload func() (any, error)))
+//
+// Constructors return any so plugins need not import this package; the
+// value must have the methods of Workload and may have those of the
+// optional workload interfaces.
+const pluginRegisterSymbol = "RegisterWorkloads"
+
+// loadPlugins opens every -plugin and registers the workloads it provides
+func loadPlugins() error {
+	for _, path := range workloadPlugins {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("loading plugin: %v", err)
+		}
+		sym, err := p.Lookup(pluginRegisterSymbol)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", path, err)
+		}
+		register, ok := sym.(func(func(string, func() (any, error))))
+		if !ok {
+			return fmt.Errorf("plugin %s: %s has type %T, want func(func(string, func() (any, error)))",
+				path, pluginRegisterSymbol, sym)
+		}
+
+		var regErr error
+		register(func(name string, newFn func() (any, error)) {
+			if _, dup := workloads[name]; dup {
+				if regErr == nil {
+					regErr = fmt.Errorf("plugin %s: workload %q is already registered", path, name)
+				}
+				return
+			}
+			registerWorkload(name, func() (Workload, error) {
+				v, err := newFn()
+				if err != nil {
+					return nil, err
+				}
+				w, ok := v.(Workload)
+				if !ok {
+					return nil, fmt.Errorf("plugin %s: workload %q constructor returned %T, which lacks Name() string and Iterate(int)",
+						path, name, v)
+				}
+				return w, nil
+			})
+		})
+		if regErr != nil {
+			return regErr
+		}
+	}
+	return nil
+}
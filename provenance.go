@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+)
+
+// provenanceExcludedFlags are flags that change how a run is driven or
+// presented but not what it measures
+var provenanceExcludedFlags = map[string]bool{
+	"config": true, "watch": true, "color": true, "threshold": true, "units": true, "format": true,
+	"csv-append": true, "html": true, "pauses-out": true, "iterations-out": true, "mu-out": true,
+	"slow-stacks-out": true, "out": true,
+	"baseline-file": true, "fail-threshold": true, "any-baseline": true,
+}
+
+// provenanceEnvVars are the environment variables that change GC behavior
+var provenanceEnvVars = []string{"GOGC", "GOMEMLIMIT", "GODEBUG", "GOMAXPROCS"}
+
+// provenanceConfig returns the run's configuration: every flag set on the
+// command line or from -config, plus the dataset seed, as sorted name=value
+// pairs
+func provenanceConfig() string {
+	set := map[string]string{"seed": fmt.Sprint(*dataSeed)}
+	flag.Visit(func(f *flag.Flag) {
+		if !provenanceExcludedFlags[f.Name] {
+			set[f.Name] = f.Value.String()
+		}
+	})
+	return joinSorted(set)
+}
+
+// provenanceBuild returns the binary's build information: Go version, main
+// module and version control state, build settings such as GOEXPERIMENT and
+// the contents of any workload plugins
+func provenanceBuild() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "go=" + runtime.Version()
+	}
+	set := map[string]string{"go": info.GoVersion, "path": info.Path}
+	for _, s := range info.Settings {
+		set[s.Key] = s.Value
+	}
+	// Plugins are code the results depend on, so their contents count too
+	for _, path := range workloadPlugins {
+		if data, err := os.ReadFile(path); err == nil {
+			set["plugin."+filepath.Base(path)] = provenanceHash(string(data))
+		}
+	}
+	return joinSorted(set)
+}
+
+// provenanceEnvironment returns the platform and GC-relevant environment
+func provenanceEnvironment() string {
+	set := map[string]string{
+		"GOOS":       runtime.GOOS,
+		"GOARCH":     runtime.GOARCH,
+		"NumCPU":     fmt.Sprint(runtime.NumCPU()),
+		"gomaxprocs": fmt.Sprint(runtime.GOMAXPROCS(0)),
+	}
+	for _, name := range provenanceEnvVars {
+		if v, ok := os.LookupEnv(name); ok {
+			set["env."+name] = v
+		}
+	}
+	if onWasm() {
+		return joinSorted(set)
+	}
+	if data, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+		set["kernel"] = strings.TrimSpace(string(data))
+	}
+	if cpu := cpuModel(); cpu != "" {
+		set["cpu"] = cpu
+	}
+	return joinSorted(set)
+}
+
+// cpuModel returns the CPU model name from /proc/cpuinfo, or "" if unknown
+func cpuModel() string {
+	if onWasm() {
+		return ""
+	}
+	data, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(name) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// joinSorted renders a map as space-separated name=value pairs sorted by
+// name, quoting values that contain spaces so the line parses back
+// unambiguously
+func joinSorted(m map[string]string) string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		v := m[name]
+		if v == "" || strings.ContainsAny(v, " \t\"") {
+			v = fmt.Sprintf("%q", v)
+		}
+		parts[i] = name + "=" + v
+	}
+	return strings.Join(parts, " ")
+}
+
+// provenanceHash is the truncated SHA-256 of s, as recorded in results
+func provenanceHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// provenanceRecord is what produced a result: the configuration, build and
+// environment, each with a hash, and a hash over all three
+type provenanceRecord struct {
+	Config          string `json:"config"`
+	Build           string `json:"build"`
+	Environment     string `json:"environment"`
+	ConfigHash      string `json:"config_hash"`
+	BuildHash       string `json:"build_hash"`
+	EnvironmentHash string `json:"environment_hash"`
+	Hash            string `json:"hash"`
+}
+
+// currentProvenance computes the provenance of this run
+func currentProvenance() provenanceRecord {
+	config, build, env := provenanceConfig(), provenanceBuild(), provenanceEnvironment()
+	return provenanceRecord{
+		Config:          config,
+		Build:           build,
+		Environment:     env,
+		ConfigHash:      provenanceHash(config),
+		BuildHash:       provenanceHash(build),
+		EnvironmentHash: provenanceHash(env),
+		Hash:            provenanceHash(config + "\n" + build + "\n" + env),
+	}
+}
+
+// printProvenance prints the run's provenance. analyze_results.py
+// recomputes the hashes to detect edited results and compares them across
+// runs to check comparisons are like for like.
+func printProvenance() {
+	p := currentProvenance()
+	printSection("Provenance")
+	fmt.Printf("Config: %s\n", p.Config)
+	fmt.Printf("Build: %s\n", p.Build)
+	fmt.Printf("Environment: %s\n", p.Environment)
+	fmt.Printf("Config Hash: %s\n", p.ConfigHash)
+	fmt.Printf("Build Hash: %s\n", p.BuildHash)
+	fmt.Printf("Environment Hash: %s\n", p.EnvironmentHash)
+	fmt.Printf("Provenance Hash: %s\n", p.Hash)
+}
+
+// verify recomputes the hashes of a provenance record read from a result
+// document and returns a problem for each that doesn't match, which means
+// the document was edited, or one if the document recorded none
+func (p provenanceRecord) verify(name string) []string {
+	if p.Hash == "" {
+		return []string{name + ": no provenance recorded"}
+	}
+	var problems []string
+	for _, c := range []struct{ component, text, hash string }{
+		{"config", p.Config, p.ConfigHash},
+		{"build", p.Build, p.BuildHash},
+		{"environment", p.Environment, p.EnvironmentHash},
+	} {
+		if provenanceHash(c.text) != c.hash {
+			problems = append(problems, fmt.Sprintf("%s: %s does not match its hash", name, c.component))
+		}
+	}
+	if len(problems) == 0 && provenanceHash(p.Config+"\n"+p.Build+"\n"+p.Environment) != p.Hash {
+		problems = append(problems, name+": provenance hash does not match")
+	}
+	return problems
+}
+
+// provenanceMismatches verifies the provenance records of two results and
+// returns why they aren't like for like: a record that doesn't verify, or a
+// configuration or environment that differs. Builds are left to the caller,
+// since comparisons are often between builds on purpose.
+func provenanceMismatches(nameA string, a provenanceRecord, nameB string, b provenanceRecord) []string {
+	problems := append(a.verify(nameA), b.verify(nameB)...)
+	if len(problems) > 0 {
+		return problems
+	}
+	for _, c := range []struct{ component, a, b string }{
+		{"configurations", a.Config, b.Config},
+		{"environments", a.Environment, b.Environment},
+	} {
+		if onlyA, onlyB := settingDifferences(c.a, c.b); len(onlyA)+len(onlyB) > 0 {
+			problems = append(problems, fmt.Sprintf("%s differ: %s [%s] vs %s [%s]",
+				c.component, nameA, strings.Join(onlyA, " "), nameB, strings.Join(onlyB, " ")))
+		}
+	}
+	return problems
+}
+
+// settingDifferences returns the name=value pairs of a provenance component
+// that are only in a and only in b
+func settingDifferences(a, b string) (onlyA, onlyB []string) {
+	inA, inB := map[string]bool{}, map[string]bool{}
+	for _, part := range strings.Fields(a) {
+		inA[part] = true
+	}
+	for _, part := range strings.Fields(b) {
+		inB[part] = true
+		if !inA[part] {
+			onlyB = append(onlyB, part)
+		}
+	}
+	for _, part := range strings.Fields(a) {
+		if !inB[part] {
+			onlyA = append(onlyA, part)
+		}
+	}
+	return onlyA, onlyB
+}
+
+// withoutExperiments drops the build settings expected to differ between
+// collectors: GOEXPERIMENT and the hashes of workload plugins, which are
+// built once per collector
+func withoutExperiments(settings []string) []string {
+	var kept []string
+	for _, s := range settings {
+		if !strings.HasPrefix(s, "GOEXPERIMENT=") && !strings.HasPrefix(s, "plugin.") {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
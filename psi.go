@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var psiInterval = flag.Duration("psi-interval", 100*time.Millisecond,
+	"Linux only: interval between memory pressure (PSI) samples during the measured phase (0 disables)")
+
+// psiWarnPercent is the stall share above which results are flagged as
+// likely dominated by system memory pressure rather than the Go GC
+const psiWarnPercent = 5.0
+
+// psiSource is a memory pressure file: the system-wide one or the one for
+// this process's cgroup
+type psiSource struct {
+	label string
+	path  string
+}
+
+// psiTotals are cumulative stall times from a pressure file. "some" counts
+// time at least one task stalled on memory, "full" time all tasks did.
+type psiTotals struct {
+	some, full time.Duration
+}
+
+// psiSources returns the readable memory pressure files
+func psiSources() []psiSource {
+	if onWasm() {
+		return nil
+	}
+	var sources []psiSource
+	if _, err := readPSITotals("/proc/pressure/memory"); err == nil {
+		sources = append(sources, psiSource{"System", "/proc/pressure/memory"})
+	}
+
+	// cgroup v2 membership is the "0::<path>" line
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return sources
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		dir, ok := strings.CutPrefix(line, "0::")
+		if !ok || dir == "/" {
+			continue
+		}
+		for _, root := range []string{"/sys/fs/cgroup", "/sys/fs/cgroup/unified"} {
+			path := filepath.Join(root, dir, "memory.pressure")
+			if _, err := readPSITotals(path); err == nil {
+				sources = append(sources, psiSource{"Cgroup", path})
+				break
+			}
+		}
+	}
+	return sources
+}
+
+// readPSITotals reads the stall totals of a pressure file
+func readPSITotals(path string) (psiTotals, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return psiTotals{}, err
+	}
+	t, ok := parsePSITotals(data)
+	if !ok {
+		return psiTotals{}, fmt.Errorf("%s: no stall totals", path)
+	}
+	return t, nil
+}
+
+// parsePSITotals parses the total= fields of a pressure file's contents,
+// which are in microseconds. It doesn't allocate, so the sampler can run it
+// inside the measured window.
+func parsePSITotals(data []byte) (psiTotals, bool) {
+	var t psiTotals
+	found := 0
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			data = nil
+		}
+		i := bytes.Index(line, []byte("total="))
+		if i < 0 {
+			continue
+		}
+		var us uint64
+		digits := 0
+		for _, c := range line[i+len("total="):] {
+			if c < '0' || c > '9' {
+				break
+			}
+			us = us*10 + uint64(c-'0')
+			digits++
+		}
+		if digits == 0 {
+			return psiTotals{}, false
+		}
+		switch {
+		case bytes.HasPrefix(line, []byte("some ")):
+			t.some = time.Duration(us) * time.Microsecond
+			found++
+		case bytes.HasPrefix(line, []byte("full ")):
+			t.full = time.Duration(us) * time.Microsecond
+			found++
+		}
+	}
+	return t, found > 0
+}
+
+// psiFile is an open pressure file that is reread in place, so sampling it
+// doesn't allocate
+type psiFile struct {
+	f   *os.File
+	buf []byte
+}
+
+func openPSIFile(path string) (*psiFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &psiFile{f: f, buf: make([]byte, 512)}, nil
+}
+
+func (p *psiFile) read() (psiTotals, bool) {
+	n, err := p.f.ReadAt(p.buf, 0)
+	if n == 0 && err != nil {
+		return psiTotals{}, false
+	}
+	return parsePSITotals(p.buf[:n])
+}
+
+// psiMonitor samples memory pressure files in the background, tracking the
+// overall stall share and the worst single sampling interval
+type psiMonitor struct {
+	sources []psiSource
+	start   []psiTotals
+	began   time.Time
+
+	peakSome []float64
+	peakFull []float64
+
+	files  []*psiFile
+	prev   []psiTotals
+	ticker *time.Ticker
+	stop   chan struct{}
+	done   chan struct{}
+	end    []psiTotals
+	took   time.Duration
+}
+
+// startPSIMonitor starts sampling, returning nil when PSI is disabled or
+// unavailable on this system
+func startPSIMonitor() *psiMonitor {
+	if *psiInterval <= 0 {
+		return nil
+	}
+	sources := psiSources()
+	if len(sources) == 0 {
+		return nil
+	}
+
+	m := &psiMonitor{
+		sources:  sources,
+		start:    make([]psiTotals, len(sources)),
+		peakSome: make([]float64, len(sources)),
+		peakFull: make([]float64, len(sources)),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	// The sampler rereads open files so it doesn't allocate while sampling
+	for _, s := range sources {
+		f, err := openPSIFile(s.path)
+		if err != nil {
+			m.closeFiles()
+			return nil
+		}
+		m.files = append(m.files, f)
+	}
+	for n, s := range sources {
+		m.start[n], _ = readPSITotals(s.path)
+	}
+	m.prev = append([]psiTotals(nil), m.start...)
+	m.began = time.Now()
+	m.ticker = time.NewTicker(*psiInterval)
+
+	go m.sample()
+	return m
+}
+
+func (m *psiMonitor) sample() {
+	defer close(m.done)
+	prevTime := m.began
+	for {
+		select {
+		case <-m.stop:
+			return
+		case now := <-m.ticker.C:
+			elapsed := now.Sub(prevTime)
+			for n, f := range m.files {
+				t, ok := f.read()
+				if !ok {
+					continue
+				}
+				m.peakSome[n] = max(m.peakSome[n], stallPercent(t.some-m.prev[n].some, elapsed))
+				m.peakFull[n] = max(m.peakFull[n], stallPercent(t.full-m.prev[n].full, elapsed))
+				m.prev[n] = t
+			}
+			prevTime = now
+		}
+	}
+}
+
+func (m *psiMonitor) closeFiles() {
+	for _, f := range m.files {
+		f.f.Close()
+	}
+}
+
+// Stop ends sampling and takes the final totals
+func (m *psiMonitor) Stop() {
+	close(m.stop)
+	<-m.done
+	m.ticker.Stop()
+	m.closeFiles()
+	m.took = time.Since(m.began)
+	m.end = make([]psiTotals, len(m.sources))
+	for n, s := range m.sources {
+		m.end[n], _ = readPSITotals(s.path)
+	}
+}
+
+// Report prints the share of the measured phase spent stalled on memory
+func (m *psiMonitor) Report() {
+	printSection("Memory Pressure (PSI)")
+	worst := 0.0
+	for n, s := range m.sources {
+		some := stallPercent(m.end[n].some-m.start[n].some, m.took)
+		full := stallPercent(m.end[n].full-m.start[n].full, m.took)
+		worst = max(worst, some)
+		printMetric(s.label+" Memory Stall (some)", "%.2f%%", some)
+		printMetric(s.label+" Memory Stall (full)", "%.2f%%", full)
+		printMetric(s.label+" Peak Memory Stall (some)", "%.2f%%", m.peakSome[n])
+		printMetric(s.label+" Peak Memory Stall (full)", "%.2f%%", m.peakFull[n])
+	}
+	if worst >= psiWarnPercent {
+		fmt.Printf("Warning: tasks were stalled on memory for %.1f%% of the run; "+
+			"results may reflect system memory pressure rather than the Go GC\n", worst)
+	}
+}
+
+// stallPercent returns stall time as a percentage of elapsed time
+func stallPercent(stall, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(stall) / float64(elapsed) * 100
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	baselineFile = flag.String("baseline-file", "",
+		"benchmark mode: fail the run (exit status 1) if Operations/sec or GC pause regress by more than -fail-threshold "+
+			"from the results of this -format=json document")
+
+	anyBaseline = flag.Bool("any-baseline", false,
+		"with -baseline-file, gate against a baseline whose configuration or environment differs from this run's, "+
+			"or whose provenance doesn't verify")
+
+	failThreshold = percentage(5)
+)
+
+func init() {
+	flag.Var(&failThreshold, "fail-threshold",
+		"with -baseline-file, the regression from the baseline that fails the run, e.g. 5%")
+}
+
+// percentage is a flag holding a percentage such as "5%"; a bare number is
+// a percentage too
+type percentage float64
+
+func (p *percentage) String() string { return fmt.Sprintf("%g%%", float64(*p)) }
+
+func (p *percentage) Set(s string) error {
+	v, kind, ok := parseMetricValue(s)
+	if !ok || v < 0 || (kind != kindPercent && kind != kindNumber) {
+		return fmt.Errorf("invalid percentage %q (want e.g. 5%%)", s)
+	}
+	*p = percentage(v)
+	return nil
+}
+
+// gateBaseline is the results of the -baseline-file document
+var gateBaseline *runResults
+
+// loadRegressionGate reads the -baseline-file document, which must hold the
+// results of a benchmark mode run of the same workload with the same
+// configuration on the same environment, so a bad baseline stops the run
+// before it is measured rather than after. Only the build may differ, since
+// that is what the gate judges; -any-baseline lifts the rest.
+func loadRegressionGate(mode, workload string) error {
+	if *baselineFile == "" {
+		return nil
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-baseline-file is only supported in benchmark mode")
+	}
+	data, err := os.ReadFile(*baselineFile)
+	if err != nil {
+		return err
+	}
+	var doc jsonReport
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %v (want a -format=json document)", *baselineFile, err)
+	}
+	if doc.Results == nil {
+		return fmt.Errorf("%s has no results; the baseline must be a benchmark mode run", *baselineFile)
+	}
+	if doc.Workload != workload {
+		return fmt.Errorf("%s is a run of the %s workload, not %s", *baselineFile, doc.Workload, workload)
+	}
+	if problems := provenanceMismatches("baseline", doc.Provenance, "this run", currentProvenance()); len(problems) > 0 {
+		if !*anyBaseline {
+			return fmt.Errorf("%s is not like for like with this run (-any-baseline to gate anyway): %s",
+				*baselineFile, strings.Join(problems, "; "))
+		}
+		for _, p := range problems {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", p)
+		}
+	}
+	gateBaseline = doc.Results
+	return nil
+}
+
+// checkRegressionGate compares the run's throughput and GC pauses with the
+// baseline's, recording an assertion for each. Throughput fails when it
+// falls by more than -fail-threshold, and pauses when they grow by more. A
+// baseline pause of zero only passes a pause of zero.
+func checkRegressionGate(r *runResults) {
+	if gateBaseline == nil {
+		return
+	}
+	b := gateBaseline
+	limit := float64(failThreshold) / 100
+	assertionResults = append(assertionResults, assertionResult{
+		name:   "Operations/sec vs baseline",
+		actual: fmt.Sprintf("%.2f (%s)", r.OpsPerSec, formatChange(r.OpsPerSec, b.OpsPerSec)),
+		limit:  fmt.Sprintf("-%s of %.2f", failThreshold.String(), b.OpsPerSec),
+		passed: r.OpsPerSec >= b.OpsPerSec*(1-limit),
+	})
+	for _, p := range []struct {
+		name           string
+		actual, before int64
+	}{
+		{"Total GC Pause vs baseline", r.TotalPauseNs, b.TotalPauseNs},
+		{"Average GC Pause vs baseline", r.AvgPauseNs, b.AvgPauseNs},
+	} {
+		assertionResults = append(assertionResults, assertionResult{
+			name:   p.name,
+			actual: fmt.Sprintf("%s (%s)", formatDuration(time.Duration(p.actual)), formatChange(float64(p.actual), float64(p.before))),
+			limit:  fmt.Sprintf("+%s of %s", failThreshold.String(), formatDuration(time.Duration(p.before))),
+			passed: float64(p.actual) <= float64(p.before)*(1+limit),
+		})
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/metrics"
+	"sort"
+	"time"
+)
+
+var (
+	runCount = flag.Int("count", 1,
+		"benchmark mode: after the measured phase, repeat it this many times on the same workload instances without "+
+			"the report's instrumentation and report the mean, standard deviation, range and 95% confidence interval "+
+			"of each headline metric over the repetitions (1 disables)")
+	excludeOutliers = flag.Bool("exclude-outliers", false,
+		"with -count, leave the outlier runs out of the mean, standard deviation, range and confidence interval")
+)
+
+// outlierScore is the modified z-score beyond which a run is an outlier, the
+// cutoff Iglewicz and Hoaglin recommend
+const outlierScore = 3.5
+
+// validateRepetitions checks that -count can be honored. The interference
+// flags are refused because their load runs only during the measured phase.
+func validateRepetitions(mode string) error {
+	if *runCount < 1 {
+		return fmt.Errorf("-count must be positive")
+	}
+	if *excludeOutliers && *runCount < 3 {
+		return fmt.Errorf("-exclude-outliers needs a -count of at least 3")
+	}
+	if *runCount == 1 {
+		return nil
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-count is only supported in benchmark mode")
+	}
+	if *bandwidthHogs > 0 || *cpuQuota != 0 {
+		return fmt.Errorf("-count can't be combined with -bandwidth-hogs or -cpu-quota")
+	}
+	return requireFeature("-count", "/cpu/classes/gc/total:cpu-seconds", "/cpu/classes/total:cpu-seconds")
+}
+
+// repetition is what one run of the measured phase measured
+type repetition struct {
+	duration   time.Duration
+	totalAlloc uint64
+	allocs     uint64
+	numGC      uint32
+	pause      time.Duration
+	gcCPU      float64 // Fraction of the CPU time spent in GC
+}
+
+// repetitions repeats the measured phase -count times after the run the
+// rest of the report describes, on the same instances, each from a
+// collected heap as that run starts from. That run carries the samplers,
+// watches and recorders the report needs, which cost it some time, so it is
+// left out and every repetition runs with the same, minimal,
+// instrumentation. Their spread tells how far a single run's numbers can be
+// trusted.
+type repetitions struct {
+	runs   []repetition
+	before runtime.MemStats
+	after  runtime.MemStats
+	cpu    []metrics.Sample
+	gcCPU  float64
+	total  float64
+}
+
+// newRepetitions returns nil unless -count asks for more than one run
+func newRepetitions() *repetitions {
+	if *runCount <= 1 {
+		return nil
+	}
+	return &repetitions{
+		runs: make([]repetition, 0, *runCount),
+		cpu: []metrics.Sample{
+			{Name: "/cpu/classes/gc/total:cpu-seconds"},
+			{Name: "/cpu/classes/total:cpu-seconds"},
+		},
+	}
+}
+
+// begin snapshots the runtime's counters before a run; it is called before
+// the run's window opens, as it stops the world
+func (r *repetitions) begin() {
+	if r == nil {
+		return
+	}
+	runtime.ReadMemStats(&r.before)
+	metrics.Read(r.cpu)
+	r.gcCPU, r.total = r.cpu[0].Value.Float64(), r.cpu[1].Value.Float64()
+}
+
+// end records a run that took duration, after the collection closing it
+func (r *repetitions) end(duration time.Duration) {
+	if r == nil {
+		return
+	}
+	runtime.ReadMemStats(&r.after)
+	metrics.Read(r.cpu)
+	run := repetition{
+		duration:   duration,
+		totalAlloc: r.after.TotalAlloc - r.before.TotalAlloc,
+		allocs:     r.after.Mallocs - r.before.Mallocs,
+		numGC:      r.after.NumGC - r.before.NumGC,
+		pause:      time.Duration(r.after.PauseTotalNs - r.before.PauseTotalNs),
+	}
+	if total := r.cpu[1].Value.Float64() - r.total; total > 0 {
+		run.gcCPU = (r.cpu[0].Value.Float64() - r.gcCPU) / total
+	}
+	r.runs = append(r.runs, run)
+}
+
+// run runs the measured phase -count times, with phases if set. The
+// iteration log, latency ring and slow iteration watch hold the measured
+// run only, so they are detached for the repetitions.
+func (r *repetitions) run(ws []Workload, phases []phase) {
+	if r == nil {
+		return
+	}
+	fmt.Printf("Running %d repetitions...\n", *runCount)
+	defer detachRecorders()()
+
+	for len(r.runs) < *runCount {
+		var measured *phaseRun
+		if phases != nil {
+			measured = newPhaseRun(ws, phases)
+		}
+		runtime.GC()
+		time.Sleep(100 * time.Millisecond)
+		r.begin()
+		start := time.Now()
+		markPhase("repetition", true)
+		if measured != nil {
+			measured.run()
+		} else {
+			runIterations(ws, *iterations)
+		}
+		markPhase("repetition", false)
+		duration := time.Since(start)
+		runtime.GC()
+		r.end(duration)
+	}
+}
+
+// Report prints the runs that are outliers in duration or GC pause, then
+// the mean of each headline metric over the runs with its 95% confidence
+// interval, the standard deviation and the range, leaving the outliers out
+// with -exclude-outliers
+func (r *repetitions) Report() {
+	printSection("Repetitions")
+	printMetric("Runs", "%d", len(r.runs))
+	outliers := r.outliers()
+	printMetric("Outlier Runs", "%d", len(outliers))
+	for n, run := range r.runs {
+		why, ok := outliers[n]
+		if !ok {
+			continue
+		}
+		printMetric(fmt.Sprintf("Outlier Run %d", n+1), "%s, GC pause %s (%s)",
+			formatDuration(run.duration), formatDuration(run.pause), why)
+	}
+	runs := r.runs
+	if *excludeOutliers && len(outliers) > 0 {
+		runs = nil
+		for n, run := range r.runs {
+			if _, ok := outliers[n]; !ok {
+				runs = append(runs, run)
+			}
+		}
+		printMetric("Runs Summarized", "%d (outliers excluded)", len(runs))
+	}
+
+	duration := func(v float64) string { return formatDuration(time.Duration(v)) }
+	number := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+	for _, m := range []struct {
+		name   string
+		value  func(repetition) float64
+		format func(float64) string
+	}{
+		{"Total Duration", func(run repetition) float64 { return float64(run.duration) }, duration},
+		{"Operations/sec", func(run repetition) float64 { return float64(*iterations) / run.duration.Seconds() }, number},
+		{"Total Allocated", func(run repetition) float64 { return float64(run.totalAlloc) },
+			func(v float64) string { return formatBytes(uint64(v)) }},
+		{"Allocations", func(run repetition) float64 { return float64(run.allocs) }, number},
+		{"Number of GCs", func(run repetition) float64 { return float64(run.numGC) }, number},
+		{"Total GC Pause", func(run repetition) float64 { return float64(run.pause) }, duration},
+		{"Average GC Pause", func(run repetition) float64 {
+			if run.numGC == 0 {
+				return 0
+			}
+			return float64(run.pause) / float64(run.numGC)
+		}, duration},
+		{"GC CPU Fraction", func(run repetition) float64 { return run.gcCPU * 100 },
+			func(v float64) string { return fmt.Sprintf("%.2f%%", v) }},
+	} {
+		values := make([]float64, len(runs))
+		for n, run := range runs {
+			values[n] = m.value(run)
+		}
+		s := summarize(values)
+		printMetric(m.name, "%s ± %s (stddev %s, min %s, max %s)",
+			m.format(s.mean), m.format(s.ci95), m.format(s.stddev), m.format(s.min), m.format(s.max))
+	}
+}
+
+// outliers returns the indexes of the runs whose duration or total GC
+// pause is an outlier among the runs', with the reason. A value is an
+// outlier when its modified z-score, its distance from the median in
+// units of the median absolute deviation (MAD), exceeds outlierScore: the
+// median and MAD are barely moved by the outliers themselves, unlike the
+// mean and standard deviation, and need no more than three runs, unlike
+// quartiles.
+func (r *repetitions) outliers() map[int]string {
+	outliers := map[int]string{}
+	for _, m := range []struct {
+		name  string
+		value func(repetition) float64
+	}{
+		{"duration", func(run repetition) float64 { return float64(run.duration) }},
+		{"GC pause", func(run repetition) float64 { return float64(run.pause) }},
+	} {
+		values := make([]float64, len(r.runs))
+		for n, run := range r.runs {
+			values[n] = m.value(run)
+		}
+		for n, z := range modifiedZScores(values) {
+			if math.Abs(z) <= outlierScore {
+				continue
+			}
+			direction := "above"
+			if z < 0 {
+				direction = "below"
+			}
+			why := fmt.Sprintf("%s %.1f MADs %s the median", m.name, math.Abs(z), direction)
+			if outliers[n] != "" {
+				why = outliers[n] + ", " + why
+			}
+			outliers[n] = why
+		}
+	}
+	return outliers
+}
+
+// modifiedZScores returns the modified z-score of each value, 0.6745 times
+// its deviation from the median over the median absolute deviation, which
+// is comparable to a z-score for normal data. It returns nil if fewer than
+// three values are given or at least half of them are equal, leaving no
+// spread to measure deviations by.
+func modifiedZScores(values []float64) []float64 {
+	if len(values) < 3 {
+		return nil
+	}
+	med := median(values)
+	deviations := make([]float64, len(values))
+	for n, v := range values {
+		deviations[n] = math.Abs(v - med)
+	}
+	mad := median(deviations)
+	if mad == 0 {
+		return nil
+	}
+	scores := make([]float64, len(values))
+	for n, v := range values {
+		scores[n] = 0.6745 * (v - med) / mad
+	}
+	return scores
+}
+
+// median returns the median of values, which must not be empty, without
+// reordering them
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// sampleSummary describes a sample of values
+type sampleSummary struct {
+	mean, stddev, min, max float64
+	ci95                   float64 // Half-width of the 95% confidence interval of the mean
+}
+
+// summarize returns the mean, sample standard deviation, range and 95%
+// confidence interval of values, of which there must be at least one. The
+// interval uses Student's t-distribution, as runs are too few for the
+// normal approximation.
+func summarize(values []float64) sampleSummary {
+	s := sampleSummary{min: values[0], max: values[0]}
+	for _, v := range values {
+		s.mean += v
+		s.min = min(s.min, v)
+		s.max = max(s.max, v)
+	}
+	n := len(values)
+	s.mean /= float64(n)
+	if n < 2 {
+		return s
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - s.mean) * (v - s.mean)
+	}
+	s.stddev = math.Sqrt(squares / float64(n-1))
+	s.ci95 = tCritical95(n-1) * s.stddev / math.Sqrt(float64(n))
+	return s
+}
+
+// tCritical95 returns the two-sided 95% critical value of Student's
+// t-distribution with df degrees of freedom, from a table up to 30 and the
+// normal distribution's beyond
+func tCritical95(df int) float64 {
+	table := [...]float64{
+		12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+	}
+	if df >= 1 && df <= len(table) {
+		return table[df-1]
+	}
+	return 1.960
+}
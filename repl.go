@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replHelp lists the interactive commands
+const replHelp = `Commands:
+  run [iterations]      run a measurement burst (default 200 iterations)
+  gogc <percent|off>    set GOGC
+  memlimit <size|off>   set GOMEMLIMIT, e.g. 512MB
+  workers <n>           set the number of workers
+  workload <name>       switch workload
+  set <flag> <value>    set a workload flag, e.g. set static-live-mb 512
+  show                  print the current settings
+  help                  print this help
+  quit                  exit`
+
+// replBurst holds the metrics of one measurement burst
+type replBurst struct {
+	duration   time.Duration
+	opsPerSec  float64
+	numGC      uint64
+	totalPause time.Duration
+	gcCPU      float64 // Percent of CPU time spent in GC
+	heapLive   uint64
+}
+
+// replMetrics are the runtime/metrics read around each burst
+var replMetrics = []string{
+	"/gc/cycles/total:gc-cycles",
+	"/cpu/classes/gc/total:cpu-seconds",
+	"/cpu/classes/total:cpu-seconds",
+	"/gc/heap/live:bytes",
+}
+
+// repl is an interactive session that adjusts GC and workload knobs and runs
+// short measurement bursts, printing each burst's metrics next to the last
+type repl struct {
+	workload string
+	workers  int
+	ws       []Workload
+	last     *replBurst
+	out      io.Writer
+}
+
+// runREPL reads commands from in until EOF or quit
+func runREPL(in io.Reader, out io.Writer, workload string, ws []Workload) {
+	r := &repl{workload: workload, workers: len(ws), ws: ws, out: out}
+	// The instances are replaced as the workload changes, so the REPL tears
+	// down whichever are current when it exits
+	defer func() { teardownWorkloads(r.ws) }()
+	fmt.Fprintln(out, "Interactive mode; type help for commands.")
+
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(out, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return
+		}
+		args := strings.Fields(sc.Text())
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" || args[0] == "exit" {
+			return
+		}
+		if err := r.exec(args); err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+	}
+}
+
+// exec runs one command
+func (r *repl) exec(args []string) error {
+	switch args[0] {
+	case "help":
+		fmt.Fprintln(r.out, replHelp)
+	case "show":
+		r.show()
+	case "run":
+		n := 200
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 1 {
+				return fmt.Errorf("iterations must be a positive integer")
+			}
+			n = v
+		}
+		r.run(n)
+	case "gogc":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: gogc <percent|off>")
+		}
+		percent := -1
+		if args[1] != "off" {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 0 {
+				return fmt.Errorf("GOGC must be a non-negative integer or off")
+			}
+			percent = v
+		}
+		debug.SetGCPercent(percent)
+		r.show()
+	case "memlimit":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: memlimit <size|off>")
+		}
+		limit := int64(math.MaxInt64)
+		if args[1] != "off" {
+			var b byteSize
+			if err := b.Set(args[1]); err != nil {
+				return err
+			}
+			limit = int64(b)
+		}
+		debug.SetMemoryLimit(limit)
+		r.show()
+	case "workers":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: workers <n>")
+		}
+		v, err := strconv.Atoi(args[1])
+		if err != nil || v < 1 {
+			return fmt.Errorf("workers must be a positive integer")
+		}
+		return r.rebuild(r.workload, v)
+	case "workload":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: workload <name>")
+		}
+		return r.rebuild(args[1], r.workers)
+	case "set":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: set <flag> <value>")
+		}
+		name := strings.TrimLeft(args[1], "-")
+		if err := flag.Set(name, args[2]); err != nil {
+			return err
+		}
+		// Workloads read their flags when constructed
+		return r.rebuild(r.workload, r.workers)
+	default:
+		return fmt.Errorf("unknown command %q; type help for commands", args[0])
+	}
+	return nil
+}
+
+// rebuild replaces the workload instances. The new ones are built first,
+// so if that fails the old ones stay in place; once they are swapped in,
+// the old ones are torn down and their live heap collected.
+func (r *repl) rebuild(workload string, workers int) error {
+	if _, ok := workloads[workload]; !ok {
+		return fmt.Errorf("unknown workload %q (available: %v)", workload, workloadNames())
+	}
+	ws, err := newWorkloads(workload, workers)
+	if err != nil {
+		return err
+	}
+	old := r.ws
+	r.workload, r.workers, r.ws = workload, workers, ws
+	r.last = nil
+	teardownWorkloads(old)
+	runtime.GC()
+	r.show()
+	return nil
+}
+
+// show prints the current settings
+func (r *repl) show() {
+	gogc := debug.SetGCPercent(-1)
+	debug.SetGCPercent(gogc)
+	gogcStr := strconv.Itoa(gogc)
+	if gogc < 0 {
+		gogcStr = "off"
+	}
+	limitStr := "off"
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		limitStr = formatBytes(uint64(limit))
+	}
+	fmt.Fprintf(r.out, "workload=%s workers=%d GOGC=%s GOMEMLIMIT=%s\n", r.workload, r.workers, gogcStr, limitStr)
+}
+
+// run times a burst of n iterations and prints its metrics alongside the
+// previous burst's
+func (r *repl) run(n int) {
+	samples := make([]metrics.Sample, len(replMetrics))
+	for i, name := range replMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+	gcBefore := samples[0].Value.Uint64()
+	gcCPUBefore, cpuBefore := samples[1].Value.Float64(), samples[2].Value.Float64()
+	gcStatsBefore := getGCStats()
+
+	start := time.Now()
+	runIterations(r.ws, n)
+	duration := time.Since(start)
+
+	metrics.Read(samples)
+	gcStatsAfter := getGCStats()
+	b := &replBurst{
+		duration:   duration,
+		opsPerSec:  float64(n) / duration.Seconds(),
+		numGC:      samples[0].Value.Uint64() - gcBefore,
+		totalPause: gcStatsAfter.PauseTotal - gcStatsBefore.PauseTotal,
+		heapLive:   samples[3].Value.Uint64(),
+	}
+	if cpu := samples[2].Value.Float64() - cpuBefore; cpu > 0 {
+		b.gcCPU = (samples[1].Value.Float64() - gcCPUBefore) / cpu * 100
+	}
+
+	prev := r.last
+	row := func(name string, format func(*replBurst) string) {
+		if prev == nil {
+			fmt.Fprintf(r.out, "  %-16s %s\n", name+":", format(b))
+			return
+		}
+		fmt.Fprintf(r.out, "  %-16s %-14s (was %s)\n", name+":", format(b), format(prev))
+	}
+	row("Duration", func(b *replBurst) string { return formatDuration(b.duration) })
+	row("Operations/sec", func(b *replBurst) string { return fmt.Sprintf("%.2f", b.opsPerSec) })
+	row("GCs", func(b *replBurst) string { return fmt.Sprint(b.numGC) })
+	row("Total GC Pause", func(b *replBurst) string { return formatDuration(b.totalPause) })
+	row("GC CPU", func(b *replBurst) string { return fmt.Sprintf("%.2f%%", b.gcCPU) })
+	row("Live Heap", func(b *replBurst) string { return formatBytes(b.heapLive) })
+	r.last = b
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+var outputFormat = flag.String("format", "text",
+	"result format: text (human-readable report), json (one JSON document on stdout), csv (one row per run) or benchstat (go test -bench lines)")
+
+// reportSchemaVersion is the version of the JSON document. Fields are only
+// ever added within a version; it changes when a field's meaning does.
+const reportSchemaVersion = 1
+
+// reportMetric is one metric line of the report, as printed
+type reportMetric struct {
+	Section string `json:"section"`
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+}
+
+var (
+	// reportSection is the section printMetric lines currently belong to
+	reportSection string
+	// reportMetrics records every printMetric line for structured output
+	reportMetrics []reportMetric
+	// reportOut is where structured output goes; in structured formats
+	// os.Stdout is redirected so the text report is discarded
+	reportOut = os.Stdout
+)
+
+// printSection starts a titled section of the report
+func printSection(title string) {
+	reportSection = title
+	fmt.Printf("=== %s ===\n", title)
+}
+
+// recordMetric adds a printed metric to the structured results
+func recordMetric(name, value string) {
+	reportMetrics = append(reportMetrics, reportMetric{Section: reportSection, Name: name, Value: value})
+}
+
+// validateFormat checks the -format flag
+func validateFormat() error {
+	switch *outputFormat {
+	case "text", "json":
+	case "csv", "benchstat":
+		return nil
+	default:
+		return fmt.Errorf("unknown -format %q (want text, json, csv or benchstat)", *outputFormat)
+	}
+	if *csvAppend != "" {
+		return fmt.Errorf("-csv-append requires -format=csv")
+	}
+	return nil
+}
+
+// beginReport discards the text report when a structured format is
+// selected, keeping stdout for the structured document
+func beginReport() error {
+	if *outputFormat == "text" {
+		return nil
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	os.Stdout = devNull
+	return nil
+}
+
+// runResults are the headline results of a benchmark mode run
+type runResults struct {
+	Iterations      int     `json:"iterations"`
+	DurationNs      int64   `json:"duration_ns"`
+	OpsPerSec       float64 `json:"ops_per_sec"`
+	TotalAllocBytes uint64  `json:"total_alloc_bytes"`
+	TotalAllocs     uint64  `json:"total_allocs"`
+	HeapAllocBytes  uint64  `json:"heap_alloc_bytes"`
+	HeapObjects     uint64  `json:"heap_objects"`
+	NumGC           uint32  `json:"num_gc"`
+	TotalPauseNs    int64   `json:"total_pause_ns"`
+	AvgPauseNs      int64   `json:"avg_pause_ns"`
+	LastPauseNs     int64   `json:"last_pause_ns"`
+	P50PauseNs      int64   `json:"p50_pause_ns"`
+	P90PauseNs      int64   `json:"p90_pause_ns"`
+	P99PauseNs      int64   `json:"p99_pause_ns"`
+	P999PauseNs     int64   `json:"p999_pause_ns"`
+	MaxPauseNs      int64   `json:"max_pause_ns"`
+	GCCPUFraction   float64 `json:"gc_cpu_fraction"`
+}
+
+// jsonAssertion is the outcome of an SLO assertion
+type jsonAssertion struct {
+	Name   string `json:"name"`
+	Actual string `json:"actual"`
+	Limit  string `json:"limit"`
+	Passed bool   `json:"passed"`
+}
+
+// jsonReport is the -format=json document
+type jsonReport struct {
+	SchemaVersion int                 `json:"schema_version"`
+	GoVersion     string              `json:"go_version"`
+	GOOS          string              `json:"goos"`
+	GOARCH        string              `json:"goarch"`
+	GOMAXPROCS    int                 `json:"gomaxprocs"`
+	NumCPU        int                 `json:"num_cpu"`
+	ClockOrigin   time.Time           `json:"clock_origin"`
+	Mode          string              `json:"mode"`
+	Workload      string              `json:"workload"`
+	Config        map[string]string   `json:"config"`
+	Results       *runResults         `json:"results,omitempty"`
+	Metrics       []reportMetric      `json:"metrics"`
+	Assertions    []jsonAssertion     `json:"assertions,omitempty"`
+	Provenance    provenanceRecord    `json:"provenance"`
+	Capabilities  runtimeCapabilities `json:"capabilities"`
+}
+
+// writeReport writes the structured document for the run, if a
+// structured format is selected. results is nil outside benchmark mode.
+func writeReport(mode, workload string, results *runResults) error {
+	switch *outputFormat {
+	case "text":
+		return nil
+	case "csv":
+		return writeCSVReport(mode, workload, results)
+	case "benchstat":
+		return writeBenchstatReport(workload, results)
+	}
+
+	enc := json.NewEncoder(reportOut)
+	enc.SetIndent("", "  ")
+	return enc.Encode(newJSONReport(mode, workload, results))
+}
+
+// newJSONReport builds the -format=json document for the run
+func newJSONReport(mode, workload string, results *runResults) jsonReport {
+	config := map[string]string{}
+	flag.VisitAll(func(f *flag.Flag) { config[f.Name] = f.Value.String() })
+
+	doc := jsonReport{
+		SchemaVersion: reportSchemaVersion,
+		GoVersion:     runtime.Version(),
+		GOOS:          runtime.GOOS,
+		GOARCH:        runtime.GOARCH,
+		GOMAXPROCS:    runtime.GOMAXPROCS(0),
+		NumCPU:        runtime.NumCPU(),
+		ClockOrigin:   runClockOrigin(),
+		Mode:          mode,
+		Workload:      workload,
+		Config:        config,
+		Results:       results,
+		Metrics:       reportMetrics,
+		Provenance:    currentProvenance(),
+		Capabilities:  currentCapabilities(),
+	}
+	for _, a := range assertionResults {
+		doc.Assertions = append(doc.Assertions, jsonAssertion{a.name, a.actual, a.limit, a.passed})
+	}
+	return doc
+}
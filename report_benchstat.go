@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// benchstatName returns the benchmark name for a workload, in the form
+// go test uses, with the GOMAXPROCS suffix
+func benchstatName(workload string) string {
+	name := "BenchmarkMatrixGC"
+	if workload != "matrix" {
+		name += "/workload=" + workload
+	}
+	if procs := runtime.GOMAXPROCS(0); procs > 1 {
+		name += fmt.Sprintf("-%d", procs)
+	}
+	return name
+}
+
+// goExperiment returns the GOEXPERIMENT the binary was built with, or ""
+func goExperiment() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+	for _, s := range info.Settings {
+		if s.Key == "GOEXPERIMENT" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// writeBenchstatReport writes the run in go test -bench format: the
+// configuration lines benchstat groups by, then one benchmark line with the
+// standard per-op metrics and GC metrics as custom units. Runs of two
+// builds can be fed straight into benchstat, e.g. with -col goexperiment.
+func writeBenchstatReport(workload string, results *runResults) error {
+	if results == nil {
+		return fmt.Errorf("benchstat format needs benchmark mode results")
+	}
+
+	fmt.Fprintf(reportOut, "goos: %s\n", runtime.GOOS)
+	fmt.Fprintf(reportOut, "goarch: %s\n", runtime.GOARCH)
+	fmt.Fprintf(reportOut, "pkg: green-tea-benchmark\n")
+	if cpu := cpuModel(); cpu != "" {
+		fmt.Fprintf(reportOut, "cpu: %s\n", cpu)
+	}
+	fmt.Fprintf(reportOut, "go: %s\n", runtime.Version())
+	experiment := goExperiment()
+	if experiment == "" {
+		experiment = "none"
+	}
+	fmt.Fprintf(reportOut, "goexperiment: %s\n", experiment)
+
+	n := float64(results.Iterations)
+	fields := []string{
+		benchstatName(workload),
+		fmt.Sprint(results.Iterations),
+		fmt.Sprintf("%.1f ns/op", float64(results.DurationNs)/n),
+		fmt.Sprintf("%.0f B/op", float64(results.TotalAllocBytes)/n),
+		fmt.Sprintf("%.0f allocs/op", float64(results.TotalAllocs)/n),
+		fmt.Sprintf("%.1f gc-pause-ns/op", float64(results.TotalPauseNs)/n),
+		fmt.Sprintf("%.4f gc-cycles/op", float64(results.NumGC)/n),
+		fmt.Sprintf("%.2f gc-cpu-%%", results.GCCPUFraction*100),
+		fmt.Sprintf("%d heap-B", results.HeapAllocBytes),
+	}
+	_, err := fmt.Fprintln(reportOut, strings.Join(fields, "\t"))
+	return err
+}
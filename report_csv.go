@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+var csvAppend = flag.String("csv-append", "",
+	"csv format: append the run's row to this file instead of writing to stdout, so repeated runs build one dataset; "+
+		"the header is written when the file is new and widened when a run adds columns")
+
+// csvColumn is one column of a CSV row
+type csvColumn struct {
+	name  string
+	value string
+}
+
+// csvMetricColumn converts a report metric to a column. Values that parse
+// as a quantity become plain numbers in base units, named with the unit,
+// so spreadsheets can compute on them.
+func csvMetricColumn(m reportMetric) csvColumn {
+	name := m.Name
+	if m.Section != "" {
+		name = m.Section + ": " + m.Name
+	}
+	v, kind, ok := parseMetricValue(m.Value)
+	if !ok {
+		return csvColumn{name, m.Value}
+	}
+	switch kind {
+	case kindDuration:
+		name += " (ns)"
+	case kindBytes:
+		name += " (bytes)"
+	case kindPercent:
+		name += " (%)"
+	}
+	return csvColumn{name, strconv.FormatFloat(v, 'f', -1, 64)}
+}
+
+// csvRow returns the run's columns: identification, headline results and
+// then every report metric
+func csvRow(mode, workload string, results *runResults) []csvColumn {
+	prov := currentProvenance()
+	row := []csvColumn{
+		{"timestamp", time.Now().UTC().Format(time.RFC3339)},
+		{"go_version", runtime.Version()},
+		{"goos", runtime.GOOS},
+		{"goarch", runtime.GOARCH},
+		{"gomaxprocs", strconv.Itoa(runtime.GOMAXPROCS(0))},
+		{"num_cpu", strconv.Itoa(runtime.NumCPU())},
+		{"clock_origin", runClockOrigin().UTC().Format(time.RFC3339Nano)},
+		{"mode", mode},
+		{"workload", workload},
+		{"provenance_hash", prov.Hash},
+		{"config_hash", prov.ConfigHash},
+		{"build_hash", prov.BuildHash},
+		{"environment_hash", prov.EnvironmentHash},
+	}
+	if results != nil {
+		row = append(row,
+			csvColumn{"iterations", strconv.Itoa(results.Iterations)},
+			csvColumn{"duration_ns", strconv.FormatInt(results.DurationNs, 10)},
+			csvColumn{"ops_per_sec", strconv.FormatFloat(results.OpsPerSec, 'f', -1, 64)},
+			csvColumn{"total_alloc_bytes", strconv.FormatUint(results.TotalAllocBytes, 10)},
+			csvColumn{"total_allocs", strconv.FormatUint(results.TotalAllocs, 10)},
+			csvColumn{"heap_alloc_bytes", strconv.FormatUint(results.HeapAllocBytes, 10)},
+			csvColumn{"heap_objects", strconv.FormatUint(results.HeapObjects, 10)},
+			csvColumn{"num_gc", strconv.FormatUint(uint64(results.NumGC), 10)},
+			csvColumn{"total_pause_ns", strconv.FormatInt(results.TotalPauseNs, 10)},
+			csvColumn{"avg_pause_ns", strconv.FormatInt(results.AvgPauseNs, 10)},
+			csvColumn{"last_pause_ns", strconv.FormatInt(results.LastPauseNs, 10)},
+			csvColumn{"p50_pause_ns", strconv.FormatInt(results.P50PauseNs, 10)},
+			csvColumn{"p90_pause_ns", strconv.FormatInt(results.P90PauseNs, 10)},
+			csvColumn{"p99_pause_ns", strconv.FormatInt(results.P99PauseNs, 10)},
+			csvColumn{"p999_pause_ns", strconv.FormatInt(results.P999PauseNs, 10)},
+			csvColumn{"max_pause_ns", strconv.FormatInt(results.MaxPauseNs, 10)},
+			csvColumn{"gc_cpu_fraction", strconv.FormatFloat(results.GCCPUFraction, 'f', -1, 64)},
+		)
+	}
+	failed := 0
+	for _, a := range assertionResults {
+		if !a.passed {
+			failed++
+		}
+	}
+	row = append(row, csvColumn{"assertions_failed", strconv.Itoa(failed)})
+	for _, m := range reportMetrics {
+		row = append(row, csvMetricColumn(m))
+	}
+	return row
+}
+
+// writeCSVReport writes the run as a header and one row to stdout, or
+// appends the row to -csv-append
+func writeCSVReport(mode, workload string, results *runResults) error {
+	row := csvRow(mode, workload, results)
+	if *csvAppend == "" {
+		return writeCSV(reportOut, nil, [][]csvColumn{row})
+	}
+
+	f, err := os.Open(*csvAppend)
+	if errors.Is(err, fs.ErrNotExist) {
+		f, err := os.Create(*csvAppend)
+		if err != nil {
+			return err
+		}
+		if err := writeCSV(f, nil, [][]csvColumn{row}); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+	if err != nil {
+		return err
+	}
+	records, err := csv.NewReader(f).ReadAll()
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", *csvAppend, err)
+	}
+	if len(records) == 0 {
+		return writeCSVFile(*csvAppend, nil, [][]csvColumn{row})
+	}
+
+	header := records[0]
+	known := map[string]bool{}
+	for _, name := range header {
+		known[name] = true
+	}
+	widened := false
+	for _, c := range row {
+		if !known[c.name] {
+			known[c.name] = true
+			header = append(header, c.name)
+			widened = true
+		}
+	}
+	if !widened {
+		f, err := os.OpenFile(*csvAppend, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return err
+		}
+		w := csv.NewWriter(f)
+		w.Write(csvRowValues(header, row))
+		w.Flush()
+		if err := w.Error(); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	}
+
+	// A new column means every earlier row needs an empty cell for it, so
+	// the file is rewritten with the wider header
+	var rows [][]csvColumn
+	for _, record := range records[1:] {
+		var old []csvColumn
+		for i, v := range record {
+			if i < len(records[0]) {
+				old = append(old, csvColumn{records[0][i], v})
+			}
+		}
+		rows = append(rows, old)
+	}
+	return writeCSVFile(*csvAppend, header, append(rows, row))
+}
+
+// csvRowValues lays out a row's values in header order, leaving columns the
+// row doesn't have empty
+func csvRowValues(header []string, row []csvColumn) []string {
+	values := map[string]string{}
+	for _, c := range row {
+		values[c.name] = c.value
+	}
+	record := make([]string, len(header))
+	for i, name := range header {
+		record[i] = values[name]
+	}
+	return record
+}
+
+// writeCSV writes a header and rows; a nil header is taken from the first row
+func writeCSV(out *os.File, header []string, rows [][]csvColumn) error {
+	if header == nil {
+		for _, c := range rows[0] {
+			header = append(header, c.name)
+		}
+	}
+	w := csv.NewWriter(out)
+	w.Write(header)
+	for _, row := range rows {
+		w.Write(csvRowValues(header, row))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// writeCSVFile replaces path with a header and rows, via a temporary file
+// so an interrupted run can't truncate the dataset
+func writeCSVFile(path string, header []string, rows [][]csvColumn) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeCSV(tmp, header, rows); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -20,14 +20,14 @@ echo "Running with STANDARD GC..."
 echo "======================================"
 echo ""
 
-go build -o matrix_benchmark_standard matrix_gc_benchmark.go
+go build -o matrix_benchmark_standard *.go
 if [ $? -ne 0 ]; then
     echo "Build failed for standard GC"
     exit 1
 fi
 
 echo "Running benchmark (this may take a minute)..."
-./matrix_benchmark_standard | tee benchmark_results/standard_gc.txt
+./matrix_benchmark_standard -pauses-out benchmark_results/standard_pauses.csv "$@" | tee benchmark_results/standard_gc.txt
 echo ""
 
 # Run with Green Tea GC
@@ -36,7 +36,7 @@ echo "Running with GREEN TEA GC..."
 echo "======================================"
 echo ""
 
-GOEXPERIMENT=greenteagc go build -o matrix_benchmark_greentea matrix_gc_benchmark.go
+GOEXPERIMENT=greenteagc go build -o matrix_benchmark_greentea *.go
 if [ $? -ne 0 ]; then
     echo "Build failed for Green Tea GC"
     echo "Note: Green Tea GC is only available in Go 1.25+"
@@ -44,7 +44,7 @@ if [ $? -ne 0 ]; then
 fi
 
 echo "Running benchmark (this may take a minute)..."
-./matrix_benchmark_greentea | tee benchmark_results/greentea_gc.txt
+./matrix_benchmark_greentea -pauses-out benchmark_results/greentea_pauses.csv "$@" | tee benchmark_results/greentea_gc.txt
 echo ""
 
 # Extract and compare key metrics
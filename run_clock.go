@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// runStart is the origin of the run clock that every timeline the benchmark
+// exports is measured on. It is read as the package initializes, within a
+// millisecond of the runtime starting the process, so the run clock also
+// lines up with gctrace's offsets, which are whole milliseconds since the
+// process started. It carries a monotonic reading, so run clock times are
+// immune to wall clock adjustments during the run.
+var runStart = time.Now()
+
+// runClock returns t on the run clock, as time since runStart. Times with a
+// monotonic reading are measured on it; others, such as the runtime's pause
+// end times, by the wall clock.
+func runClock(t time.Time) time.Duration {
+	return t.Sub(runStart)
+}
+
+// runClockOrigin returns the wall clock time of the run clock's origin, the
+// anchor that converts run clock times to absolute times for joining with
+// samples taken outside the process
+func runClockOrigin() time.Time {
+	return runStart.Round(0)
+}
+
+// runClockMillis formats a run clock time in milliseconds, the unit of the
+// exported timelines
+func runClockMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+)
+
+// selfTestIterations is how many iterations each self-test window runs
+const selfTestIterations = 20000
+
+// selfTestMUWindow is the mutator utilization timeline's window in the
+// self-test, which samples it whatever -mu-window is
+const selfTestMUWindow = 10 * time.Millisecond
+
+// selfTestWindows is how many windows each self-test runs. The runtime
+// occasionally allocates for itself, growing a timer heap or refilling a
+// cache of goroutine wait records, so the fewest allocations of any window
+// is the harness's.
+const selfTestWindows = 3
+
+// noopWorkload does nothing, so every allocation in a window it runs in is
+// the harness's own
+type noopWorkload struct{}
+
+func (noopWorkload) Name() string  { return "noop" }
+func (noopWorkload) Iterate(i int) {}
+
+// heapAllocs returns the objects and bytes allocated since the program
+// started. Unlike runtime/metrics, ReadMemStats flushes the per-P
+// allocation caches, so the counts are exact without running a GC.
+func heapAllocs() (objects, bytes uint64) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.Mallocs, ms.TotalAlloc
+}
+
+// selfTestWindow runs noop iterations on the given number of workers with
+// the measured window's instrumentation active, the iteration log, the
+// latency ring and the background samplers included, and returns the fewest heap allocations
+// made inside any of selfTestWindows windows. No GC runs inside the
+// windows, since the runtime refills caches of its own after one.
+func selfTestWindow(workers int) (objects, bytes uint64) {
+	ws := make([]Workload, workers)
+	for n := range ws {
+		ws[n] = noopWorkload{}
+	}
+	peakHeap := startPeakHeapTracker()
+	psi := startPSIMonitor()
+	mu := startMUTimeline(selfTestMUWindow)
+	samplerWait := max(selfTestMUWindow, *psiInterval)
+
+	// A warm window sets up what the runtime builds on first use, just as a
+	// benchmark's warmup would: the goroutines' wait records, which move
+	// between per-P caches as goroutines block and wake, and the timers of
+	// the samplers and this goroutine's sleep
+	runIterations(ws, selfTestIterations)
+	time.Sleep(2 * samplerWait)
+	measuredIterations = newIterationLog(selfTestWindows*selfTestIterations, true)
+	iterationLatencies = newLatencyRing(selfTestWindows * selfTestIterations)
+	defer func() { measuredIterations, iterationLatencies = nil, nil }()
+
+	objects = math.MaxUint64
+	for range selfTestWindows {
+		objectsBefore, bytesBefore := heapAllocs()
+		runIterations(ws, selfTestIterations)
+		// Let the samplers run a few times within the window
+		time.Sleep(3 * samplerWait)
+		objectsAfter, bytesAfter := heapAllocs()
+		if objectsAfter-objectsBefore < objects {
+			objects, bytes = objectsAfter-objectsBefore, bytesAfter-bytesBefore
+		}
+	}
+
+	if psi != nil {
+		psi.Stop()
+	}
+	if mu != nil {
+		mu.Stop()
+	}
+	peakHeap.Stop()
+	return objects, bytes
+}
+
+// runSelfTest measures the harness's own allocations inside the measured
+// window, with one worker and with several, and returns the exit status: 1
+// if the harness allocated. Instrumentation that allocates perturbs the
+// collector it is measuring.
+func runSelfTest(workers int) int {
+	printSection("Harness Self-Test")
+	status := 0
+	for _, n := range []int{1, max(workers, 4)} {
+		objects, bytes := selfTestWindow(n)
+		printMetric(fmt.Sprintf("Allocations (%d workers)", n), "%d", objects)
+		printMetric(fmt.Sprintf("Allocated (%d workers)", n), "%s", formatBytes(bytes))
+		if objects > 0 {
+			status = 1
+		}
+	}
+	if status != 0 {
+		fmt.Println("FAIL: the harness allocates inside the measured window")
+	} else {
+		fmt.Println("PASS: the harness makes no heap allocations inside the measured window")
+	}
+	return status
+}
@@ -0,0 +1,57 @@
+package main
+
+import "fmt"
+
+// Sink keeps a workload's results observable, so the compiler can neither
+// prove the work producing them unused and drop it, nor keep the objects it
+// allocates on the stack. Workloads embed a Sink and pass each result to
+// Keep; each instance owns its own Sink, so workers never contend on it.
+type Sink struct {
+	last any
+	kept uint64
+}
+
+// Keep records v as the latest result. Pointers, maps and channels are
+// stored without allocating; other values are boxed.
+func (s *Sink) Keep(v any) {
+	s.last = v
+	s.kept++
+}
+
+// Kept returns the number of results passed to Keep
+func (s *Sink) Kept() uint64 { return s.kept }
+
+// Last returns the latest result passed to Keep
+func (s *Sink) Last() any { return s.last }
+
+// sinkOwner is implemented by workloads that embed a Sink
+type sinkOwner interface {
+	Kept() uint64
+	Last() any
+}
+
+// allocationFreeWorkload is implemented by workloads that may legitimately
+// run without allocating, such as when reusing buffers
+type allocationFreeWorkload interface {
+	AllocationFree() bool
+}
+
+// verifyWorkloads checks that the workload instances did real work during
+// warmup: that the heap saw allocations and the embedded Sink of every
+// instance that ran a warmup iteration was fed. ran holds each instance's
+// warmup iterations. A zero count means a kernel was optimized away or a
+// workload is misconfigured, and any timing of it would be meaningless.
+func verifyWorkloads(ws []Workload, ran []uint64, allocs uint64) error {
+	name := ws[0].Name()
+	if allocs == 0 {
+		if af, ok := ws[0].(allocationFreeWorkload); !ok || !af.AllocationFree() {
+			return fmt.Errorf("workload %s allocated no objects during warmup; its work may have been optimized away", name)
+		}
+	}
+	for n, w := range ws {
+		if s, ok := w.(sinkOwner); ok && ran[n] > 0 && s.Kept() == 0 {
+			return fmt.Errorf("workload %s (worker %d) kept no results during warmup; its work may have been optimized away", name, n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	slowThreshold = flag.Duration("slow-threshold", 0,
+		"benchmark mode: when a measured iteration runs longer than this, snapshot every goroutine's stack while it is still running (0 disables)")
+	slowSnapshots = flag.Int("slow-snapshots", 5,
+		"with -slow-threshold, the most stack snapshots to take")
+	slowInterval = flag.Duration("slow-interval", time.Second,
+		"with -slow-threshold, the least time between stack snapshots")
+	slowStacksOut = flag.String("slow-stacks-out", "",
+		"with -slow-threshold, write the full goroutine dump of every snapshot to this file")
+)
+
+// slowStackBufferSize bounds a goroutine dump; longer dumps are truncated
+const slowStackBufferSize = 1 << 20
+
+// validateSlowIterations checks the -slow-threshold flags
+func validateSlowIterations(mode string) error {
+	if *slowThreshold == 0 {
+		return nil
+	}
+	if *slowThreshold < 0 {
+		return fmt.Errorf("-slow-threshold must not be negative, got %s", *slowThreshold)
+	}
+	if mode != "benchmark" {
+		return fmt.Errorf("-slow-threshold is only supported in benchmark mode")
+	}
+	if *slowSnapshots < 1 || *slowInterval < 0 {
+		return fmt.Errorf("-slow-snapshots must be positive and -slow-interval non-negative")
+	}
+	return nil
+}
+
+// slowWorker is what one worker is running, as seen by the watchdog
+type slowWorker struct {
+	started   atomic.Int64 // Run clock nanoseconds the iteration began at, 0 while idle
+	iteration atomic.Int64
+	goid      atomic.Int64 // The worker goroutine's ID, 0 until its first iteration
+	slow      atomic.Uint64
+	snapped   int64 // Last iteration snapshotted; only the watchdog uses it
+	idBuf     [64]byte
+}
+
+// slowSnapshot is a goroutine dump taken while an iteration was overdue
+type slowSnapshot struct {
+	iteration int64
+	worker    int
+	elapsed   time.Duration // How long the iteration had been running
+	at        time.Duration // On the run clock
+	state     string        // The worker goroutine's wait reason or status
+	stack     string        // The worker goroutine's stanza of the dump
+	dump      string
+}
+
+// slowIterationWatch catches long-tail iterations in the act. Workers note
+// when each iteration begins and ends, which costs two atomic stores; a
+// watchdog goroutine polls the workers at a quarter of the threshold and,
+// when it finds an iteration running past it, dumps every goroutine's stack
+// with runtime.Stack, showing what the slow iteration was doing or blocked
+// on at that moment: a GC assist, a channel, a lock or plain work. A dump
+// stops the world, so snapshots are capped and spaced by -slow-interval;
+// overdue iterations past those limits are counted as suppressed. Slow
+// iterations that finish between polls are counted but not snapshotted.
+type slowIterationWatch struct {
+	threshold  time.Duration
+	limit      int
+	interval   time.Duration
+	workers    []slowWorker
+	buf        []byte
+	mu         sync.Mutex
+	snapshots  []slowSnapshot
+	suppressed int
+	last       time.Time
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+// slowIterations is the watch of the measured phase, or nil when
+// -slow-threshold is disabled
+var slowIterations *slowIterationWatch
+
+// newSlowIterationWatch prepares a watch over the given number of workers,
+// returning nil when disabled
+func newSlowIterationWatch(workers int) *slowIterationWatch {
+	if *slowThreshold == 0 {
+		return nil
+	}
+	s := &slowIterationWatch{
+		threshold: *slowThreshold,
+		limit:     *slowSnapshots,
+		interval:  *slowInterval,
+		workers:   make([]slowWorker, workers),
+		buf:       make([]byte, slowStackBufferSize),
+		snapshots: make([]slowSnapshot, 0, *slowSnapshots),
+	}
+	for n := range s.workers {
+		s.workers[n].snapped = -1
+	}
+	return s
+}
+
+// begin notes that worker n started iteration i
+func (s *slowIterationWatch) begin(n, i int) {
+	w := &s.workers[n]
+	if w.goid.Load() == 0 {
+		w.goid.Store(goroutineID(w.idBuf[:]))
+	}
+	w.iteration.Store(int64(i))
+	w.started.Store(int64(runClock(time.Now())))
+}
+
+// end notes that worker n finished its iteration
+func (s *slowIterationWatch) end(n int) {
+	w := &s.workers[n]
+	if time.Duration(int64(runClock(time.Now()))-w.started.Load()) > s.threshold {
+		w.slow.Add(1)
+	}
+	w.started.Store(0)
+}
+
+// goroutineID parses the calling goroutine's ID from the header of its
+// stack trace, using buf so it doesn't allocate
+func goroutineID(buf []byte) int64 {
+	n := runtime.Stack(buf, false)
+	var id int64
+	for _, c := range bytes.TrimPrefix(buf[:n], []byte("goroutine ")) {
+		if c < '0' || c > '9' {
+			break
+		}
+		id = id*10 + int64(c-'0')
+	}
+	return id
+}
+
+// Start starts the watchdog
+func (s *slowIterationWatch) Start() {
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.watch(max(s.threshold/4, time.Millisecond))
+}
+
+// Stop stops the watchdog
+func (s *slowIterationWatch) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *slowIterationWatch) watch(every time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+		for n := range s.workers {
+			w := &s.workers[n]
+			started := w.started.Load()
+			if started == 0 {
+				continue
+			}
+			now := runClock(time.Now())
+			elapsed := now - time.Duration(started)
+			i := w.iteration.Load()
+			if elapsed <= s.threshold || i == w.snapped {
+				continue
+			}
+			w.snapped = i
+			s.snapshot(n, i, elapsed, now)
+		}
+	}
+}
+
+// snapshot dumps every goroutine's stack for worker n's overdue iteration i,
+// unless the cap or the interval suppresses it
+func (s *slowIterationWatch) snapshot(n int, i int64, elapsed, at time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.snapshots) == s.limit || (!s.last.IsZero() && time.Since(s.last) < s.interval) {
+		s.suppressed++
+		return
+	}
+	s.last = time.Now()
+	dump := string(s.buf[:runtime.Stack(s.buf, true)])
+	snap := slowSnapshot{iteration: i, worker: n, elapsed: elapsed, at: at, dump: dump}
+	header := "goroutine " + strconv.FormatInt(s.workers[n].goid.Load(), 10) + " ["
+	for _, stanza := range strings.Split(dump, "\n\n") {
+		if strings.HasPrefix(stanza, header) {
+			snap.stack = stanza
+			snap.state, _, _ = strings.Cut(stanza[len(header):], "]")
+			break
+		}
+	}
+	s.snapshots = append(s.snapshots, snap)
+}
+
+// stackFunctions lists the functions of a goroutine's stanza, innermost
+// first
+func stackFunctions(stanza string) []string {
+	var funcs []string
+	for _, line := range strings.Split(stanza, "\n")[1:] {
+		// Calls alternate with their indented file and line
+		if line == "" || line[0] == '\t' {
+			continue
+		}
+		if strings.HasPrefix(line, "created by ") {
+			break
+		}
+		if i := strings.LastIndexByte(line, '('); i > 0 {
+			line = line[:i]
+		}
+		funcs = append(funcs, line)
+	}
+	return funcs
+}
+
+// Report prints how many iterations overran the threshold and, for each
+// snapshot, which iteration it caught, what state the worker goroutine was
+// in and the functions on its stack
+func (s *slowIterationWatch) Report() {
+	printSection("Slow Iterations")
+	printMetric("Threshold", "%s", formatDuration(s.threshold))
+	var slow uint64
+	for n := range s.workers {
+		slow += s.workers[n].slow.Load()
+	}
+	printMetric("Slow Iterations", "%d", slow)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	printMetric("Snapshots", "%d", len(s.snapshots))
+	printMetric("Suppressed Snapshots", "%d", s.suppressed)
+	for k, snap := range s.snapshots {
+		desc := fmt.Sprintf("iteration %d on worker %d, %s in at %s", snap.iteration, snap.worker,
+			formatDuration(snap.elapsed), formatDuration(snap.at))
+		if snap.stack == "" {
+			printMetric(fmt.Sprintf("Snapshot %d", k+1), "%s (worker goroutine not found in dump)", desc)
+			continue
+		}
+		printMetric(fmt.Sprintf("Snapshot %d", k+1), "%s (%s)", desc, snap.state)
+		printMetric(fmt.Sprintf("Snapshot %d Stack", k+1), "%s", strings.Join(stackFunctions(snap.stack), " < "))
+	}
+}
+
+// WriteFile writes every snapshot's full goroutine dump to -slow-stacks-out
+func (s *slowIterationWatch) WriteFile() error {
+	if *slowStacksOut == "" {
+		return nil
+	}
+	f, err := os.Create(*slowStacksOut)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, snap := range s.snapshots {
+		fmt.Fprintf(f, "=== Snapshot %d: iteration %d on worker %d, %s in at %s ===\n\n%s\n",
+			k+1, snap.iteration, snap.worker, formatDuration(snap.elapsed), formatDuration(snap.at), snap.dump)
+	}
+	return f.Close()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// sortDurations sorts d in ascending order in place
+func sortDurations(d []time.Duration) {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+}
+
+// durationPercentile returns the p-th percentile (0-100) of sorted using the
+// nearest-rank method, or 0 if sorted is empty
+func durationPercentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// meanDuration returns the arithmetic mean of d, or 0 if d is empty
+func meanDuration(d []time.Duration) time.Duration {
+	if len(d) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, v := range d {
+		total += v
+	}
+	return total / time.Duration(len(d))
+}
@@ -0,0 +1,88 @@
+#!/bin/bash
+
+# Runs a workload across a range of values of one of its flags under both
+# collectors and prints the resulting GC cost curve. Extra arguments are
+# passed to both benchmark binaries.
+#
+# Usage: ./sweep.sh <workload> <flag> "<values>" [benchmark flags...]
+#
+# Examples:
+#   ./sweep.sh pointerdensity pointer-density "0 25 50 75 100"
+#   ./sweep.sh scanratio scan-fraction "0 10 25 50 75 90 100"
+#   ./sweep.sh matrix element-pointers "0 25 50 100" -layout mixed
+
+if [ $# -lt 3 ]; then
+    echo "Usage: $0 <workload> <flag> \"<values>\" [benchmark flags...]"
+    exit 1
+fi
+
+WORKLOAD=$1
+PARAM=$2
+VALUES=$3
+shift 3
+OUT_DIR=benchmark_results/sweep_${WORKLOAD}_${PARAM}
+
+echo "======================================"
+echo "Sweep: $WORKLOAD -$PARAM"
+echo "Standard GC vs Green Tea GC"
+echo "======================================"
+echo ""
+
+mkdir -p "$OUT_DIR"
+
+go build -o matrix_benchmark_standard *.go
+if [ $? -ne 0 ]; then
+    echo "Build failed for standard GC"
+    exit 1
+fi
+
+GOEXPERIMENT=greenteagc go build -o matrix_benchmark_greentea *.go
+if [ $? -ne 0 ]; then
+    echo "Build failed for Green Tea GC"
+    echo "Note: Green Tea GC is only available in Go 1.25+"
+    exit 1
+fi
+
+# Prints the first word of a metric from the result message a run sent over
+# the coordinator protocol
+extract_metric() {
+    python3 - "$1" "$2" <<'PY'
+import json, sys
+for line in open(sys.argv[1]):
+    msg = json.loads(line)
+    if msg.get("v") != 1:
+        sys.exit("unsupported protocol version %s" % msg.get("v"))
+    if msg["type"] != "result":
+        continue
+    for m in msg.get("metrics", []):
+        if m["name"] == sys.argv[2]:
+            print(m["value"].split()[0])
+            sys.exit()
+PY
+}
+
+for value in $VALUES; do
+    echo "Running -$PARAM=$value..."
+    GREEN_TEA_BENCHMARK_IPC=fd:3 ./matrix_benchmark_standard -workload="$WORKLOAD" -"$PARAM"="$value" "$@" \
+        > "$OUT_DIR/standard_${value}.txt" 3> "$OUT_DIR/standard_${value}.jsonl" || exit 1
+    GREEN_TEA_BENCHMARK_IPC=fd:3 ./matrix_benchmark_greentea -workload="$WORKLOAD" -"$PARAM"="$value" "$@" \
+        > "$OUT_DIR/greentea_${value}.txt" 3> "$OUT_DIR/greentea_${value}.jsonl" || exit 1
+done
+
+echo ""
+printf "%-10s | Std GC CPU | GT GC CPU | Std Duration     | GT Duration      | Std GC Pause | GT GC Pause\n" "$PARAM"
+echo "-----------|------------|-----------|------------------|------------------|--------------|-------------"
+for value in $VALUES; do
+    STD="$OUT_DIR/standard_${value}.jsonl"
+    GT="$OUT_DIR/greentea_${value}.jsonl"
+    printf "%-10s | %10s | %9s | %-16s | %-16s | %-12s | %-12s\n" "$value" \
+        "$(extract_metric "$STD" "GC CPU Fraction")" "$(extract_metric "$GT" "GC CPU Fraction")" \
+        "$(extract_metric "$STD" "Total Duration")" "$(extract_metric "$GT" "Total Duration")" \
+        "$(extract_metric "$STD" "Total GC Pause")" "$(extract_metric "$GT" "Total GC Pause")"
+done
+
+echo ""
+echo "Full results saved to $OUT_DIR/"
+
+# Cleanup
+rm -f matrix_benchmark_standard matrix_benchmark_greentea
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+)
+
+// wasmMemoryLimit is the most linear memory a wasm32 module can address
+const wasmMemoryLimit = 4 * GB
+
+// onWasm reports whether the benchmark is running as WebAssembly (js/wasm
+// or wasip1). There, files like /proc belong to the host runtime, not to
+// this module, so OS samplers are replaced by portable fallbacks.
+func onWasm() bool {
+	return runtime.GOARCH == "wasm"
+}
+
+// wasmLinearMemory returns the size of the module's linear memory. Wasm
+// memory only grows, so this is also its peak.
+func wasmLinearMemory() uint64 {
+	sample := []metrics.Sample{{Name: "/memory/classes/total:bytes"}}
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// validateWasm rejects options that need to start processes, which
+// WebAssembly can't do
+func validateWasm() error {
+	if !onWasm() {
+		return nil
+	}
+	switch {
+	case *gcTimeline != "":
+		return fmt.Errorf("-gc-timeline re-runs the benchmark as a subprocess, which is not supported on %s/wasm", runtime.GOOS)
+	case *watch:
+		return fmt.Errorf("-watch re-runs the benchmark as a subprocess, which is not supported on %s/wasm", runtime.GOOS)
+	case len(workloadPlugins) > 0:
+		return fmt.Errorf("-plugin is not supported on %s/wasm", runtime.GOOS)
+	}
+	return nil
+}
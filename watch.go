@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+var watch = flag.Bool("watch", false,
+	"re-run the benchmark whenever the -config file changes, printing the change in key metrics against the previous run")
+
+// watchChildEnv marks a benchmark run started by -watch
+const watchChildEnv = "GREEN_TEA_BENCHMARK_WATCH_CHILD"
+
+// watchPollInterval is how often the config file is checked for changes
+const watchPollInterval = 500 * time.Millisecond
+
+// watchMetrics are the report lines compared between runs
+var watchMetrics = []string{
+	"Total Duration",
+	"Operations/sec",
+	"Number of GCs",
+	"Total GC Pause",
+	"Average GC Pause",
+	"GC CPU Fraction",
+	"Heap Allocated",
+	"Peak RSS",
+}
+
+// wantWatch reports whether this process should run the watch loop
+func wantWatch() bool {
+	return *watch && os.Getenv(watchChildEnv) == ""
+}
+
+// validateWatch checks that -watch has a config file to watch
+func validateWatch() error {
+	if *watch && *configFile == "" {
+		return fmt.Errorf("-watch needs a -config file to watch")
+	}
+	if *watch && *outputFormat != "text" {
+		return fmt.Errorf("-watch compares text reports and only supports -format=text")
+	}
+	return nil
+}
+
+// runWatch runs the benchmark in a child process each time the config file
+// changes, until interrupted. Each run is a fresh process, so one run's heap
+// never affects the next.
+func runWatch() {
+	var last time.Time
+	var prev map[string]string
+	for {
+		info, err := os.Stat(*configFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		} else if info.ModTime() != last {
+			last = info.ModTime()
+			fmt.Printf("=== %s: running with %s ===\n", time.Now().Format("15:04:05"), *configFile)
+			cur := runWatchChild()
+			if prev != nil && cur != nil {
+				printWatchDelta(prev, cur)
+			}
+			if cur != nil {
+				prev = cur
+			}
+			fmt.Printf("Watching %s for changes...\n", *configFile)
+		}
+		time.Sleep(watchPollInterval)
+	}
+}
+
+// runWatchChild runs the benchmark once with the original arguments, its
+// output passing through, and returns the metrics of its result by name, or
+// nil if it failed
+func runWatchChild() map[string]string {
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), watchChildEnv+"=1")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	result, err := runChild(cmd, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "run failed: %v\n", err)
+		return nil
+	}
+	if result == nil {
+		fmt.Fprintln(os.Stderr, "run failed: no result reported")
+		return nil
+	}
+	return metricsByName(result.Metrics)
+}
+
+// metricsByName indexes metrics by name. Where a name appears in several
+// sections, such as per phase, the first, overall value is kept.
+func metricsByName(metrics []reportMetric) map[string]string {
+	byName := map[string]string{}
+	for _, m := range metrics {
+		if _, ok := byName[m.Name]; !ok {
+			byName[m.Name] = m.Value
+		}
+	}
+	return byName
+}
+
+// printWatchDelta prints how each key metric changed since the previous run
+func printWatchDelta(prev, cur map[string]string) {
+	fmt.Println()
+	fmt.Println("=== Change from previous run ===")
+	for _, name := range watchMetrics {
+		was, ok1 := prev[name]
+		now, ok2 := cur[name]
+		if !ok1 || !ok2 {
+			continue
+		}
+		delta := ""
+		a, _, okA := parseMetricValue(was)
+		b, _, okB := parseMetricValue(now)
+		if okA && okB && a != 0 {
+			delta = fmt.Sprintf(" (%+.1f%%)", (b-a)/a*100)
+		}
+		fmt.Printf("%s: %s -> %s%s\n", name, was, now, delta)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// iterationRunner runs iterations on a fixed set of workload instances. Its
+// worker goroutines, latency histograms and GC phase probes are created once
+// and reused by every run, so running iterations allocates nothing beyond
+// what the workloads themselves do.
+type iterationRunner struct {
+	ws     []Workload
+	hists  []*latencyHistogram
+	probes []*gcPhaseProbe
+	starts []chan int // Per worker goroutine; each receive is one run's iteration count
+	next   atomic.Int64
+	done   sync.WaitGroup
+}
+
+// currentRunner is the runner runIterations reuses while it is passed the
+// same instances
+var currentRunner *iterationRunner
+
+func newIterationRunner(ws []Workload) *iterationRunner {
+	r := &iterationRunner{
+		ws:     ws,
+		hists:  make([]*latencyHistogram, len(ws)),
+		probes: make([]*gcPhaseProbe, len(ws)),
+	}
+	for n := range ws {
+		r.hists[n] = new(latencyHistogram)
+		r.probes[n] = newGCPhaseProbe()
+		r.probes[n].Read() // Allocates the probe's histogram buffer now
+	}
+	if len(ws) > 1 {
+		r.starts = make([]chan int, len(ws))
+		for n := range ws {
+			r.starts[n] = make(chan int)
+			go r.work(n)
+		}
+	}
+	return r
+}
+
+// iterationRunnerFor returns the runner for ws, which must be the instances
+// of the current runner or a prefix of them for it to be reused. Calling it
+// before a measured window keeps the runner's setup out of the window.
+func iterationRunnerFor(ws []Workload) *iterationRunner {
+	r := currentRunner
+	if r != nil && len(ws) <= len(r.ws) && &ws[0] == &r.ws[0] {
+		return r
+	}
+	if r != nil {
+		r.close()
+	}
+	currentRunner = newIterationRunner(ws)
+	return currentRunner
+}
+
+// close stops the runner's worker goroutines
+func (r *iterationRunner) close() {
+	for _, c := range r.starts {
+		close(c)
+	}
+}
+
+func (r *iterationRunner) work(n int) {
+	for iterations := range r.starts[n] {
+		r.claim(n, iterations)
+		r.done.Done()
+	}
+}
+
+// claim runs iterations on instance n, claiming each iteration index from
+// the shared counter, until all iterations are claimed
+func (r *iterationRunner) claim(n, iterations int) {
+	w, h, probe, log, slow, ring := r.ws[n], r.hists[n], r.probes[n], measuredIterations, slowIterations, iterationLatencies
+	phased := log.wantPhases() || ring.wantPhases()
+	for {
+		i := int(r.next.Add(1) - 1)
+		if i >= iterations {
+			return
+		}
+		markIteration(i, true)
+		if slow != nil {
+			slow.begin(n, i)
+		}
+		var gcStart gcState
+		if phased {
+			gcStart = probe.Read()
+		}
+		start := time.Now()
+		w.Iterate(i)
+		d := time.Since(start)
+		h.Record(d)
+		if log != nil || ring != nil {
+			phase := gcPhaseOff
+			if phased {
+				phase = gcPhaseBetween(gcStart, probe.Read())
+			}
+			if ring != nil {
+				ring.Record(start, d, phase)
+			}
+			if log != nil {
+				log.Record(start, d, phase)
+			}
+		}
+		if slow != nil {
+			slow.end(n)
+		}
+		markIteration(i, false)
+	}
+}
+
+// run runs iterations on the first workers instances; see runIterations
+func (r *iterationRunner) run(workers, iterations int) []*latencyHistogram {
+	if workers == 1 {
+		w, probe, log, slow, ring := r.ws[0], r.probes[0], measuredIterations, slowIterations, iterationLatencies
+		phased := log.wantPhases() || ring.wantPhases()
+		for i := 0; i < iterations; i++ {
+			markIteration(i, true)
+			if slow != nil {
+				slow.begin(0, i)
+			}
+			if log != nil || ring != nil {
+				var gcStart gcState
+				if phased {
+					gcStart = probe.Read()
+				}
+				start := time.Now()
+				w.Iterate(i)
+				d := time.Since(start)
+				phase := gcPhaseOff
+				if phased {
+					phase = gcPhaseBetween(gcStart, probe.Read())
+				}
+				if ring != nil {
+					ring.Record(start, d, phase)
+				}
+				if log != nil {
+					log.Record(start, d, phase)
+				}
+			} else {
+				w.Iterate(i)
+			}
+			if slow != nil {
+				slow.end(0)
+			}
+			markIteration(i, false)
+		}
+		return nil
+	}
+
+	r.next.Store(0)
+	r.done.Add(workers)
+	for n := 0; n < workers; n++ {
+		*r.hists[n] = latencyHistogram{}
+		r.starts[n] <- iterations
+	}
+	r.done.Wait()
+	return r.hists[:workers]
+}
+
+// runIterations runs iterations of the workload instances, one goroutine
+// per instance, with each goroutine claiming the next iteration index from a
+// shared counter. With more than one instance it records every iteration's
+// latency in a per-worker histogram, which it returns; a single instance
+// runs a plain loop and returns nil, timing iterations only while something
+// records them. Either way, iterations are also timed into
+// iterationLatencies and measuredIterations while they are set, and tagged
+// with their GC phase when one of them needs it. The histograms are reused by the next
+// call, so callers that keep them across calls must merge them first.
+func runIterations(ws []Workload, iterations int) []*latencyHistogram {
+	return iterationRunnerFor(ws).run(len(ws), iterations)
+}
+
+// detachRecorders detaches the iteration log, latency ring and slow
+// iteration watch, which hold the measured run only, for a run after it,
+// and returns a function that reattaches them
+func detachRecorders() (reattach func()) {
+	log, ring, slow := measuredIterations, iterationLatencies, slowIterations
+	measuredIterations, iterationLatencies, slowIterations = nil, nil, nil
+	return func() {
+		measuredIterations, iterationLatencies, slowIterations = log, ring, slow
+	}
+}
+
+// printWorkerLatency reports the merged iteration latency distribution over
+// all workers, each worker's own distribution, and the worst worker by p99.
+// A worker far slower than the rest points at unfair distribution of GC
+// assists or scheduling.
+func printWorkerLatency(hists []*latencyHistogram) {
+	merged := new(latencyHistogram)
+	for _, h := range hists {
+		merged.Merge(h)
+	}
+
+	printSection(fmt.Sprintf("Iteration Latency (%d workers)", len(hists)))
+	printMetric("Merged p50", "%s", formatDuration(merged.Percentile(50)))
+	printMetric("Merged p90", "%s", formatDuration(merged.Percentile(90)))
+	printMetric("Merged p99", "%s", formatDuration(merged.Percentile(99)))
+	printMetric("Merged p99.9", "%s", formatDuration(merged.Percentile(99.9)))
+	printMetric("Merged Max", "%s", formatDuration(merged.Max()))
+	fmt.Println()
+
+	fmt.Printf("%-8s | %-10s | %-14s | %-14s | %-14s | %-14s\n",
+		"Worker", "Iterations", "Mean", "p50", "p99", "Max")
+	worst := 0
+	for n, h := range hists {
+		fmt.Printf("%-8d | %-10d | %-14s | %-14s | %-14s | %-14s\n",
+			n, h.Count(), formatDuration(h.Mean()), formatDuration(h.Percentile(50)),
+			formatDuration(h.Percentile(99)), formatDuration(h.Max()))
+		if h.Percentile(99) > hists[worst].Percentile(99) {
+			worst = n
+		}
+	}
+	fmt.Println()
+
+	worstP99 := hists[worst].Percentile(99)
+	fmt.Printf("Worst Worker: %d (p99 %s", worst, formatDuration(worstP99))
+	if mp99 := merged.Percentile(99); mp99 > 0 {
+		fmt.Printf(", %.2fx merged p99", float64(worstP99)/float64(mp99))
+	}
+	fmt.Println(")")
+}
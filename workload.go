@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+)
+
+var dataSeed = flag.Int64("seed", 1, "seed for the random data and access patterns workloads generate")
+
+// Workload is an allocation pattern exercised once per benchmark iteration.
+// The harness owns the loop: it constructs instances from the registry, sets
+// them up, runs warmup and measured iterations and tears them down, so a new
+// allocation pattern only needs a Workload and a registerWorkload call.
+// Workloads with resources to manage implement workloadSetup and
+// workloadTeardown as well.
+type Workload interface {
+	// Name returns the name the workload is registered under
+	Name() string
+	// Iterate performs a single benchmark iteration; i is the iteration index
+	Iterate(i int)
+}
+
+// workloads maps workload names to their constructors. Constructors run
+// after flags are parsed, so they may read workload-specific flags.
+var workloads = map[string]func() (Workload, error){}
+
+// registerWorkload makes a workload selectable with -workload
+func registerWorkload(name string, newWorkload func() (Workload, error)) {
+	if _, dup := workloads[name]; dup {
+		panic("workload registered twice: " + name)
+	}
+	workloads[name] = newWorkload
+}
+
+// workloadNames returns the registered workload names in sorted order
+func workloadNames() []string {
+	names := make([]string, 0, len(workloads))
+	for name := range workloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// newWorkload constructs the workload registered under name
+func newWorkload(name string) (Workload, error) {
+	newFn, ok := workloads[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown workload %q (available: %v)", name, workloadNames())
+	}
+	return newFn()
+}
+
+// newWorkloads constructs and sets up n instances of the workload registered
+// under name. If any fails, the ones already set up are torn down.
+func newWorkloads(name string, n int) ([]Workload, error) {
+	ws := make([]Workload, 0, n)
+	for len(ws) < n {
+		w, err := newWorkload(name)
+		if err == nil {
+			err = setupWorkload(w)
+		}
+		if err != nil {
+			teardownWorkloads(ws)
+			return nil, err
+		}
+		ws = append(ws, w)
+	}
+	return ws, nil
+}
+
+// setupWorkload runs w's Setup, if it has one
+func setupWorkload(w Workload) error {
+	if s, ok := w.(workloadSetup); ok {
+		if err := s.Setup(); err != nil {
+			return fmt.Errorf("%s workload: setup: %w", w.Name(), err)
+		}
+	}
+	return nil
+}
+
+// teardownWorkloads runs the Teardown of every instance that has one
+func teardownWorkloads(ws []Workload) {
+	for _, w := range ws {
+		if t, ok := w.(workloadTeardown); ok {
+			t.Teardown()
+		}
+	}
+}
+
+// reportWorkloads prints the statistics of workloads that have their own.
+// The instances are merged into the first when they support it; otherwise
+// each instance gets a section of its own.
+func reportWorkloads(ws []Workload) {
+	r, ok := ws[0].(workloadReporter)
+	if !ok {
+		return
+	}
+	if m, ok := ws[0].(workloadMerger); ok || len(ws) == 1 {
+		for _, w := range ws[1:] {
+			m.Merge(w)
+		}
+		fmt.Println()
+		printSection("Workload Statistics")
+		r.Report()
+		return
+	}
+	for n, w := range ws {
+		fmt.Println()
+		printSection(fmt.Sprintf("Workload Statistics (instance %d)", n+1))
+		w.(workloadReporter).Report()
+	}
+}
+
+// workloadSetup is implemented by workloads that acquire resources, such as
+// servers, connections or goroutines, before their first iteration. Setup
+// runs once per instance, outside any measurement.
+type workloadSetup interface {
+	Setup() error
+}
+
+// workloadTeardown is implemented by workloads that release resources after
+// the run; Teardown runs once per set up instance
+type workloadTeardown interface {
+	Teardown()
+}
+
+// workloadReporter is implemented by workloads that have statistics of
+// their own to print after the run
+type workloadReporter interface {
+	Report()
+}
+
+// workloadMerger is implemented by reporting workloads that can fold
+// another instance's statistics into their own, so that with several
+// workers the report covers every instance. other is always an instance of
+// the same workload, and Merge runs after the last iteration.
+type workloadMerger interface {
+	Merge(other any)
+}
+
+// workloadBaseline is implemented by workloads with a baseline kernel for
+// -baseline: the same computation without allocation, whose time is the
+// ceiling for the workload's
+type workloadBaseline interface {
+	Baseline() Workload
+}
+
+// workloadAllocStrategy is implemented by workloads with a choice of
+// allocation strategies, whose measured phase is compared with a run of
+// the default strategy when another is selected, and which the strategies
+// mode runs with each strategy in turn
+type workloadAllocStrategy interface {
+	AllocStrategy() string
+	WithAlloc(strategy string) Workload
+}
+
+// workloadStatsResetter is implemented by workloads that collect statistics
+// of their own, so statistics gathered during warmup can be discarded
+type workloadStatsResetter interface {
+	ResetStats()
+}
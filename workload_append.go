@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+var (
+	appendLengths = flag.String("append-lengths", "8,512,16384",
+		"append workload: comma-separated final lengths of the slices built by appending each iteration")
+	appendPresize = flag.Bool("append-presize", false,
+		"append workload: allocate each slice at its final capacity up front, removing growth as a baseline")
+)
+
+func init() {
+	registerWorkload("append", newAppendWorkload)
+}
+
+// appendElem is a slice element. Its pointer makes backing arrays scannable
+// and gives every element a payload object of its own.
+type appendElem struct {
+	id   int
+	data *[4]uint64
+}
+
+// appendWorkload builds slices one append at a time, from empty to each of
+// the configured lengths, so they grow through the runtime's capacity steps:
+// every step allocates a larger backing array, copies the elements over and
+// drops the old array as garbage. Growth allocations are counted apart from
+// payload allocations, the final backing arrays and the element objects,
+// which a presized slice would allocate too.
+type appendWorkload struct {
+	Sink
+	lengths []int
+	presize bool
+	live    [][]appendElem // Each length's latest slice
+
+	slices       uint64
+	growths      uint64 // Backing arrays dropped by growth
+	growthBytes  uint64
+	payloadBytes uint64
+	payloads     uint64 // Payload allocations: final arrays and elements
+}
+
+func newAppendWorkload() (Workload, error) {
+	var lengths []int
+	for _, field := range strings.Split(*appendLengths, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("-append-lengths: lengths must be positive integers, got %q", field)
+		}
+		lengths = append(lengths, n)
+	}
+	return &appendWorkload{
+		lengths: lengths,
+		presize: *appendPresize,
+		live:    make([][]appendElem, len(lengths)),
+	}, nil
+}
+
+func (w *appendWorkload) Name() string { return "append" }
+
+func (w *appendWorkload) Iterate(i int) {
+	const elemSize = uint64(unsafe.Sizeof(appendElem{}))
+	for n, length := range w.lengths {
+		var s []appendElem
+		if w.presize {
+			s = make([]appendElem, 0, length)
+		}
+		for k := 0; k < length; k++ {
+			before := cap(s)
+			s = append(s, appendElem{id: i*length + k, data: new([4]uint64)})
+			if cap(s) != before && before > 0 {
+				w.growths++
+				w.growthBytes += uint64(before) * elemSize // All of it was copied, too
+			}
+		}
+		w.live[n] = s
+		w.slices++
+		w.payloads += uint64(length) + 1
+		w.payloadBytes += uint64(cap(s))*elemSize + uint64(length)*uint64(unsafe.Sizeof([4]uint64{}))
+	}
+	w.Keep(&w.live[len(w.live)-1][0])
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *appendWorkload) ResetStats() {
+	w.slices, w.growths, w.growthBytes, w.payloadBytes, w.payloads = 0, 0, 0, 0, 0
+}
+
+// Report prints how much of the allocation was growth rather than payload
+func (w *appendWorkload) Report() {
+	printMetric("Slices Built", "%d", w.slices)
+	printMetric("Growth Allocations", "%d", w.growths)
+	printMetric("Growth Bytes", "%s", formatBytes(w.growthBytes))
+	printMetric("Payload Allocations", "%d", w.payloads)
+	printMetric("Payload Bytes", "%s", formatBytes(w.payloadBytes))
+	if total := w.growthBytes + w.payloadBytes; total > 0 {
+		printMetric("Growth Share of Bytes", "%.2f%%", float64(w.growthBytes)/float64(total)*100)
+	}
+	if total := w.growths + w.payloads; total > 0 {
+		printMetric("Growth Share of Allocations", "%.2f%%", float64(w.growths)/float64(total)*100)
+	}
+}
+
+// Merge adds other's counts to w's
+func (w *appendWorkload) Merge(other any) {
+	o := other.(*appendWorkload)
+	w.slices += o.slices
+	w.growths += o.growths
+	w.growthBytes += o.growthBytes
+	w.payloadBytes += o.payloadBytes
+	w.payloads += o.payloads
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"math/rand"
+)
+
+var (
+	bigFloatSize = flag.Int("bigfloat-size", 16,
+		"bigfloat workload: rows and columns of each matrix")
+	bigFloatPrec = flag.Uint("bigfloat-prec", 256,
+		"bigfloat workload: mantissa precision of each element in bits")
+	bigFloatKeepEvery = flag.Int("bigfloat-keep-every", 100,
+		"bigfloat workload: retain the result of every Nth iteration as long-lived heap (0 retains none)")
+)
+
+func init() {
+	registerWorkload("bigfloat", newBigFloatWorkload)
+}
+
+// BigMatrix is a matrix of arbitrary-precision elements. Each element is a
+// big.Float, a struct of several words pointing to its mantissa, a separate
+// array of prec/64 words, so every element is two allocations of which the
+// larger holds a pointer.
+type BigMatrix struct {
+	rows int
+	cols int
+	prec uint
+	data [][]*big.Float
+}
+
+// NewBigMatrix creates a matrix of zero elements with the given precision
+func NewBigMatrix(rows, cols int, prec uint) *BigMatrix {
+	m := &BigMatrix{rows: rows, cols: cols, prec: prec, data: make([][]*big.Float, rows)}
+	for i := range m.data {
+		m.data[i] = make([]*big.Float, cols)
+		for j := range m.data[i] {
+			m.data[i][j] = new(big.Float).SetPrec(prec)
+		}
+	}
+	return m
+}
+
+// randomBigMatrix creates a matrix of random elements
+func randomBigMatrix(rows, cols int, prec uint, rng *rand.Rand) *BigMatrix {
+	m := NewBigMatrix(rows, cols, prec)
+	for i := range m.data {
+		for j := range m.data[i] {
+			// A full-width mantissa, so elements use their whole precision
+			m.data[i][j].Quo(big.NewFloat(rng.Float64()), big.NewFloat(3))
+		}
+	}
+	return m
+}
+
+// Multiply performs matrix multiplication
+func (m *BigMatrix) Multiply(other *BigMatrix) *BigMatrix {
+	if m.cols != other.rows {
+		panic("incompatible dimensions for multiplication")
+	}
+
+	result := NewBigMatrix(m.rows, other.cols, m.prec)
+	product := new(big.Float).SetPrec(m.prec)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < other.cols; j++ {
+			sum := result.data[i][j]
+			for k := 0; k < m.cols; k++ {
+				sum.Add(sum, product.Mul(m.data[i][k], other.data[k][j]))
+			}
+		}
+	}
+	return result
+}
+
+// Add performs matrix addition
+func (m *BigMatrix) Add(other *BigMatrix) *BigMatrix {
+	if m.rows != other.rows || m.cols != other.cols {
+		panic("incompatible dimensions for addition")
+	}
+
+	result := NewBigMatrix(m.rows, m.cols, m.prec)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			result.data[i][j].Add(m.data[i][j], other.data[i][j])
+		}
+	}
+	return result
+}
+
+// ScalarMultiply multiplies each element by a scalar
+func (m *BigMatrix) ScalarMultiply(scalar float64) *BigMatrix {
+	result := NewBigMatrix(m.rows, m.cols, m.prec)
+	s := big.NewFloat(scalar)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			result.data[i][j].Mul(m.data[i][j], s)
+		}
+	}
+	return result
+}
+
+// bigFloatWorkload is the matrix workload with *big.Float elements in place
+// of *float64: every iteration multiplies two fresh matrices, adds the
+// product to one of them and scales the sum. Where a *float64 element is a
+// single 8-byte pointer-free object, a big.Float is a larger object holding
+// a pointer to its mantissa, so the same number of elements gives the
+// marker fewer, bigger objects to scan and a pointer to chase from each,
+// a contrast for marker throughput studies.
+type bigFloatWorkload struct {
+	Sink
+	size      int
+	prec      uint
+	keepEvery int
+	rng       *rand.Rand
+	results   []*BigMatrix
+	retained  int // Results retained by merged instances
+}
+
+func newBigFloatWorkload() (Workload, error) {
+	if *bigFloatSize < 1 || *bigFloatKeepEvery < 0 {
+		return nil, fmt.Errorf("-bigfloat-size must be positive and -bigfloat-keep-every non-negative")
+	}
+	if *bigFloatPrec < 1 || *bigFloatPrec > big.MaxPrec {
+		return nil, fmt.Errorf("-bigfloat-prec must be between 1 and %d, got %d", uint(big.MaxPrec), *bigFloatPrec)
+	}
+	return &bigFloatWorkload{
+		size:      *bigFloatSize,
+		prec:      *bigFloatPrec,
+		keepEvery: *bigFloatKeepEvery,
+		rng:       rand.New(rand.NewSource(*dataSeed)),
+	}, nil
+}
+
+func (w *bigFloatWorkload) Name() string { return "bigfloat" }
+
+func (w *bigFloatWorkload) Iterate(i int) {
+	a := randomBigMatrix(w.size, w.size, w.prec, w.rng)
+	b := randomBigMatrix(w.size, w.size, w.prec, w.rng)
+	result := a.Multiply(b).Add(a).ScalarMultiply(2.5)
+	w.Keep(result)
+
+	// Retain some results as long-lived heap
+	if w.keepEvery > 0 && i%w.keepEvery == 0 {
+		w.results = append(w.results, result)
+	}
+}
+
+// Report prints the element precision and the retained results
+func (w *bigFloatWorkload) Report() {
+	printMetric("Precision", "%d bits", w.prec)
+	printMetric("Elements per Matrix", "%d", w.size*w.size)
+	printMetric("Retained Matrices", "%d", len(w.results)+w.retained)
+}
+
+// Merge counts other's retained results with w's
+func (w *bigFloatWorkload) Merge(other any) {
+	o := other.(*bigFloatWorkload)
+	w.retained += len(o.results) + o.retained
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var (
+	bintreeMaxDepth = flag.Int("bintree-depth", 16,
+		"bintree workload: depth of the long-lived tree and of the deepest short-lived trees")
+	bintreeMinDepth = flag.Int("bintree-min-depth", 4,
+		"bintree workload: depth of the shallowest short-lived trees")
+)
+
+func init() {
+	registerWorkload("bintree", newBintreeWorkload)
+}
+
+// treeNode is a node of a binary tree; like the original benchmark it holds
+// nothing but its children
+type treeNode struct {
+	left, right *treeNode
+}
+
+// newTree builds a complete binary tree of the given depth
+func newTree(depth int) *treeNode {
+	if depth == 0 {
+		return &treeNode{}
+	}
+	return &treeNode{left: newTree(depth - 1), right: newTree(depth - 1)}
+}
+
+// check walks the tree and returns its node count
+func (t *treeNode) check() int {
+	if t.left == nil {
+		return 1
+	}
+	return 1 + t.left.check() + t.right.check()
+}
+
+// bintreeWorkload is the binary-trees benchmark from the Computer Language
+// Benchmarks Game, the canonical GC comparison: a long-lived tree of the
+// maximum depth stays live while short-lived trees are built, walked and
+// dropped. Each iteration takes the next depth from minimum to maximum in
+// steps of two and builds 2^(max-depth) trees of it, so every iteration
+// allocates about the same number of nodes but the tree shapes vary.
+type bintreeWorkload struct {
+	Sink
+	minDepth  int
+	maxDepth  int
+	longLived *treeNode
+	checks    uint64
+	trees     uint64
+	nodes     int // Long-lived tree nodes of merged instances
+}
+
+func newBintreeWorkload() (Workload, error) {
+	if *bintreeMinDepth < 1 || *bintreeMaxDepth < *bintreeMinDepth || *bintreeMaxDepth > 30 {
+		return nil, fmt.Errorf("-bintree-min-depth must be positive and at most -bintree-depth, which must be at most 30")
+	}
+	return &bintreeWorkload{
+		minDepth:  *bintreeMinDepth,
+		maxDepth:  *bintreeMaxDepth,
+		longLived: newTree(*bintreeMaxDepth),
+	}, nil
+}
+
+func (w *bintreeWorkload) Name() string { return "bintree" }
+
+func (w *bintreeWorkload) Iterate(i int) {
+	steps := (w.maxDepth-w.minDepth)/2 + 1
+	depth := w.minDepth + 2*(i%steps)
+	for n := 1 << (w.maxDepth - depth); n > 0; n-- {
+		t := newTree(depth)
+		w.checks += uint64(t.check())
+		w.trees++
+		w.Keep(t)
+	}
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *bintreeWorkload) ResetStats() {
+	w.checks, w.trees = 0, 0
+}
+
+// Report prints tree counts and the long-lived tree's check
+func (w *bintreeWorkload) Report() {
+	printMetric("Trees Built", "%d", w.trees)
+	printMetric("Nodes Checked", "%d", w.checks)
+	printMetric("Long-Lived Tree Nodes", "%d", w.longLived.check()+w.nodes)
+}
+
+// Merge adds other's counts and long-lived tree to w's
+func (w *bintreeWorkload) Merge(other any) {
+	o := other.(*bintreeWorkload)
+	w.checks += o.checks
+	w.trees += o.trees
+	w.nodes += o.longLived.check() + o.nodes
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"unsafe"
+)
+
+var (
+	boxValues = flag.Int("box-values", 20000,
+		"boxing workload: values stored behind interfaces per iteration")
+	boxKind = flag.String("box-kind", "mixed",
+		"boxing workload: values boxed: scalar (integers and floats), string, struct or mixed")
+	boxLive = flag.Int("box-live", 100000,
+		"boxing workload: most recent interface values kept alive")
+	boxTyped = flag.Bool("box-typed", false,
+		"boxing workload: store the same values in typed slices instead, removing boxing as a baseline")
+)
+
+func init() {
+	registerWorkload("boxing", newBoxingWorkload)
+}
+
+// boxStruct is a small record of the kind passed around as any in
+// interface-heavy code
+type boxStruct struct {
+	id     int64
+	weight float64
+	label  string
+}
+
+// Kinds of value the boxing workload stores, in the order mixed cycles
+// through them
+const (
+	boxInt = iota
+	boxFloat
+	boxString
+	boxRecord
+	boxKinds
+)
+
+// boxLabels are the strings boxed values point to
+var boxLabels = []string{"GET", "PUT", "POST", "DELETE", "order", "user", "session", "metric"}
+
+// boxingWorkload stores values behind any, the way loggers, generic
+// containers and reflection-based encoders do. Converting a non-pointer
+// value to an interface copies it into a heap object of its own: integers
+// and floats into pointer-free 8-byte objects, strings into 16-byte headers
+// that point at their bytes and structs into copies that keep their
+// pointers. Integers are offset past 255, whose boxes the runtime shares
+// rather than allocates. A ring of the latest interfaces stays alive, so
+// the collector scans every interface and follows it to its box. With
+// -box-typed the same values go into typed slices, which allocate nothing,
+// so the difference is the cost of boxing.
+type boxingWorkload struct {
+	Sink
+	perIter int
+	kinds   []int
+	typed   bool
+	size    int // Length of the rings
+	next    int
+
+	live    []any // Ring of recent interface values
+	ints    []int64
+	floats  []float64
+	strs    []string
+	records []boxStruct
+
+	stored uint64
+	boxed  [boxKinds]uint64
+}
+
+func newBoxingWorkload() (Workload, error) {
+	if *boxValues < 1 || *boxLive < 1 {
+		return nil, fmt.Errorf("-box-values and -box-live must be positive")
+	}
+	var kinds []int
+	switch *boxKind {
+	case "scalar":
+		kinds = []int{boxInt, boxFloat}
+	case "string":
+		kinds = []int{boxString}
+	case "struct":
+		kinds = []int{boxRecord}
+	case "mixed":
+		kinds = []int{boxInt, boxFloat, boxString, boxRecord}
+	default:
+		return nil, fmt.Errorf("-box-kind must be scalar, string, struct or mixed, got %q", *boxKind)
+	}
+
+	w := &boxingWorkload{
+		perIter: *boxValues,
+		kinds:   kinds,
+		typed:   *boxTyped,
+		size:    *boxLive,
+	}
+	if w.typed {
+		w.ints = make([]int64, *boxLive)
+		w.floats = make([]float64, *boxLive)
+		w.strs = make([]string, *boxLive)
+		w.records = make([]boxStruct, *boxLive)
+	} else {
+		w.live = make([]any, *boxLive)
+	}
+	return w, nil
+}
+
+func (w *boxingWorkload) Name() string { return "boxing" }
+
+func (w *boxingWorkload) Iterate(i int) {
+	for k := 0; k < w.perIter; k++ {
+		v := int64(i*w.perIter + k)
+		kind := w.kinds[k%len(w.kinds)]
+		slot := w.next
+		w.next = (w.next + 1) % w.size
+		label := boxLabels[k%len(boxLabels)]
+
+		if w.typed {
+			switch kind {
+			case boxInt:
+				w.ints[slot] = v + 256
+			case boxFloat:
+				w.floats[slot] = float64(v) * 0.5
+			case boxString:
+				w.strs[slot] = label
+			case boxRecord:
+				w.records[slot] = boxStruct{id: v, weight: float64(v) * 0.5, label: label}
+			}
+		} else {
+			switch kind {
+			case boxInt:
+				w.live[slot] = v + 256
+			case boxFloat:
+				w.live[slot] = float64(v) * 0.5
+			case boxString:
+				w.live[slot] = label
+			case boxRecord:
+				w.live[slot] = boxStruct{id: v, weight: float64(v) * 0.5, label: label}
+			}
+		}
+		w.boxed[kind]++
+	}
+	w.stored += uint64(w.perIter)
+	w.Keep(w.checksum())
+}
+
+// checksum reads a stretch of the stored values back, through a type
+// switch when they are boxed, as consumers of interface values do
+func (w *boxingWorkload) checksum() uint64 {
+	var sum uint64
+	for slot := 0; slot < min(w.perIter, w.size); slot++ {
+		if w.typed {
+			sum += uint64(w.ints[slot]) + uint64(w.floats[slot]) + uint64(len(w.strs[slot])) + uint64(w.records[slot].id)
+			continue
+		}
+		switch v := w.live[slot].(type) {
+		case int64:
+			sum += uint64(v)
+		case float64:
+			sum += uint64(v)
+		case string:
+			sum += uint64(len(v))
+		case boxStruct:
+			sum += uint64(v.id)
+		}
+	}
+	return sum
+}
+
+// AllocationFree reports whether values go into typed slices, which
+// allocates nothing after setup
+func (w *boxingWorkload) AllocationFree() bool { return w.typed }
+
+// ResetStats discards counters accumulated during warmup
+func (w *boxingWorkload) ResetStats() {
+	w.stored = 0
+	w.boxed = [boxKinds]uint64{}
+}
+
+// Report prints how many values were stored of each kind and, when they
+// were boxed, how many bytes of boxes that took
+func (w *boxingWorkload) Report() {
+	storage := "interfaces"
+	if w.typed {
+		storage = "typed slices"
+	}
+	printMetric("Storage", "%s", storage)
+	printMetric("Values Stored", "%d", w.stored)
+	printMetric("Integers", "%d", w.boxed[boxInt])
+	printMetric("Floats", "%d", w.boxed[boxFloat])
+	printMetric("Strings", "%d", w.boxed[boxString])
+	printMetric("Structs", "%d", w.boxed[boxRecord])
+	if !w.typed {
+		boxBytes := (w.boxed[boxInt]+w.boxed[boxFloat])*8 +
+			w.boxed[boxString]*uint64(unsafe.Sizeof("")) +
+			w.boxed[boxRecord]*uint64(unsafe.Sizeof(boxStruct{}))
+		printMetric("Boxes Allocated", "%d", w.stored)
+		printMetric("Box Bytes", "%s", formatBytes(boxBytes))
+	}
+}
+
+// Merge adds other's counts to w's
+func (w *boxingWorkload) Merge(other any) {
+	o := other.(*boxingWorkload)
+	w.stored += o.stored
+	for k := range w.boxed {
+		w.boxed[k] += o.boxed[k]
+	}
+}
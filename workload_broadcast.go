@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	wsConns = flag.Int("ws-conns", 2000,
+		"broadcast workload: number of simulated WebSocket connections")
+	wsMessages = flag.Int("ws-messages", 1,
+		"broadcast workload: messages broadcast to every connection per iteration")
+	wsPayload = flag.Int("ws-payload", 256,
+		"broadcast workload: approximate size of each message payload in bytes")
+	wsHistory = flag.Int("ws-history", 16,
+		"broadcast workload: recent messages retained per connection")
+)
+
+func init() {
+	registerWorkload("broadcast", newBroadcastWorkload)
+}
+
+// wsMessage is a message handed from the hub to every connection
+type wsMessage struct {
+	data []byte
+	done *sync.WaitGroup
+}
+
+// wsConn is the long-lived state of one simulated WebSocket connection,
+// shaped like a typical hub client: identity, headers, subscriptions, an
+// outbound queue drained by a writer goroutine, and recent history.
+type wsConn struct {
+	id      int
+	user    string
+	headers map[string]string
+	topics  map[string]struct{}
+	send    chan wsMessage
+	history [][]byte
+	next    int
+	written int64
+}
+
+// wsChatMessage is the JSON payload broadcast by the hub
+type wsChatMessage struct {
+	Topic  string `json:"topic"`
+	Seq    int    `json:"seq"`
+	Sender string `json:"sender"`
+	Body   string `json:"body"`
+}
+
+// broadcastWorkload simulates a pub/sub WebSocket hub broadcasting to
+// thousands of connections. Each message is encoded once by the hub and
+// then framed into a fresh buffer per connection, while the connections'
+// long-lived state forms a large stable heap underneath the churn.
+type broadcastWorkload struct {
+	Sink
+	conns    []*wsConn
+	messages int
+	body     string
+	latency  latencyHistogram
+
+	mergedConns   int // Connections and their bytes of merged instances
+	mergedWritten int64
+}
+
+func newBroadcastWorkload() (Workload, error) {
+	if *wsConns < 1 || *wsMessages < 1 || *wsPayload < 0 || *wsHistory < 1 {
+		return nil, fmt.Errorf("-ws-conns, -ws-messages and -ws-history must be positive")
+	}
+
+	w := &broadcastWorkload{
+		conns:    make([]*wsConn, *wsConns),
+		messages: *wsMessages,
+		body:     strings.Repeat("x", *wsPayload),
+	}
+	for n := range w.conns {
+		c := &wsConn{
+			id:   n,
+			user: "user-" + strconv.Itoa(n),
+			headers: map[string]string{
+				"User-Agent":             "green-tea-benchmark",
+				"Sec-WebSocket-Protocol": "chat",
+				"X-Connection-Id":        strconv.Itoa(n),
+			},
+			topics:  map[string]struct{}{"general": {}, "topic-" + strconv.Itoa(n%64): {}},
+			send:    make(chan wsMessage, *wsMessages),
+			history: make([][]byte, *wsHistory),
+		}
+		w.conns[n] = c
+		go c.writePump()
+	}
+
+	return w, nil
+}
+
+// writePump frames every queued message into a new buffer, as a WebSocket
+// writer would before handing it to the socket
+func (c *wsConn) writePump() {
+	for msg := range c.send {
+		frame := make([]byte, 10+len(msg.data))
+		frame[0] = 0x81 // FIN + text frame
+		frame[1] = 127
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(msg.data)))
+		copy(frame[10:], msg.data)
+
+		c.history[c.next] = frame
+		c.next = (c.next + 1) % len(c.history)
+		c.written += int64(len(frame))
+		msg.done.Done()
+	}
+}
+
+func (w *broadcastWorkload) Name() string { return "broadcast" }
+
+func (w *broadcastWorkload) Iterate(i int) {
+	for m := 0; m < w.messages; m++ {
+		data, err := json.Marshal(wsChatMessage{
+			Topic:  "general",
+			Seq:    i*w.messages + m,
+			Sender: w.conns[(i+m)%len(w.conns)].user,
+			Body:   w.body,
+		})
+		if err != nil {
+			panic(fmt.Sprintf("broadcast workload: %v", err))
+		}
+		w.Keep(data)
+
+		start := time.Now()
+		done := new(sync.WaitGroup)
+		done.Add(len(w.conns))
+		for _, c := range w.conns {
+			c.send <- wsMessage{data: data, done: done}
+		}
+		done.Wait()
+		w.latency.Record(time.Since(start))
+	}
+}
+
+// ResetStats discards latencies recorded during warmup
+func (w *broadcastWorkload) ResetStats() {
+	w.latency = latencyHistogram{}
+}
+
+// Report prints the broadcast fan-out latency distribution
+func (w *broadcastWorkload) Report() {
+	written := w.mergedWritten
+	for _, c := range w.conns {
+		written += c.written
+	}
+	printMetric("Connections", "%d", len(w.conns)+w.mergedConns)
+	printMetric("Broadcasts", "%d", w.latency.Count())
+	printMetric("Frame Bytes Written", "%s", formatBytes(uint64(written)))
+	printMetric("Broadcast Latency p50", "%s", formatDuration(w.latency.Percentile(50)))
+	printMetric("Broadcast Latency p99", "%s", formatDuration(w.latency.Percentile(99)))
+	printMetric("Broadcast Latency Max", "%s", formatDuration(w.latency.Max()))
+}
+
+// Merge adds other's connections and latencies to w's
+func (w *broadcastWorkload) Merge(other any) {
+	o := other.(*broadcastWorkload)
+	w.mergedConns += len(o.conns) + o.mergedConns
+	w.mergedWritten += o.mergedWritten
+	for _, c := range o.conns {
+		w.mergedWritten += c.written
+	}
+	w.latency.Merge(&o.latency)
+}
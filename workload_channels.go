@@ -0,0 +1,156 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	chanProducers = flag.Int("chan-producers", 4,
+		"channels workload: number of goroutines allocating messages")
+	chanConsumers = flag.Int("chan-consumers", 4,
+		"channels workload: number of goroutines processing and dropping messages, each with its own channel")
+	chanFanout = flag.Int("chan-fanout", 1,
+		"channels workload: consumers each message is handed to, at most -chan-consumers")
+	chanBuffer = flag.Int("chan-buffer", 128,
+		"channels workload: buffer capacity of each consumer's channel")
+	chanMessages = flag.Int("chan-messages", 2000,
+		"channels workload: messages produced per iteration, across all producers")
+	chanSize = flag.Int("chan-size", 256,
+		"channels workload: payload size of each message in bytes")
+)
+
+func init() {
+	registerWorkload("channels", newChannelsWorkload)
+}
+
+// chanMessage is handed from a producer to one or more consumers
+type chanMessage struct {
+	seq     int
+	payload []byte
+	done    *sync.WaitGroup
+}
+
+// channelsWorkload has producers allocate messages and hand them through
+// buffered channels to consumers, which read them and drop them. Every
+// message is allocated on one goroutine and becomes garbage on another,
+// usually running on a different P, so allocation and the last use of the
+// memory are split across threads, the handoff pattern of pipelines. With
+// -chan-fanout above 1 consumers share a message, which dies when the last
+// of them drops it.
+type channelsWorkload struct {
+	Sink
+	producers int
+	fanout    int
+	messages  int
+	size      int
+	inboxes   []chan *chanMessage
+	consumers int // Consumers of merged instances
+
+	produced  uint64
+	delivered atomic.Uint64
+	checksum  atomic.Uint64
+}
+
+func newChannelsWorkload() (Workload, error) {
+	if *chanProducers < 1 || *chanConsumers < 1 || *chanMessages < 1 || *chanBuffer < 0 {
+		return nil, fmt.Errorf("-chan-producers, -chan-consumers and -chan-messages must be positive and -chan-buffer non-negative")
+	}
+	if *chanFanout < 1 || *chanFanout > *chanConsumers {
+		return nil, fmt.Errorf("-chan-fanout must be between 1 and -chan-consumers (%d), got %d", *chanConsumers, *chanFanout)
+	}
+	if *chanSize < 0 {
+		return nil, fmt.Errorf("-chan-size must not be negative, got %d", *chanSize)
+	}
+	return &channelsWorkload{
+		producers: *chanProducers,
+		fanout:    *chanFanout,
+		messages:  *chanMessages,
+		size:      *chanSize,
+		inboxes:   make([]chan *chanMessage, *chanConsumers),
+	}, nil
+}
+
+func (w *channelsWorkload) Name() string { return "channels" }
+
+// Setup starts the consumers
+func (w *channelsWorkload) Setup() error {
+	for n := range w.inboxes {
+		w.inboxes[n] = make(chan *chanMessage, *chanBuffer)
+		go w.consume(w.inboxes[n])
+	}
+	return nil
+}
+
+// Teardown stops the consumers
+func (w *channelsWorkload) Teardown() {
+	for _, inbox := range w.inboxes {
+		close(inbox)
+	}
+}
+
+// consume reads every message it receives and drops it
+func (w *channelsWorkload) consume(inbox chan *chanMessage) {
+	for msg := range inbox {
+		var sum uint64
+		for _, b := range msg.payload {
+			sum += uint64(b)
+		}
+		w.checksum.Add(sum + uint64(msg.seq))
+		w.delivered.Add(1)
+		msg.done.Done()
+	}
+}
+
+func (w *channelsWorkload) Iterate(i int) {
+	var done sync.WaitGroup
+	done.Add(w.messages * w.fanout)
+	var producers sync.WaitGroup
+	for p := 0; p < w.producers; p++ {
+		producers.Add(1)
+		go func(p int) {
+			defer producers.Done()
+			for m := p; m < w.messages; m += w.producers {
+				msg := &chanMessage{seq: i*w.messages + m, payload: make([]byte, w.size), done: &done}
+				for k := range msg.payload {
+					msg.payload[k] = byte(m + k)
+				}
+				// Consecutive messages go to consecutive groups of consumers
+				for f := 0; f < w.fanout; f++ {
+					w.inboxes[(m*w.fanout+f)%len(w.inboxes)] <- msg
+				}
+			}
+		}(p)
+	}
+	producers.Wait()
+	done.Wait()
+	w.produced += uint64(w.messages)
+	w.Keep(w.checksum.Load())
+}
+
+// ResetStats discards counters accumulated during warmup. Every message of
+// the previous iteration has been consumed, so the consumers are idle.
+func (w *channelsWorkload) ResetStats() {
+	w.produced = 0
+	w.delivered.Store(0)
+}
+
+// Report prints handoff statistics
+func (w *channelsWorkload) Report() {
+	printMetric("Producers", "%d", w.producers)
+	printMetric("Consumers", "%d", len(w.inboxes)+w.consumers)
+	printMetric("Messages Produced", "%d", w.produced)
+	printMetric("Messages Delivered", "%d", w.delivered.Load())
+	printMetric("Bytes Handed Off", "%s", formatBytes(w.produced*uint64(w.size)))
+}
+
+// Merge adds other's producers, consumers and counts to w's
+func (w *channelsWorkload) Merge(other any) {
+	o := other.(*channelsWorkload)
+	w.producers += o.producers
+	w.consumers += len(o.inboxes) + o.consumers
+	w.produced += o.produced
+	w.delivered.Add(o.delivered.Load())
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+)
+
+var (
+	closureCount = flag.Int("closure-count", 10000,
+		"closures workload: callbacks allocated and invoked per iteration")
+	closureCapture = flag.Int("closure-capture", 4,
+		"closures workload: words of state each callback captures by value: 1, 2, 4, 8, 16 or 32")
+	closureLive = flag.Int("closure-live", 50000,
+		"closures workload: most recent callbacks kept registered")
+)
+
+func init() {
+	registerWorkload("closures", newClosuresWorkload)
+}
+
+// callbackState is the heap state a callback captures a pointer to, such as
+// the request or component a handler was registered for
+type callbackState struct {
+	id    uint64
+	calls uint64
+	prev  *callbackState
+}
+
+// apply records a call and returns a value derived from the state
+func (s *callbackState) apply(x uint64) uint64 {
+	s.calls++
+	return x + s.id + s.calls
+}
+
+// callbackCapture is the set of value captures a callback can have
+type callbackCapture interface {
+	[1]uint64 | [2]uint64 | [4]uint64 | [8]uint64 | [16]uint64 | [32]uint64
+}
+
+// newCallback returns a closure capturing state and a words array by value.
+// Go copies captured variables of up to 128 bytes into the closure object
+// itself and moves larger ones to a heap cell of their own, so 32 words
+// cost a second allocation.
+func newCallback[A callbackCapture](state *callbackState, seed uint64) func(uint64) uint64 {
+	var words A
+	for k := 0; k < len(words); k++ {
+		words[k] = seed + uint64(k)
+	}
+	return func(x uint64) uint64 {
+		return state.apply(x ^ words[0] ^ words[len(words)-1])
+	}
+}
+
+// callbackConstructors are the newCallback instantiations by capture size
+var callbackConstructors = map[int]func(*callbackState, uint64) func(uint64) uint64{
+	1:  newCallback[[1]uint64],
+	2:  newCallback[[2]uint64],
+	4:  newCallback[[4]uint64],
+	8:  newCallback[[8]uint64],
+	16: newCallback[[16]uint64],
+	32: newCallback[[32]uint64],
+}
+
+// closuresWorkload works the way callback-heavy frameworks do: every
+// iteration allocates a state object per event and registers a closure
+// over it, dispatches the new callbacks and some long-registered ones, and
+// keeps a window of registrations alive. Each callback is a closure object
+// holding its captured words and a pointer to its state, which chains to
+// the previous state, so the collector traces from the registry through
+// closures to their state.
+type closuresWorkload struct {
+	Sink
+	perIter   int
+	capture   int
+	construct func(*callbackState, uint64) func(uint64) uint64
+	live      []func(uint64) uint64 // Ring of registered callbacks
+	next      int
+	last      *callbackState
+	merged    int // Callbacks registered by merged instances
+
+	created uint64
+	calls   uint64
+}
+
+func newClosuresWorkload() (Workload, error) {
+	if *closureCount < 1 || *closureLive < 1 {
+		return nil, fmt.Errorf("-closure-count and -closure-live must be positive")
+	}
+	construct, ok := callbackConstructors[*closureCapture]
+	if !ok {
+		return nil, fmt.Errorf("-closure-capture must be 1, 2, 4, 8, 16 or 32, got %d", *closureCapture)
+	}
+	return &closuresWorkload{
+		perIter:   *closureCount,
+		capture:   *closureCapture,
+		construct: construct,
+		live:      make([]func(uint64) uint64, *closureLive),
+	}, nil
+}
+
+func (w *closuresWorkload) Name() string { return "closures" }
+
+func (w *closuresWorkload) Iterate(i int) {
+	first := w.next
+	for k := 0; k < w.perIter; k++ {
+		id := uint64(i*w.perIter + k)
+		w.last = &callbackState{id: id, prev: w.last}
+		w.live[w.next] = w.construct(w.last, id)
+		w.next = (w.next + 1) % len(w.live)
+		// Only the latest few states stay chained; older ones are held by
+		// their callbacks alone
+		if k%16 == 0 {
+			w.last.prev = nil
+		}
+	}
+	w.created += uint64(w.perIter)
+
+	// Dispatch this iteration's callbacks, then as many of the oldest
+	// registrations still alive
+	var sum uint64
+	for k := 0; k < min(w.perIter, len(w.live)); k++ {
+		sum += w.live[(first+k)%len(w.live)](sum)
+		if old := w.live[(w.next+k)%len(w.live)]; old != nil {
+			sum += old(sum)
+			w.calls++
+		}
+		w.calls++
+	}
+	w.Keep(sum)
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *closuresWorkload) ResetStats() {
+	w.created, w.calls = 0, 0
+}
+
+// Report prints how many callbacks were created and invoked
+func (w *closuresWorkload) Report() {
+	printMetric("Captured Words", "%d", w.capture)
+	printMetric("Callbacks Created", "%d", w.created)
+	printMetric("Callbacks Invoked", "%d", w.calls)
+	printMetric("Callbacks Registered", "%d", len(w.live)+w.merged)
+}
+
+// Merge adds other's counts and registered callbacks to w's
+func (w *closuresWorkload) Merge(other any) {
+	o := other.(*closuresWorkload)
+	w.created += o.created
+	w.calls += o.calls
+	w.merged += len(o.live) + o.merged
+}
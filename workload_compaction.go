@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+var (
+	compactRecords = flag.Int("compact-records", 200000,
+		"compaction workload: number of records held in the index")
+	compactUpdates = flag.Int("compact-updates", 1000,
+		"compaction workload: record updates per iteration")
+	compactEvery = flag.Int("compact-every", 100,
+		"compaction workload: iterations between compactions")
+)
+
+func init() {
+	registerWorkload("compaction", newCompactionWorkload)
+}
+
+// compactRecord is an indexed record rebuilt on every compaction
+type compactRecord struct {
+	id      int64
+	version int64
+	name    string
+	fields  []int64
+}
+
+// compactionWorkload keeps a large map and slice of records, updates them a
+// little each iteration, and every -compact-every iterations rebuilds both
+// from scratch. Each rebuild drops the whole previous structure at once, a
+// synchronized burst of garbage. After a compaction the workload measures
+// how long iteration latency takes to return to its pre-compaction
+// baseline, i.e. how quickly the collector absorbs the drop.
+type compactionWorkload struct {
+	Sink
+	index   map[int64]*compactRecord
+	ordered []*compactRecord
+	rng     *rand.Rand
+	updates int
+	every   int
+
+	window      []time.Duration // Iteration latencies since the last recovery
+	recovering  bool
+	threshold   time.Duration
+	fastStreak  int
+	compactEnd  time.Time
+	compactTime latencyHistogram
+	recovery    latencyHistogram
+	unrecovered int
+}
+
+// recoveryStreak is the number of consecutive iterations at baseline
+// latency that mark the end of a recovery
+const recoveryStreak = 3
+
+func newCompactionWorkload() (Workload, error) {
+	if *compactRecords < 1 || *compactUpdates < 0 || *compactEvery < 1 {
+		return nil, fmt.Errorf("-compact-records and -compact-every must be positive")
+	}
+
+	w := &compactionWorkload{
+		rng:     rand.New(rand.NewSource(*dataSeed)),
+		updates: *compactUpdates,
+		every:   *compactEvery,
+	}
+	w.ordered = make([]*compactRecord, *compactRecords)
+	for n := range w.ordered {
+		w.ordered[n] = &compactRecord{
+			id:     int64(n),
+			name:   fmt.Sprintf("record-%d", n),
+			fields: make([]int64, 4),
+		}
+	}
+	w.rebuild()
+
+	return w, nil
+}
+
+// rebuild replaces the index and ordered slice with freshly allocated
+// copies, discarding every previous record
+func (w *compactionWorkload) rebuild() {
+	index := make(map[int64]*compactRecord, len(w.ordered))
+	ordered := make([]*compactRecord, len(w.ordered))
+	for n, old := range w.ordered {
+		rec := &compactRecord{
+			id:      old.id,
+			version: old.version,
+			name:    old.name,
+			fields:  append([]int64(nil), old.fields...),
+		}
+		ordered[n] = rec
+		index[rec.id] = rec
+	}
+	w.index = index
+	w.ordered = ordered
+}
+
+func (w *compactionWorkload) Name() string { return "compaction" }
+
+func (w *compactionWorkload) Iterate(i int) {
+	start := time.Now()
+	var rec *compactRecord
+	for n := 0; n < w.updates; n++ {
+		rec = w.index[w.rng.Int63n(int64(len(w.ordered)))]
+		rec.version++
+		rec.fields = append(rec.fields[:0:0], rec.fields...)
+		rec.fields[0] = rec.version
+	}
+	w.Keep(rec)
+	w.observe(time.Since(start))
+
+	if (i+1)%w.every == 0 {
+		w.compact()
+	}
+}
+
+// observe tracks iteration latency against the recovery threshold
+func (w *compactionWorkload) observe(d time.Duration) {
+	if !w.recovering {
+		w.window = append(w.window, d)
+		return
+	}
+	if d > w.threshold {
+		w.fastStreak = 0
+		return
+	}
+	w.fastStreak++
+	if w.fastStreak == recoveryStreak {
+		w.recovery.Record(time.Since(w.compactEnd))
+		w.recovering = false
+	}
+}
+
+// compact rebuilds the structures and starts a recovery measurement
+// against the median latency seen since the previous recovery
+func (w *compactionWorkload) compact() {
+	if w.recovering {
+		// Never got back to baseline before the next compaction
+		w.unrecovered++
+	}
+
+	if len(w.window) > 0 {
+		sortDurations(w.window)
+		w.threshold = durationPercentile(w.window, 50) * 5 / 4
+		w.window = w.window[:0]
+	}
+
+	start := time.Now()
+	w.rebuild()
+	w.compactEnd = time.Now()
+	w.compactTime.Record(w.compactEnd.Sub(start))
+	w.recovering = true
+	w.fastStreak = 0
+}
+
+// ResetStats discards measurements taken during warmup
+func (w *compactionWorkload) ResetStats() {
+	w.compactTime = latencyHistogram{}
+	w.recovery = latencyHistogram{}
+	w.unrecovered = 0
+}
+
+// Report prints compaction and recovery time distributions
+func (w *compactionWorkload) Report() {
+	printMetric("Compactions", "%d", w.compactTime.Count())
+	printMetric("Compaction Time p50", "%s", formatDuration(w.compactTime.Percentile(50)))
+	printMetric("Compaction Time Max", "%s", formatDuration(w.compactTime.Max()))
+	printMetric("Recovery Time p50", "%s", formatDuration(w.recovery.Percentile(50)))
+	printMetric("Recovery Time p99", "%s", formatDuration(w.recovery.Percentile(99)))
+	printMetric("Recovery Time Max", "%s", formatDuration(w.recovery.Max()))
+	printMetric("Unrecovered Before Next Compaction", "%d", w.unrecovered)
+}
+
+// Merge adds other's compactions and recoveries to w's
+func (w *compactionWorkload) Merge(other any) {
+	o := other.(*compactionWorkload)
+	w.compactTime.Merge(&o.compactTime)
+	w.recovery.Merge(&o.recovery)
+	w.unrecovered += o.unrecovered
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	csvRows = flag.Int("csv-rows", 200000,
+		"csv workload: rows in the generated CSV data")
+	csvBatch = flag.Int("csv-batch", 2000,
+		"csv workload: rows parsed per iteration")
+	csvRetain = flag.Int("csv-retain", 10,
+		"csv workload: most recent parsed batches kept alive")
+)
+
+func init() {
+	registerWorkload("csv", newCSVWorkload)
+}
+
+// csvRecord is the typed form of one CSV row
+type csvRecord struct {
+	ID        int64
+	Name      string
+	Email     string
+	City      string
+	Amount    float64
+	Timestamp time.Time
+	Tags      []string
+}
+
+// csvWorkload parses generated CSV data into typed records with string
+// fields, the allocation pattern of data ingestion: a reader producing a
+// []string per row, then conversion into structs that own those strings.
+type csvWorkload struct {
+	Sink
+	chunks   [][]byte // Generated CSV data, csvBatch rows per chunk
+	retained [][]csvRecord
+	next     int
+	parsed   int64
+}
+
+var csvCities = []string{"Berlin", "Lagos", "Lima", "Mumbai", "Osaka", "Seattle", "Sydney", "Toronto"}
+
+func newCSVWorkload() (Workload, error) {
+	if *csvRows < 1 || *csvBatch < 1 || *csvRetain < 1 {
+		return nil, fmt.Errorf("-csv-rows, -csv-batch and -csv-retain must be positive")
+	}
+
+	w := &csvWorkload{retained: make([][]csvRecord, *csvRetain)}
+
+	rng := rand.New(rand.NewSource(*dataSeed))
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	for row := 0; row < *csvRows; row++ {
+		name := fmt.Sprintf("customer %d", rng.Intn(1000000))
+		cw.Write([]string{
+			strconv.Itoa(row),
+			name,
+			strings.ReplaceAll(name, " ", ".") + "@example.com",
+			csvCities[rng.Intn(len(csvCities))],
+			strconv.FormatFloat(rng.Float64()*1000, 'f', 2, 64),
+			base.Add(time.Duration(row) * time.Second).Format(time.RFC3339),
+			"tier-" + strconv.Itoa(rng.Intn(4)) + ";region-" + strconv.Itoa(rng.Intn(16)),
+		})
+		if (row+1)%*csvBatch == 0 || row == *csvRows-1 {
+			cw.Flush()
+			w.chunks = append(w.chunks, bytes.Clone(buf.Bytes()))
+			buf.Reset()
+		}
+	}
+
+	return w, nil
+}
+
+func (w *csvWorkload) Name() string { return "csv" }
+
+func (w *csvWorkload) Iterate(i int) {
+	r := csv.NewReader(bytes.NewReader(w.chunks[i%len(w.chunks)]))
+	var records []csvRecord
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic(fmt.Sprintf("csv workload: %v", err))
+		}
+		records = append(records, parseCSVRecord(fields))
+	}
+
+	w.retained[w.next] = records
+	w.Keep(&w.retained[w.next])
+	w.next = (w.next + 1) % len(w.retained)
+	w.parsed += int64(len(records))
+}
+
+// parseCSVRecord converts one row's fields into a typed record
+func parseCSVRecord(fields []string) csvRecord {
+	id, _ := strconv.ParseInt(fields[0], 10, 64)
+	amount, _ := strconv.ParseFloat(fields[4], 64)
+	ts, _ := time.Parse(time.RFC3339, fields[5])
+	return csvRecord{
+		ID:        id,
+		Name:      fields[1],
+		Email:     fields[2],
+		City:      fields[3],
+		Amount:    amount,
+		Timestamp: ts,
+		Tags:      strings.Split(fields[6], ";"),
+	}
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *csvWorkload) ResetStats() {
+	w.parsed = 0
+}
+
+// Report prints parsing statistics
+func (w *csvWorkload) Report() {
+	printMetric("Rows Parsed", "%d", w.parsed)
+}
+
+// Merge adds other's rows to w's
+func (w *csvWorkload) Merge(other any) {
+	w.parsed += other.(*csvWorkload).parsed
+}
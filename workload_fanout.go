@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+)
+
+var (
+	fanoutProducers = flag.Int("fanout-producers", 4,
+		"fanout workload: number of publishing goroutines")
+	fanoutSubscribers = flag.Int("fanout-subscribers", 64,
+		"fanout workload: number of subscriber goroutines every message is delivered to")
+	fanoutMessages = flag.Int("fanout-messages", 32,
+		"fanout workload: messages published per iteration, across all producers")
+	fanoutSize = flag.Int("fanout-size", 128,
+		"fanout workload: payload size of each message in bytes")
+	fanoutWindow = flag.Int("fanout-window", 256,
+		"fanout workload: most recent messages retained by each subscriber")
+)
+
+func init() {
+	registerWorkload("fanout", newFanoutWorkload)
+}
+
+// fanoutMessage is a published message; every subscriber receives its own copy
+type fanoutMessage struct {
+	topic   string
+	seq     int
+	headers map[string]string
+	payload []byte
+}
+
+// clone returns a deep copy of m, as a broker duplicating a message per
+// subscriber would
+func (m *fanoutMessage) clone() *fanoutMessage {
+	headers := make(map[string]string, len(m.headers))
+	for k, v := range m.headers {
+		headers[k] = v
+	}
+	return &fanoutMessage{
+		topic:   m.topic,
+		seq:     m.seq,
+		headers: headers,
+		payload: append([]byte(nil), m.payload...),
+	}
+}
+
+// fanoutSubscriber retains a sliding window of the messages it received
+type fanoutSubscriber struct {
+	inbox    chan *fanoutMessage
+	window   []*fanoutMessage
+	next     int
+	received int64
+}
+
+// fanoutWorkload publishes messages from several producers and duplicates
+// each one into every subscriber's inbox. Subscribers keep a sliding window
+// of recent messages, so the live heap holds subscribers × window message
+// copies that continuously roll over, the high fan-out duplication pattern
+// of pub/sub brokers.
+type fanoutWorkload struct {
+	Sink
+	producers   int
+	messages    int
+	size        int
+	subscribers []*fanoutSubscriber
+	last        []*fanoutMessage // Each producer's last message of the iteration
+	delivered   sync.WaitGroup
+
+	mergedSubs     int // Subscribers of merged instances
+	mergedReceived int64
+}
+
+func newFanoutWorkload() (Workload, error) {
+	if *fanoutProducers < 1 || *fanoutSubscribers < 1 || *fanoutMessages < 1 || *fanoutWindow < 1 {
+		return nil, fmt.Errorf("-fanout-producers, -fanout-subscribers, -fanout-messages and -fanout-window must be positive")
+	}
+	if *fanoutSize < 0 {
+		return nil, fmt.Errorf("-fanout-size must not be negative, got %d", *fanoutSize)
+	}
+
+	w := &fanoutWorkload{
+		producers:   *fanoutProducers,
+		messages:    *fanoutMessages,
+		size:        *fanoutSize,
+		subscribers: make([]*fanoutSubscriber, *fanoutSubscribers),
+		last:        make([]*fanoutMessage, *fanoutProducers),
+	}
+	for n := range w.subscribers {
+		sub := &fanoutSubscriber{
+			inbox:  make(chan *fanoutMessage, 64),
+			window: make([]*fanoutMessage, *fanoutWindow),
+		}
+		w.subscribers[n] = sub
+		go w.consume(sub)
+	}
+
+	return w, nil
+}
+
+// consume stores every received message in the subscriber's window
+func (w *fanoutWorkload) consume(sub *fanoutSubscriber) {
+	for msg := range sub.inbox {
+		sub.window[sub.next] = msg
+		sub.next = (sub.next + 1) % len(sub.window)
+		sub.received++
+		w.delivered.Done()
+	}
+}
+
+func (w *fanoutWorkload) Name() string { return "fanout" }
+
+func (w *fanoutWorkload) Iterate(i int) {
+	w.delivered.Add(w.messages * len(w.subscribers))
+
+	var published sync.WaitGroup
+	for p := 0; p < w.producers; p++ {
+		published.Add(1)
+		go func(p int) {
+			defer published.Done()
+			for m := p; m < w.messages; m += w.producers {
+				msg := &fanoutMessage{
+					topic:   "events",
+					seq:     i*w.messages + m,
+					headers: map[string]string{"producer": fmt.Sprint(p)},
+					payload: make([]byte, w.size),
+				}
+				for _, sub := range w.subscribers {
+					sub.inbox <- msg.clone()
+				}
+				w.last[p] = msg
+			}
+		}(p)
+	}
+	published.Wait()
+	w.delivered.Wait()
+	w.Keep(w.last[0])
+}
+
+// ResetStats discards counters accumulated during warmup. Every delivery of
+// the previous iteration has completed, so the subscribers are idle.
+func (w *fanoutWorkload) ResetStats() {
+	for _, sub := range w.subscribers {
+		sub.received = 0
+	}
+}
+
+// Report prints delivery statistics
+func (w *fanoutWorkload) Report() {
+	received := w.mergedReceived
+	for _, sub := range w.subscribers {
+		received += sub.received
+	}
+	subs := len(w.subscribers) + w.mergedSubs
+	printMetric("Subscribers", "%d", subs)
+	printMetric("Messages Delivered", "%d", received)
+	printMetric("Retained Message Copies", "%d", subs*len(w.subscribers[0].window))
+}
+
+// Merge adds other's subscribers and deliveries to w's
+func (w *fanoutWorkload) Merge(other any) {
+	o := other.(*fanoutWorkload)
+	w.mergedSubs += len(o.subscribers) + o.mergedSubs
+	w.mergedReceived += o.mergedReceived
+	for _, sub := range o.subscribers {
+		w.mergedReceived += sub.received
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	gochurnGoroutines = flag.Int("gochurn-goroutines", 4000,
+		"gochurn workload: short-lived goroutines spawned per iteration")
+	gochurnLive = flag.Int("gochurn-live", 1000,
+		"gochurn workload: goroutines alive at once; each iteration spawns them in waves of this size")
+	gochurnDepth = flag.Int("gochurn-depth", 8,
+		"gochurn workload: call depth each goroutine reaches; every frame holds heap pointers")
+	gochurnObjects = flag.Int("gochurn-objects", 4,
+		"gochurn workload: small heap objects allocated per frame, reachable only from the goroutine's stack")
+)
+
+func init() {
+	registerWorkload("gochurn", newGochurnWorkload)
+}
+
+// gochurnWorkload spawns goroutines in waves that each build a small heap
+// reachable only from their stacks, wait for the rest of the wave and then
+// exit. Unlike the stacks workload, whose goroutines stay parked for the
+// whole run, stacks here are created and retired continuously, so GC
+// cycles find a changing population of stacks to scan alongside the heap
+// and the runtime keeps allocating and freeing stack memory.
+type gochurnWorkload struct {
+	Sink
+	goroutines int
+	live       int
+	depth      int
+	objects    int
+
+	stackMem  []metrics.Sample
+	spawned   uint64
+	peakStack uint64
+	checksum  atomic.Uint64
+}
+
+func newGochurnWorkload() (Workload, error) {
+	if *gochurnGoroutines < 1 || *gochurnLive < 1 || *gochurnDepth < 1 || *gochurnObjects < 1 {
+		return nil, fmt.Errorf("-gochurn-goroutines, -gochurn-live, -gochurn-depth and -gochurn-objects must be positive")
+	}
+	return &gochurnWorkload{
+		goroutines: *gochurnGoroutines,
+		live:       min(*gochurnLive, *gochurnGoroutines),
+		depth:      *gochurnDepth,
+		objects:    *gochurnObjects,
+		stackMem:   []metrics.Sample{{Name: "/memory/classes/heap/stacks:bytes"}},
+	}, nil
+}
+
+func (w *gochurnWorkload) Name() string { return "gochurn" }
+
+func (w *gochurnWorkload) Iterate(i int) {
+	for started := 0; started < w.goroutines; started += w.live {
+		wave := min(w.live, w.goroutines-started)
+		release := make(chan struct{})
+		var ready, exited sync.WaitGroup
+		ready.Add(wave)
+		exited.Add(wave)
+		for g := 0; g < wave; g++ {
+			go func(seed uint64) {
+				defer exited.Done()
+				w.checksum.Add(w.descend(w.depth, seed, nil, &ready, release))
+			}(uint64(i*w.goroutines + started + g))
+		}
+
+		// The whole wave is alive and parked here, its stacks at their
+		// deepest
+		ready.Wait()
+		metrics.Read(w.stackMem)
+		if w.stackMem[0].Value.Kind() == metrics.KindUint64 {
+			w.peakStack = max(w.peakStack, w.stackMem[0].Value.Uint64())
+		}
+		close(release)
+		exited.Wait()
+		w.spawned += uint64(wave)
+	}
+	w.Keep(w.checksum.Load())
+}
+
+// descend recurses depth frames deep, each frame allocating its objects and
+// holding the newest in a local, then waits at the bottom for the wave's
+// release. Unwinding, each frame sums its objects, which become garbage once
+// the goroutine exits.
+func (w *gochurnWorkload) descend(depth int, seed uint64, parent *stackObject, ready *sync.WaitGroup, release chan struct{}) uint64 {
+	head := parent
+	for n := 0; n < w.objects; n++ {
+		obj := newStackObject(seed + uint64(n))
+		obj.next = head
+		head = obj
+	}
+
+	var sum uint64
+	if depth > 1 {
+		sum = w.descend(depth-1, seed, head, ready, release)
+	} else {
+		ready.Done()
+		<-release
+	}
+	for obj := head; obj != parent; obj = obj.next {
+		sum += obj.value[0]
+	}
+	return sum
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *gochurnWorkload) ResetStats() {
+	w.spawned, w.peakStack = 0, 0
+}
+
+// Report prints how many goroutines came and went and how much of the last
+// GC cycle's scan work was stacks
+func (w *gochurnWorkload) Report() {
+	printMetric("Goroutines Spawned", "%d", w.spawned)
+	printMetric("Goroutines Alive at Once", "%d", w.live)
+	printMetric("Stack Depth", "%d", w.depth)
+	printMetric("Peak Stack Memory", "%s", formatBytes(w.peakStack))
+	printScanBreakdown()
+}
+
+// Merge adds other's goroutines to w's. Stack memory is process-wide, so
+// the peak is the highest either saw.
+func (w *gochurnWorkload) Merge(other any) {
+	o := other.(*gochurnWorkload)
+	w.spawned += o.spawned
+	w.live += o.live
+	w.peakStack = max(w.peakStack, o.peakStack)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+var (
+	hashBuffers = flag.Int("hash-buffers", 64,
+		"hashing workload: buffers hashed per iteration")
+	hashSize = flag.Int("hash-size", 16*1024,
+		"hashing workload: size of each buffer in bytes")
+	hashReuse = flag.String("hash-reuse", "none",
+		"hashing workload: buffer reuse strategy: none (allocate every buffer), pool (sync.Pool) or single (one buffer reused)")
+)
+
+func init() {
+	registerWorkload("hashing", newHashingWorkload)
+}
+
+// hashingWorkload fills buffers and streams them through SHA-256. It is
+// CPU-heavy and allocates only pointer-free memory, a low-pointer contrast
+// to the pointer-dense workloads; the reuse strategy controls how much of
+// that memory is garbage.
+type hashingWorkload struct {
+	Sink
+	buffers int
+	size    int
+	reuse   string
+	pool    sync.Pool
+	single  []byte
+	h       hash.Hash
+	digest  [sha256.Size]byte
+	hashed  int64
+}
+
+func newHashingWorkload() (Workload, error) {
+	if *hashBuffers < 1 || *hashSize < 1 {
+		return nil, fmt.Errorf("-hash-buffers and -hash-size must be positive")
+	}
+	switch *hashReuse {
+	case "none", "pool", "single":
+	default:
+		return nil, fmt.Errorf("unknown -hash-reuse %q (want none, pool or single)", *hashReuse)
+	}
+
+	w := &hashingWorkload{
+		buffers: *hashBuffers,
+		size:    *hashSize,
+		reuse:   *hashReuse,
+		h:       sha256.New(),
+	}
+	w.pool.New = func() any { return make([]byte, w.size) }
+	w.single = make([]byte, w.size)
+
+	return w, nil
+}
+
+func (w *hashingWorkload) Name() string { return "hashing" }
+
+// AllocationFree reports whether buffers are reused, in which case
+// iterations may not allocate at all
+func (w *hashingWorkload) AllocationFree() bool { return w.reuse != "none" }
+
+// getBuffer returns a buffer according to the reuse strategy
+func (w *hashingWorkload) getBuffer() []byte {
+	switch w.reuse {
+	case "pool":
+		return w.pool.Get().([]byte)
+	case "single":
+		return w.single
+	default:
+		return make([]byte, w.size)
+	}
+}
+
+// putBuffer releases a buffer obtained from getBuffer
+func (w *hashingWorkload) putBuffer(buf []byte) {
+	if w.reuse == "pool" {
+		w.pool.Put(buf)
+	}
+}
+
+func (w *hashingWorkload) Iterate(i int) {
+	w.h.Reset()
+	for n := 0; n < w.buffers; n++ {
+		buf := w.getBuffer()
+		for j := 0; j < len(buf); j += 8 {
+			buf[j] = byte(i + n + j)
+		}
+		w.h.Write(buf)
+		w.putBuffer(buf)
+	}
+	w.h.Sum(w.digest[:0])
+	w.Keep(&w.digest)
+	w.hashed += int64(w.buffers * w.size)
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *hashingWorkload) ResetStats() {
+	w.hashed = 0
+}
+
+// Report prints hashing throughput statistics
+func (w *hashingWorkload) Report() {
+	printMetric("Reuse Strategy", "%s", w.reuse)
+	printMetric("Bytes Hashed", "%s", formatBytes(uint64(w.hashed)))
+	printMetric("Last Digest", "%x", w.digest)
+}
+
+// Merge adds other's bytes to w's
+func (w *hashingWorkload) Merge(other any) {
+	w.hashed += other.(*hashingWorkload).hashed
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/metrics"
+	"time"
+	"unsafe"
+)
+
+var (
+	hugeMB = flag.Int("huge-mb", 256,
+		"huge workload: size of each huge allocation in MB")
+	hugeEvery = flag.Int("huge-every", 50,
+		"huge workload: iterations between huge allocations")
+	hugeRetain = flag.Int("huge-retain", 10,
+		"huge workload: iterations each huge allocation stays alive")
+	hugePointers = flag.Bool("huge-pointers", false,
+		"huge workload: allocate pointer slices, which the GC must scan, instead of byte slices")
+	hugeChurn = flag.Int("huge-churn", 10000,
+		"huge workload: small short-lived objects allocated per iteration")
+)
+
+func init() {
+	registerWorkload("huge", newHugeWorkload)
+}
+
+// hugeTarget is what the pointers in pointer-bearing huge slices point at
+var hugeTarget [8]uint64
+
+// hugeWorkload periodically allocates a multi-hundred-megabyte slice, keeps
+// it for a few iterations and drops it, on top of light small-object churn.
+// Each huge allocation makes the heap goal jump and each drop leaves the
+// goal stranded high, so the report tracks the heap goal as well as how long
+// the huge allocations themselves took.
+type hugeWorkload struct {
+	Sink
+	bytes    int
+	every    int
+	retain   int
+	pointers bool
+	churn    int
+
+	current  any // The live huge allocation, if any
+	since    int // Iteration it was allocated in
+	goal     []metrics.Sample
+	minGoal  uint64
+	maxGoal  uint64
+	maxJump  uint64
+	lastGoal uint64
+	allocs   latencyHistogram
+}
+
+func newHugeWorkload() (Workload, error) {
+	if *hugeMB < 1 || *hugeEvery < 1 || *hugeRetain < 1 || *hugeChurn < 0 {
+		return nil, fmt.Errorf("-huge-mb, -huge-every and -huge-retain must be positive")
+	}
+
+	return &hugeWorkload{
+		bytes:    *hugeMB * MB,
+		every:    *hugeEvery,
+		retain:   *hugeRetain,
+		pointers: *hugePointers,
+		churn:    *hugeChurn,
+		goal:     []metrics.Sample{{Name: "/gc/heap/goal:bytes"}},
+	}, nil
+}
+
+func (w *hugeWorkload) Name() string { return "huge" }
+
+func (w *hugeWorkload) Iterate(i int) {
+	if w.current != nil && i-w.since >= w.retain {
+		w.current = nil
+	}
+	if i%w.every == 0 {
+		start := time.Now()
+		w.current = w.allocate()
+		w.since = i
+		w.allocs.Record(time.Since(start))
+	}
+
+	for n := 0; n < w.churn; n++ {
+		w.Keep(&[8]uint64{uint64(n)})
+	}
+
+	w.sampleGoal()
+}
+
+// allocate makes one huge slice and touches every page of it, so the cost
+// of faulting the memory in is part of the allocation
+func (w *hugeWorkload) allocate() any {
+	const page = 4096
+	if w.pointers {
+		s := make([]*[8]uint64, w.bytes/int(unsafe.Sizeof(&hugeTarget)))
+		for j := 0; j < len(s); j += page / int(unsafe.Sizeof(&hugeTarget)) {
+			s[j] = &hugeTarget
+		}
+		return s
+	}
+	s := make([]byte, w.bytes)
+	for j := 0; j < len(s); j += page {
+		s[j] = 1
+	}
+	return s
+}
+
+// sampleGoal tracks the range of the heap goal and the largest increase in
+// the goal between consecutive iterations
+func (w *hugeWorkload) sampleGoal() {
+	metrics.Read(w.goal)
+	if w.goal[0].Value.Kind() != metrics.KindUint64 {
+		return
+	}
+	goal := w.goal[0].Value.Uint64()
+	if w.minGoal == 0 || goal < w.minGoal {
+		w.minGoal = goal
+	}
+	if goal > w.maxGoal {
+		w.maxGoal = goal
+	}
+	if w.lastGoal > 0 && goal > w.lastGoal && goal-w.lastGoal > w.maxJump {
+		w.maxJump = goal - w.lastGoal
+	}
+	w.lastGoal = goal
+}
+
+// ResetStats discards measurements taken during warmup
+func (w *hugeWorkload) ResetStats() {
+	w.minGoal, w.maxGoal, w.maxJump, w.lastGoal = 0, 0, 0, 0
+	w.allocs = latencyHistogram{}
+}
+
+// Report prints huge allocation and heap goal statistics
+func (w *hugeWorkload) Report() {
+	printMetric("Huge Allocations", "%d x %s", w.allocs.Count(), formatBytes(uint64(w.bytes)))
+	printMetric("Huge Allocation Time p50", "%s", formatDuration(w.allocs.Percentile(50)))
+	printMetric("Huge Allocation Time Max", "%s", formatDuration(w.allocs.Max()))
+	printMetric("Lowest Heap Goal", "%s", formatBytes(w.minGoal))
+	printMetric("Peak Heap Goal", "%s", formatBytes(w.maxGoal))
+	printMetric("Largest Heap Goal Jump", "%s", formatBytes(w.maxJump))
+}
+
+// Merge adds other's allocations to w's. The heap goal is process-wide,
+// so its extremes are the widest either saw.
+func (w *hugeWorkload) Merge(other any) {
+	o := other.(*hugeWorkload)
+	w.allocs.Merge(&o.allocs)
+	if o.minGoal != 0 && (w.minGoal == 0 || o.minGoal < w.minGoal) {
+		w.minGoal = o.minGoal
+	}
+	w.maxGoal = max(w.maxGoal, o.maxGoal)
+	w.maxJump = max(w.maxJump, o.maxJump)
+}
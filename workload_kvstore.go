@@ -0,0 +1,170 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	kvOps = flag.Int("kv-ops", 2000,
+		"kvstore workload: operations per iteration")
+	kvWriteRatio = flag.Float64("kv-write-ratio", 0.3,
+		"kvstore workload: fraction of operations that are writes (0-1)")
+	kvKeys = flag.Int("kv-keys", 100000,
+		"kvstore workload: size of the key space")
+	kvValueSize = flag.Int("kv-value-size", 512,
+		"kvstore workload: size of each value in bytes, at least 1")
+	kvTTL = flag.Duration("kv-ttl", 200*time.Millisecond,
+		"kvstore workload: mean time-to-live of written values (jittered by ±50%)")
+	kvSweepInterval = flag.Duration("kv-sweep-interval", 20*time.Millisecond,
+		"kvstore workload: interval between background expiry sweeps")
+)
+
+func init() {
+	registerWorkload("kvstore", newKVStoreWorkload)
+}
+
+// kvEntry is a cached value with its expiry deadline
+type kvEntry struct {
+	key     string
+	value   []byte
+	expires int64 // Unix nanoseconds
+}
+
+// kvStoreWorkload models an in-memory cache with TTL eviction: writes insert
+// values that live for around -kv-ttl, reads look them up, and a background
+// goroutine periodically sweeps out expired entries. The write rate and TTL
+// together set a steady population of medium-lived values.
+type kvStoreWorkload struct {
+	Sink
+	mu      sync.Mutex
+	entries map[string]*kvEntry
+	keys    []string
+	rng     *rand.Rand
+
+	ops        int
+	writeRatio float64
+	valueSize  int
+	ttl        time.Duration
+
+	hits, misses, writes, expired int64
+	merged                        int // Live entries of merged instances
+}
+
+func newKVStoreWorkload() (Workload, error) {
+	if *kvOps < 1 || *kvKeys < 1 {
+		return nil, fmt.Errorf("-kv-ops and -kv-keys must be positive")
+	}
+	if *kvValueSize < 1 {
+		return nil, fmt.Errorf("-kv-value-size must be at least 1, got %d", *kvValueSize)
+	}
+	if *kvWriteRatio < 0 || *kvWriteRatio > 1 {
+		return nil, fmt.Errorf("-kv-write-ratio must be between 0 and 1, got %g", *kvWriteRatio)
+	}
+	if *kvTTL <= 0 || *kvSweepInterval <= 0 {
+		return nil, fmt.Errorf("-kv-ttl and -kv-sweep-interval must be positive")
+	}
+
+	w := &kvStoreWorkload{
+		entries:    make(map[string]*kvEntry),
+		keys:       make([]string, *kvKeys),
+		rng:        rand.New(rand.NewSource(*dataSeed)),
+		ops:        *kvOps,
+		writeRatio: *kvWriteRatio,
+		valueSize:  *kvValueSize,
+		ttl:        *kvTTL,
+	}
+	for k := range w.keys {
+		w.keys[k] = "key-" + strconv.Itoa(k)
+	}
+
+	go w.sweepLoop(*kvSweepInterval)
+
+	return w, nil
+}
+
+// sweepLoop removes expired entries every interval, like a cache's
+// background expiry job
+func (w *kvStoreWorkload) sweepLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		now := time.Now().UnixNano()
+		w.mu.Lock()
+		for key, e := range w.entries {
+			if e.expires <= now {
+				delete(w.entries, key)
+				w.expired++
+			}
+		}
+		w.mu.Unlock()
+	}
+}
+
+func (w *kvStoreWorkload) Name() string { return "kvstore" }
+
+func (w *kvStoreWorkload) Iterate(i int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	var last *kvEntry
+	for n := 0; n < w.ops; n++ {
+		key := w.keys[w.rng.Intn(len(w.keys))]
+		if w.rng.Float64() < w.writeRatio {
+			ttl := int64(w.ttl)/2 + w.rng.Int63n(int64(w.ttl))
+			value := make([]byte, w.valueSize)
+			value[0] = byte(n)
+			last = &kvEntry{key: key, value: value, expires: now + ttl}
+			w.entries[key] = last
+			w.writes++
+			continue
+		}
+		if e, ok := w.entries[key]; ok && e.expires > now {
+			last = e
+			w.hits++
+		} else {
+			w.misses++
+		}
+	}
+	w.Keep(last)
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *kvStoreWorkload) ResetStats() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.hits, w.misses, w.writes, w.expired = 0, 0, 0, 0
+}
+
+// Report prints cache population and hit statistics
+func (w *kvStoreWorkload) Report() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	printMetric("Live Entries", "%d", len(w.entries)+w.merged)
+	printMetric("Writes", "%d", w.writes)
+	printMetric("Expired by Sweeper", "%d", w.expired)
+	if reads := w.hits + w.misses; reads > 0 {
+		printMetric("Hit Ratio", "%.2f%%", float64(w.hits)/float64(reads)*100)
+	}
+}
+
+// Merge adds other's entries and counts to w's
+func (w *kvStoreWorkload) Merge(other any) {
+	o := other.(*kvStoreWorkload)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.merged += len(o.entries) + o.merged
+	w.hits += o.hits
+	w.misses += o.misses
+	w.writes += o.writes
+	w.expired += o.expired
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+)
+
+var (
+	listCount = flag.Int("list-count", 8,
+		"listchurn workload: number of linked lists")
+	listLength = flag.Int("list-length", 100000,
+		"listchurn workload: length at which a list is truncated")
+	listAppend = flag.Int("list-append", 5000,
+		"listchurn workload: nodes appended to one list per iteration")
+	listPayload = flag.Int("list-payload", 16,
+		"listchurn workload: payload bytes in each node: 0, 8, 16, 32, 64, 128 or 256")
+)
+
+func init() {
+	registerWorkload("listchurn", newListChurnWorkload)
+}
+
+// listNode is a singly-linked list node with an inline payload of type P,
+// a byte array of the configured size
+type listNode[P any] struct {
+	next    *listNode[P]
+	payload P
+}
+
+// listChurnWorkload keeps long singly-linked lists that it continuously
+// appends to and, once they exceed the length limit, truncates at a random
+// point, dropping the rest. Nodes are small, separately allocated and linked
+// in allocation order only by chance, so marking is a long chain of
+// dependent pointer loads across non-contiguous objects, the case page-based
+// scanning has to handle without locality to exploit.
+type listChurnWorkload[P any] struct {
+	Sink
+	heads     []*listNode[P]
+	tails     []*listNode[P]
+	lengths   []int
+	maxLength int
+	perIter   int
+	rng       *rand.Rand
+	appended  uint64
+	dropped   uint64
+}
+
+func newListChurnWorkload() (Workload, error) {
+	if *listCount < 1 || *listLength < 1 || *listAppend < 1 {
+		return nil, fmt.Errorf("-list-count, -list-length and -list-append must be positive")
+	}
+	switch *listPayload {
+	case 0:
+		return newListChurn[struct{}](), nil
+	case 8:
+		return newListChurn[[8]byte](), nil
+	case 16:
+		return newListChurn[[16]byte](), nil
+	case 32:
+		return newListChurn[[32]byte](), nil
+	case 64:
+		return newListChurn[[64]byte](), nil
+	case 128:
+		return newListChurn[[128]byte](), nil
+	case 256:
+		return newListChurn[[256]byte](), nil
+	}
+	return nil, fmt.Errorf("-list-payload must be 0, 8, 16, 32, 64, 128 or 256, got %d", *listPayload)
+}
+
+func newListChurn[P any]() *listChurnWorkload[P] {
+	return &listChurnWorkload[P]{
+		heads:     make([]*listNode[P], *listCount),
+		tails:     make([]*listNode[P], *listCount),
+		lengths:   make([]int, *listCount),
+		maxLength: *listLength,
+		perIter:   *listAppend,
+		rng:       rand.New(rand.NewSource(*dataSeed)),
+	}
+}
+
+func (w *listChurnWorkload[P]) Name() string { return "listchurn" }
+
+func (w *listChurnWorkload[P]) Iterate(i int) {
+	l := i % len(w.heads)
+	for n := 0; n < w.perIter; n++ {
+		node := &listNode[P]{}
+		if w.tails[l] == nil {
+			w.heads[l] = node
+		} else {
+			w.tails[l].next = node
+		}
+		w.tails[l] = node
+	}
+	w.lengths[l] += w.perIter
+	w.appended += uint64(w.perIter)
+
+	// Truncate to between half and all of the limit, walking the list to
+	// the cut point
+	if w.lengths[l] > w.maxLength {
+		keep := w.maxLength/2 + w.rng.Intn(w.maxLength/2+1)
+		if keep == 0 {
+			w.heads[l], w.tails[l] = nil, nil
+		} else {
+			cut := w.heads[l]
+			for k := 1; k < keep; k++ {
+				cut = cut.next
+			}
+			cut.next = nil
+			w.tails[l] = cut
+		}
+		w.dropped += uint64(w.lengths[l] - keep)
+		w.lengths[l] = keep
+	}
+	w.Keep(w.tails[l])
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *listChurnWorkload[P]) ResetStats() {
+	w.appended, w.dropped = 0, 0
+}
+
+// Report prints list churn statistics
+func (w *listChurnWorkload[P]) Report() {
+	live := 0
+	for _, n := range w.lengths {
+		live += n
+	}
+	printMetric("Nodes Appended", "%d", w.appended)
+	printMetric("Nodes Dropped", "%d", w.dropped)
+	printMetric("Live Nodes", "%d", live)
+}
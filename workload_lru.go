@@ -0,0 +1,152 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+)
+
+var (
+	lruCapacity = flag.Int("lru-capacity", 50000,
+		"lru workload: entries the cache holds before evicting the least recently used")
+	lruHitRatio = flag.Float64("lru-hit-ratio", 0.8,
+		"lru workload: target fraction of lookups that hit, above 0 and at most 1; sets the key space to capacity / ratio")
+	lruEntrySize = flag.Int("lru-entry-size", 512,
+		"lru workload: size of each cached value in bytes")
+	lruOps = flag.Int("lru-ops", 5000,
+		"lru workload: lookups per iteration; each miss loads and inserts the value")
+)
+
+func init() {
+	registerWorkload("lru", newLRUWorkload)
+}
+
+// lruEntry is a cached value on the cache's recency list
+type lruEntry struct {
+	key        int
+	value      []byte
+	prev, next *lruEntry
+}
+
+// lruWorkload runs lookups against a bounded LRU cache: a map from key to
+// entry plus a doubly linked list in recency order. Keys are drawn
+// uniformly from a key space sized so the cache holds -lru-hit-ratio of it,
+// which is then the steady-state hit ratio. A hit moves the entry to the
+// front of the list; a miss allocates a new value and evicts the least
+// recently used entry. Entries live from their miss until enough other keys
+// have missed to push them out, a mid-lifetime population between
+// transient garbage and a static heap, and every hit rewrites list
+// pointers in old objects.
+type lruWorkload struct {
+	Sink
+	entries    map[int]*lruEntry
+	head, tail *lruEntry // Most and least recently used
+	capacity   int
+	keys       int
+	entrySize  int
+	ops        int
+	rng        *rand.Rand
+
+	lookups, hits, evictions uint64
+	merged                   int // Entries cached by merged instances
+}
+
+func newLRUWorkload() (Workload, error) {
+	if *lruCapacity < 1 || *lruOps < 1 || *lruEntrySize < 0 {
+		return nil, fmt.Errorf("-lru-capacity and -lru-ops must be positive and -lru-entry-size non-negative")
+	}
+	if *lruHitRatio <= 0 || *lruHitRatio > 1 {
+		return nil, fmt.Errorf("-lru-hit-ratio must be above 0 and at most 1, got %g", *lruHitRatio)
+	}
+	return &lruWorkload{
+		entries:   make(map[int]*lruEntry, *lruCapacity),
+		capacity:  *lruCapacity,
+		keys:      int(float64(*lruCapacity) / *lruHitRatio),
+		entrySize: *lruEntrySize,
+		ops:       *lruOps,
+		rng:       rand.New(rand.NewSource(*dataSeed)),
+	}, nil
+}
+
+func (w *lruWorkload) Name() string { return "lru" }
+
+func (w *lruWorkload) Iterate(i int) {
+	for op := 0; op < w.ops; op++ {
+		key := w.rng.Intn(w.keys)
+		w.lookups++
+		e, ok := w.entries[key]
+		if ok {
+			w.hits++
+			w.unlink(e)
+		} else {
+			e = &lruEntry{key: key, value: make([]byte, w.entrySize)}
+			if len(e.value) > 0 {
+				e.value[0] = byte(key)
+			}
+			w.entries[key] = e
+			if len(w.entries) > w.capacity {
+				evicted := w.tail
+				w.unlink(evicted)
+				delete(w.entries, evicted.key)
+				w.evictions++
+			}
+		}
+		w.pushFront(e)
+	}
+	w.Keep(w.head)
+}
+
+// unlink removes e from the recency list
+func (w *lruWorkload) unlink(e *lruEntry) {
+	if e.prev != nil {
+		e.prev.next = e.next
+	} else {
+		w.head = e.next
+	}
+	if e.next != nil {
+		e.next.prev = e.prev
+	} else {
+		w.tail = e.prev
+	}
+	e.prev, e.next = nil, nil
+}
+
+// pushFront makes e the most recently used entry
+func (w *lruWorkload) pushFront(e *lruEntry) {
+	e.next = w.head
+	if w.head != nil {
+		w.head.prev = e
+	}
+	w.head = e
+	if w.tail == nil {
+		w.tail = e
+	}
+}
+
+// ResetStats discards counters accumulated during warmup, when the cache
+// was filling and missed more than in steady state
+func (w *lruWorkload) ResetStats() {
+	w.lookups, w.hits, w.evictions = 0, 0, 0
+}
+
+// Report prints the achieved hit ratio and the cache's size
+func (w *lruWorkload) Report() {
+	entries := len(w.entries) + w.merged
+	printMetric("Cache Entries", "%d", entries)
+	printMetric("Key Space", "%d", w.keys)
+	printMetric("Lookups", "%d", w.lookups)
+	if w.lookups > 0 {
+		printMetric("Hit Ratio", "%.2f%%", float64(w.hits)/float64(w.lookups)*100)
+	}
+	printMetric("Evictions", "%d", w.evictions)
+	printMetric("Cached Bytes", "%s", formatBytes(uint64(entries)*uint64(w.entrySize)))
+}
+
+// Merge adds other's entries and counts to w's
+func (w *lruWorkload) Merge(other any) {
+	o := other.(*lruWorkload)
+	w.merged += len(o.entries) + o.merged
+	w.lookups += o.lookups
+	w.hits += o.hits
+	w.evictions += o.evictions
+}
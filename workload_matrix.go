@@ -0,0 +1,805 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+var (
+	matrixSize = flag.Int("size", 50, "matrix workload: rows and columns of each matrix")
+	keepEvery  = flag.Int("keep-every", 100,
+		"matrix workload: retain the result of every Nth iteration as long-lived heap (0 retains none)")
+	chainOps = flag.Int("chain-ops", 5,
+		"matrix workload: operations chained per iteration, cycling through multiply, add, transpose, scale, add")
+	retainIntermediates = flag.Int("retain-intermediates", 0,
+		"matrix workload: iterations each iteration's intermediate matrices stay live for (0 drops them at the end of the iteration)")
+	elementType = flag.String("element", "float64",
+		"matrix workload: element type: float64, float32, int64, complex128 or struct (three float64s)")
+	multiplyTile = flag.Int("tile", 0,
+		"matrix workload: multiply in cache-blocked tiles of this many rows, columns and terms (0 multiplies a whole row by a whole column)")
+	fusedOps = flag.Bool("fused", false,
+		"matrix workload: evaluate the chain with fused kernels that skip intermediate matrices where they can")
+	valueDistribution = flag.String("values", "uniform",
+		"matrix workload: distribution of element values: uniform (in [0, -value-scale)), normal (mean 0, deviation -value-scale) "+
+			"or sparse (uniform, with -value-zeros of them zero)")
+	valueScale = flag.Float64("value-scale", 1,
+		"matrix workload: scale of element values, the upper bound of uniform values and the deviation of normal ones")
+	valueZeros = flag.Float64("value-zeros", 0.9,
+		"matrix workload: with -values=sparse, fraction of element values that are zero (0-1)")
+	elementPointers = flag.Int("element-pointers", 50,
+		"matrix workload: with -layout=mixed, percentage of each row's elements stored behind pointers, the rest inline (0-100)")
+)
+
+func init() {
+	registerWorkload("matrix", func() (Workload, error) {
+		if *matrixSize < 1 || *keepEvery < 0 {
+			return nil, fmt.Errorf("-size must be positive and -keep-every non-negative")
+		}
+		if *chainOps < 1 || *retainIntermediates < 0 {
+			return nil, fmt.Errorf("-chain-ops must be positive and -retain-intermediates non-negative")
+		}
+		if *multiplyTile < 0 {
+			return nil, fmt.Errorf("-tile must not be negative, got %d", *multiplyTile)
+		}
+		if !slices.Contains(matrixAllocStrategies, *matrixAlloc) {
+			return nil, fmt.Errorf("unknown -alloc %q (want heap, pool, arena or chunked)", *matrixAlloc)
+		}
+		if *elementPointers < 0 || *elementPointers > 100 {
+			return nil, fmt.Errorf("-element-pointers must be between 0 and 100, got %d", *elementPointers)
+		}
+		distribution, ok := matrixValueDistributions[*valueDistribution]
+		if !ok {
+			return nil, fmt.Errorf("unknown -values %q (want uniform, normal or sparse)", *valueDistribution)
+		}
+		if *valueScale <= 0 || *valueZeros < 0 || *valueZeros > 1 {
+			return nil, fmt.Errorf("-value-scale must be positive and -value-zeros between 0 and 1")
+		}
+		matrixValue = distribution
+		newWorkload, ok := matrixWorkloads[*elementType]
+		if !ok {
+			return nil, fmt.Errorf("unknown -element %q (want float64, float32, int64, complex128 or struct)", *elementType)
+		}
+		return newWorkload(), nil
+	})
+}
+
+// matrixWorkloads are the matrix workload's instantiations by element type
+var matrixWorkloads = map[string]func() Workload{
+	"float64":    newMatrixWorkload[elemFloat64],
+	"float32":    newMatrixWorkload[elemFloat32],
+	"int64":      newMatrixWorkload[elemInt64],
+	"complex128": newMatrixWorkload[elemComplex128],
+	"struct":     newMatrixWorkload[elemVector],
+}
+
+// matrixValueDistributions generate element values by -values. Values can
+// change how fast the compute kernels run, through products that underflow
+// to subnormals or runs of zeros, and so the share of an iteration spent in
+// allocation and GC rather than computation.
+var matrixValueDistributions = map[string]func(rng *rand.Rand) float64{
+	"uniform": func(rng *rand.Rand) float64 { return rng.Float64() * *valueScale },
+	"normal":  func(rng *rand.Rand) float64 { return rng.NormFloat64() * *valueScale },
+	"sparse": func(rng *rand.Rand) float64 {
+		if rng.Float64() < *valueZeros {
+			return 0
+		}
+		return rng.Float64() * *valueScale
+	},
+}
+
+// matrixValue generates the value of each new matrix element from the
+// random source of the workload instance creating it
+var matrixValue = func(rng *rand.Rand) float64 { return rng.Float64() }
+
+// newMatrixWorkload returns a matrix workload over elements of type T
+func newMatrixWorkload[T matrixElement[T]]() Workload {
+	return &matrixWorkload[T]{
+		size:          *matrixSize,
+		keepEvery:     *keepEvery,
+		ops:           *chainOps,
+		fused:         *fusedOps,
+		alloc:         *matrixAlloc,
+		allocator:     newMatrixAllocator[T](*matrixAlloc),
+		rng:           rand.New(rand.NewSource(*dataSeed)),
+		chain:         make([]*Matrix[T], 0, *chainOps+2),
+		intermediates: make([][]*Matrix[T], *retainIntermediates),
+	}
+}
+
+// matrixOp is one operation of the expression chain
+type matrixOp int
+
+const (
+	opMultiply matrixOp = iota
+	opAdd
+	opTranspose
+	opScale
+)
+
+// matrixStep is one operation of the expression chain and the matrices it
+// reads, counted back from the latest, 1, when it runs
+type matrixStep struct {
+	op   matrixOp
+	x, y int // y only for binary operations
+}
+
+// matrixChain is the repeating pattern of chain operations. A cycle is the
+// original expression over two inputs m1 and m2: m3 = m1·m2, m4 = m1+m2,
+// m5 = m3ᵀ, m6 = 2.5·m4 and m7 = m5+m6. The next cycle takes the last two
+// matrices as its inputs.
+var matrixChain = []matrixStep{
+	{opMultiply, 2, 1},
+	{opAdd, 3, 2},
+	{opTranspose, 2, 0},
+	{opScale, 2, 0},
+	{opAdd, 2, 1},
+}
+
+// matrixWorkload evaluates a chain of matrix operations over two fresh
+// matrices, each operation allocating a new matrix; the default five
+// operations are the original expression (see matrixChain). How
+// long the intermediates live, from the end of the iteration to several
+// iterations later, sets how much of the allocation survives a GC cycle.
+// The element type sets the size of each element's allocation, from 4
+// bytes for float32 to 24 for struct, with the same object count. With
+// -fused, each whole cycle of the chain is evaluated by two kernels with the
+// same result, so the difference from an unfused run is the GC pressure
+// of the intermediate matrices alone.
+type matrixWorkload[T matrixElement[T]] struct {
+	Sink
+	size          int
+	keepEvery     int
+	ops           int
+	fused         bool
+	alloc         string
+	allocator     *matrixAllocator[T] // nil with -alloc=heap
+	rng           *rand.Rand          // Source of the input matrices' values
+	results       []*Matrix[T]
+	chain         []*Matrix[T]   // The iteration's matrices, reused by every iteration
+	previous      *Matrix[T]     // The last iteration's result, released once replaced
+	intermediates [][]*Matrix[T] // Ring of recent iterations' intermediates
+}
+
+func (w *matrixWorkload[T]) Name() string { return "matrix" }
+
+func (w *matrixWorkload[T]) Iterate(i int) {
+	// Create matrices
+	w.allocator.beginIteration()
+	chain := append(w.chain[:0], w.allocator.newMatrix(w.rng, w.size, w.size), w.allocator.newMatrix(w.rng, w.size, w.size))
+
+	// Perform operations (creates many intermediate objects)
+	for op := 0; op < w.ops; op++ {
+		// A whole cycle fuses into two kernels, leaving the last two
+		// matrices the next cycle reads; no operation reads the matrices
+		// skipped
+		if w.fused && op%len(matrixChain) == 0 && op+len(matrixChain) <= w.ops {
+			a, b := chain[len(chain)-2], chain[len(chain)-1]
+			scaled := a.ScaledSum(b, 2.5)
+			chain = append(chain, scaled, a.MulTransposeAdd(b, scaled))
+			op += len(matrixChain) - 1
+			continue
+		}
+		step := matrixChain[op%len(matrixChain)]
+		x := chain[len(chain)-step.x]
+		var m *Matrix[T]
+		switch step.op {
+		case opMultiply:
+			m = x.Multiply(chain[len(chain)-step.y])
+		case opAdd:
+			m = x.Add(chain[len(chain)-step.y])
+		case opTranspose:
+			m = x.Transpose()
+		case opScale:
+			m = x.ScalarMultiply(2.5)
+		}
+		chain = append(chain, m)
+	}
+	result := chain[len(chain)-1]
+	w.Keep(result)
+
+	// Keep this iteration's intermediates live for the next iterations,
+	// releasing those of the iteration they replace
+	if len(w.intermediates) > 0 {
+		slot := &w.intermediates[i%len(w.intermediates)]
+		releaseMatrices(*slot)
+		*slot = append((*slot)[:0], chain[:len(chain)-1]...)
+	} else {
+		releaseMatrices(chain[:len(chain)-1])
+	}
+	// The reused chain mustn't keep this iteration's matrices live
+	clear(chain)
+
+	// Retain some results as long-lived heap; the rest die when replaced
+	w.previous.release()
+	w.previous = result
+	if w.keepEvery > 0 && i%w.keepEvery == 0 {
+		w.results = append(w.results, result)
+		w.previous = nil
+	}
+}
+
+// Element allocation layouts supported by NewMatrix
+const (
+	// LayoutPointers allocates every element as an independent heap object
+	LayoutPointers = "pointers"
+	// LayoutRowBatch allocates each row's values as one []float64 and points
+	// every element into it, so a row costs a single allocation
+	LayoutRowBatch = "rowbatch"
+	// LayoutValues stores each row's elements by value in a slice of its
+	// own, so a row costs a single allocation and holds no pointers
+	LayoutValues = "values"
+	// LayoutMixed stores -element-pointers percent of each row's elements
+	// as independent heap objects behind pointers and the rest inline, for
+	// sweeping GC cost against pointer density
+	LayoutMixed = "mixed"
+	// LayoutFlat stores all elements in one slice, indexed by arithmetic, so
+	// a matrix costs two allocations and no element pointers at all
+	LayoutFlat = "flat"
+)
+
+// layout selects how NewMatrix allocates element storage
+var layout = LayoutPointers
+
+// matrixElement is the constraint on Matrix element types: T with the
+// arithmetic the matrix operations need. Methods called through a type
+// parameter aren't inlined, so multiplication hands each element type a
+// whole dot product rather than calling it per multiply-add.
+type matrixElement[T any] interface {
+	// Add returns the element plus b
+	Add(b T) T
+	// Mul returns the element multiplied by b
+	Mul(b T) T
+	// Scale returns the element multiplied by s
+	Scale(s float64) T
+	// Dot returns the dot product of row and column j of rows
+	Dot(row []*T, rows [][]*T, j int) T
+	// DotValues returns the dot product of row and column j of rows, whose
+	// elements are stored by value
+	DotValues(row []T, rows [][]T, j int) T
+	// DotStrided returns the dot product of row and the column of a flat
+	// matrix that starts at col[0], with elements stride apart
+	DotStrided(row, col []T, stride int) T
+	// FromFloat returns v as an element
+	FromFloat(v float64) T
+}
+
+// Element types of the matrix workload
+type (
+	elemFloat64    float64
+	elemFloat32    float32
+	elemInt64      int64
+	elemComplex128 complex128
+	// elemVector is a struct element of three components, multiplied
+	// componentwise
+	elemVector struct{ X, Y, Z float64 }
+)
+
+// numericElement is the set of element types with arithmetic operators
+type numericElement interface {
+	~float64 | ~float32 | ~int64 | ~complex128
+}
+
+// dot returns the dot product of row and column j of rows
+func dot[T numericElement](row []*T, rows [][]*T, j int) T {
+	var sum T
+	for k, a := range row {
+		sum += *a * *rows[k][j]
+	}
+	return sum
+}
+
+// dotValues returns the dot product of row and column j of rows
+func dotValues[T numericElement](row []T, rows [][]T, j int) T {
+	var sum T
+	for k, a := range row {
+		sum += a * rows[k][j]
+	}
+	return sum
+}
+
+// dotStrided returns the dot product of row and the column starting at
+// col[0] with elements stride apart
+func dotStrided[T numericElement](row, col []T, stride int) T {
+	var sum T
+	for k, a := range row {
+		sum += a * col[k*stride]
+	}
+	return sum
+}
+
+func (a elemFloat64) Add(b elemFloat64) elemFloat64 { return a + b }
+func (a elemFloat64) Mul(b elemFloat64) elemFloat64 { return a * b }
+func (a elemFloat64) Scale(s float64) elemFloat64   { return a * elemFloat64(s) }
+func (elemFloat64) Dot(row []*elemFloat64, rows [][]*elemFloat64, j int) elemFloat64 {
+	return dot(row, rows, j)
+}
+func (elemFloat64) DotValues(row []elemFloat64, rows [][]elemFloat64, j int) elemFloat64 {
+	return dotValues(row, rows, j)
+}
+func (elemFloat64) DotStrided(row, col []elemFloat64, stride int) elemFloat64 {
+	return dotStrided(row, col, stride)
+}
+func (elemFloat64) FromFloat(v float64) elemFloat64 { return elemFloat64(v) }
+
+func (a elemFloat32) Add(b elemFloat32) elemFloat32 { return a + b }
+func (a elemFloat32) Mul(b elemFloat32) elemFloat32 { return a * b }
+func (a elemFloat32) Scale(s float64) elemFloat32   { return a * elemFloat32(s) }
+func (elemFloat32) Dot(row []*elemFloat32, rows [][]*elemFloat32, j int) elemFloat32 {
+	return dot(row, rows, j)
+}
+func (elemFloat32) DotValues(row []elemFloat32, rows [][]elemFloat32, j int) elemFloat32 {
+	return dotValues(row, rows, j)
+}
+func (elemFloat32) DotStrided(row, col []elemFloat32, stride int) elemFloat32 {
+	return dotStrided(row, col, stride)
+}
+func (elemFloat32) FromFloat(v float64) elemFloat32 { return elemFloat32(v) }
+
+func (a elemInt64) Add(b elemInt64) elemInt64 { return a + b }
+func (a elemInt64) Mul(b elemInt64) elemInt64 { return a * b }
+func (a elemInt64) Scale(s float64) elemInt64 { return elemInt64(float64(a) * s) }
+func (elemInt64) Dot(row []*elemInt64, rows [][]*elemInt64, j int) elemInt64 {
+	return dot(row, rows, j)
+}
+func (elemInt64) DotValues(row []elemInt64, rows [][]elemInt64, j int) elemInt64 {
+	return dotValues(row, rows, j)
+}
+func (elemInt64) DotStrided(row, col []elemInt64, stride int) elemInt64 {
+	return dotStrided(row, col, stride)
+}
+func (elemInt64) FromFloat(v float64) elemInt64 { return elemInt64(v * 100) }
+
+func (a elemComplex128) Add(b elemComplex128) elemComplex128 { return a + b }
+func (a elemComplex128) Mul(b elemComplex128) elemComplex128 { return a * b }
+func (a elemComplex128) Scale(s float64) elemComplex128 {
+	return a * elemComplex128(complex(s, 0))
+}
+func (elemComplex128) Dot(row []*elemComplex128, rows [][]*elemComplex128, j int) elemComplex128 {
+	return dot(row, rows, j)
+}
+func (elemComplex128) DotValues(row []elemComplex128, rows [][]elemComplex128, j int) elemComplex128 {
+	return dotValues(row, rows, j)
+}
+func (elemComplex128) DotStrided(row, col []elemComplex128, stride int) elemComplex128 {
+	return dotStrided(row, col, stride)
+}
+func (elemComplex128) FromFloat(v float64) elemComplex128 { return elemComplex128(complex(v, 1-v)) }
+
+func (a elemVector) Add(b elemVector) elemVector {
+	return elemVector{a.X + b.X, a.Y + b.Y, a.Z + b.Z}
+}
+func (a elemVector) Mul(b elemVector) elemVector {
+	return elemVector{a.X * b.X, a.Y * b.Y, a.Z * b.Z}
+}
+func (a elemVector) Scale(s float64) elemVector {
+	return elemVector{a.X * s, a.Y * s, a.Z * s}
+}
+func (elemVector) Dot(row []*elemVector, rows [][]*elemVector, j int) elemVector {
+	var sum elemVector
+	for k, a := range row {
+		b := rows[k][j]
+		sum = elemVector{sum.X + a.X*b.X, sum.Y + a.Y*b.Y, sum.Z + a.Z*b.Z}
+	}
+	return sum
+}
+func (elemVector) DotValues(row []elemVector, rows [][]elemVector, j int) elemVector {
+	var sum elemVector
+	for k, a := range row {
+		b := rows[k][j]
+		sum = elemVector{sum.X + a.X*b.X, sum.Y + a.Y*b.Y, sum.Z + a.Z*b.Z}
+	}
+	return sum
+}
+func (elemVector) DotStrided(row, col []elemVector, stride int) elemVector {
+	var sum elemVector
+	for k, a := range row {
+		b := col[k*stride]
+		sum = elemVector{sum.X + a.X*b.X, sum.Y + a.Y*b.Y, sum.Z + a.Z*b.Z}
+	}
+	return sum
+}
+func (elemVector) FromFloat(v float64) elemVector { return elemVector{v, v / 2, v / 3} }
+
+// Matrix represents a 2D matrix with heap-allocated rows
+type Matrix[T matrixElement[T]] struct {
+	rows   int
+	cols   int
+	data   [][]*T // Slice of slices of pointers - creates lots of heap objects
+	values [][]T  // Rows of elements by value, in place of data for LayoutValues
+	flat   []T    // All elements in row order, in place of data for LayoutFlat
+	mixed  []mixedRow[T]
+	boxed  int                 // Percentage of elements behind pointers in mixed rows
+	alloc  *matrixAllocator[T] // Where the matrix came from, nil for the heap
+	arena  *matrixArena[T]     // With -alloc=arena, the arena holding the matrix
+	rng    *rand.Rand          // Source of its values and of those of matrices derived from it
+}
+
+// mixedRow is a row of a LayoutMixed matrix. Its boxed elements are spread
+// evenly along it: column j is behind a pointer when j*boxed/100 and
+// (j+1)*boxed/100 differ, at ptrs[j*boxed/100], and inline otherwise, at
+// vals[j-j*boxed/100].
+type mixedRow[T any] struct {
+	ptrs []*T
+	vals []T
+}
+
+// at returns element i, j of a mixed matrix
+func (m *Matrix[T]) at(i, j int) T {
+	b := j * m.boxed / 100
+	if (j+1)*m.boxed/100 > b {
+		return *m.mixed[i].ptrs[b]
+	}
+	return m.mixed[i].vals[j-b]
+}
+
+// ref returns a pointer to element i, j in any layout
+func (m *Matrix[T]) ref(i, j int) *T {
+	switch {
+	case m.flat != nil:
+		return &m.flat[i*m.cols+j]
+	case m.values != nil:
+		return &m.values[i][j]
+	case m.mixed != nil:
+		b := j * m.boxed / 100
+		if (j+1)*m.boxed/100 > b {
+			return m.mixed[i].ptrs[b]
+		}
+		return &m.mixed[i].vals[j-b]
+	}
+	return m.data[i][j]
+}
+
+// set sets element i, j of a mixed matrix
+func (m *Matrix[T]) set(i, j int, v T) {
+	b := j * m.boxed / 100
+	if (j+1)*m.boxed/100 > b {
+		*m.mixed[i].ptrs[b] = v
+		return
+	}
+	m.mixed[i].vals[j-b] = v
+}
+
+// NewMatrix creates a new matrix with the given dimensions and values drawn
+// from rng
+func NewMatrix[T matrixElement[T]](rng *rand.Rand, rows, cols int) *Matrix[T] {
+	return newMatrixIn[T](nil, rng, rows, cols)
+}
+
+// newMatrixIn creates a new matrix with the given dimensions and values
+// drawn from rng, with its storage carved from arena a, or allocated from
+// the heap if a is nil
+func newMatrixIn[T matrixElement[T]](a *matrixArena[T], rng *rand.Rand, rows, cols int) *Matrix[T] {
+	m := a.newMatrix()
+	*m = Matrix[T]{
+		rows:  rows,
+		cols:  cols,
+		arena: a,
+		rng:   rng,
+	}
+
+	var zero T
+	f := asFloat64(m)
+	if layout == LayoutFlat {
+		m.flat = a.makeElems(rows * cols)
+		if f != nil {
+			fillFloat64(f.flat, rng)
+			return m
+		}
+		for k := range m.flat {
+			m.flat[k] = zero.FromFloat(matrixValue(rng))
+		}
+		return m
+	}
+	if layout == LayoutMixed {
+		m.boxed = *elementPointers
+		m.mixed = a.makeMixedRows(rows)
+		boxed := cols * m.boxed / 100
+		for i := range m.mixed {
+			row := &m.mixed[i]
+			row.ptrs = a.makePtrs(boxed)
+			for b := range row.ptrs {
+				row.ptrs[b] = a.newElem()
+			}
+			row.vals = a.makeElems(cols - boxed)
+			for j := 0; j < cols; j++ {
+				m.set(i, j, zero.FromFloat(matrixValue(rng)))
+			}
+		}
+		return m
+	}
+	if layout == LayoutValues {
+		m.values = a.makeValueRows(rows)
+		for i := range m.values {
+			m.values[i] = a.makeElems(cols)
+			if f != nil {
+				fillFloat64(f.values[i], rng)
+				continue
+			}
+			for j := range m.values[i] {
+				m.values[i][j] = zero.FromFloat(matrixValue(rng))
+			}
+		}
+		return m
+	}
+	m.data = a.makePtrRows(rows)
+	for i := 0; i < rows; i++ {
+		m.data[i] = a.makePtrs(cols)
+		if layout == LayoutRowBatch {
+			row := a.makeElems(cols)
+			for j := 0; j < cols; j++ {
+				m.data[i][j] = &row[j] // Elements point into the row's backing array
+			}
+		} else {
+			for j := 0; j < cols; j++ {
+				m.data[i][j] = a.newElem() // Each element is a pointer to a T allocated on its own
+			}
+		}
+		if f != nil {
+			fillFloat64Ptrs(f.data[i], rng)
+			continue
+		}
+		for _, val := range m.data[i] {
+			*val = zero.FromFloat(matrixValue(rng))
+		}
+	}
+
+	return m
+}
+
+// Multiply performs matrix multiplication
+func (m *Matrix[T]) Multiply(other *Matrix[T]) *Matrix[T] {
+	if m.cols != other.rows {
+		panic("incompatible dimensions for multiplication")
+	}
+
+	result := m.alloc.newMatrix(m.rng, m.rows, other.cols)
+	if *multiplyTile > 0 {
+		m.multiplyTiled(other, result, *multiplyTile)
+		return result
+	}
+
+	var zero T
+	if m.flat != nil {
+		for i := 0; i < m.rows; i++ {
+			row := m.flat[i*m.cols : (i+1)*m.cols]
+			for j := 0; j < other.cols; j++ {
+				result.flat[i*other.cols+j] = zero.DotStrided(row, other.flat[j:], other.cols)
+			}
+		}
+		return result
+	}
+	if m.mixed != nil {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < other.cols; j++ {
+				var sum T
+				for k := 0; k < m.cols; k++ {
+					sum = sum.Add(m.at(i, k).Mul(other.at(k, j)))
+				}
+				result.set(i, j, sum)
+			}
+		}
+		return result
+	}
+	if m.values != nil {
+		for i, row := range m.values {
+			for j := 0; j < other.cols; j++ {
+				result.values[i][j] = zero.DotValues(row, other.values, j)
+			}
+		}
+		return result
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < other.cols; j++ {
+			*result.data[i][j] = zero.Dot(m.data[i], other.data, j)
+		}
+	}
+
+	return result
+}
+
+// dotRange returns the sum of the terms k0 to k1 of the dot product of row
+// i of m and column j of other
+func (m *Matrix[T]) dotRange(other *Matrix[T], i, j, k0, k1 int) T {
+	var zero T
+	switch {
+	case m.flat != nil:
+		return zero.DotStrided(m.flat[i*m.cols+k0:i*m.cols+k1], other.flat[k0*other.cols+j:], other.cols)
+	case m.values != nil:
+		return zero.DotValues(m.values[i][k0:k1], other.values[k0:k1], j)
+	case m.mixed != nil:
+		var sum T
+		for k := k0; k < k1; k++ {
+			sum = sum.Add(m.at(i, k).Mul(other.at(k, j)))
+		}
+		return sum
+	}
+	return zero.Dot(m.data[i][k0:k1], other.data[k0:k1], j)
+}
+
+// multiplyTiled multiplies m by other into result one tile of rows,
+// columns and terms at a time, so the parts of both operands a tile reads
+// stay in cache while it is computed. Each term tile adds a partial dot
+// product to the result elements of its row and column tile. Tiling
+// changes the order elements are visited in, not what is allocated, so
+// comparing it with untiled runs separates the locality of the algorithm
+// from the locality of the collector.
+func (m *Matrix[T]) multiplyTiled(other, result *Matrix[T], tile int) {
+	for i0 := 0; i0 < m.rows; i0 += tile {
+		i1 := min(i0+tile, m.rows)
+		for k0 := 0; k0 < m.cols; k0 += tile {
+			k1 := min(k0+tile, m.cols)
+			for j0 := 0; j0 < other.cols; j0 += tile {
+				j1 := min(j0+tile, other.cols)
+				for i := i0; i < i1; i++ {
+					for j := j0; j < j1; j++ {
+						partial := m.dotRange(other, i, j, k0, k1)
+						// The first term tile overwrites NewMatrix's random values
+						if e := result.ref(i, j); k0 == 0 {
+							*e = partial
+						} else {
+							*e = (*e).Add(partial)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// ScaledSum returns scalar·(m+other), the add and scale of a cycle of the
+// expression chain, in one pass with no intermediate matrix
+func (m *Matrix[T]) ScaledSum(other *Matrix[T], scalar float64) *Matrix[T] {
+	if m.rows != other.rows || m.cols != other.cols {
+		panic("incompatible dimensions for scaled sum")
+	}
+
+	result := m.alloc.newMatrix(m.rng, m.rows, m.cols)
+	if f := asFloat64(m); f != nil {
+		scaledSumFloat64(f, asFloat64(other), asFloat64(result), scalar)
+		return result
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			*result.ref(i, j) = (*m.ref(i, j)).Add(*other.ref(i, j)).Scale(scalar)
+		}
+	}
+	return result
+}
+
+// MulTransposeAdd returns (m·other)ᵀ + addend, the multiply, transpose and
+// final add of a cycle of the expression chain, in one pass with no
+// intermediate matrices
+func (m *Matrix[T]) MulTransposeAdd(other, addend *Matrix[T]) *Matrix[T] {
+	if m.cols != other.rows || addend.rows != other.cols || addend.cols != m.rows {
+		panic("incompatible dimensions for multiply-transpose-add")
+	}
+
+	result := m.alloc.newMatrix(m.rng, other.cols, m.rows)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < other.cols; j++ {
+			*result.ref(j, i) = m.dotRange(other, i, j, 0, m.cols).Add(*addend.ref(j, i))
+		}
+	}
+	return result
+}
+
+// Add performs matrix addition
+func (m *Matrix[T]) Add(other *Matrix[T]) *Matrix[T] {
+	if m.rows != other.rows || m.cols != other.cols {
+		panic("incompatible dimensions for addition")
+	}
+
+	result := m.alloc.newMatrix(m.rng, m.rows, m.cols)
+	if f := asFloat64(m); f != nil {
+		addFloat64(f, asFloat64(other), asFloat64(result))
+		return result
+	}
+
+	if m.flat != nil {
+		for k, v := range m.flat {
+			result.flat[k] = v.Add(other.flat[k])
+		}
+		return result
+	}
+	if m.mixed != nil {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				result.set(i, j, m.at(i, j).Add(other.at(i, j)))
+			}
+		}
+		return result
+	}
+	if m.values != nil {
+		for i, row := range m.values {
+			for j, v := range row {
+				result.values[i][j] = v.Add(other.values[i][j])
+			}
+		}
+		return result
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			*result.data[i][j] = (*m.data[i][j]).Add(*other.data[i][j])
+		}
+	}
+
+	return result
+}
+
+// Transpose creates a transposed version of the matrix
+func (m *Matrix[T]) Transpose() *Matrix[T] {
+	result := m.alloc.newMatrix(m.rng, m.cols, m.rows)
+
+	if m.flat != nil {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				result.flat[j*m.rows+i] = m.flat[i*m.cols+j]
+			}
+		}
+		return result
+	}
+	if m.mixed != nil {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				result.set(j, i, m.at(i, j))
+			}
+		}
+		return result
+	}
+	if m.values != nil {
+		for i, row := range m.values {
+			for j, v := range row {
+				result.values[j][i] = v
+			}
+		}
+		return result
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			*result.data[j][i] = *m.data[i][j]
+		}
+	}
+
+	return result
+}
+
+// ScalarMultiply multiplies each element by a scalar
+func (m *Matrix[T]) ScalarMultiply(scalar float64) *Matrix[T] {
+	result := m.alloc.newMatrix(m.rng, m.rows, m.cols)
+	if f := asFloat64(m); f != nil {
+		scaleFloat64(f, asFloat64(result), scalar)
+		return result
+	}
+
+	if m.flat != nil {
+		for k, v := range m.flat {
+			result.flat[k] = v.Scale(scalar)
+		}
+		return result
+	}
+	if m.mixed != nil {
+		for i := 0; i < m.rows; i++ {
+			for j := 0; j < m.cols; j++ {
+				result.set(i, j, m.at(i, j).Scale(scalar))
+			}
+		}
+		return result
+	}
+	if m.values != nil {
+		for i, row := range m.values {
+			for j, v := range row {
+				result.values[i][j] = v.Scale(scalar)
+			}
+		}
+		return result
+	}
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			*result.data[i][j] = (*m.data[i][j]).Scale(scalar)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/metrics"
+	"time"
+)
+
+var (
+	noscanSize = flag.Int("noscan-size", 256*1024,
+		"noscan workload: size of each pointer-free buffer in bytes")
+	noscanBuffers = flag.Int("noscan-buffers", 4,
+		"noscan workload: buffers allocated per iteration")
+	noscanKeep = flag.Int("noscan-keep", 2,
+		"noscan workload: buffers per iteration added to the ever-growing live set")
+)
+
+func init() {
+	registerWorkload("noscan", newNoScanWorkload)
+}
+
+// noscanCycle is the state of the heap observed after one GC cycle
+type noscanCycle struct {
+	live      uint64        // Live heap marked by the cycle
+	scannable uint64        // Heap bytes that may contain pointers
+	markCPU   time.Duration // Mark CPU time spent since the previous cycle
+}
+
+// noscanWorkload is a control case: it allocates large pointer-free
+// buffers and keeps a growing share of them alive, so the live heap grows
+// steadily while the part of it the GC has to scan stays tiny. The report
+// checks that mark cost stays flat as the heap grows; if it doesn't, the
+// collector is paying for bytes it never needs to look inside.
+type noscanWorkload struct {
+	Sink
+	size    int
+	buffers int
+	keep    int
+	live    [][]byte
+	merged  int // Live buffers of merged instances
+
+	samples []metrics.Sample
+	cycles  []noscanCycle
+	lastGC  uint64
+	lastCPU time.Duration
+}
+
+func newNoScanWorkload() (Workload, error) {
+	if *noscanSize < 1 || *noscanBuffers < 1 || *noscanKeep < 0 || *noscanKeep > *noscanBuffers {
+		return nil, fmt.Errorf("-noscan-size and -noscan-buffers must be positive and -noscan-keep between 0 and -noscan-buffers")
+	}
+
+	w := &noscanWorkload{
+		size:    *noscanSize,
+		buffers: *noscanBuffers,
+		keep:    *noscanKeep,
+	}
+	w.samples = []metrics.Sample{
+		{Name: "/gc/cycles/total:gc-cycles"},
+		{Name: "/gc/heap/live:bytes"},
+		{Name: "/gc/scan/heap:bytes"},
+	}
+	for _, name := range markCPUMetrics {
+		w.samples = append(w.samples, metrics.Sample{Name: name})
+	}
+
+	return w, nil
+}
+
+func (w *noscanWorkload) Name() string { return "noscan" }
+
+func (w *noscanWorkload) Iterate(i int) {
+	for n := 0; n < w.buffers; n++ {
+		buf := make([]byte, w.size)
+		for j := 0; j < len(buf); j += 4096 {
+			buf[j] = byte(i)
+		}
+		if n < w.keep {
+			w.live = append(w.live, buf)
+		}
+		w.Keep(&buf[0]) // An interior pointer, so keeping it allocates nothing scannable
+	}
+	w.observe()
+}
+
+// observe records a noscanCycle whenever a GC cycle has completed since
+// the previous call
+func (w *noscanWorkload) observe() {
+	gc := w.readSamples()
+	if gc == w.lastGC {
+		return
+	}
+	markCPU := readMarkCPU(w.samples[3:])
+	if w.lastGC != 0 {
+		w.cycles = append(w.cycles, noscanCycle{
+			live:      w.samples[1].Value.Uint64(),
+			scannable: w.samples[2].Value.Uint64(),
+			markCPU:   markCPU - w.lastCPU,
+		})
+	}
+	w.lastGC = gc
+	w.lastCPU = markCPU
+}
+
+// readSamples reads the metrics and returns the completed GC cycle count
+func (w *noscanWorkload) readSamples() uint64 {
+	metrics.Read(w.samples[:3])
+	for _, s := range w.samples[:3] {
+		if s.Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+	}
+	return w.samples[0].Value.Uint64()
+}
+
+// ResetStats discards cycles observed during warmup
+func (w *noscanWorkload) ResetStats() {
+	w.cycles = nil
+	w.lastGC = 0
+}
+
+// Report compares heap growth to scan and mark cost growth between the
+// first and last GC cycles of the run
+func (w *noscanWorkload) Report() {
+	live := len(w.live) + w.merged
+	printMetric("Live Buffers", "%d (%s)", live, formatBytes(uint64(live*w.size)))
+	if len(w.cycles) < 2 {
+		fmt.Println("Scan Flatness: not enough GC cycles observed")
+		return
+	}
+
+	fmt.Printf("%-6s | %-14s | %-14s | %-14s\n", "Cycle", "Live Heap", "Scannable", "Mark CPU")
+	step := max(1, len(w.cycles)/10)
+	for n := 0; n < len(w.cycles); n += step {
+		c := w.cycles[n]
+		fmt.Printf("%-6d | %-14s | %-14s | %-14s\n",
+			n+1, formatBytes(c.live), formatBytes(c.scannable), formatDuration(c.markCPU))
+	}
+
+	first, last := w.cycles[0], w.cycles[len(w.cycles)-1]
+	heapGrowth := float64(last.live) / float64(max(first.live, 1))
+	scanGrowth := float64(last.scannable) / float64(max(first.scannable, 1))
+	cpuGrowth := float64(last.markCPU) / float64(max(first.markCPU, 1))
+	printMetric("Live Heap Growth", "%.2fx", heapGrowth)
+	printMetric("Scannable Heap Growth", "%.2fx", scanGrowth)
+	printMetric("Mark CPU Growth", "%.2fx", cpuGrowth)
+
+	// Mark CPU per cycle is noisy, so only call it out when it tracks the
+	// heap rather than the scannable bytes
+	if heapGrowth >= 2 && cpuGrowth >= heapGrowth/2 {
+		fmt.Println("Scan Flatness: NOT FLAT (mark cost grew with the pointer-free heap)")
+	} else {
+		fmt.Println("Scan Flatness: flat")
+	}
+}
+
+// Merge adds other's live buffers to w's. Every instance samples the same
+// process-wide GC cycles, so w's are kept.
+func (w *noscanWorkload) Merge(other any) {
+	o := other.(*noscanWorkload)
+	w.merged += len(o.live) + o.merged
+}
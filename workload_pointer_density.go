@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"unsafe"
+)
+
+var (
+	pointerDensity = flag.Int("pointer-density", 50,
+		"pointerdensity workload: percentage of each object's fields that are pointers (0-100)")
+	densityFields = flag.Int("density-fields", 16,
+		"pointerdensity workload: number of word-sized fields per object")
+	densityObjects = flag.Int("density-objects", 2000,
+		"pointerdensity workload: objects allocated per iteration")
+	densityLive = flag.Int("density-live", 100000,
+		"pointerdensity workload: approximate number of most recent objects kept alive")
+)
+
+func init() {
+	registerWorkload("pointerdensity", newPointerDensityWorkload)
+}
+
+// pointerDensityWorkload allocates fixed-size objects in which a configurable
+// fraction of the fields are pointers. Pointer fields link to other objects
+// of the same iteration's batch, so the marker has to follow them while
+// dropping a batch frees it entirely; scalar fields only add size. Sweeping
+// the density yields a curve of GC cost versus pointer density for each
+// collector.
+type pointerDensityWorkload struct {
+	Sink
+	typ     reflect.Type
+	ptrOffs []uintptr // Offsets of the pointer fields in typ
+	perIter int
+	live    [][]unsafe.Pointer // Ring of retained per-iteration batches
+	next    int
+	rng     *rand.Rand
+}
+
+func newPointerDensityWorkload() (Workload, error) {
+	if *pointerDensity < 0 || *pointerDensity > 100 {
+		return nil, fmt.Errorf("-pointer-density must be between 0 and 100, got %d", *pointerDensity)
+	}
+	if *densityFields < 1 {
+		return nil, fmt.Errorf("-density-fields must be at least 1, got %d", *densityFields)
+	}
+	if *densityObjects < 1 || *densityLive < 1 {
+		return nil, fmt.Errorf("-density-objects and -density-live must be positive")
+	}
+
+	fields := *densityFields
+	ptrs := (fields**pointerDensity + 50) / 100
+
+	// Spread the pointer fields evenly through the object rather than
+	// clustering them, so the pointer bitmap is representative.
+	w := &pointerDensityWorkload{
+		perIter: *densityObjects,
+		live:    make([][]unsafe.Pointer, max(1, *densityLive / *densityObjects)),
+		rng:     rand.New(rand.NewSource(*dataSeed)),
+	}
+	structFields := make([]reflect.StructField, fields)
+	for f := 0; f < fields; f++ {
+		if (f+1)*ptrs/fields != f*ptrs/fields {
+			structFields[f] = reflect.StructField{
+				Name: fmt.Sprintf("P%d", f),
+				Type: reflect.TypeOf(unsafe.Pointer(nil)),
+			}
+		} else {
+			structFields[f] = reflect.StructField{
+				Name: fmt.Sprintf("S%d", f),
+				Type: reflect.TypeOf(uintptr(0)),
+			}
+		}
+	}
+	w.typ = reflect.StructOf(structFields)
+	// Iterations write the pointer fields at their offsets, so reflection
+	// only allocates the objects
+	for f := range structFields {
+		if sf := w.typ.Field(f); sf.Type.Kind() == reflect.UnsafePointer {
+			w.ptrOffs = append(w.ptrOffs, sf.Offset)
+		}
+	}
+
+	return w, nil
+}
+
+func (w *pointerDensityWorkload) Name() string { return "pointerdensity" }
+
+func (w *pointerDensityWorkload) Iterate(i int) {
+	batch := make([]unsafe.Pointer, w.perIter)
+	for n := range batch {
+		obj := reflect.New(w.typ).UnsafePointer()
+		if n > 0 {
+			for _, off := range w.ptrOffs {
+				*(*unsafe.Pointer)(unsafe.Add(obj, off)) = batch[w.rng.Intn(n)]
+			}
+		}
+		batch[n] = obj
+	}
+	w.Keep(batch[len(batch)-1])
+	w.live[w.next] = batch
+	w.next = (w.next + 1) % len(w.live)
+}
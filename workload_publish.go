@@ -0,0 +1,204 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	publishAllocators = flag.Int("publish-allocators", 4,
+		"publish workload: goroutines allocating and publishing objects")
+	publishRetainers = flag.Int("publish-retainers", 4,
+		"publish workload: goroutines receiving objects and retaining them, each with its own channel")
+	publishObjects = flag.Int("publish-objects", 4000,
+		"publish workload: objects published per iteration, across all allocators")
+	publishRetain = flag.Int("publish-retain", 100000,
+		"publish workload: objects kept live across all retainers; each retainer releases its oldest as new ones arrive")
+	publishChain = flag.Int("publish-chain", 8,
+		"publish workload: each object points at the allocator's previous objects, in chains of this length")
+)
+
+func init() {
+	registerWorkload("publish", newPublishWorkload)
+}
+
+// pubObject is allocated by one goroutine and published to another
+type pubObject struct {
+	seq   int
+	prev  *pubObject // The allocator's previous object, held by another retainer
+	items []*pubItem
+	done  *sync.WaitGroup
+}
+
+// pubItem is a child object published along with its parent
+type pubItem struct {
+	key   int
+	value [4]uint64
+}
+
+// pubRetainer keeps the objects published to it in a ring of its own
+type pubRetainer struct {
+	inbox    chan *pubObject
+	ring     []*pubObject
+	next     int
+	released uint64
+}
+
+// publishWorkload splits the ownership of live objects across goroutines.
+// Allocator goroutines build small object graphs and publish them over
+// channels, the happens-before edge that makes the writes visible, to
+// retainer goroutines, which hold them in rings of their own until newer
+// objects push them out. Each object also points at its allocator's
+// previous object, which went to a different retainer, so the live heap is
+// a web of objects allocated on one P, retained from the roots of another
+// and reachable across retainers. Unlike the channels workload, whose
+// messages die on arrival, objects live on after the handoff, so the marker
+// finds young objects allocated by other Ps rather than each P's own, a
+// different distribution of mark work than thread-local churn.
+type publishWorkload struct {
+	Sink
+	allocators int
+	objects    int
+	chain      int
+	retainers  []*pubRetainer
+	wg         sync.WaitGroup
+
+	published uint64
+	checksum  atomic.Uint64
+
+	mergedRetainers int // Retainers of merged instances and their objects
+	mergedReleased  uint64
+	mergedCapacity  int
+}
+
+func newPublishWorkload() (Workload, error) {
+	if *publishAllocators < 1 || *publishRetainers < 1 || *publishObjects < 1 || *publishChain < 1 {
+		return nil, fmt.Errorf("-publish-allocators, -publish-retainers, -publish-objects and -publish-chain must be positive")
+	}
+	if *publishRetain < *publishRetainers {
+		return nil, fmt.Errorf("-publish-retain must be at least -publish-retainers (%d), got %d", *publishRetainers, *publishRetain)
+	}
+	w := &publishWorkload{
+		allocators: *publishAllocators,
+		objects:    *publishObjects,
+		chain:      *publishChain,
+		retainers:  make([]*pubRetainer, *publishRetainers),
+	}
+	for n := range w.retainers {
+		w.retainers[n] = &pubRetainer{ring: make([]*pubObject, *publishRetain / *publishRetainers)}
+	}
+	return w, nil
+}
+
+func (w *publishWorkload) Name() string { return "publish" }
+
+// Setup starts the retainers
+func (w *publishWorkload) Setup() error {
+	for _, r := range w.retainers {
+		r.inbox = make(chan *pubObject, 128)
+		w.wg.Add(1)
+		go w.retain(r)
+	}
+	return nil
+}
+
+// Teardown stops the retainers and waits for them, so their counts are final
+func (w *publishWorkload) Teardown() {
+	for _, r := range w.retainers {
+		close(r.inbox)
+	}
+	w.wg.Wait()
+}
+
+// retain stores each object published to r, releasing the oldest it holds,
+// and reads the object and its predecessor
+func (w *publishWorkload) retain(r *pubRetainer) {
+	defer w.wg.Done()
+	for obj := range r.inbox {
+		sum := uint64(obj.seq)
+		for _, item := range obj.items {
+			sum += item.value[0]
+		}
+		if obj.prev != nil {
+			sum += uint64(obj.prev.seq)
+		}
+		if r.ring[r.next] != nil {
+			r.released++
+		}
+		r.ring[r.next] = obj
+		r.next = (r.next + 1) % len(r.ring)
+		w.checksum.Add(sum)
+		done := obj.done
+		obj.done = nil
+		done.Done()
+	}
+}
+
+func (w *publishWorkload) Iterate(i int) {
+	var done sync.WaitGroup
+	done.Add(w.objects)
+	var allocators sync.WaitGroup
+	for a := 0; a < w.allocators; a++ {
+		allocators.Add(1)
+		go func(a int) {
+			defer allocators.Done()
+			var prev *pubObject
+			for m, n := a, 0; m < w.objects; m, n = m+w.allocators, n+1 {
+				obj := &pubObject{seq: i*w.objects + m, items: make([]*pubItem, 4), done: &done}
+				for k := range obj.items {
+					obj.items[k] = &pubItem{key: m, value: [4]uint64{uint64(m + k)}}
+				}
+				if n%w.chain != 0 {
+					obj.prev = prev
+				}
+				prev = obj
+				// Round-robin from an offset of its own, so each allocator
+				// publishes to every retainer whatever the counts are
+				w.retainers[(n+a)%len(w.retainers)].inbox <- obj
+			}
+		}(a)
+	}
+	allocators.Wait()
+	done.Wait()
+	w.published += uint64(w.objects)
+	w.Keep(w.checksum.Load())
+}
+
+// ResetStats discards counters accumulated during warmup. Every object of
+// the previous iteration has been retained, so the retainers are idle.
+func (w *publishWorkload) ResetStats() {
+	w.published = 0
+	for _, r := range w.retainers {
+		r.released = 0
+	}
+}
+
+// Report prints how many objects were handed over and released
+func (w *publishWorkload) Report() {
+	released, live := w.mergedReleased, w.mergedCapacity
+	for _, r := range w.retainers {
+		released += r.released
+		live += len(r.ring)
+	}
+	printMetric("Allocators", "%d", w.allocators)
+	printMetric("Retainers", "%d", len(w.retainers)+w.mergedRetainers)
+	printMetric("Objects Published", "%d", w.published)
+	printMetric("Objects Released", "%d", released)
+	printMetric("Retention Capacity", "%d", live)
+}
+
+// Merge adds other's allocators, retainers and counts to w's
+func (w *publishWorkload) Merge(other any) {
+	o := other.(*publishWorkload)
+	w.allocators += o.allocators
+	w.published += o.published
+	w.mergedRetainers += len(o.retainers) + o.mergedRetainers
+	w.mergedReleased += o.mergedReleased
+	w.mergedCapacity += o.mergedCapacity
+	for _, r := range o.retainers {
+		w.mergedReleased += r.released
+		w.mergedCapacity += len(r.ring)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"unsafe"
+)
+
+var (
+	regionAlloc = flag.String("region-alloc", "region",
+		"regions workload: heap allocates every request object individually; region carves them from per-worker chunks released wholesale")
+	regionRequests = flag.Int("region-requests", 50,
+		"regions workload: requests handled per iteration")
+	regionObjects = flag.Int("region-objects", 200,
+		"regions workload: objects each request allocates")
+	regionRelease = flag.Int("region-release", 10,
+		"regions workload: requests whose objects stay live until they are released together")
+	regionChunk = flag.Int("region-chunk", 1024,
+		"regions workload: objects per region chunk")
+)
+
+func init() {
+	registerWorkload("regions", newRegionsWorkload)
+}
+
+// regionNode is a request-scoped object; requests link theirs into a list
+type regionNode struct {
+	next  *regionNode
+	value [7]uint64
+}
+
+// region hands out nodes from chunks, bumping an index, and releases them
+// all at once by resetting the index. Released chunks are reused rather than
+// dropped, so a steady load allocates nothing from the heap once enough
+// chunks exist.
+type region struct {
+	chunks [][]regionNode
+	chunk  int // Chunk being allocated from
+	used   int // Nodes handed out of it
+}
+
+// alloc returns a node from the region, adding a chunk when the existing
+// ones are used up
+func (r *region) alloc(size int) *regionNode {
+	if r.chunk == len(r.chunks) {
+		r.chunks = append(r.chunks, make([]regionNode, size))
+	}
+	n := &r.chunks[r.chunk][r.used]
+	if r.used++; r.used == size {
+		r.chunk, r.used = r.chunk+1, 0
+	}
+	return n
+}
+
+// release frees every node the region has handed out
+func (r *region) release() {
+	r.chunk, r.used = 0, 0
+}
+
+// regionsWorkload models request handling where each request allocates a
+// burst of objects that stay live until a batch of requests completes, then
+// die together. With -region-alloc=heap every object is its own allocation
+// and becomes garbage for the collector to find; with region, each worker's
+// instance carves them from its own chunks and releases the batch
+// wholesale by reusing the chunks, the region allocation that arenas bring
+// to Go. Comparing the two runs' GC counts, CPU and pauses quantifies what
+// the collector is spared. Region nodes are reused without being cleared,
+// so the retained chunks stay scannable live heap.
+type regionsWorkload struct {
+	Sink
+	useRegion bool
+	requests  int
+	objects   int
+	release   int
+	chunkSize int
+	region    region
+	batch     []*regionNode // Lists of the requests since the last release
+
+	handled      uint64
+	heapAllocs   uint64
+	regionAllocs uint64
+	releases     uint64
+	chunks       int // Region chunks of merged instances
+}
+
+func newRegionsWorkload() (Workload, error) {
+	if *regionAlloc != "heap" && *regionAlloc != "region" {
+		return nil, fmt.Errorf("-region-alloc must be heap or region, got %q", *regionAlloc)
+	}
+	if *regionRequests < 1 || *regionObjects < 1 || *regionRelease < 1 || *regionChunk < 1 {
+		return nil, fmt.Errorf("-region-requests, -region-objects, -region-release and -region-chunk must be positive")
+	}
+	return &regionsWorkload{
+		useRegion: *regionAlloc == "region",
+		requests:  *regionRequests,
+		objects:   *regionObjects,
+		release:   *regionRelease,
+		chunkSize: *regionChunk,
+		batch:     make([]*regionNode, 0, *regionRelease),
+	}, nil
+}
+
+func (w *regionsWorkload) Name() string { return "regions" }
+
+func (w *regionsWorkload) Iterate(i int) {
+	for r := 0; r < w.requests; r++ {
+		var list *regionNode
+		for o := 0; o < w.objects; o++ {
+			var n *regionNode
+			if w.useRegion {
+				n = w.region.alloc(w.chunkSize)
+				w.regionAllocs++
+			} else {
+				n = new(regionNode)
+				w.heapAllocs++
+			}
+			n.value[0] = uint64(i*w.requests*w.objects + r*w.objects + o)
+			n.next = list
+			list = n
+		}
+		w.batch = append(w.batch, list)
+		w.Keep(list)
+		w.handled++
+
+		if len(w.batch) == w.release {
+			clear(w.batch)
+			w.batch = w.batch[:0]
+			if w.useRegion {
+				w.region.release()
+			}
+			w.releases++
+		}
+	}
+}
+
+// AllocationFree reports whether objects come from the region, which stops
+// allocating from the heap once it has enough chunks
+func (w *regionsWorkload) AllocationFree() bool { return w.useRegion }
+
+// ResetStats discards counters accumulated during warmup
+func (w *regionsWorkload) ResetStats() {
+	w.handled, w.heapAllocs, w.regionAllocs, w.releases = 0, 0, 0, 0
+}
+
+// Report prints where request objects came from and how much memory the
+// region keeps
+func (w *regionsWorkload) Report() {
+	alloc := "heap"
+	if w.useRegion {
+		alloc = "region"
+	}
+	printMetric("Allocation", "%s", alloc)
+	printMetric("Requests Handled", "%d", w.handled)
+	printMetric("Batch Releases", "%d", w.releases)
+	printMetric("Heap-Allocated Objects", "%d", w.heapAllocs)
+	printMetric("Region-Allocated Objects", "%d", w.regionAllocs)
+	if w.useRegion {
+		chunks := len(w.region.chunks) + w.chunks
+		printMetric("Region Chunks", "%d", chunks)
+		printMetric("Region Size", "%s",
+			formatBytes(uint64(chunks*w.chunkSize)*uint64(unsafe.Sizeof(regionNode{}))))
+	}
+}
+
+// Merge adds other's counts and region chunks to w's
+func (w *regionsWorkload) Merge(other any) {
+	o := other.(*regionsWorkload)
+	w.handled += o.handled
+	w.heapAllocs += o.heapAllocs
+	w.regionAllocs += o.regionAllocs
+	w.releases += o.releases
+	w.chunks += len(o.region.chunks) + o.chunks
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	replayLog = flag.String("replay-log", "",
+		"replay workload: request log to replay, one \"<timestamp> <payload-bytes>\" per line; timestamps are Unix seconds or RFC 3339")
+	replaySpeed = flag.Float64("replay-speed", 0,
+		"replay workload: playback speed relative to the log's timestamps (0 replays as fast as possible)")
+	replayBatch = flag.Int("replay-batch", 100,
+		"replay workload: log events replayed per iteration")
+	replayRetain = flag.Int("replay-retain", 1000,
+		"replay workload: most recent responses kept alive, modeling in-flight and cached requests")
+)
+
+func init() {
+	registerWorkload("replay", newReplayWorkload)
+}
+
+// replayEvent is one request from the log: its offset from the first
+// request and its payload size
+type replayEvent struct {
+	offset time.Duration
+	size   int
+}
+
+// replayResponse is the object built for each replayed request
+type replayResponse struct {
+	headers map[string]string
+	body    []byte
+}
+
+// replayWorkload turns a production request log into allocation events: each
+// logged request allocates a buffer of its payload size and a response
+// derived from it. Optionally pacing by the logged timestamps reproduces the
+// real traffic's burstiness instead of a uniform loop. The log is replayed
+// from the start whenever it is exhausted.
+type replayWorkload struct {
+	Sink
+	events   []replayEvent
+	span     time.Duration // Offset of the last event plus the mean gap
+	batch    int
+	speed    float64
+	cursor   int
+	loops    int
+	start    time.Time
+	retained []*replayResponse
+	next     int
+	bytes    int64
+	replayed int64
+
+	mergedLoops int           // Full passes of merged instances
+	mergedLag   time.Duration // Largest lag of merged instances
+}
+
+func newReplayWorkload() (Workload, error) {
+	if *replayLog == "" {
+		return nil, fmt.Errorf("replay workload needs a request log: -replay-log=path")
+	}
+	if *replayBatch < 1 || *replayRetain < 1 {
+		return nil, fmt.Errorf("-replay-batch and -replay-retain must be positive")
+	}
+	if *replaySpeed < 0 {
+		return nil, fmt.Errorf("-replay-speed must not be negative, got %g", *replaySpeed)
+	}
+
+	events, err := loadReplayLog(*replayLog)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &replayWorkload{
+		events:   events,
+		batch:    *replayBatch,
+		speed:    *replaySpeed,
+		retained: make([]*replayResponse, *replayRetain),
+	}
+	last := events[len(events)-1].offset
+	w.span = last
+	if len(events) > 1 {
+		w.span += last / time.Duration(len(events)-1)
+	}
+
+	return w, nil
+}
+
+// loadReplayLog parses a request log. Blank lines and lines starting with
+// '#' are ignored; events are replayed in file order.
+func loadReplayLog(path string) ([]replayEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []replayEvent
+	var first time.Time
+	scanner := bufio.NewScanner(f)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want \"<timestamp> <payload-bytes>\", got %q", path, lineno, line)
+		}
+		ts, err := parseReplayTimestamp(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineno, err)
+		}
+		size, err := strconv.Atoi(fields[1])
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("%s:%d: invalid payload size %q", path, lineno, fields[1])
+		}
+		if len(events) == 0 {
+			first = ts
+		}
+		offset := ts.Sub(first)
+		if offset < 0 {
+			return nil, fmt.Errorf("%s:%d: timestamp goes backwards", path, lineno)
+		}
+		events = append(events, replayEvent{offset: offset, size: size})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%s: no events", path)
+	}
+
+	return events, nil
+}
+
+// parseReplayTimestamp accepts fractional Unix seconds or RFC 3339
+func parseReplayTimestamp(s string) (time.Time, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return ts, nil
+}
+
+func (w *replayWorkload) Name() string { return "replay" }
+
+func (w *replayWorkload) Iterate(i int) {
+	if w.start.IsZero() {
+		w.start = time.Now()
+	}
+
+	for n := 0; n < w.batch; n++ {
+		ev := w.events[w.cursor]
+		if w.speed > 0 {
+			due := w.start.Add(time.Duration(float64(time.Duration(w.loops)*w.span+ev.offset) / w.speed))
+			if wait := time.Until(due); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		w.Keep(w.handle(ev))
+
+		w.cursor++
+		if w.cursor == len(w.events) {
+			w.cursor = 0
+			w.loops++
+		}
+	}
+}
+
+// handle allocates the request payload and builds a response from it,
+// which it returns
+func (w *replayWorkload) handle(ev replayEvent) *replayResponse {
+	payload := make([]byte, ev.size)
+	for j := 0; j < len(payload); j += 64 {
+		payload[j] = byte(j)
+	}
+	resp := &replayResponse{
+		headers: map[string]string{
+			"Content-Length": strconv.Itoa(ev.size),
+			"Content-Type":   "application/octet-stream",
+		},
+		body: append([]byte(nil), payload[:len(payload)/2]...),
+	}
+
+	w.retained[w.next] = resp
+	w.next = (w.next + 1) % len(w.retained)
+	w.bytes += int64(ev.size)
+	w.replayed++
+	return resp
+}
+
+// ResetStats discards counters from warmup and restarts the pacing clock so
+// the gap between warmup and measurement isn't treated as lag
+func (w *replayWorkload) ResetStats() {
+	w.start = time.Time{}
+	w.cursor = 0
+	w.loops = 0
+	w.bytes = 0
+	w.replayed = 0
+}
+
+// Report prints how much of the log was replayed
+func (w *replayWorkload) Report() {
+	printMetric("Log Events", "%d", len(w.events))
+	printMetric("Events Replayed", "%d (%d full passes)", w.replayed, w.loops+w.mergedLoops)
+	printMetric("Payload Replayed", "%s", formatBytes(uint64(w.bytes)))
+	if w.speed > 0 && !w.start.IsZero() {
+		printMetric("Replay Lag", "%s", formatDuration(max(w.lag(), w.mergedLag)))
+	}
+}
+
+// Merge adds other's counts to w's and keeps the larger lag
+func (w *replayWorkload) Merge(other any) {
+	o := other.(*replayWorkload)
+	w.replayed += o.replayed
+	w.bytes += o.bytes
+	w.mergedLoops += o.loops + o.mergedLoops
+	w.mergedLag = max(w.mergedLag, o.lag(), o.mergedLag)
+}
+
+// lag returns how far the replay is behind the log's timing at its speed
+func (w *replayWorkload) lag() time.Duration {
+	if w.speed <= 0 || w.start.IsZero() {
+		return 0
+	}
+	ideal := time.Duration(float64(time.Duration(w.loops)*w.span+w.events[w.cursor].offset) / w.speed)
+	return time.Since(w.start) - ideal
+}
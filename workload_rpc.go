@@ -0,0 +1,212 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/rpc"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	rpcCalls = flag.Int("rpc-calls", 32,
+		"rpc workload: concurrent calls issued per iteration")
+	rpcConns = flag.Int("rpc-conns", 4,
+		"rpc workload: client connections the calls are spread over")
+	rpcItems = flag.Int("rpc-items", 16,
+		"rpc workload: items per request message")
+	rpcValues = flag.Int("rpc-values", 16,
+		"rpc workload: float values per item")
+)
+
+func init() {
+	registerWorkload("rpc", newRPCWorkload)
+}
+
+// RPCItem is one record in a request or response message
+type RPCItem struct {
+	Name   string
+	Values []float64
+	Tags   map[string]string
+}
+
+// RPCRequest is the message sent by the client
+type RPCRequest struct {
+	ID    int64
+	Items []RPCItem
+}
+
+// RPCResponse is the message returned by the server
+type RPCResponse struct {
+	ID      int64
+	Items   []RPCItem
+	Summary map[string]float64
+}
+
+// RPCService is the server side of the rpc workload
+type RPCService struct{}
+
+// Process builds a response derived from every item of the request, so the
+// server allocates a message of similar shape to the one it decoded
+func (s *RPCService) Process(req *RPCRequest, resp *RPCResponse) error {
+	resp.ID = req.ID
+	resp.Items = make([]RPCItem, len(req.Items))
+	resp.Summary = make(map[string]float64, len(req.Items))
+	for i, item := range req.Items {
+		sum := 0.0
+		values := make([]float64, len(item.Values))
+		for j, v := range item.Values {
+			values[j] = v * 2
+			sum += v
+		}
+		resp.Items[i] = RPCItem{Name: item.Name + "-resp", Values: values, Tags: item.Tags}
+		resp.Summary[item.Name] = sum
+	}
+	return nil
+}
+
+// rpcWorkload runs an RPC server and clients in process, talking over
+// loopback TCP. Every call encodes, decodes and allocates a structured
+// message on both sides, an end-to-end service shape in which GC pauses
+// show up as RPC tail latency.
+//
+// It uses net/rpc with gob encoding rather than gRPC and protobuf so the
+// benchmark keeps building with the standard library alone; the allocation
+// profile (reflection-driven codec, per-message buffers, maps and slices) is
+// comparable.
+type rpcWorkload struct {
+	Sink
+	listener net.Listener
+	clients  []*rpc.Client
+	conns    int
+	calls    int
+	items    int
+	values   int
+	callTime []time.Duration
+	replies  []*RPCResponse // Each call's response in the latest iteration
+	latency  latencyHistogram
+}
+
+func newRPCWorkload() (Workload, error) {
+	if *rpcCalls < 1 || *rpcConns < 1 || *rpcItems < 1 || *rpcValues < 0 {
+		return nil, fmt.Errorf("-rpc-calls, -rpc-conns and -rpc-items must be positive")
+	}
+
+	return &rpcWorkload{
+		conns:    *rpcConns,
+		calls:    *rpcCalls,
+		items:    *rpcItems,
+		values:   *rpcValues,
+		callTime: make([]time.Duration, *rpcCalls),
+		replies:  make([]*RPCResponse, *rpcCalls),
+	}, nil
+}
+
+func (w *rpcWorkload) Name() string { return "rpc" }
+
+// Setup starts the server on a loopback port and connects the clients
+func (w *rpcWorkload) Setup() error {
+	server := rpc.NewServer()
+	if err := server.Register(new(RPCService)); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	// server.Accept logs when the listener closes; Teardown closing it is
+	// the normal way out
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.ServeConn(conn)
+		}
+	}()
+
+	w.listener = listener
+	for c := 0; c < w.conns; c++ {
+		client, err := rpc.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			w.Teardown()
+			return err
+		}
+		w.clients = append(w.clients, client)
+	}
+	return nil
+}
+
+// Teardown closes the clients and stops the server
+func (w *rpcWorkload) Teardown() {
+	for _, c := range w.clients {
+		c.Close()
+	}
+	w.clients = nil
+	w.listener.Close()
+}
+
+// newRequest builds a request message for call id
+func (w *rpcWorkload) newRequest(id int64) *RPCRequest {
+	req := &RPCRequest{ID: id, Items: make([]RPCItem, w.items)}
+	for i := range req.Items {
+		values := make([]float64, w.values)
+		for j := range values {
+			values[j] = float64(i*j) + 0.5
+		}
+		req.Items[i] = RPCItem{
+			Name:   "item-" + strconv.Itoa(i),
+			Values: values,
+			Tags:   map[string]string{"id": strconv.FormatInt(id, 10)},
+		}
+	}
+	return req
+}
+
+func (w *rpcWorkload) Iterate(i int) {
+	var wg sync.WaitGroup
+	for c := 0; c < w.calls; c++ {
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			id := int64(i*w.calls + c)
+			start := time.Now()
+			var resp RPCResponse
+			if err := w.clients[c%len(w.clients)].Call("RPCService.Process", w.newRequest(id), &resp); err != nil {
+				panic(fmt.Sprintf("rpc workload: call failed: %v", err))
+			}
+			w.callTime[c] = time.Since(start)
+			w.replies[c] = &resp
+		}(c)
+	}
+	wg.Wait()
+	w.Keep(w.replies[0])
+
+	for _, d := range w.callTime {
+		w.latency.Record(d)
+	}
+}
+
+// Report prints the RPC latency distribution
+func (w *rpcWorkload) Report() {
+	printMetric("RPC Calls", "%d", w.latency.Count())
+	printMetric("RPC Latency Mean", "%s", formatDuration(w.latency.Mean()))
+	printMetric("RPC Latency p50", "%s", formatDuration(w.latency.Percentile(50)))
+	printMetric("RPC Latency p90", "%s", formatDuration(w.latency.Percentile(90)))
+	printMetric("RPC Latency p99", "%s", formatDuration(w.latency.Percentile(99)))
+	printMetric("RPC Latency p99.9", "%s", formatDuration(w.latency.Percentile(99.9)))
+	printMetric("RPC Latency Max", "%s", formatDuration(w.latency.Max()))
+}
+
+// Merge adds other's calls to w's
+func (w *rpcWorkload) Merge(other any) {
+	w.latency.Merge(&other.(*rpcWorkload).latency)
+}
+
+// ResetStats discards latencies recorded during warmup
+func (w *rpcWorkload) ResetStats() {
+	w.latency = latencyHistogram{}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime/metrics"
+	"unsafe"
+)
+
+var (
+	scanFraction = flag.Int("scan-fraction", 50,
+		"scanratio workload: percentage of live heap bytes that are pointer-bearing (0-100)")
+	scanRatioLiveMB = flag.Int("scanratio-live-mb", 128,
+		"scanratio workload: size of the live heap in MB")
+	scanRatioChurn = flag.Int("scanratio-churn", 16,
+		"scanratio workload: 64 KB chunks of the live heap replaced per iteration")
+)
+
+func init() {
+	registerWorkload("scanratio", newScanRatioWorkload)
+}
+
+// scanRatioChunkBytes is the size of every live heap chunk
+const scanRatioChunkBytes = 64 * 1024
+
+// scanNode is a 64-byte object made entirely of pointers
+type scanNode struct {
+	children [8]*scanNode
+}
+
+// scanRatioWorkload keeps a live heap made of 64 KB chunks, each either a
+// tree of small pointer-dense objects or a single pointer-free buffer, in
+// the proportion set by -scan-fraction. Each iteration replaces a few
+// chunks with new ones of the same kind, so the ratio stays fixed while
+// the GC keeps cycling. Sweeping the fraction gives a curve of GC cost
+// against the scannable share of the heap.
+type scanRatioWorkload struct {
+	Sink
+	chunks  []unsafe.Pointer // Root of each chunk
+	scan    []bool           // Whether each chunk is pointer-bearing
+	churn   int
+	cursor  int
+	samples []metrics.Sample
+
+	mergedChunks, mergedScan int // Chunks of merged instances
+}
+
+func newScanRatioWorkload() (Workload, error) {
+	if *scanFraction < 0 || *scanFraction > 100 {
+		return nil, fmt.Errorf("-scan-fraction must be between 0 and 100, got %d", *scanFraction)
+	}
+	if *scanRatioLiveMB < 1 || *scanRatioChurn < 0 {
+		return nil, fmt.Errorf("-scanratio-live-mb must be positive and -scanratio-churn not negative")
+	}
+
+	n := *scanRatioLiveMB * MB / scanRatioChunkBytes
+	w := &scanRatioWorkload{
+		chunks: make([]unsafe.Pointer, n),
+		scan:   make([]bool, n),
+		churn:  min(*scanRatioChurn, n),
+		samples: []metrics.Sample{
+			{Name: "/gc/heap/live:bytes"},
+			{Name: "/gc/scan/heap:bytes"},
+		},
+	}
+	// Spread the pointer-bearing chunks evenly through the live set
+	scanChunks := (n**scanFraction + 50) / 100
+	for k := range w.chunks {
+		w.scan[k] = (k+1)*scanChunks/n != k*scanChunks/n
+		w.chunks[k] = newScanRatioChunk(w.scan[k])
+	}
+
+	return w, nil
+}
+
+// newScanRatioChunk allocates one chunk: either a complete 8-ary tree of
+// scanNodes totalling 64 KB, or a single 64 KB pointer-free buffer
+func newScanRatioChunk(scan bool) unsafe.Pointer {
+	if !scan {
+		buf := make([]byte, scanRatioChunkBytes)
+		return unsafe.Pointer(&buf[0])
+	}
+
+	const count = scanRatioChunkBytes / int(unsafe.Sizeof(scanNode{}))
+	var nodes [count]*scanNode
+	for j := range nodes {
+		nodes[j] = new(scanNode)
+	}
+	for j, node := range nodes {
+		for c := range node.children {
+			if child := 8*j + c + 1; child < count {
+				node.children[c] = nodes[child]
+			}
+		}
+	}
+	return unsafe.Pointer(nodes[0])
+}
+
+func (w *scanRatioWorkload) Name() string { return "scanratio" }
+
+func (w *scanRatioWorkload) Iterate(i int) {
+	for n := 0; n < w.churn; n++ {
+		w.chunks[w.cursor] = newScanRatioChunk(w.scan[w.cursor])
+		w.Keep(w.chunks[w.cursor])
+		w.cursor = (w.cursor + 1) % len(w.chunks)
+	}
+}
+
+// Report prints the configured and observed scannable fraction
+func (w *scanRatioWorkload) Report() {
+	scanChunks := w.mergedScan
+	for _, s := range w.scan {
+		if s {
+			scanChunks++
+		}
+	}
+	printMetric("Live Chunks", "%d (%d pointer-bearing)", len(w.chunks)+w.mergedChunks, scanChunks)
+
+	metrics.Read(w.samples)
+	if w.samples[0].Value.Kind() != metrics.KindUint64 || w.samples[1].Value.Kind() != metrics.KindUint64 {
+		return
+	}
+	if live := w.samples[0].Value.Uint64(); live > 0 {
+		fmt.Printf("Observed Scannable Fraction: %.2f%%\n",
+			float64(w.samples[1].Value.Uint64())/float64(live)*100)
+	}
+}
+
+// Merge adds other's chunks to w's
+func (w *scanRatioWorkload) Merge(other any) {
+	o := other.(*scanRatioWorkload)
+	w.mergedChunks += len(o.chunks) + o.mergedChunks
+	w.mergedScan += o.mergedScan
+	for _, s := range o.scan {
+		if s {
+			w.mergedScan++
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	serviceRate = flag.Float64("service-rate", 0,
+		"service workload: request arrival rate in requests/sec per worker (0 sends each request as soon as a handler is free)")
+	serviceRequests = flag.Int("service-requests", 200,
+		"service workload: requests arriving per iteration")
+	serviceHandlers = flag.Int("service-handlers", 4,
+		"service workload: goroutines handling requests")
+	serviceItems = flag.Int("service-items", 32,
+		"service workload: items in each request body")
+	serviceDeadline = flag.Duration("service-deadline", 10*time.Millisecond,
+		"service workload: time after arrival by which a response counts as on time")
+)
+
+func init() {
+	registerWorkload("service", newServiceWorkload)
+}
+
+// serviceRequest is the decoded body of a request
+type serviceRequest struct {
+	ID     int               `json:"id"`
+	User   string            `json:"user"`
+	Labels map[string]string `json:"labels"`
+	Items  []serviceItem     `json:"items"`
+}
+
+// serviceItem is one record of a request
+type serviceItem struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// serviceResponse is the encoded answer to a request
+type serviceResponse struct {
+	ID      int                `json:"id"`
+	User    string             `json:"user"`
+	Totals  map[string]float64 `json:"totals"`
+	Largest []string           `json:"largest"`
+}
+
+// serviceContext is the per-request state a server allocates on arrival
+type serviceContext struct {
+	id       int
+	arrival  time.Time // When the request was scheduled to arrive
+	deadline time.Time
+	headers  map[string]string
+	body     []byte
+	done     *sync.WaitGroup
+}
+
+// serviceWorkload models a service handling requests: on arrival each
+// request gets a context, its body is decoded from JSON, a response is
+// computed from it and encoded back to JSON, and everything is released.
+// Requests arrive on a fixed schedule of -service-rate per second and
+// latency is measured from the scheduled arrival to the encoded response,
+// so a GC pause delays not only the requests in flight but the queue
+// building up behind them, and shows up as the tail latency a client sees.
+type serviceWorkload struct {
+	Sink
+	queue    chan *serviceContext
+	requests int
+	rate     float64
+	interval time.Duration
+	next     time.Time
+	bodies   [][]byte           // Encoded request bodies, sent in turn
+	latency  []latencyHistogram // One per handler
+	encoded  []int              // Response bytes, per handler
+	missed   []int              // Responses after the deadline, per handler
+	wg       sync.WaitGroup
+}
+
+func newServiceWorkload() (Workload, error) {
+	if *serviceRequests < 1 || *serviceHandlers < 1 || *serviceItems < 1 {
+		return nil, fmt.Errorf("-service-requests, -service-handlers and -service-items must be positive")
+	}
+	if *serviceRate < 0 {
+		return nil, fmt.Errorf("-service-rate must not be negative, got %g", *serviceRate)
+	}
+	w := &serviceWorkload{
+		requests: *serviceRequests,
+		bodies:   make([][]byte, 16),
+		latency:  make([]latencyHistogram, *serviceHandlers),
+		encoded:  make([]int, *serviceHandlers),
+		missed:   make([]int, *serviceHandlers),
+	}
+	w.SetArrivalRate(*serviceRate)
+	for b := range w.bodies {
+		req := serviceRequest{
+			ID:     b,
+			User:   "user-" + strconv.Itoa(b),
+			Labels: map[string]string{"region": "eu-west", "tier": strconv.Itoa(b % 3)},
+			Items:  make([]serviceItem, *serviceItems),
+		}
+		for k := range req.Items {
+			req.Items[k] = serviceItem{Name: "item-" + strconv.Itoa(k%8), Values: []float64{float64(b), float64(k), 0.5}}
+		}
+		body, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		w.bodies[b] = body
+	}
+	return w, nil
+}
+
+func (w *serviceWorkload) Name() string { return "service" }
+
+// Setup starts the handlers
+func (w *serviceWorkload) Setup() error {
+	w.queue = make(chan *serviceContext, 4*len(w.latency))
+	for n := range w.latency {
+		w.wg.Add(1)
+		go w.handle(n)
+	}
+	return nil
+}
+
+// Teardown stops the handlers
+func (w *serviceWorkload) Teardown() {
+	close(w.queue)
+	w.wg.Wait()
+}
+
+// handle serves requests from the queue, recording each one's latency
+func (w *serviceWorkload) handle(n int) {
+	defer w.wg.Done()
+	for ctx := range w.queue {
+		var req serviceRequest
+		if err := json.Unmarshal(ctx.body, &req); err != nil {
+			panic(err)
+		}
+
+		resp := serviceResponse{ID: ctx.id, User: req.User, Totals: make(map[string]float64)}
+		var largest float64
+		for _, item := range req.Items {
+			for _, v := range item.Values {
+				resp.Totals[item.Name] += v
+			}
+			if t := resp.Totals[item.Name]; t >= largest {
+				largest = t
+				resp.Largest = append(resp.Largest, item.Name)
+			}
+		}
+
+		out, err := json.Marshal(resp)
+		if err != nil {
+			panic(err)
+		}
+		w.encoded[n] += len(out)
+		now := time.Now()
+		if now.After(ctx.deadline) {
+			w.missed[n]++
+		}
+		w.latency[n].Record(now.Sub(ctx.arrival))
+		ctx.done.Done()
+	}
+}
+
+func (w *serviceWorkload) Iterate(i int) {
+	var done sync.WaitGroup
+	done.Add(w.requests)
+
+	if w.next.IsZero() {
+		w.next = time.Now()
+	}
+	for n := 0; n < w.requests; n++ {
+		arrival := time.Now()
+		if w.interval > 0 {
+			if wait := time.Until(w.next); wait > 0 {
+				time.Sleep(wait)
+			}
+			arrival = w.next
+			w.next = w.next.Add(w.interval)
+		}
+
+		id := i*w.requests + n
+		ctx := &serviceContext{
+			id:       id,
+			arrival:  arrival,
+			deadline: arrival.Add(*serviceDeadline),
+			headers:  map[string]string{"request-id": strconv.Itoa(id), "content-type": "application/json"},
+			body:     w.bodies[id%len(w.bodies)],
+			done:     &done,
+		}
+		w.queue <- ctx
+		w.Keep(ctx)
+	}
+
+	done.Wait()
+}
+
+// SetArrivalRate sets the request arrival rate in requests/sec, 0 for
+// unlimited. The arrival schedule restarts at the next ResetStats.
+func (w *serviceWorkload) SetArrivalRate(rate float64) {
+	w.rate, w.interval = rate, 0
+	if rate > 0 {
+		w.interval = time.Duration(float64(time.Second) / rate)
+	}
+}
+
+// RequestLatency returns the latency of the requests served since the last
+// ResetStats, over all handlers
+func (w *serviceWorkload) RequestLatency() *latencyHistogram {
+	merged := new(latencyHistogram)
+	for n := range w.latency {
+		merged.Merge(&w.latency[n])
+	}
+	return merged
+}
+
+// ResetStats discards latencies recorded during warmup. Every request of
+// the previous iteration has been answered, so the handlers are idle. The
+// arrival schedule restarts too, so the pause between warmup and
+// measurement isn't counted as backlog.
+func (w *serviceWorkload) ResetStats() {
+	w.next = time.Time{}
+	for n := range w.latency {
+		w.latency[n] = latencyHistogram{}
+		w.encoded[n], w.missed[n] = 0, 0
+	}
+}
+
+// Report prints the request latency distribution
+func (w *serviceWorkload) Report() {
+	merged := w.RequestLatency()
+	var encoded, missed int
+	for n := range w.latency {
+		encoded += w.encoded[n]
+		missed += w.missed[n]
+	}
+	if w.interval > 0 {
+		printMetric("Arrival Rate", "%g req/s", w.rate)
+	} else {
+		printMetric("Arrival Rate", "unlimited")
+	}
+	printMetric("Requests Served", "%d", merged.Count())
+	printMetric("Response Bytes", "%s", formatBytes(uint64(encoded)))
+	printMetric("Request Latency Mean", "%s", formatDuration(merged.Mean()))
+	printMetric("Request Latency p50", "%s", formatDuration(merged.Percentile(50)))
+	printMetric("Request Latency p90", "%s", formatDuration(merged.Percentile(90)))
+	printMetric("Request Latency p99", "%s", formatDuration(merged.Percentile(99)))
+	printMetric("Request Latency p99.9", "%s", formatDuration(merged.Percentile(99.9)))
+	printMetric("Request Latency Max", "%s", formatDuration(merged.Max()))
+	printMetric("Deadline", "%s", formatDuration(*serviceDeadline))
+	if merged.Count() > 0 {
+		printMetric("Deadline Misses", "%d (%.3f%%)", missed, float64(missed)/float64(merged.Count())*100)
+	}
+}
+
+// Merge adds other's requests to w's handler by handler. Each instance
+// arrives at its own rate, so the rates add up too.
+func (w *serviceWorkload) Merge(other any) {
+	o := other.(*serviceWorkload)
+	w.rate += o.rate
+	for n := range o.latency {
+		w.latency[n].Merge(&o.latency[n])
+		w.encoded[n] += o.encoded[n]
+		w.missed[n] += o.missed[n]
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"unsafe"
+)
+
+var (
+	sizeClassMax = flag.Int("sizeclass-max", 1024,
+		"sizeclass workload: largest runtime size class to allocate from, in bytes")
+	sizeClassAllocs = flag.Int("sizeclass-allocs", 5000,
+		"sizeclass workload: allocations per iteration")
+	sizeClassRetain = flag.Int("sizeclass-retain", 20000,
+		"sizeclass workload: most recent allocations kept alive, leaving spans partially full")
+	sizeClassPattern = flag.String("sizeclass-pattern", "roundrobin",
+		"sizeclass workload: order of size classes: roundrobin, pingpong (smallest/largest alternating) or random")
+	sizeClassNoScan = flag.Bool("sizeclass-noscan", false,
+		"sizeclass workload: allocate pointer-free objects instead of pointer-bearing ones")
+)
+
+func init() {
+	registerWorkload("sizeclass", newSizeClassWorkload)
+}
+
+// runtimeSizeClasses are the small-object size classes of the Go runtime's
+// allocator (runtime/sizeclasses.go), in bytes
+var runtimeSizeClasses = []int{
+	8, 16, 24, 32, 48, 64, 80, 96, 112, 128, 144, 160, 176, 192, 208, 224,
+	240, 256, 288, 320, 352, 384, 416, 448, 480, 512, 576, 640, 704, 768, 896,
+	1024, 1152, 1280, 1408, 1536, 1792, 2048, 2304, 2688, 3072, 3200, 3456,
+	4096, 4864, 5376, 6144, 6528, 6784, 6912, 8192, 9472, 9728, 10240, 10880,
+	12288, 13568, 14336, 16384, 18432, 19072, 20480, 21760, 24576, 27264,
+	28672, 32768,
+}
+
+// sizeClassTarget is what pointer-bearing sizeclass objects point at;
+// linking them to each other instead would chain evicted objects together
+var sizeClassTarget uint64
+
+// sizeClassWorkload interleaves allocations across many adjacent size
+// classes so that consecutive allocations almost never share a span. Every
+// allocation goes to a different mcache span, spans run out at staggered
+// times, and retaining a rolling window leaves them partially full, which
+// stresses the mcache/mcentral refill paths and span turnover.
+type sizeClassWorkload struct {
+	Sink
+	order    []int // Element counts of each allocation, in allocation order
+	noscan   bool
+	allocs   int
+	retained []unsafe.Pointer
+	next     int
+	cursor   int
+}
+
+func newSizeClassWorkload() (Workload, error) {
+	if *sizeClassAllocs < 1 || *sizeClassRetain < 1 {
+		return nil, fmt.Errorf("-sizeclass-allocs and -sizeclass-retain must be positive")
+	}
+
+	var classes []int
+	for _, size := range runtimeSizeClasses {
+		if size <= *sizeClassMax {
+			classes = append(classes, size)
+		}
+	}
+	if len(classes) < 2 {
+		return nil, fmt.Errorf("-sizeclass-max %d covers fewer than two size classes", *sizeClassMax)
+	}
+
+	w := &sizeClassWorkload{
+		noscan:   *sizeClassNoScan,
+		allocs:   *sizeClassAllocs,
+		retained: make([]unsafe.Pointer, *sizeClassRetain),
+	}
+
+	// Precompute one cycle of the pattern so Iterate only allocates
+	switch *sizeClassPattern {
+	case "roundrobin":
+		w.order = classes
+	case "pingpong":
+		for lo, hi := 0, len(classes)-1; lo <= hi; lo, hi = lo+1, hi-1 {
+			w.order = append(w.order, classes[lo])
+			if lo != hi {
+				w.order = append(w.order, classes[hi])
+			}
+		}
+	case "random":
+		rng := rand.New(rand.NewSource(*dataSeed))
+		w.order = make([]int, 4096)
+		for n := range w.order {
+			w.order[n] = classes[rng.Intn(len(classes))]
+		}
+	default:
+		return nil, fmt.Errorf("unknown -sizeclass-pattern %q (want roundrobin, pingpong or random)", *sizeClassPattern)
+	}
+	for n := range w.order {
+		w.order[n] /= 8 // Bytes to pointer-sized elements
+	}
+
+	return w, nil
+}
+
+func (w *sizeClassWorkload) Name() string { return "sizeclass" }
+
+func (w *sizeClassWorkload) Iterate(i int) {
+	for n := 0; n < w.allocs; n++ {
+		elems := w.order[w.cursor]
+		w.cursor = (w.cursor + 1) % len(w.order)
+
+		var p unsafe.Pointer
+		if w.noscan {
+			p = unsafe.Pointer(&make([]uint64, elems)[0])
+		} else {
+			obj := make([]unsafe.Pointer, elems)
+			obj[0] = unsafe.Pointer(&sizeClassTarget) // Give the marker a pointer to follow
+			p = unsafe.Pointer(&obj[0])
+		}
+		w.retained[w.next] = p
+		w.next = (w.next + 1) % len(w.retained)
+		w.Keep(p)
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"slices"
+)
+
+var (
+	sparseFormat = flag.String("sparse-format", "csr",
+		"sparse workload: matrix representation, map (a map of column to value per row) or csr (compressed sparse row arrays)")
+	sparseSize = flag.Int("sparse-size", 1000,
+		"sparse workload: rows and columns of each matrix")
+	sparseDensity = flag.Float64("sparse-density", 0.01,
+		"sparse workload: fraction of entries that are non-zero, above 0 and at most 1")
+	sparseLive = flag.Int("sparse-live", 8,
+		"sparse workload: most recent matrices kept live")
+)
+
+func init() {
+	registerWorkload("sparse", newSparseWorkload)
+}
+
+// sparseMatrix is a square sparse matrix in one of the representations
+type sparseMatrix interface {
+	// MulVec returns the product of the matrix and x
+	MulVec(x []float64) []float64
+	// Add returns the sum of the matrix and other, which has the same
+	// representation
+	Add(other sparseMatrix) sparseMatrix
+	// NonZeros returns the number of stored entries
+	NonZeros() int
+}
+
+// mapSparse stores each row's entries in a map from column to value, the
+// representation that is easiest to build incrementally. Every row is a map
+// of its own, with buckets of keys and values the collector has to find
+// and, as entries are added, outgrows and abandons.
+type mapSparse struct {
+	rows []map[int]float64
+}
+
+func (m *mapSparse) MulVec(x []float64) []float64 {
+	y := make([]float64, len(m.rows))
+	for r, row := range m.rows {
+		for c, v := range row {
+			y[r] += v * x[c]
+		}
+	}
+	return y
+}
+
+func (m *mapSparse) Add(other sparseMatrix) sparseMatrix {
+	o := other.(*mapSparse)
+	sum := &mapSparse{rows: make([]map[int]float64, len(m.rows))}
+	for r := range m.rows {
+		row := make(map[int]float64, len(m.rows[r]))
+		for c, v := range m.rows[r] {
+			row[c] = v
+		}
+		for c, v := range o.rows[r] {
+			row[c] += v
+		}
+		sum.rows[r] = row
+	}
+	return sum
+}
+
+func (m *mapSparse) NonZeros() int {
+	n := 0
+	for _, row := range m.rows {
+		n += len(row)
+	}
+	return n
+}
+
+// csrSparse stores a matrix in compressed sparse row form: the column
+// indices and values of all entries in row order, and where each row
+// starts. Three pointer-free arrays make up the whole matrix, so the
+// collector allocates and scans almost nothing for it however many entries
+// it has.
+type csrSparse struct {
+	rowStart []int // Row r's entries are at rowStart[r]:rowStart[r+1]
+	cols     []int
+	values   []float64
+}
+
+func (m *csrSparse) MulVec(x []float64) []float64 {
+	y := make([]float64, len(m.rowStart)-1)
+	for r := range y {
+		for k := m.rowStart[r]; k < m.rowStart[r+1]; k++ {
+			y[r] += m.values[k] * x[m.cols[k]]
+		}
+	}
+	return y
+}
+
+// Add merges the two matrices' rows, whose columns are in ascending order
+func (m *csrSparse) Add(other sparseMatrix) sparseMatrix {
+	o := other.(*csrSparse)
+	rows := len(m.rowStart) - 1
+	sum := &csrSparse{
+		rowStart: make([]int, rows+1),
+		cols:     make([]int, 0, len(m.cols)+len(o.cols)),
+		values:   make([]float64, 0, len(m.values)+len(o.values)),
+	}
+	for r := 0; r < rows; r++ {
+		a, aEnd := m.rowStart[r], m.rowStart[r+1]
+		b, bEnd := o.rowStart[r], o.rowStart[r+1]
+		for a < aEnd || b < bEnd {
+			switch {
+			case b == bEnd || (a < aEnd && m.cols[a] < o.cols[b]):
+				sum.cols = append(sum.cols, m.cols[a])
+				sum.values = append(sum.values, m.values[a])
+				a++
+			case a == aEnd || o.cols[b] < m.cols[a]:
+				sum.cols = append(sum.cols, o.cols[b])
+				sum.values = append(sum.values, o.values[b])
+				b++
+			default:
+				sum.cols = append(sum.cols, m.cols[a])
+				sum.values = append(sum.values, m.values[a]+o.values[b])
+				a++
+				b++
+			}
+		}
+		sum.rowStart[r+1] = len(sum.cols)
+	}
+	return sum
+}
+
+func (m *csrSparse) NonZeros() int { return len(m.values) }
+
+// sparseWorkload builds a random sparse matrix every iteration, multiplies
+// it by a vector and adds it to the previous iteration's, keeping the
+// latest matrices live and dropping the sum. The same entries cost very different heaps by
+// representation: -sparse-format=map spreads them over a map per row, many
+// pointer-bearing objects of varied sizes, while csr packs them into three
+// flat arrays the collector needn't scan.
+type sparseWorkload struct {
+	Sink
+	format  string
+	size    int
+	perRow  int // Entries drawn per row, before duplicates are merged
+	rng     *rand.Rand
+	x       []float64
+	live    []sparseMatrix // Ring of recent matrices
+	entries uint64
+	built   uint64
+}
+
+func newSparseWorkload() (Workload, error) {
+	if *sparseFormat != "map" && *sparseFormat != "csr" {
+		return nil, fmt.Errorf("-sparse-format must be map or csr, got %q", *sparseFormat)
+	}
+	if *sparseSize < 1 || *sparseLive < 1 {
+		return nil, fmt.Errorf("-sparse-size and -sparse-live must be positive")
+	}
+	if *sparseDensity <= 0 || *sparseDensity > 1 {
+		return nil, fmt.Errorf("-sparse-density must be above 0 and at most 1, got %g", *sparseDensity)
+	}
+	w := &sparseWorkload{
+		format: *sparseFormat,
+		size:   *sparseSize,
+		perRow: max(1, int(float64(*sparseSize)**sparseDensity)),
+		rng:    rand.New(rand.NewSource(*dataSeed)),
+		x:      make([]float64, *sparseSize),
+		live:   make([]sparseMatrix, *sparseLive),
+	}
+	for c := range w.x {
+		w.x[c] = w.rng.Float64()
+	}
+	return w, nil
+}
+
+func (w *sparseWorkload) Name() string { return "sparse" }
+
+// generate returns a random matrix in the workload's representation
+func (w *sparseWorkload) generate() sparseMatrix {
+	cols := make([]int, w.perRow)
+	if w.format == "map" {
+		m := &mapSparse{rows: make([]map[int]float64, w.size)}
+		for r := range m.rows {
+			// Grown entry by entry, as a matrix assembled incrementally is
+			row := map[int]float64{}
+			for range cols {
+				row[w.rng.Intn(w.size)] += w.rng.Float64()
+			}
+			m.rows[r] = row
+		}
+		return m
+	}
+
+	m := &csrSparse{
+		rowStart: make([]int, w.size+1),
+		cols:     make([]int, 0, w.size*w.perRow),
+		values:   make([]float64, 0, w.size*w.perRow),
+	}
+	for r := 0; r < w.size; r++ {
+		for k := range cols {
+			cols[k] = w.rng.Intn(w.size)
+		}
+		slices.Sort(cols)
+		for k, c := range cols {
+			if k > 0 && c == cols[k-1] {
+				m.values[len(m.values)-1] += w.rng.Float64()
+				continue
+			}
+			m.cols = append(m.cols, c)
+			m.values = append(m.values, w.rng.Float64())
+		}
+		m.rowStart[r+1] = len(m.cols)
+	}
+	return m
+}
+
+func (w *sparseWorkload) Iterate(i int) {
+	m := w.generate()
+	y := m.MulVec(w.x)
+	sum := m
+	if prev := w.live[(i+len(w.live)-1)%len(w.live)]; prev != nil {
+		sum = m.Add(prev)
+	}
+	w.live[i%len(w.live)] = m
+	copy(w.x, y)
+	// Keep the vector bounded
+	for c := range w.x {
+		w.x[c] = w.x[c] / (1 + w.x[c])
+	}
+	w.entries += uint64(m.NonZeros())
+	w.built++
+	w.Keep(sum)
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *sparseWorkload) ResetStats() {
+	w.entries, w.built = 0, 0
+}
+
+// Report prints the representation and the matrices' fill
+func (w *sparseWorkload) Report() {
+	printMetric("Format", "%s", w.format)
+	printMetric("Matrices Built", "%d", w.built)
+	if w.built > 0 {
+		nnz := float64(w.entries) / float64(w.built)
+		printMetric("Mean Non-Zeros", "%.0f", nnz)
+		printMetric("Achieved Density", "%.4f%%", nnz/float64(w.size)/float64(w.size)*100)
+	}
+}
+
+// Merge adds other's matrices to w's
+func (w *sparseWorkload) Merge(other any) {
+	o := other.(*sparseWorkload)
+	w.built += o.built
+	w.entries += o.entries
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+)
+
+var (
+	stackGoroutines = flag.Int("stack-goroutines", 20000,
+		"stacks workload: number of parked goroutines")
+	stackDepth = flag.Int("stack-depth", 16,
+		"stacks workload: call depth of each parked goroutine; every frame holds heap pointers")
+	stackChurn = flag.Int("stack-churn", 20000,
+		"stacks workload: short-lived objects allocated per iteration")
+)
+
+func init() {
+	registerWorkload("stacks", newStacksWorkload)
+}
+
+// stackObject is a small heap object referenced from goroutine stacks
+type stackObject struct {
+	next  *stackObject
+	value [3]uint64
+}
+
+// newStackObject is kept out of line so the result always escapes to the
+// heap instead of being allocated on the caller's stack
+//
+//go:noinline
+func newStackObject(v uint64) *stackObject {
+	return &stackObject{value: [3]uint64{v}}
+}
+
+// stacksWorkload parks many goroutines at the bottom of deep call chains
+// whose frames hold heap pointers, then churns a small amount of garbage so
+// GC cycles keep happening. Every cycle has to scan all the parked stacks,
+// exposing the stack-scan share of GC work.
+type stacksWorkload struct {
+	Sink
+	goroutines int
+	depth      int
+	churn      int
+	release    chan struct{}
+}
+
+func newStacksWorkload() (Workload, error) {
+	if *stackGoroutines < 1 || *stackDepth < 1 {
+		return nil, fmt.Errorf("-stack-goroutines and -stack-depth must be positive")
+	}
+	if *stackChurn < 0 {
+		return nil, fmt.Errorf("-stack-churn must not be negative, got %d", *stackChurn)
+	}
+
+	w := &stacksWorkload{
+		goroutines: *stackGoroutines,
+		depth:      *stackDepth,
+		churn:      *stackChurn,
+		release:    make(chan struct{}),
+	}
+
+	var parked sync.WaitGroup
+	parked.Add(w.goroutines)
+	for g := 0; g < w.goroutines; g++ {
+		go w.park(w.depth, nil, &parked)
+	}
+	parked.Wait()
+
+	return w, nil
+}
+
+// park recurses depth frames deep, each frame holding pointers to heap
+// objects, and then blocks until the workload is released
+func (w *stacksWorkload) park(depth int, parent *stackObject, parked *sync.WaitGroup) {
+	var frame [4]*stackObject
+	for i := range frame {
+		frame[i] = newStackObject(uint64(depth))
+		frame[i].next = parent
+	}
+
+	if depth > 1 {
+		w.park(depth-1, frame[0], parked)
+	} else {
+		parked.Done()
+		<-w.release
+	}
+
+	runtime.KeepAlive(frame)
+}
+
+func (w *stacksWorkload) Name() string { return "stacks" }
+
+func (w *stacksWorkload) Iterate(i int) {
+	for n := 0; n < w.churn; n++ {
+		w.Keep(newStackObject(uint64(n)))
+	}
+}
+
+// Report prints how much of the last GC cycle's scan work was stacks
+func (w *stacksWorkload) Report() {
+	printMetric("Parked Goroutines", "%d", w.goroutines)
+	printMetric("Stack Depth", "%d", w.depth)
+	printScanBreakdown()
+}
+
+// Merge adds other's parked goroutines to w's
+func (w *stacksWorkload) Merge(other any) {
+	w.goroutines += other.(*stacksWorkload).goroutines
+}
+
+// printScanBreakdown prints the stack, heap and globals bytes the last GC
+// cycle scanned and the stack share of them
+func printScanBreakdown() {
+	samples := []metrics.Sample{
+		{Name: "/gc/scan/stack:bytes"},
+		{Name: "/gc/scan/heap:bytes"},
+		{Name: "/gc/scan/globals:bytes"},
+	}
+	metrics.Read(samples)
+
+	var scanned [3]uint64
+	for i, s := range samples {
+		if s.Value.Kind() != metrics.KindUint64 {
+			fmt.Println("Scan breakdown: not supported by this Go version")
+			return
+		}
+		scanned[i] = s.Value.Uint64()
+	}
+	total := scanned[0] + scanned[1] + scanned[2]
+	printMetric("Stack Scanned (last cycle)", "%s", formatBytes(scanned[0]))
+	printMetric("Heap Scanned (last cycle)", "%s", formatBytes(scanned[1]))
+	printMetric("Globals Scanned (last cycle)", "%s", formatBytes(scanned[2]))
+	if total > 0 {
+		printMetric("Stack Share of Scan Work", "%.2f%%", float64(scanned[0])/float64(total)*100)
+	}
+}
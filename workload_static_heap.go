@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"unsafe"
+)
+
+var (
+	staticLiveMB = flag.Int("static-live-mb", 256,
+		"staticheap workload: approximate size of the stable object graph in MB")
+	staticChurn = flag.Int("static-churn", 1000,
+		"staticheap workload: short-lived objects allocated per iteration")
+)
+
+func init() {
+	registerWorkload("staticheap", newStaticHeapWorkload)
+}
+
+// graphNode is a node of the static object graph; the payload pads it to a
+// size class typical of small structs
+type graphNode struct {
+	edges   [4]*graphNode
+	payload [4]uint64
+}
+
+// staticHeapWorkload builds one large, randomly linked object graph up front
+// and then allocates only lightly, so GC cycles are dominated by marking the
+// big live set rather than by allocation pressure.
+type staticHeapWorkload struct {
+	Sink
+	nodes []*graphNode
+	churn int
+}
+
+func newStaticHeapWorkload() (Workload, error) {
+	if *staticLiveMB < 1 {
+		return nil, fmt.Errorf("-static-live-mb must be positive, got %d", *staticLiveMB)
+	}
+	if *staticChurn < 0 {
+		return nil, fmt.Errorf("-static-churn must not be negative, got %d", *staticChurn)
+	}
+
+	n := *staticLiveMB * MB / int(unsafe.Sizeof(graphNode{}))
+	w := &staticHeapWorkload{
+		nodes: make([]*graphNode, n),
+		churn: *staticChurn,
+	}
+	for i := range w.nodes {
+		w.nodes[i] = &graphNode{payload: [4]uint64{uint64(i)}}
+	}
+
+	// Random edges give the marker poor locality, like a real object graph
+	rng := rand.New(rand.NewSource(*dataSeed))
+	for _, node := range w.nodes {
+		for e := range node.edges {
+			node.edges[e] = w.nodes[rng.Intn(n)]
+		}
+	}
+
+	return w, nil
+}
+
+func (w *staticHeapWorkload) Name() string { return "staticheap" }
+
+func (w *staticHeapWorkload) Iterate(i int) {
+	for n := 0; n < w.churn; n++ {
+		node := &graphNode{payload: [4]uint64{uint64(n)}}
+		node.edges[0] = w.nodes[(i*w.churn+n)%len(w.nodes)]
+		w.Keep(node)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+var (
+	strMinLen = flag.Int("str-min-len", 8,
+		"strings workload: minimum length of generated strings in bytes")
+	strMaxLen = flag.Int("str-max-len", 256,
+		"strings workload: maximum length of generated strings in bytes")
+	strCount = flag.Int("str-count", 500,
+		"strings workload: strings built per iteration")
+	strLive = flag.Int("str-live", 50000,
+		"strings workload: most recent strings kept alive")
+)
+
+func init() {
+	registerWorkload("strings", newStringsWorkload)
+}
+
+// stringsWorkload builds strings the way string-bound services do: builder
+// appends of words and numbers, concatenation, substrings that pin their
+// parent's bytes, splitting and joining, and a map keyed by string. Strings
+// are pointer-free, but the headers in slices and maps that hold them are
+// not, so the heap is a mix of many small noscan objects referenced from a
+// few pointer-dense ones.
+type stringsWorkload struct {
+	Sink
+	minLen, maxLen int
+	perIter        int
+	live           []string // Ring of recent strings
+	next           int
+	index          map[string]int
+	words          []string
+	rng            *rand.Rand
+	built          uint64
+	bytes          uint64
+	indexed        int // Strings indexed by merged instances
+}
+
+// stringWords is the vocabulary strings are built from
+var stringWords = []string{
+	"alpha", "beta", "gamma", "delta", "user", "order", "item", "status",
+	"pending", "shipped", "GET", "POST", "/api/v1", "id", "region", "eu-west",
+}
+
+func newStringsWorkload() (Workload, error) {
+	if *strMinLen < 1 || *strMaxLen < *strMinLen {
+		return nil, fmt.Errorf("-str-min-len must be positive and at most -str-max-len")
+	}
+	if *strCount < 1 || *strLive < 1 {
+		return nil, fmt.Errorf("-str-count and -str-live must be positive")
+	}
+	return &stringsWorkload{
+		minLen:  *strMinLen,
+		maxLen:  *strMaxLen,
+		perIter: *strCount,
+		live:    make([]string, *strLive),
+		index:   make(map[string]int),
+		words:   stringWords,
+		rng:     rand.New(rand.NewSource(*dataSeed)),
+	}, nil
+}
+
+func (w *stringsWorkload) Name() string { return "strings" }
+
+// build returns a new string of about n bytes of words and numbers
+func (w *stringsWorkload) build(n int) string {
+	var b strings.Builder
+	for b.Len() < n {
+		if b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		if w.rng.Intn(4) == 0 {
+			b.WriteString(strconv.Itoa(w.rng.Intn(1 << 20)))
+		} else {
+			b.WriteString(w.words[w.rng.Intn(len(w.words))])
+		}
+	}
+	return b.String()[:n]
+}
+
+func (w *stringsWorkload) Iterate(i int) {
+	for n := 0; n < w.perIter; n++ {
+		length := w.minLen + w.rng.Intn(w.maxLen-w.minLen+1)
+		var s string
+		switch n % 4 {
+		case 0:
+			s = w.build(length)
+		case 1:
+			// Concatenation copies both halves into a new string
+			s = w.build(length/2+1) + ":" + w.build(length-length/2)
+		case 2:
+			// A substring shares, and keeps alive, its parent's bytes
+			parent := w.build(2 * length)
+			s = parent[w.rng.Intn(length+1):][:length]
+		case 3:
+			// Split and rejoin with a different separator
+			s = strings.Join(strings.Split(w.build(length), "-"), "/")
+		}
+		if old := w.live[w.next]; old != "" {
+			delete(w.index, old)
+		}
+		w.live[w.next] = s
+		w.index[s] = i
+		w.next = (w.next + 1) % len(w.live)
+		w.built++
+		w.bytes += uint64(len(s))
+	}
+	w.Keep(&w.live[w.next])
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *stringsWorkload) ResetStats() {
+	w.built, w.bytes = 0, 0
+}
+
+// Report prints string building statistics
+func (w *stringsWorkload) Report() {
+	printMetric("Strings Built", "%d", w.built)
+	printMetric("String Bytes", "%s", formatBytes(w.bytes))
+	printMetric("Indexed Strings", "%d", len(w.index)+w.indexed)
+}
+
+// Merge adds other's counts and index to w's
+func (w *stringsWorkload) Merge(other any) {
+	o := other.(*stringsWorkload)
+	w.built += o.built
+	w.bytes += o.bytes
+	w.indexed += len(o.index) + o.indexed
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	poolWorkers = flag.Int("pool-workers", 8,
+		"taskqueue workload: size of the worker pool")
+	poolQueue = flag.Int("pool-queue", 1024,
+		"taskqueue workload: capacity of the task queue")
+	poolTasks = flag.Int("pool-tasks", 200,
+		"taskqueue workload: tasks submitted per iteration")
+	poolRate = flag.Float64("pool-rate", 0,
+		"taskqueue workload: task submission rate in tasks/sec (0 submits as fast as the queue accepts)")
+	poolTaskSize = flag.Int("pool-task-size", 256,
+		"taskqueue workload: number of float64 inputs carried by each task")
+)
+
+func init() {
+	registerWorkload("taskqueue", newTaskQueueWorkload)
+}
+
+// poolTask is a heap-allocated unit of work
+type poolTask struct {
+	id       int
+	intended time.Time // When the task was scheduled to be submitted
+	input    []float64
+	result   []float64
+	done     *sync.WaitGroup
+}
+
+// taskQueueWorkload feeds heap-allocated tasks through a bounded queue to a
+// fixed pool of workers. With -pool-rate set, tasks are submitted on a fixed
+// schedule and latency is measured from the scheduled submission time, so
+// queueing delay caused by GC stalls is counted rather than hidden.
+type taskQueueWorkload struct {
+	Sink
+	queue    chan *poolTask
+	tasks    int
+	size     int
+	interval time.Duration
+	next     time.Time
+	latency  []latencyHistogram // One per pool worker
+}
+
+func newTaskQueueWorkload() (Workload, error) {
+	if *poolWorkers < 1 || *poolQueue < 1 || *poolTasks < 1 || *poolTaskSize < 1 {
+		return nil, fmt.Errorf("-pool-workers, -pool-queue, -pool-tasks and -pool-task-size must be positive")
+	}
+	if *poolRate < 0 {
+		return nil, fmt.Errorf("-pool-rate must not be negative, got %g", *poolRate)
+	}
+
+	w := &taskQueueWorkload{
+		queue:   make(chan *poolTask, *poolQueue),
+		tasks:   *poolTasks,
+		size:    *poolTaskSize,
+		latency: make([]latencyHistogram, *poolWorkers),
+	}
+	if *poolRate > 0 {
+		w.interval = time.Duration(float64(time.Second) / *poolRate)
+	}
+	for n := range w.latency {
+		go w.work(&w.latency[n])
+	}
+
+	return w, nil
+}
+
+// work processes tasks from the queue, recording completion latency
+func (w *taskQueueWorkload) work(latency *latencyHistogram) {
+	for t := range w.queue {
+		t.result = make([]float64, len(t.input))
+		for j, v := range t.input {
+			t.result[j] = math.Sqrt(v) + float64(t.id)
+		}
+		latency.Record(time.Since(t.intended))
+		t.done.Done()
+	}
+}
+
+func (w *taskQueueWorkload) Name() string { return "taskqueue" }
+
+func (w *taskQueueWorkload) Iterate(i int) {
+	var done sync.WaitGroup
+	done.Add(w.tasks)
+
+	if w.next.IsZero() {
+		w.next = time.Now()
+	}
+	for n := 0; n < w.tasks; n++ {
+		intended := time.Now()
+		if w.interval > 0 {
+			if wait := time.Until(w.next); wait > 0 {
+				time.Sleep(wait)
+			}
+			intended = w.next
+			w.next = w.next.Add(w.interval)
+		}
+
+		t := &poolTask{id: i*w.tasks + n, intended: intended, input: make([]float64, w.size), done: &done}
+		for j := range t.input {
+			t.input[j] = float64(j)
+		}
+		w.queue <- t
+		w.Keep(t)
+	}
+
+	done.Wait()
+}
+
+// ResetStats discards latencies recorded during warmup. All tasks of the
+// previous iteration have completed, so the pool is idle. The submission
+// schedule restarts too, so the pause between warmup and measurement isn't
+// counted as backlog.
+func (w *taskQueueWorkload) ResetStats() {
+	w.next = time.Time{}
+	for n := range w.latency {
+		w.latency[n] = latencyHistogram{}
+	}
+}
+
+// Report prints the task completion latency distribution
+func (w *taskQueueWorkload) Report() {
+	var merged latencyHistogram
+	for n := range w.latency {
+		merged.Merge(&w.latency[n])
+	}
+	printMetric("Tasks Completed", "%d", merged.Count())
+	printMetric("Task Latency Mean", "%s", formatDuration(merged.Mean()))
+	printMetric("Task Latency p50", "%s", formatDuration(merged.Percentile(50)))
+	printMetric("Task Latency p90", "%s", formatDuration(merged.Percentile(90)))
+	printMetric("Task Latency p99", "%s", formatDuration(merged.Percentile(99)))
+	printMetric("Task Latency p99.9", "%s", formatDuration(merged.Percentile(99.9)))
+	printMetric("Task Latency Max", "%s", formatDuration(merged.Max()))
+}
+
+// Merge adds other's tasks to w's pool worker by pool worker
+func (w *taskQueueWorkload) Merge(other any) {
+	o := other.(*taskQueueWorkload)
+	for n := range o.latency {
+		w.latency[n].Merge(&o.latency[n])
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"strconv"
+)
+
+var (
+	tmplRequests = flag.Int("tmpl-requests", 2,
+		"template workload: pages rendered per iteration")
+	tmplItems = flag.Int("tmpl-items", 50,
+		"template workload: items listed on each page")
+)
+
+func init() {
+	registerWorkload("template", newTemplateWorkload)
+}
+
+const pageTemplate = `<!DOCTYPE html>
+<html>
+<head><title>{{.Title}}</title></head>
+<body>
+<header>Signed in as {{.User.Name}}{{range .User.Roles}} <span class="role">{{.}}</span>{{end}}</header>
+<ul>
+{{range $i, $item := .Items}}<li id="item-{{$i}}">
+  <a href="/items/{{$item.ID}}?ref={{$.Title}}">{{$item.Name}}</a> {{printf "%.2f" $item.Price}}
+  {{if $item.Tags}}<span class="tags">{{range $item.Tags}}#{{.}} {{end}}</span>{{end}}
+  {{with $item.Reviews}}<ol>{{range .}}<li>{{.Author}}: {{.Text}} ({{.Stars}}/5)</li>{{end}}</ol>{{else}}<em>No reviews</em>{{end}}
+</li>
+{{end}}</ul>
+</body>
+</html>
+`
+
+// tmplPage is the data rendered for one request
+type tmplPage struct {
+	Title string
+	User  tmplUser
+	Items []tmplItem
+}
+
+type tmplUser struct {
+	Name  string
+	Roles []string
+}
+
+type tmplItem struct {
+	ID      int
+	Name    string
+	Price   float64
+	Tags    []string
+	Reviews []tmplReview
+}
+
+type tmplReview struct {
+	Author string
+	Text   string
+	Stars  int
+}
+
+// templateWorkload renders an html/template page from freshly built nested
+// data for every simulated request. Template execution walks the data with
+// reflection and escapes every value, allocating heavily, as in typical
+// server-rendered web apps.
+type templateWorkload struct {
+	Sink
+	tmpl     *template.Template
+	requests int
+	items    int
+	rendered int64
+	bytes    int64
+}
+
+func newTemplateWorkload() (Workload, error) {
+	if *tmplRequests < 1 || *tmplItems < 0 {
+		return nil, fmt.Errorf("-tmpl-requests must be positive and -tmpl-items not negative")
+	}
+
+	tmpl, err := template.New("page").Parse(pageTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &templateWorkload{tmpl: tmpl, requests: *tmplRequests, items: *tmplItems}, nil
+}
+
+func (w *templateWorkload) Name() string { return "template" }
+
+// newPage builds the nested data for request id
+func (w *templateWorkload) newPage(id int) *tmplPage {
+	page := &tmplPage{
+		Title: "Catalog page " + strconv.Itoa(id),
+		User:  tmplUser{Name: "user-" + strconv.Itoa(id%1000), Roles: []string{"viewer", "buyer"}},
+		Items: make([]tmplItem, w.items),
+	}
+	for n := range page.Items {
+		item := tmplItem{
+			ID:    id*w.items + n,
+			Name:  "Item <" + strconv.Itoa(n) + "> & co",
+			Price: float64(n) * 1.25,
+		}
+		if n%2 == 0 {
+			item.Tags = []string{"new", "sale"}
+		}
+		for r := 0; r < n%4; r++ {
+			item.Reviews = append(item.Reviews, tmplReview{
+				Author: "reviewer-" + strconv.Itoa(r),
+				Text:   "Would \"buy\" again",
+				Stars:  r + 2,
+			})
+		}
+		page.Items[n] = item
+	}
+	return page
+}
+
+func (w *templateWorkload) Iterate(i int) {
+	for r := 0; r < w.requests; r++ {
+		var buf bytes.Buffer
+		if err := w.tmpl.Execute(&buf, w.newPage(i*w.requests+r)); err != nil {
+			panic(fmt.Sprintf("template workload: %v", err))
+		}
+		w.rendered++
+		w.bytes += int64(buf.Len())
+		w.Keep(buf.Bytes())
+	}
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *templateWorkload) ResetStats() {
+	w.rendered = 0
+	w.bytes = 0
+}
+
+// Report prints rendering statistics
+func (w *templateWorkload) Report() {
+	printMetric("Pages Rendered", "%d", w.rendered)
+	if w.rendered > 0 {
+		printMetric("Average Page Size", "%.1f KB", float64(w.bytes)/float64(w.rendered)/1024)
+	}
+}
+
+// Merge adds other's pages to w's
+func (w *templateWorkload) Merge(other any) {
+	o := other.(*templateWorkload)
+	w.rendered += o.rendered
+	w.bytes += o.bytes
+}
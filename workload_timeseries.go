@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+var (
+	tsSeries = flag.Int("ts-series", 200000,
+		"timeseries workload: number of series (millions are realistic but need several GB)")
+	tsRing = flag.Int("ts-ring", 16,
+		"timeseries workload: samples held in each series' ring buffer before it is sealed into a chunk")
+	tsSamples = flag.Int("ts-samples", 20000,
+		"timeseries workload: samples appended per iteration")
+)
+
+func init() {
+	registerWorkload("timeseries", newTimeSeriesWorkload)
+}
+
+// tsLabel is one name/value pair identifying a series
+type tsLabel struct {
+	name, value string
+}
+
+// tsSample is a single timestamped value
+type tsSample struct {
+	ts    int64
+	value float64
+}
+
+// tsSeriesState is a series with its labels, its ring of recent samples
+// and the most recently sealed chunk of older samples
+type tsSeriesState struct {
+	labels  []tsLabel
+	samples []tsSample
+	head    int
+	chunk   []byte
+}
+
+// timeSeriesWorkload appends samples round-robin into per-series ring
+// buffers, the heap shape of a Prometheus-like TSDB head: a huge number of
+// small stable objects (series, labels, rings) plus rolling churn as full
+// rings are sealed into freshly allocated chunks that replace the old ones.
+type timeSeriesWorkload struct {
+	Sink
+	series  []*tsSeriesState
+	perIter int
+	cursor  int
+	now     int64
+	sealed  int64
+	merged  int // Series of merged instances
+}
+
+func newTimeSeriesWorkload() (Workload, error) {
+	if *tsSeries < 1 || *tsRing < 1 || *tsSamples < 1 {
+		return nil, fmt.Errorf("-ts-series, -ts-ring and -ts-samples must be positive")
+	}
+
+	w := &timeSeriesWorkload{
+		series:  make([]*tsSeriesState, *tsSeries),
+		perIter: *tsSamples,
+	}
+	for n := range w.series {
+		w.series[n] = &tsSeriesState{
+			labels: []tsLabel{
+				{"__name__", "http_requests_total"},
+				{"instance", "host-" + strconv.Itoa(n%1000)},
+				{"series", strconv.Itoa(n)},
+			},
+			samples: make([]tsSample, *tsRing),
+		}
+	}
+
+	return w, nil
+}
+
+func (w *timeSeriesWorkload) Name() string { return "timeseries" }
+
+// AllocationFree reports true: iterations only allocate when a ring fills
+// and is sealed, which with large -ts-series may not happen during warmup
+func (w *timeSeriesWorkload) AllocationFree() bool { return true }
+
+func (w *timeSeriesWorkload) Iterate(i int) {
+	for n := 0; n < w.perIter; n++ {
+		s := w.series[w.cursor]
+		w.cursor++
+		if w.cursor == len(w.series) {
+			w.cursor = 0
+			w.now += 15000 // Next scrape, 15s later in milliseconds
+		}
+
+		s.samples[s.head] = tsSample{ts: w.now, value: float64(n)}
+		s.head++
+		if s.head == len(s.samples) {
+			s.chunk = sealChunk(s.samples)
+			s.head = 0
+			w.sealed++
+		}
+		w.Keep(s)
+	}
+}
+
+// sealChunk encodes a full ring into a new chunk using delta-encoded
+// timestamps and raw float bits
+func sealChunk(samples []tsSample) []byte {
+	buf := make([]byte, 0, len(samples)*(binary.MaxVarintLen64+8))
+	prev := int64(0)
+	for _, s := range samples {
+		buf = binary.AppendVarint(buf, s.ts-prev)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(s.value))
+		prev = s.ts
+	}
+	return buf
+}
+
+// ResetStats discards counters accumulated during warmup
+func (w *timeSeriesWorkload) ResetStats() {
+	w.sealed = 0
+}
+
+// Report prints series and chunk statistics
+func (w *timeSeriesWorkload) Report() {
+	printMetric("Series", "%d", len(w.series)+w.merged)
+	printMetric("Chunks Sealed", "%d", w.sealed)
+}
+
+// Merge adds other's series and chunks to w's
+func (w *timeSeriesWorkload) Merge(other any) {
+	o := other.(*timeSeriesWorkload)
+	w.merged += len(o.series) + o.merged
+	w.sealed += o.sealed
+}